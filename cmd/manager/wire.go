@@ -24,6 +24,7 @@ import (
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/controller"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/managers"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/managers/runnables"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/reporter"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
 )
 
@@ -31,6 +32,7 @@ func InitializeMarketplaceController() (*managers.ControllerMain, error) {
 	panic(wire.Build(
 		config.ProvideConfig,
 		controller.ControllerSet,
+		reporter.ProvideReportRunnerFactory,
 		controller.ProvideControllerFlagSet,
 		controller.SchemeDefinitions,
 		reconcileutils.CommandRunnerProviderSet,
@@ -17,6 +17,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/operator-framework/operator-sdk/pkg/log/zap"
@@ -53,6 +55,15 @@ func run(cmd *cobra.Command, args []string) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
+
+	// stop serving on termination so the stores save their snapshots
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
 	err = server.Serve(ctx.Done())
 
 	if err != nil {
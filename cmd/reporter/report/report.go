@@ -14,7 +14,7 @@ import (
 
 var log = logf.Log.WithName("reporter_report_cmd")
 
-var name, namespace, cafile, tokenFile, uploadTarget string
+var name, namespace, cafile, tokenFile, uploadTarget, clusterIDSecret, uploadSecret string
 var local, upload bool
 var retry int
 
@@ -43,6 +43,8 @@ var ReportCmd = &cobra.Command{
 			Local:           local,
 			Upload:          upload,
 			UploaderTarget:  reporter.MustParseUploaderTarget(uploadTarget),
+			ClusterIDSecret: clusterIDSecret,
+			UploadSecret:    uploadSecret,
 		}
 		cfg.SetDefaults()
 
@@ -76,6 +78,8 @@ func init() {
 	ReportCmd.Flags().BoolVar(&local, "local", false, "run locally")
 	ReportCmd.Flags().BoolVar(&upload, "upload", true, "to upload the payload")
 	ReportCmd.Flags().IntVar(&retry, "retry", 3, "number of retries")
+	ReportCmd.Flags().StringVar(&clusterIDSecret, "clusterid-secret", "", "secret with the cluster id, instead of the openshift cluster version")
+	ReportCmd.Flags().StringVar(&uploadSecret, "upload-secret", "", "secret with the upload token, instead of the openshift pull secret")
 
 	ReportCmd.Flags().MarkHidden("uploadTarget")
 	ReportCmd.Flags().MarkHidden("local")
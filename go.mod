@@ -17,8 +17,11 @@ require (
 	github.com/fatih/structtag v1.2.0 // indirect
 	github.com/ghodss/yaml v1.0.1-0.20190212211648-25d852aebe32
 	github.com/go-bindata/go-bindata v3.1.2+incompatible
+	github.com/go-kit/kit v0.10.0
 	github.com/go-logr/logr v0.1.0
+	github.com/gogo/protobuf v1.3.1
 	github.com/golang/mock v1.4.3
+	github.com/golang/snappy v0.0.1
 	github.com/golangci/golangci-lint v1.27.0
 	github.com/google/addlicense v0.0.0-20200906110928-a0294312aa76 // indirect
 	github.com/google/go-cmp v0.5.1 // indirect
@@ -48,7 +51,9 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/prometheus/alertmanager v0.21.0 // indirect
 	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.10.0
+	github.com/prometheus/prometheus v2.3.2+incompatible
 	github.com/sasha-s/go-deadlock v0.2.0
 	github.com/sirupsen/logrus v1.6.0 // indirect
 	github.com/spf13/cobra v1.0.0
@@ -23,6 +23,7 @@ import (
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 	"k8s.io/kube-state-metrics/pkg/options"
@@ -39,6 +40,7 @@ type Builder struct {
 	totalShards      int
 	cc               reconcileutils.ClientCommandRunner
 	meterDefStores   meter_definition.MeterDefinitionStores
+	limiter          *SeriesLimiter
 }
 
 // NewBuilder returns a new builder.
@@ -81,6 +83,11 @@ func (b *Builder) WithMeterDefinitionStores(stores meter_definition.MeterDefinit
 	b.meterDefStores = stores
 }
 
+// WithSeriesLimiter sets the limiter shared by the stores of a Builder.
+func (b *Builder) WithSeriesLimiter(limiter *SeriesLimiter) {
+	b.limiter = limiter
+}
+
 func (b *Builder) Build() []*MetricsStore {
 	stores := []*MetricsStore{}
 	activeStoreNames := []string{"pods", "services", "persistentvolumeclaims", "meterdefinitions"}
@@ -127,13 +134,29 @@ func (b *Builder) buildPodStore() *MetricsStore {
 	)
 }
 
+// buildPVCStore builds the store of the persistentvolumeclaims. The
+// bound persistentvolumes are read from an informer started with the
+// context of the builder.
 func (b *Builder) buildPVCStore() *MetricsStore {
-	return b.buildStore(
-		pvcMetricsFamilies,
+	factory := informers.NewSharedInformerFactory(b.kubeClient, 0)
+	volumes := factory.Core().V1().PersistentVolumes()
+
+	families := append(
+		append([]FamilyGenerator{}, pvcMetricsFamilies...),
+		persistentVolumeMetricsFamilies(&persistentVolumeLister{volumes.Lister()})...,
+	)
+
+	store := b.buildStore(
+		families,
 		persistentVolType,
 		&meterDefFetcher{b.cc, b.meterDefStores[meter_definition.PersistentVolumeStore]},
 		b.meterDefStores[meter_definition.PersistentVolumeStore],
 	)
+
+	volumes.Informer().AddEventHandler(persistentVolumeHandler(store))
+	factory.Start(b.ctx.Done())
+
+	return store
 }
 
 func (b *Builder) buildMeterDefinitionStore() *MetricsStore {
@@ -154,13 +177,22 @@ func (b *Builder) buildStore(
 	composedMetricGenFuncs := ComposeMetricGenFuncs(metricFamilies)
 	familyHeaders := ExtractMetricFamilyHeaders(metricFamilies)
 
-	return NewMetricsStore(
+	store := NewMetricsStore(
 		familyHeaders,
 		composedMetricGenFuncs,
 		meterStore,
 		meterDefFetcher,
 		expectedType,
 	)
+	store.sharding = meter_definition.Sharding{
+		Shard:       b.shard,
+		TotalShards: b.totalShards,
+	}
+	store.limiter = b.limiter
+	store.openMetricsHeaders = ExtractOpenMetricsFamilyHeaders(metricFamilies)
+	store.familyTypes = ExtractFamilyTypes(metricFamilies)
+
+	return store
 }
 
 func ComposeMetricGenFuncs(familyGens []FamilyGenerator) func(interface{}, []*marketplacev1alpha1.MeterDefinition) []FamilyByteSlicer {
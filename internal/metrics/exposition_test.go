@@ -0,0 +1,195 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// expectGolden compares out with the golden file, or rewrites the file
+// when the tests run with -update.
+func expectGolden(name string, out []byte) {
+	path := filepath.Join("testdata", name)
+
+	if *updateGolden {
+		Expect(ioutil.WriteFile(path, out, 0644)).To(Succeed())
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	Expect(err).To(Succeed())
+	Expect(string(out)).To(Equal(string(golden)))
+}
+
+var _ = Describe("exposition", func() {
+	var (
+		stores []*MetricsStore
+	)
+
+	BeforeEach(func() {
+		created := metav1.NewTime(time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC))
+		class := "gp2"
+
+		mdefs := fakeMeterDefFetcher{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "mdef-a", Namespace: "ns", UID: "mdef-uid-a"},
+				Spec:       marketplacev1alpha1.MeterDefinitionSpec{Group: "app.com", Kind: "App"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "mdef-b", Namespace: "ns", UID: "mdef-uid-b"},
+				Spec:       marketplacev1alpha1.MeterDefinitionSpec{Group: "app.com", Kind: "Other"},
+			},
+		}
+		volumes := fakePersistentVolumeFetcher{
+			"pv-1": &corev1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: "pv-1"},
+				Spec: corev1.PersistentVolumeSpec{
+					Capacity: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("2Gi"),
+					},
+					StorageClassName: class,
+				},
+			},
+		}
+
+		b := &Builder{}
+		podStore := b.buildStore(podMetricsFamilies, podType, mdefs, nil)
+		pvcStore := b.buildStore(
+			append(append([]FamilyGenerator{}, pvcMetricsFamilies...), persistentVolumeMetricsFamilies(volumes)...),
+			persistentVolType, mdefs, nil)
+		serviceStore := b.buildStore(serviceMetricsFamilies, serviceType, mdefs, nil)
+		meterDefStore := b.buildStore(meterDefinitionMetricsFamilies, meterDefinitionType, emptyFetcher, nil)
+
+		Expect(podStore.Add(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "pod-1",
+				Namespace:         "ns",
+				UID:               "pod-uid-1",
+				CreationTimestamp: created,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "app",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("250m"),
+								corev1.ResourceMemory: resource.MustParse("64Mi"),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("1"),
+								corev1.ResourceMemory: resource.MustParse("128Mi"),
+							},
+						},
+					},
+				},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "app", RestartCount: 2},
+				},
+			},
+		})).To(Succeed())
+
+		Expect(pvcStore.Add(&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "data",
+				Namespace:         "ns",
+				UID:               "pvc-uid-1",
+				CreationTimestamp: created,
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("1Gi"),
+					},
+				},
+				StorageClassName: &class,
+				VolumeName:       "pv-1",
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase: corev1.ClaimBound,
+				Capacity: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("2Gi"),
+				},
+			},
+		})).To(Succeed())
+
+		Expect(serviceStore.Add(&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "svc",
+				Namespace:         "ns",
+				UID:               "svc-uid-1",
+				CreationTimestamp: created,
+			},
+			Spec: corev1.ServiceSpec{ClusterIP: "10.0.0.1"},
+		})).To(Succeed())
+
+		Expect(meterDefStore.Add(&marketplacev1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "mdef-a",
+				Namespace:         "ns",
+				UID:               "mdef-uid-a",
+				CreationTimestamp: created,
+			},
+			Spec: marketplacev1alpha1.MeterDefinitionSpec{
+				Group:              "app.com",
+				Kind:               "App",
+				WorkloadVertexType: marketplacev1alpha1.WorkloadVertexOperatorGroup,
+				Workloads: []marketplacev1alpha1.Workload{
+					{
+						Name:         "pods",
+						WorkloadType: marketplacev1alpha1.WorkloadTypePod,
+						MetricLabels: []marketplacev1alpha1.MeterLabelQuery{
+							{Label: "cpu_request_cores", Aggregation: "sum"},
+						},
+					},
+				},
+			},
+		})).To(Succeed())
+
+		stores = []*MetricsStore{podStore, pvcStore, serviceStore, meterDefStore}
+	})
+
+	It("should write the text format", func() {
+		out := &bytes.Buffer{}
+		for _, s := range stores {
+			s.WriteAll(out)
+		}
+
+		expectGolden("text.golden", out.Bytes())
+	})
+
+	It("should write the OpenMetrics format", func() {
+		out := &bytes.Buffer{}
+		WriteOpenMetrics(out, stores)
+
+		expectGolden("openmetrics.golden", out.Bytes())
+	})
+})
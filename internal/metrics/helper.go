@@ -32,6 +32,8 @@ var log = logf.Log.WithName("meteric")
 type FamilyGenerator struct {
 	GenerateMeterFunc func(interface{}, []*marketplacev1alpha1.MeterDefinition) *kbsm.Family
 	kbsm.FamilyGenerator
+	// Unit is exposed in the OpenMetrics format, the name must end with it
+	Unit string
 }
 
 func (g *FamilyGenerator) generateHeader() string {
@@ -70,16 +72,23 @@ func MapMeterDefinitions(metrics []*kbsm.Metric, mdefs []*marketplacev1alpha1.Me
 		return metrics
 	}
 
-	newMeters := make([]*kbsm.Metric, 0, len(mdefs))
+	newMeters := make([]*kbsm.Metric, 0, len(metrics)*len(mdefs))
 
 	for _, m := range metrics {
 		for _, mdef := range mdefs {
 			mdefLabelKeys, mdefLabelValues := GetMeterDefLabelsKeys(mdef)
 
+			// copy the labels so metrics of different meterdefs don't
+			// share a backing array
+			labelKeys := make([]string, 0, len(m.LabelKeys)+len(mdefLabelKeys))
+			labelKeys = append(append(labelKeys, m.LabelKeys...), mdefLabelKeys...)
+			labelValues := make([]string, 0, len(m.LabelValues)+len(mdefLabelValues))
+			labelValues = append(append(labelValues, m.LabelValues...), mdefLabelValues...)
+
 			newMeters = append(newMeters, &kbsm.Metric{
 				Value:       m.Value,
-				LabelKeys:   append(m.LabelKeys, mdefLabelKeys...),
-				LabelValues: append(m.LabelValues, mdefLabelValues...),
+				LabelKeys:   labelKeys,
+				LabelValues: labelValues,
 			})
 		}
 	}
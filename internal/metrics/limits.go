@@ -0,0 +1,515 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/prometheus/client_golang/prometheus"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	"github.com/sasha-s/go-deadlock"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	kbsm "k8s.io/kube-state-metrics/pkg/metric"
+)
+
+const (
+	// SeriesLimitAnnotation overrides the series limit of a meter definition.
+	SeriesLimitAnnotation = "marketplace.redhat.com/series-limit"
+
+	// SeriesLimitedShardsAnnotation lists the metric-state shards that drop
+	// series of a meter definition when metric-state is sharded.
+	SeriesLimitedShardsAnnotation = "marketplace.redhat.com/series-limited-shards"
+
+	DefaultMeterDefinitionSeriesLimit = 50000
+	DefaultGlobalSeriesLimit          = 500000
+
+	seriesLimitReasonMeterDef = "meterdef_limit"
+	seriesLimitReasonGlobal   = "global_limit"
+)
+
+var (
+	meterDefSeriesDesc = prometheus.NewDesc(
+		"meterdef_series",
+		"Number of series labeled with the meter definition",
+		[]string{"meter_def_name", "meter_def_namespace"}, nil,
+	)
+	globalSeriesDesc = prometheus.NewDesc(
+		"meterdef_global_series",
+		"Number of meter definition labeled series across all meter definitions",
+		nil, nil,
+	)
+)
+
+// SeriesLimiter keeps the count of series generated for each meter
+// definition and drops the series of objects that would take a meter
+// definition over its limit, or metric-state over the global limit.
+// Objects that were admitted keep their series, so one meter definition
+// with a broad selector only loses its own new series.
+//
+// When metric-state is sharded each replica limits its own objects and
+// writes the condition of the meter definitions it drops series for. The
+// shards that drop series are listed in an annotation so the condition is
+// only cleared once none of them drops series anymore.
+type SeriesLimiter struct {
+	mutex deadlock.Mutex
+
+	sharding meter_definition.Sharding
+
+	meterDefLimit int
+	globalLimit   int
+
+	total  int
+	counts map[types.NamespacedName]int
+
+	// admitted is the series count of each object per meter definition
+	admitted map[types.UID]map[types.NamespacedName]int
+	// dropped is the reason the objects of a meter definition were dropped
+	dropped map[types.NamespacedName]map[types.UID]string
+	// changed are the meter definitions whose condition needs an update
+	changed map[types.NamespacedName]bool
+
+	droppedSeries *prometheus.CounterVec
+}
+
+// NewSeriesLimiter returns a limiter, a limit of 0 disables it.
+func NewSeriesLimiter(meterDefLimit, globalLimit int) *SeriesLimiter {
+	return &SeriesLimiter{
+		sharding:      meter_definition.NoSharding,
+		meterDefLimit: meterDefLimit,
+		globalLimit:   globalLimit,
+		counts:        make(map[types.NamespacedName]int),
+		admitted:      make(map[types.UID]map[types.NamespacedName]int),
+		dropped:       make(map[types.NamespacedName]map[types.UID]string),
+		changed:       make(map[types.NamespacedName]bool),
+		droppedSeries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "meterdef_series_dropped_total",
+			Help: "Number of series dropped because a series limit was exceeded",
+		}, []string{"meter_def_name", "meter_def_namespace", "reason"}),
+	}
+}
+
+// Admit returns the meter definitions the object can generate series
+// for. series is the number of series the object generates for each meter
+// definition.
+func (l *SeriesLimiter) Admit(
+	uid types.UID,
+	mdefs []*marketplacev1alpha1.MeterDefinition,
+	series int,
+) []*marketplacev1alpha1.MeterDefinition {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	previous := l.release(uid)
+
+	admitted := make([]*marketplacev1alpha1.MeterDefinition, 0, len(mdefs))
+
+	for _, mdef := range mdefs {
+		key := types.NamespacedName{Name: mdef.Name, Namespace: mdef.Namespace}
+		limit := l.limitFor(mdef)
+
+		reason := ""
+		switch {
+		case limit > 0 && l.counts[key]+series > limit:
+			reason = seriesLimitReasonMeterDef
+		case l.globalLimit > 0 && l.total+series > l.globalLimit:
+			reason = seriesLimitReasonGlobal
+		}
+
+		if reason != "" {
+			// an object that was already dropped, on resync or when it's
+			// updated, isn't counted again
+			if _, ok := previous[key]; !ok {
+				l.droppedSeries.WithLabelValues(key.Name, key.Namespace, reason).Add(float64(series))
+			}
+
+			if _, ok := l.dropped[key]; !ok {
+				l.dropped[key] = make(map[types.UID]string)
+			}
+
+			if previous[key] != reason {
+				l.changed[key] = true
+			}
+
+			l.dropped[key][uid] = reason
+			delete(previous, key)
+			continue
+		}
+
+		if _, ok := l.admitted[uid]; !ok {
+			l.admitted[uid] = make(map[types.NamespacedName]int)
+		}
+
+		l.admitted[uid][key] = series
+		l.counts[key] = l.counts[key] + series
+		l.total = l.total + series
+		admitted = append(admitted, mdef)
+	}
+
+	// the meter definitions that no longer drop the object
+	for key := range previous {
+		l.changed[key] = true
+	}
+
+	return admitted
+}
+
+// Release removes the series of the object from the counts.
+func (l *SeriesLimiter) Release(uid types.UID) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key := range l.release(uid) {
+		l.changed[key] = true
+	}
+}
+
+// SetSharding sets the shard of this replica.
+func (l *SeriesLimiter) SetSharding(sharding meter_definition.Sharding) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.sharding = sharding
+}
+
+// release removes the object from the counts and returns the reasons it
+// was dropped by each meter definition.
+func (l *SeriesLimiter) release(uid types.UID) map[types.NamespacedName]string {
+	for key, series := range l.admitted[uid] {
+		l.counts[key] = l.counts[key] - series
+		l.total = l.total - series
+
+		if l.counts[key] <= 0 {
+			delete(l.counts, key)
+		}
+	}
+
+	delete(l.admitted, uid)
+
+	previous := make(map[types.NamespacedName]string)
+
+	for key, objs := range l.dropped {
+		reason, ok := objs[uid]
+		if !ok {
+			continue
+		}
+
+		previous[key] = reason
+		delete(objs, uid)
+
+		if len(objs) == 0 {
+			delete(l.dropped, key)
+		}
+	}
+
+	return previous
+}
+
+func (l *SeriesLimiter) limitFor(mdef *marketplacev1alpha1.MeterDefinition) int {
+	if value, ok := mdef.Annotations[SeriesLimitAnnotation]; ok {
+		if limit, err := strconv.Atoi(value); err == nil && limit >= 0 {
+			return limit
+		}
+	}
+
+	return l.meterDefLimit
+}
+
+// condition returns the series limit condition of the meter definition.
+func (l *SeriesLimiter) condition(key types.NamespacedName) status.Condition {
+	objs, ok := l.dropped[key]
+	if !ok {
+		return marketplacev1alpha1.MeterDefConditionWithinSeriesLimits
+	}
+
+	cond := marketplacev1alpha1.MeterDefConditionGlobalSeriesLimitExceeded
+	for _, reason := range objs {
+		if reason == seriesLimitReasonMeterDef {
+			cond = marketplacev1alpha1.MeterDefConditionMeterDefSeriesLimitExceeded
+			break
+		}
+	}
+
+	if l.sharding.Enabled() {
+		cond.Message = fmt.Sprintf("%s Shard %d dropped %d objects, %d series are kept.", cond.Message, l.sharding.Shard, len(objs), l.counts[key])
+		return cond
+	}
+
+	cond.Message = fmt.Sprintf("%s %d objects were dropped, %d series are kept.", cond.Message, len(objs), l.counts[key])
+	return cond
+}
+
+// Start updates the series limit condition of the meter definitions that
+// changed on every interval.
+func (l *SeriesLimiter) Start(ctx context.Context, cc ClientCommandRunner, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	if l.sharding.Enabled() {
+		l.checkLimitedShard(ctx, cc)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.updateConditions(ctx, cc)
+		}
+	}
+}
+
+// checkLimitedShard marks the meter definitions this shard dropped series
+// for before it restarted as changed, their condition is cleared if the
+// shard no longer drops their series.
+func (l *SeriesLimiter) checkLimitedShard(ctx context.Context, cc ClientCommandRunner) {
+	list := &marketplacev1alpha1.MeterDefinitionList{}
+
+	result, _ := cc.Do(ctx, ListAction(list))
+	if result.Is(Error) {
+		log.Error(result.GetError(), "failed to list meter definitions")
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i := range list.Items {
+		mdef := &list.Items[i]
+
+		if seriesLimitedShards(mdef, l.sharding)[l.sharding.Shard] {
+			l.changed[types.NamespacedName{Name: mdef.Name, Namespace: mdef.Namespace}] = true
+		}
+	}
+}
+
+func (l *SeriesLimiter) updateConditions(ctx context.Context, cc ClientCommandRunner) {
+	l.mutex.Lock()
+	sharding := l.sharding
+
+	conditions := make(map[types.NamespacedName]status.Condition, len(l.changed))
+	for key := range l.changed {
+		conditions[key] = l.condition(key)
+	}
+	l.changed = make(map[types.NamespacedName]bool)
+	l.mutex.Unlock()
+
+	for key, cond := range conditions {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if sharding.Enabled() {
+				limited, err := setSeriesLimitedShard(ctx, cc, key, sharding, cond.IsTrue())
+				if err != nil {
+					return err
+				}
+
+				// the condition keeps reporting the drops of the other shards
+				if !cond.IsTrue() && limited {
+					return nil
+				}
+			}
+
+			return setMeterDefinitionCondition(ctx, cc, key, cond)
+		})
+
+		if err != nil {
+			log.Error(err, "failed to update series limit condition", "mdef", key)
+
+			l.mutex.Lock()
+			l.changed[key] = true
+			l.mutex.Unlock()
+		}
+	}
+}
+
+// setMeterDefinitionCondition sets the condition on the meter definition.
+// A condition that would report it's within limits is only set if the
+// meter definition was limited before.
+func setMeterDefinitionCondition(
+	ctx context.Context,
+	cc ClientCommandRunner,
+	key types.NamespacedName,
+	cond status.Condition,
+) error {
+	mdef := &marketplacev1alpha1.MeterDefinition{}
+
+	result, _ := cc.Do(ctx,
+		HandleResult(
+			GetAction(key, mdef),
+			OnContinue(Call(func() (ClientAction, error) {
+				existing := mdef.Status.Conditions.GetCondition(cond.Type)
+
+				if existing == nil && !cond.IsTrue() {
+					return nil, nil
+				}
+
+				if !mdef.Status.Conditions.SetCondition(cond) {
+					return nil, nil
+				}
+
+				return UpdateAction(mdef, UpdateStatusOnly(true)), nil
+			})),
+		),
+	)
+
+	if result.Is(NotFound) {
+		return nil
+	}
+
+	if result.Is(Error) {
+		return errors.Cause(result.GetError())
+	}
+
+	return nil
+}
+
+// setSeriesLimitedShard adds the shard to the series limited shards of the
+// meter definition, or removes it, and returns true if any shard still
+// drops series of the meter definition.
+func setSeriesLimitedShard(
+	ctx context.Context,
+	cc ClientCommandRunner,
+	key types.NamespacedName,
+	sharding meter_definition.Sharding,
+	limited bool,
+) (bool, error) {
+	mdef := &marketplacev1alpha1.MeterDefinition{}
+	shards := map[int32]bool{}
+
+	result, _ := cc.Do(ctx,
+		HandleResult(
+			GetAction(key, mdef),
+			OnContinue(Call(func() (ClientAction, error) {
+				shards = seriesLimitedShards(mdef, sharding)
+
+				if shards[sharding.Shard] == limited {
+					return nil, nil
+				}
+
+				if limited {
+					shards[sharding.Shard] = true
+				} else {
+					delete(shards, sharding.Shard)
+				}
+
+				setSeriesLimitedShards(mdef, shards)
+				return UpdateAction(mdef), nil
+			})),
+		),
+	)
+
+	if result.Is(NotFound) {
+		return false, nil
+	}
+
+	if result.Is(Error) {
+		return false, errors.Cause(result.GetError())
+	}
+
+	return len(shards) > 0, nil
+}
+
+// seriesLimitedShards returns the shards listed in the annotation of the
+// meter definition. Shards that no longer exist are left out.
+func seriesLimitedShards(
+	mdef *marketplacev1alpha1.MeterDefinition,
+	sharding meter_definition.Sharding,
+) map[int32]bool {
+	shards := map[int32]bool{}
+
+	value, ok := mdef.Annotations[SeriesLimitedShardsAnnotation]
+	if !ok {
+		return shards
+	}
+
+	for _, field := range strings.Split(value, ",") {
+		shard, err := strconv.ParseInt(strings.TrimSpace(field), 10, 32)
+		if err != nil || shard < 0 || int(shard) >= sharding.TotalShards {
+			continue
+		}
+
+		shards[int32(shard)] = true
+	}
+
+	return shards
+}
+
+func setSeriesLimitedShards(mdef *marketplacev1alpha1.MeterDefinition, shards map[int32]bool) {
+	if len(shards) == 0 {
+		delete(mdef.Annotations, SeriesLimitedShardsAnnotation)
+		return
+	}
+
+	values := make([]int, 0, len(shards))
+	for shard := range shards {
+		values = append(values, int(shard))
+	}
+	sort.Ints(values)
+
+	fields := make([]string, len(values))
+	for i, shard := range values {
+		fields[i] = strconv.Itoa(shard)
+	}
+
+	if mdef.Annotations == nil {
+		mdef.Annotations = make(map[string]string)
+	}
+
+	mdef.Annotations[SeriesLimitedShardsAnnotation] = strings.Join(fields, ",")
+}
+
+// Describe implements the prometheus.Collector interface.
+func (l *SeriesLimiter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- meterDefSeriesDesc
+	ch <- globalSeriesDesc
+	l.droppedSeries.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (l *SeriesLimiter) Collect(ch chan<- prometheus.Metric) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key, series := range l.counts {
+		ch <- prometheus.MustNewConstMetric(meterDefSeriesDesc, prometheus.GaugeValue, float64(series), key.Name, key.Namespace)
+	}
+
+	ch <- prometheus.MustNewConstMetric(globalSeriesDesc, prometheus.GaugeValue, float64(l.total))
+	l.droppedSeries.Collect(ch)
+}
+
+// seriesCount is the number of series in the families.
+func seriesCount(families []FamilyByteSlicer) int {
+	count := 0
+
+	for _, f := range families {
+		if family, ok := f.(*kbsm.Family); ok {
+			count = count + len(family.Metrics)
+		}
+	}
+
+	return count
+}
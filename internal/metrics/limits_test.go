@@ -0,0 +1,269 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type fakeMeterDefFetcher []*marketplacev1alpha1.MeterDefinition
+
+func (f fakeMeterDefFetcher) GetMeterDefinitions(obj interface{}) ([]*marketplacev1alpha1.MeterDefinition, error) {
+	return f, nil
+}
+
+var _ = Describe("SeriesLimiter", func() {
+	var (
+		limiter      *SeriesLimiter
+		mdefA, mdefB *marketplacev1alpha1.MeterDefinition
+	)
+
+	newPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "ns",
+				UID:       types.UID(name),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		limiter = NewSeriesLimiter(2, 3)
+		mdefA = &marketplacev1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "mdef-a", Namespace: "ns"},
+		}
+		mdefB = &marketplacev1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "mdef-b", Namespace: "ns"},
+		}
+	})
+
+	It("should drop series over the meter definition limit", func() {
+		mdefs := []*marketplacev1alpha1.MeterDefinition{mdefA}
+
+		Expect(limiter.Admit("1", mdefs, 1)).To(HaveLen(1))
+		Expect(limiter.Admit("2", mdefs, 1)).To(HaveLen(1))
+		Expect(limiter.Admit("3", mdefs, 1)).To(BeEmpty())
+		Expect(testutil.ToFloat64(limiter.droppedSeries.WithLabelValues("mdef-a", "ns", seriesLimitReasonMeterDef))).To(Equal(1.0))
+
+		By("re-adding a dropped object without counting its series again")
+		Expect(limiter.Admit("3", mdefs, 1)).To(BeEmpty())
+		Expect(testutil.ToFloat64(limiter.droppedSeries.WithLabelValues("mdef-a", "ns", seriesLimitReasonMeterDef))).To(Equal(1.0))
+
+		By("re-admitting an object without counting it twice")
+		Expect(limiter.Admit("1", mdefs, 1)).To(HaveLen(1))
+
+		cond := limiter.condition(types.NamespacedName{Name: "mdef-a", Namespace: "ns"})
+		Expect(cond.Reason).To(Equal(marketplacev1alpha1.MeterDefConditionReasonMeterDefSeriesLimit))
+
+		By("releasing an object")
+		limiter.Release("2")
+		limiter.Release("3")
+		Expect(limiter.Admit("3", mdefs, 1)).To(HaveLen(1))
+
+		cond = limiter.condition(types.NamespacedName{Name: "mdef-a", Namespace: "ns"})
+		Expect(cond).To(Equal(marketplacev1alpha1.MeterDefConditionWithinSeriesLimits))
+	})
+
+	It("should use the annotation limit", func() {
+		mdefA.Annotations = map[string]string{SeriesLimitAnnotation: "0"}
+		mdefs := []*marketplacev1alpha1.MeterDefinition{mdefA}
+
+		Expect(limiter.Admit("1", mdefs, 2)).To(HaveLen(1))
+		Expect(limiter.Admit("2", mdefs, 1)).To(HaveLen(1))
+		Expect(limiter.Admit("3", mdefs, 1)).To(BeEmpty())
+
+		cond := limiter.condition(types.NamespacedName{Name: "mdef-a", Namespace: "ns"})
+		Expect(cond.Reason).To(Equal(marketplacev1alpha1.MeterDefConditionReasonGlobalSeriesLimit))
+	})
+
+	It("should only drop the meter definition over its limit", func() {
+		mdefA.Annotations = map[string]string{SeriesLimitAnnotation: "1"}
+		limiter = NewSeriesLimiter(10, 0)
+
+		store := NewMetricsStore(
+			ExtractMetricFamilyHeaders(podMetricsFamilies),
+			ComposeMetricGenFuncs(podMetricsFamilies),
+			nil,
+			fakeMeterDefFetcher{mdefA, mdefB},
+			podType,
+		)
+		store.limiter = limiter
+
+		Expect(store.Add(newPod("pod-1"))).To(Succeed())
+		Expect(store.Add(newPod("pod-2"))).To(Succeed())
+
+		out := &bytes.Buffer{}
+		store.WriteAll(out)
+
+		Expect(out.String()).To(ContainSubstring(`pod="pod-1",pod_uid="pod-1",priority_class="",meter_def_name="mdef-a"`))
+		Expect(out.String()).To(ContainSubstring(`pod="pod-1",pod_uid="pod-1",priority_class="",meter_def_name="mdef-b"`))
+		Expect(out.String()).To(ContainSubstring(`pod="pod-2",pod_uid="pod-2",priority_class="",meter_def_name="mdef-b"`))
+		Expect(out.String()).ToNot(ContainSubstring(`pod="pod-2",pod_uid="pod-2",priority_class="",meter_def_name="mdef-a"`))
+
+		By("re-admitting after a delete")
+		Expect(store.Delete(newPod("pod-1"))).To(Succeed())
+		Expect(store.Add(newPod("pod-2"))).To(Succeed())
+
+		out.Reset()
+		store.WriteAll(out)
+		Expect(out.String()).To(ContainSubstring(`pod="pod-2",pod_uid="pod-2",priority_class="",meter_def_name="mdef-a"`))
+	})
+
+	It("should set the condition on the meter definition", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(marketplacev1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+
+		k8sClient := fake.NewFakeClientWithScheme(scheme, mdefA.DeepCopy(), mdefB.DeepCopy())
+		cc := reconcileutils.NewClientCommand(k8sClient, scheme, logf.Log)
+		ctx := context.TODO()
+		mdefs := []*marketplacev1alpha1.MeterDefinition{mdefA, mdefB}
+
+		limiter = NewSeriesLimiter(1, 0)
+		limiter.Admit("1", mdefs, 1)
+		limiter.Admit("2", mdefs[:1], 1)
+		limiter.updateConditions(ctx, cc)
+
+		mdef := &marketplacev1alpha1.MeterDefinition{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "mdef-a", Namespace: "ns"}, mdef)).To(Succeed())
+		Expect(mdef.Status.Conditions.IsTrueFor(marketplacev1alpha1.MeterDefConditionTypeSeriesLimited)).To(BeTrue())
+
+		mdef = &marketplacev1alpha1.MeterDefinition{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "mdef-b", Namespace: "ns"}, mdef)).To(Succeed())
+		Expect(mdef.Status.Conditions.GetCondition(marketplacev1alpha1.MeterDefConditionTypeSeriesLimited)).To(BeNil())
+
+		limiter.Release("2")
+		limiter.updateConditions(ctx, cc)
+
+		mdef = &marketplacev1alpha1.MeterDefinition{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "mdef-a", Namespace: "ns"}, mdef)).To(Succeed())
+		Expect(mdef.Status.Conditions.IsFalseFor(marketplacev1alpha1.MeterDefConditionTypeSeriesLimited)).To(BeTrue())
+	})
+
+	It("should set the condition from every shard", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(marketplacev1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+
+		k8sClient := fake.NewFakeClientWithScheme(scheme, mdefA.DeepCopy())
+		cc := reconcileutils.NewClientCommand(k8sClient, scheme, logf.Log)
+		ctx := context.TODO()
+		mdefs := []*marketplacev1alpha1.MeterDefinition{mdefA}
+
+		getMeterDef := func() *marketplacev1alpha1.MeterDefinition {
+			mdef := &marketplacev1alpha1.MeterDefinition{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "mdef-a", Namespace: "ns"}, mdef)).To(Succeed())
+			return mdef
+		}
+
+		shard0 := NewSeriesLimiter(1, 0)
+		shard0.SetSharding(meter_definition.Sharding{Shard: 0, TotalShards: 2})
+		shard1 := NewSeriesLimiter(1, 0)
+		shard1.SetSharding(meter_definition.Sharding{Shard: 1, TotalShards: 2})
+
+		By("dropping series on shard 1")
+		shard1.Admit("1", mdefs, 1)
+		shard1.Admit("2", mdefs, 1)
+		shard1.updateConditions(ctx, cc)
+
+		mdef := getMeterDef()
+		Expect(mdef.Status.Conditions.IsTrueFor(marketplacev1alpha1.MeterDefConditionTypeSeriesLimited)).To(BeTrue())
+		Expect(mdef.Annotations).To(HaveKeyWithValue(SeriesLimitedShardsAnnotation, "1"))
+
+		By("dropping series on shard 0")
+		shard0.Admit("3", mdefs, 1)
+		shard0.Admit("4", mdefs, 1)
+		shard0.updateConditions(ctx, cc)
+		Expect(getMeterDef().Annotations).To(HaveKeyWithValue(SeriesLimitedShardsAnnotation, "0,1"))
+
+		By("keeping the condition while shard 0 drops series")
+		shard1.Release("2")
+		shard1.updateConditions(ctx, cc)
+
+		mdef = getMeterDef()
+		Expect(mdef.Status.Conditions.IsTrueFor(marketplacev1alpha1.MeterDefConditionTypeSeriesLimited)).To(BeTrue())
+		Expect(mdef.Annotations).To(HaveKeyWithValue(SeriesLimitedShardsAnnotation, "0"))
+
+		By("clearing the condition when no shard drops series")
+		shard0.Release("4")
+		shard0.updateConditions(ctx, cc)
+
+		mdef = getMeterDef()
+		Expect(mdef.Status.Conditions.IsFalseFor(marketplacev1alpha1.MeterDefConditionTypeSeriesLimited)).To(BeTrue())
+		Expect(mdef.Annotations).ToNot(HaveKey(SeriesLimitedShardsAnnotation))
+	})
+
+	It("should clear the condition of a restarted shard", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(marketplacev1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+
+		mdefA.Annotations = map[string]string{SeriesLimitedShardsAnnotation: "1,3"}
+		mdefA.Status.Conditions.SetCondition(marketplacev1alpha1.MeterDefConditionMeterDefSeriesLimitExceeded)
+
+		k8sClient := fake.NewFakeClientWithScheme(scheme, mdefA.DeepCopy())
+		cc := reconcileutils.NewClientCommand(k8sClient, scheme, logf.Log)
+		ctx := context.TODO()
+
+		limiter.SetSharding(meter_definition.Sharding{Shard: 1, TotalShards: 2})
+		limiter.checkLimitedShard(ctx, cc)
+		limiter.updateConditions(ctx, cc)
+
+		mdef := &marketplacev1alpha1.MeterDefinition{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "mdef-a", Namespace: "ns"}, mdef)).To(Succeed())
+		Expect(mdef.Status.Conditions.IsFalseFor(marketplacev1alpha1.MeterDefConditionTypeSeriesLimited)).To(BeTrue())
+		Expect(mdef.Annotations).ToNot(HaveKey(SeriesLimitedShardsAnnotation))
+	})
+
+	It("should release dropped objects on replace", func() {
+		limiter = NewSeriesLimiter(1, 0)
+
+		store := NewMetricsStore(
+			ExtractMetricFamilyHeaders(podMetricsFamilies),
+			ComposeMetricGenFuncs(podMetricsFamilies),
+			nil,
+			fakeMeterDefFetcher{mdefA},
+			podType,
+		)
+		store.limiter = limiter
+
+		Expect(store.Add(newPod("pod-1"))).To(Succeed())
+		Expect(store.Add(newPod("pod-2"))).To(Succeed())
+		Expect(limiter.dropped).To(HaveLen(1))
+
+		Expect(store.Replace([]interface{}{}, "")).To(Succeed())
+		Expect(limiter.dropped).To(BeEmpty())
+		Expect(limiter.counts).To(BeEmpty())
+		Expect(limiter.condition(types.NamespacedName{Name: "mdef-a", Namespace: "ns"})).To(
+			Equal(marketplacev1alpha1.MeterDefConditionWithinSeriesLimits))
+	})
+})
@@ -15,6 +15,8 @@
 package metrics
 
 import (
+	"sort"
+
 	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
 	kbsm "k8s.io/kube-state-metrics/pkg/metric"
 )
@@ -46,12 +48,15 @@ var meterDefinitionMetricsFamilies = []FamilyGenerator{
 						"metric_query":         metricLabel.Query,
 					}
 
-					keys := []string{}
-					values := []string{}
-
-					for key, value := range labels {
+					keys := make([]string, 0, len(labels))
+					for key := range labels {
 						keys = append(keys, key)
-						values = append(values, value)
+					}
+					sort.Strings(keys)
+
+					values := make([]string, 0, len(labels))
+					for _, key := range keys {
+						values = append(values, labels[key])
 					}
 
 					metrics = append(metrics, &kbsm.Metric{
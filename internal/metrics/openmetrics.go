@@ -0,0 +1,204 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kbsm "k8s.io/kube-state-metrics/pkg/metric"
+)
+
+// openMetricsEOF terminates an OpenMetrics exposition.
+const openMetricsEOF = "# EOF\n"
+
+var openMetricsEscaper = strings.NewReplacer("\\", `\\`, "\n", `\n`, "\"", `\"`)
+
+// openMetricsFamilyName is the name of the family in the OpenMetrics
+// format, where the _total suffix belongs to the counter samples.
+func openMetricsFamilyName(name string, metricType kbsm.Type) string {
+	if metricType == kbsm.Counter {
+		return strings.TrimSuffix(name, "_total")
+	}
+
+	return name
+}
+
+func (g *FamilyGenerator) generateOpenMetricsHeader() string {
+	name := openMetricsFamilyName(g.Name, g.Type)
+
+	header := strings.Builder{}
+	header.WriteString("# HELP ")
+	header.WriteString(name)
+	header.WriteByte(' ')
+	header.WriteString(g.Help)
+	header.WriteString("\n# TYPE ")
+	header.WriteString(name)
+	header.WriteByte(' ')
+	header.WriteString(string(g.Type))
+
+	if g.Unit != "" {
+		header.WriteString("\n# UNIT ")
+		header.WriteString(name)
+		header.WriteByte(' ')
+		header.WriteString(g.Unit)
+	}
+
+	return header.String()
+}
+
+// ExtractOpenMetricsFamilyHeaders returns the OpenMetrics headers of the
+// families, which include the unit.
+func ExtractOpenMetricsFamilyHeaders(families []FamilyGenerator) []string {
+	headers := make([]string, len(families))
+
+	for i, f := range families {
+		headers[i] = f.generateOpenMetricsHeader()
+	}
+
+	return headers
+}
+
+// ExtractFamilyTypes returns the metric type of each family.
+func ExtractFamilyTypes(families []FamilyGenerator) []kbsm.Type {
+	familyTypes := make([]kbsm.Type, len(families))
+
+	for i, f := range families {
+		familyTypes[i] = f.Type
+	}
+
+	return familyTypes
+}
+
+// openMetricsByteSlices renders the families in the OpenMetrics format.
+// OpenMetrics only allows exemplars on counters and histogram buckets, so
+// the series of counter families get an exemplar linking them to the uid
+// of their meter definition and the uid of the workload resource, and a
+// _created series with the creation time of the resource. Gauges are
+// written as they are. familyTypes is the type of each family, families
+// without a type are gauges.
+func openMetricsByteSlices(
+	families []FamilyByteSlicer,
+	familyTypes []kbsm.Type,
+	obj metav1.Object,
+	mdefs []*marketplacev1alpha1.MeterDefinition,
+) [][]byte {
+	uids := make(map[types.NamespacedName]types.UID, len(mdefs))
+	for _, mdef := range mdefs {
+		uids[types.NamespacedName{Name: mdef.Name, Namespace: mdef.Namespace}] = mdef.UID
+	}
+
+	out := make([][]byte, len(families))
+
+	for i, f := range families {
+		family, ok := f.(*kbsm.Family)
+		if !ok {
+			out[i] = f.ByteSlice()
+			continue
+		}
+
+		if i >= len(familyTypes) || familyTypes[i] != kbsm.Counter {
+			out[i] = f.ByteSlice()
+			continue
+		}
+
+		b := strings.Builder{}
+
+		created := obj.GetCreationTimestamp()
+
+		for _, m := range family.Metrics {
+			line := strings.Builder{}
+			line.WriteString(family.Name)
+			m.Write(&line)
+
+			// the exemplar goes before the newline written by the metric
+			b.WriteString(strings.TrimSuffix(line.String(), "\n"))
+			writeExemplar(&b, m, obj, uids)
+			b.WriteByte('\n')
+
+			if !created.IsZero() {
+				b.WriteString(openMetricsFamilyName(family.Name, kbsm.Counter))
+				b.WriteString("_created")
+				createdMetric := kbsm.Metric{
+					LabelKeys:   m.LabelKeys,
+					LabelValues: m.LabelValues,
+					Value:       float64(created.Unix()),
+				}
+				createdMetric.Write(&b)
+			}
+		}
+
+		out[i] = []byte(b.String())
+	}
+
+	return out
+}
+
+func writeExemplar(
+	b *strings.Builder,
+	m *kbsm.Metric,
+	obj metav1.Object,
+	uids map[types.NamespacedName]types.UID,
+) {
+	keys, values := []string{}, []string{}
+
+	if uid, ok := uids[meterDefOfMetric(m)]; ok {
+		keys, values = append(keys, "meter_def_uid"), append(values, string(uid))
+	}
+
+	keys, values = append(keys, "resource_uid"), append(values, string(obj.GetUID()))
+
+	b.WriteString(" # {")
+	for i := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(keys[i])
+		b.WriteString(`="`)
+		openMetricsEscaper.WriteString(b, values[i])
+		b.WriteByte('"')
+	}
+	b.WriteString("} ")
+	b.WriteString(strconv.FormatFloat(m.Value, 'g', -1, 64))
+}
+
+func meterDefOfMetric(m *kbsm.Metric) types.NamespacedName {
+	name := types.NamespacedName{}
+
+	for i, key := range m.LabelKeys {
+		switch key {
+		case "meter_def_name":
+			name.Name = m.LabelValues[i]
+		case "meter_def_namespace":
+			name.Namespace = m.LabelValues[i]
+		}
+	}
+
+	return name
+}
+
+// WriteOpenMetrics writes the metrics of all stores in the OpenMetrics
+// format, terminated by # EOF.
+func WriteOpenMetrics(w io.Writer, stores []*MetricsStore) {
+	for _, s := range stores {
+		s.WriteAllOpenMetrics(w)
+	}
+
+	w.Write([]byte(openMetricsEOF))
+}
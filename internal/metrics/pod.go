@@ -49,6 +49,107 @@ var podMetricsFamilies = []FamilyGenerator{
 			}
 		}),
 	},
+	podContainerResourceFamily(
+		"meterdef_pod_container_resource_requests_cpu_cores",
+		"The number of requested cpu cores by a container of a metered pod",
+		corev1.ResourceCPU, false,
+	),
+	podContainerResourceFamily(
+		"meterdef_pod_container_resource_limits_cpu_cores",
+		"The cpu core limit of a container of a metered pod",
+		corev1.ResourceCPU, true,
+	),
+	podContainerResourceFamily(
+		"meterdef_pod_container_resource_requests_memory_bytes",
+		"The number of requested memory bytes by a container of a metered pod",
+		corev1.ResourceMemory, false,
+	),
+	podContainerResourceFamily(
+		"meterdef_pod_container_resource_limits_memory_bytes",
+		"The memory limit in bytes of a container of a metered pod",
+		corev1.ResourceMemory, true,
+	),
+	{
+		FamilyGenerator: kbsm.FamilyGenerator{
+			Name: "meterdef_pod_container_restarts_total",
+			Type: kbsm.Counter,
+			Help: "The number of restarts of a container of a metered pod",
+		},
+		GenerateMeterFunc: wrapPodFunc(func(pod *corev1.Pod, meterDefinitions []*marketplacev1alpha1.MeterDefinition) *kbsm.Family {
+			metrics := []*kbsm.Metric{}
+
+			for _, cs := range pod.Status.ContainerStatuses {
+				metrics = append(metrics, &kbsm.Metric{
+					LabelKeys:   []string{"container"},
+					LabelValues: []string{cs.Name},
+					Value:       float64(cs.RestartCount),
+				})
+			}
+
+			return &kbsm.Family{
+				Metrics: metrics,
+			}
+		}),
+	},
+}
+
+// podContainerResourceFamily generates a family with one metric per container
+// of the pod that sets a request (or limit) for the resource. Pods that have
+// terminated no longer hold their resources and are skipped.
+func podContainerResourceFamily(
+	name, help string,
+	resource corev1.ResourceName,
+	limits bool,
+) FamilyGenerator {
+	unit := "bytes"
+	if resource == corev1.ResourceCPU {
+		unit = "cores"
+	}
+
+	return FamilyGenerator{
+		FamilyGenerator: kbsm.FamilyGenerator{
+			Name: name,
+			Type: kbsm.Gauge,
+			Help: help,
+		},
+		Unit: unit,
+		GenerateMeterFunc: wrapPodFunc(func(pod *corev1.Pod, meterDefinitions []*marketplacev1alpha1.MeterDefinition) *kbsm.Family {
+			metrics := []*kbsm.Metric{}
+
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				return &kbsm.Family{
+					Metrics: metrics,
+				}
+			}
+
+			for _, c := range pod.Spec.Containers {
+				resources := c.Resources.Requests
+				if limits {
+					resources = c.Resources.Limits
+				}
+
+				quantity, ok := resources[resource]
+				if !ok {
+					continue
+				}
+
+				value := float64(quantity.Value())
+				if resource == corev1.ResourceCPU {
+					value = float64(quantity.MilliValue()) / 1000
+				}
+
+				metrics = append(metrics, &kbsm.Metric{
+					LabelKeys:   []string{"container"},
+					LabelValues: []string{c.Name},
+					Value:       value,
+				})
+			}
+
+			return &kbsm.Family{
+				Metrics: metrics,
+			}
+		}),
+	}
 }
 
 // wrapPodFunc is a helper function for generating pod-based metrics
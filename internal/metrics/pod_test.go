@@ -0,0 +1,138 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kbsm "k8s.io/kube-state-metrics/pkg/metric"
+)
+
+var _ = Describe("pod families", func() {
+	var (
+		pod   *corev1.Pod
+		mdefs []*marketplacev1alpha1.MeterDefinition
+	)
+
+	generate := func(name string) *kbsm.Family {
+		for _, f := range podMetricsFamilies {
+			if f.Name == name {
+				return f.GenerateMeterFunc(pod, mdefs)
+			}
+		}
+		Fail("family not found " + name)
+		return nil
+	}
+
+	BeforeEach(func() {
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod-1",
+				Namespace: "ns",
+				UID:       "uid-1",
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "app",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("250m"),
+								corev1.ResourceMemory: resource.MustParse("64Mi"),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU: resource.MustParse("2"),
+							},
+						},
+					},
+					{
+						Name: "sidecar",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU: resource.MustParse("100m"),
+							},
+						},
+					},
+				},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "app", RestartCount: 3},
+					{Name: "sidecar"},
+				},
+			},
+		}
+		mdefs = []*marketplacev1alpha1.MeterDefinition{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "mdef-a", Namespace: "ns"},
+				Spec:       marketplacev1alpha1.MeterDefinitionSpec{Group: "app.com", Kind: "App"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "mdef-b", Namespace: "ns"},
+				Spec:       marketplacev1alpha1.MeterDefinitionSpec{Group: "app.com", Kind: "Other"},
+			},
+		}
+	})
+
+	It("should emit cpu requests per container and meterdef", func() {
+		family := generate("meterdef_pod_container_resource_requests_cpu_cores")
+		Expect(family.Metrics).To(HaveLen(4))
+
+		m := family.Metrics[0]
+		Expect(m.LabelKeys).To(Equal([]string{
+			"namespace", "pod", "container",
+			"meter_def_name", "meter_def_namespace", "meter_def_domain", "meter_def_kind",
+		}))
+		Expect(m.LabelValues).To(Equal([]string{"ns", "pod-1", "app", "mdef-a", "ns", "app.com", "App"}))
+		Expect(m.Value).To(Equal(0.25))
+
+		Expect(family.Metrics[1].LabelValues).To(Equal([]string{"ns", "pod-1", "app", "mdef-b", "ns", "app.com", "Other"}))
+		Expect(family.Metrics[2].LabelValues[2]).To(Equal("sidecar"))
+		Expect(family.Metrics[2].Value).To(Equal(0.1))
+	})
+
+	It("should only emit containers that set the resource", func() {
+		family := generate("meterdef_pod_container_resource_limits_cpu_cores")
+		Expect(family.Metrics).To(HaveLen(2))
+		Expect(family.Metrics[0].Value).To(Equal(2.0))
+
+		family = generate("meterdef_pod_container_resource_requests_memory_bytes")
+		Expect(family.Metrics).To(HaveLen(2))
+		Expect(family.Metrics[0].Value).To(Equal(float64(64 * 1024 * 1024)))
+
+		family = generate("meterdef_pod_container_resource_limits_memory_bytes")
+		Expect(family.Metrics).To(BeEmpty())
+	})
+
+	It("should count the restarts per container and meterdef", func() {
+		family := generate("meterdef_pod_container_restarts_total")
+		Expect(family.Metrics).To(HaveLen(4))
+		Expect(family.Metrics[0].LabelValues).To(Equal([]string{"ns", "pod-1", "app", "mdef-a", "ns", "app.com", "App"}))
+		Expect(family.Metrics[0].Value).To(Equal(3.0))
+		Expect(family.Metrics[2].LabelValues[2]).To(Equal("sidecar"))
+		Expect(family.Metrics[2].Value).To(Equal(0.0))
+	})
+
+	It("should skip terminated pods", func() {
+		pod.Status.Phase = corev1.PodSucceeded
+		family := generate("meterdef_pod_container_resource_requests_cpu_cores")
+		Expect(family.Metrics).To(BeEmpty())
+	})
+})
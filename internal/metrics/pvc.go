@@ -15,9 +15,14 @@
 package metrics
 
 import (
+	"reflect"
+
 	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	kbsm "k8s.io/kube-state-metrics/pkg/metric"
 )
 
@@ -48,6 +53,216 @@ var pvcMetricsFamilies = []FamilyGenerator{
 			}
 		}),
 	},
+	{
+		FamilyGenerator: kbsm.FamilyGenerator{
+			Name: "meterdef_persistentvolumeclaim_resource_requests_storage_bytes",
+			Type: kbsm.Gauge,
+			Help: "The storage requested by a metered persistentvolumeclaim in bytes",
+		},
+		Unit: "bytes",
+		GenerateMeterFunc: wrapPersistentVolumeClaimFunc(func(pvc *corev1.PersistentVolumeClaim, meterDefinitions []*marketplacev1alpha1.MeterDefinition) *kbsm.Family {
+			metrics := []*kbsm.Metric{}
+
+			if storage, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+				metrics = append(metrics, &kbsm.Metric{
+					Value: float64(storage.Value()),
+				})
+			}
+
+			return &kbsm.Family{
+				Metrics: metrics,
+			}
+		}),
+	},
+	{
+		FamilyGenerator: kbsm.FamilyGenerator{
+			Name: "meterdef_persistentvolumeclaim_capacity_bytes",
+			Type: kbsm.Gauge,
+			Help: "The capacity of the volume bound to a metered persistentvolumeclaim in bytes",
+		},
+		Unit: "bytes",
+		GenerateMeterFunc: wrapPersistentVolumeClaimFunc(func(pvc *corev1.PersistentVolumeClaim, meterDefinitions []*marketplacev1alpha1.MeterDefinition) *kbsm.Family {
+			metrics := []*kbsm.Metric{}
+
+			if storage, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+				metrics = append(metrics, &kbsm.Metric{
+					Value: float64(storage.Value()),
+				})
+			}
+
+			return &kbsm.Family{
+				Metrics: metrics,
+			}
+		}),
+	},
+	{
+		FamilyGenerator: kbsm.FamilyGenerator{
+			Name: "meterdef_persistentvolumeclaim_access_mode",
+			Type: kbsm.Gauge,
+			Help: "The access modes requested by a metered persistentvolumeclaim",
+		},
+		GenerateMeterFunc: wrapPersistentVolumeClaimFunc(func(pvc *corev1.PersistentVolumeClaim, meterDefinitions []*marketplacev1alpha1.MeterDefinition) *kbsm.Family {
+			metrics := []*kbsm.Metric{}
+
+			for _, mode := range pvc.Spec.AccessModes {
+				metrics = append(metrics, &kbsm.Metric{
+					LabelKeys:   []string{"access_mode"},
+					LabelValues: []string{string(mode)},
+					Value:       1,
+				})
+			}
+
+			return &kbsm.Family{
+				Metrics: metrics,
+			}
+		}),
+	},
+	{
+		FamilyGenerator: kbsm.FamilyGenerator{
+			Name: "meterdef_persistentvolumeclaim_storageclass_info",
+			Type: kbsm.Gauge,
+			Help: "The storage class and volume of a metered persistentvolumeclaim",
+		},
+		GenerateMeterFunc: wrapPersistentVolumeClaimFunc(func(pvc *corev1.PersistentVolumeClaim, meterDefinitions []*marketplacev1alpha1.MeterDefinition) *kbsm.Family {
+			metrics := []*kbsm.Metric{}
+
+			metrics = append(metrics, &kbsm.Metric{
+				LabelKeys:   []string{"storageclass", "volumename"},
+				LabelValues: []string{getPersistentVolumeClaimClass(pvc), pvc.Spec.VolumeName},
+				Value:       1,
+			})
+
+			return &kbsm.Family{
+				Metrics: metrics,
+			}
+		}),
+	},
+}
+
+// PersistentVolumeFetcher gets the persistentvolume bound to a claim.
+type PersistentVolumeFetcher interface {
+	GetPersistentVolume(name string) (*corev1.PersistentVolume, error)
+}
+
+// persistentVolumeMetricsFamilies are generated from persistentvolumeclaims
+// but describe the bound persistentvolume, so they carry the claim labels and
+// join with the other persistentvolumeclaim families. The volume is read when
+// the claim's metrics are generated, and the claim's metrics are generated
+// again when the volume changes.
+func persistentVolumeMetricsFamilies(fetcher PersistentVolumeFetcher) []FamilyGenerator {
+	return []FamilyGenerator{
+		{
+			FamilyGenerator: kbsm.FamilyGenerator{
+				Name: "meterdef_persistentvolume_capacity_bytes",
+				Type: kbsm.Gauge,
+				Help: "The capacity of the persistentvolume bound to a metered persistentvolumeclaim in bytes",
+			},
+			Unit: "bytes",
+			GenerateMeterFunc: wrapPersistentVolumeClaimFunc(func(pvc *corev1.PersistentVolumeClaim, meterDefinitions []*marketplacev1alpha1.MeterDefinition) *kbsm.Family {
+				metrics := []*kbsm.Metric{}
+
+				if pvc.Spec.VolumeName == "" {
+					return &kbsm.Family{
+						Metrics: metrics,
+					}
+				}
+
+				pv, err := fetcher.GetPersistentVolume(pvc.Spec.VolumeName)
+				if err != nil {
+					log.Error(err, "failed to get persistentvolume", "name", pvc.Spec.VolumeName)
+					return &kbsm.Family{
+						Metrics: metrics,
+					}
+				}
+
+				if pv == nil {
+					return &kbsm.Family{
+						Metrics: metrics,
+					}
+				}
+
+				if storage, ok := pv.Spec.Capacity[corev1.ResourceStorage]; ok {
+					metrics = append(metrics, &kbsm.Metric{
+						LabelKeys:   []string{"persistentvolume", "storageclass"},
+						LabelValues: []string{pv.Name, pv.Spec.StorageClassName},
+						Value:       float64(storage.Value()),
+					})
+				}
+
+				return &kbsm.Family{
+					Metrics: metrics,
+				}
+			}),
+		},
+	}
+}
+
+// getPersistentVolumeClaimClass returns the storage class of the claim,
+// falling back to the beta annotation.
+func getPersistentVolumeClaimClass(pvc *corev1.PersistentVolumeClaim) string {
+	if class, ok := pvc.Annotations[corev1.BetaStorageClassAnnotation]; ok {
+		return class
+	}
+
+	if pvc.Spec.StorageClassName != nil {
+		return *pvc.Spec.StorageClassName
+	}
+
+	return ""
+}
+
+// persistentVolumeLister reads the persistentvolumes from an informer
+// cache.
+type persistentVolumeLister struct {
+	lister corelisters.PersistentVolumeLister
+}
+
+var _ PersistentVolumeFetcher = &persistentVolumeLister{}
+
+// GetPersistentVolume returns nil if the volume does not exist or has
+// not been listed yet.
+func (p *persistentVolumeLister) GetPersistentVolume(name string) (*corev1.PersistentVolume, error) {
+	pv, err := p.lister.Get(name)
+
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	return pv, err
+}
+
+// persistentVolumeHandler generates the metrics of the claim bound to a
+// persistentvolume again when the volume is listed or its capacity or
+// storage class change, so resized volumes are reported without an event
+// of the claim.
+func persistentVolumeHandler(store *MetricsStore) cache.ResourceEventHandler {
+	regenerate := func(obj interface{}) {
+		pv, ok := obj.(*corev1.PersistentVolume)
+		if !ok || pv.Spec.ClaimRef == nil {
+			return
+		}
+
+		if err := store.regenerate(pv.Spec.ClaimRef.UID); err != nil {
+			log.Error(err, "failed to regenerate persistentvolumeclaim metrics", "persistentvolume", pv.Name)
+		}
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: regenerate,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPV, ok := oldObj.(*corev1.PersistentVolume)
+			newPV, ok2 := newObj.(*corev1.PersistentVolume)
+
+			if ok && ok2 &&
+				reflect.DeepEqual(oldPV.Spec.Capacity, newPV.Spec.Capacity) &&
+				oldPV.Spec.StorageClassName == newPV.Spec.StorageClassName &&
+				reflect.DeepEqual(oldPV.Spec.ClaimRef, newPV.Spec.ClaimRef) {
+				return
+			}
+
+			regenerate(newObj)
+		},
+	}
 }
 
 // wrapPersistentVolumeClaimFunc is a helper function for generating pvc-based metrics
@@ -0,0 +1,225 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+	kbsm "k8s.io/kube-state-metrics/pkg/metric"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type fakePersistentVolumeFetcher map[string]*corev1.PersistentVolume
+
+func (f fakePersistentVolumeFetcher) GetPersistentVolume(name string) (*corev1.PersistentVolume, error) {
+	return f[name], nil
+}
+
+var _ = Describe("pvc families", func() {
+	var (
+		pvc      *corev1.PersistentVolumeClaim
+		volumes  fakePersistentVolumeFetcher
+		mdefs    []*marketplacev1alpha1.MeterDefinition
+		families []FamilyGenerator
+	)
+
+	generate := func(name string) *kbsm.Family {
+		for _, f := range families {
+			if f.Name == name {
+				return f.GenerateMeterFunc(pvc, mdefs)
+			}
+		}
+		Fail("family not found " + name)
+		return nil
+	}
+
+	BeforeEach(func() {
+		class := "gp2"
+		pvc = &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "data",
+				Namespace: "ns",
+				UID:       "uid-1",
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{
+					corev1.ReadWriteOnce,
+					corev1.ReadOnlyMany,
+				},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("1Gi"),
+					},
+				},
+				StorageClassName: &class,
+				VolumeName:       "pv-1",
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase: corev1.ClaimBound,
+				Capacity: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("2Gi"),
+				},
+			},
+		}
+		volumes = fakePersistentVolumeFetcher{
+			"pv-1": &corev1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: "pv-1"},
+				Spec: corev1.PersistentVolumeSpec{
+					Capacity: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("2Gi"),
+					},
+					StorageClassName: "gp2",
+				},
+			},
+		}
+		mdefs = []*marketplacev1alpha1.MeterDefinition{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "mdef-a", Namespace: "ns"},
+				Spec:       marketplacev1alpha1.MeterDefinitionSpec{Group: "app.com", Kind: "App"},
+			},
+		}
+		families = append(append([]FamilyGenerator{}, pvcMetricsFamilies...), persistentVolumeMetricsFamilies(volumes)...)
+	})
+
+	It("should emit requested and bound capacity", func() {
+		family := generate("meterdef_persistentvolumeclaim_resource_requests_storage_bytes")
+		Expect(family.Metrics).To(HaveLen(1))
+		Expect(family.Metrics[0].LabelKeys).To(Equal([]string{
+			"namespace", "persistentvolumeclaim",
+			"meter_def_name", "meter_def_namespace", "meter_def_domain", "meter_def_kind",
+		}))
+		Expect(family.Metrics[0].LabelValues).To(Equal([]string{"ns", "data", "mdef-a", "ns", "app.com", "App"}))
+		Expect(family.Metrics[0].Value).To(Equal(float64(1 << 30)))
+
+		family = generate("meterdef_persistentvolumeclaim_capacity_bytes")
+		Expect(family.Metrics).To(HaveLen(1))
+		Expect(family.Metrics[0].Value).To(Equal(float64(2 << 30)))
+	})
+
+	It("should emit access modes and storage class", func() {
+		family := generate("meterdef_persistentvolumeclaim_access_mode")
+		Expect(family.Metrics).To(HaveLen(2))
+		Expect(family.Metrics[0].LabelValues[2]).To(Equal("ReadWriteOnce"))
+		Expect(family.Metrics[1].LabelValues[2]).To(Equal("ReadOnlyMany"))
+
+		family = generate("meterdef_persistentvolumeclaim_storageclass_info")
+		Expect(family.Metrics).To(HaveLen(1))
+		Expect(family.Metrics[0].LabelKeys[2:4]).To(Equal([]string{"storageclass", "volumename"}))
+		Expect(family.Metrics[0].LabelValues[2:4]).To(Equal([]string{"gp2", "pv-1"}))
+	})
+
+	It("should emit the bound volume capacity with the claim labels", func() {
+		family := generate("meterdef_persistentvolume_capacity_bytes")
+		Expect(family.Metrics).To(HaveLen(1))
+		Expect(family.Metrics[0].LabelKeys[:4]).To(Equal([]string{
+			"namespace", "persistentvolumeclaim", "persistentvolume", "storageclass",
+		}))
+		Expect(family.Metrics[0].LabelValues[:4]).To(Equal([]string{"ns", "data", "pv-1", "gp2"}))
+		Expect(family.Metrics[0].Value).To(Equal(float64(2 << 30)))
+	})
+
+	It("should skip unbound claims", func() {
+		pvc.Spec.VolumeName = ""
+		pvc.Status = corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending}
+
+		Expect(generate("meterdef_persistentvolumeclaim_capacity_bytes").Metrics).To(BeEmpty())
+		Expect(generate("meterdef_persistentvolume_capacity_bytes").Metrics).To(BeEmpty())
+	})
+
+	Context("with a persistentvolume informer", func() {
+		var (
+			ctx        context.Context
+			cancel     context.CancelFunc
+			kubeClient *fake.Clientset
+			pv         *corev1.PersistentVolume
+			store      *MetricsStore
+		)
+
+		capacity := func() string {
+			out := &bytes.Buffer{}
+			store.WriteAll(out)
+			return out.String()
+		}
+
+		BeforeEach(func() {
+			ctx, cancel = context.WithCancel(context.Background())
+
+			pv = volumes["pv-1"].DeepCopy()
+			pv.Spec.ClaimRef = &corev1.ObjectReference{Name: pvc.Name, Namespace: pvc.Namespace, UID: pvc.UID}
+			kubeClient = fake.NewSimpleClientset(pv)
+
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			meterDefStore := meter_definition.NewMeterDefinitionStoreBuilder(
+				ctx, logf.Log, nil, nil, nil, nil, nil, nil, scheme).NewInstance()
+			Expect(meterDefStore.Add(pvc)).To(Succeed())
+
+			factory := informers.NewSharedInformerFactory(kubeClient, 0)
+			informer := factory.Core().V1().PersistentVolumes()
+
+			families = append(append([]FamilyGenerator{}, pvcMetricsFamilies...),
+				persistentVolumeMetricsFamilies(&persistentVolumeLister{informer.Lister()})...)
+			store = NewMetricsStore(
+				ExtractMetricFamilyHeaders(families),
+				ComposeMetricGenFuncs(families),
+				meterDefStore,
+				fakeMeterDefFetcher(mdefs),
+				persistentVolType,
+			)
+
+			By("generating the claim before the volume is listed")
+			Expect(store.Add(pvc)).To(Succeed())
+			Expect(capacity()).ToNot(ContainSubstring("meterdef_persistentvolume_capacity_bytes{"))
+
+			informer.Informer().AddEventHandler(persistentVolumeHandler(store))
+			factory.Start(ctx.Done())
+			Expect(cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced)).To(BeTrue())
+		})
+
+		AfterEach(func() {
+			cancel()
+		})
+
+		It("should read the volume from the informer", func() {
+			lister := &persistentVolumeLister{informers.NewSharedInformerFactory(kubeClient, 0).Core().V1().PersistentVolumes().Lister()}
+			Expect(lister.GetPersistentVolume("missing")).To(BeNil())
+
+			Eventually(capacity).Should(ContainSubstring(`persistentvolume="pv-1",storageclass="gp2",meter_def_name="mdef-a"`))
+		})
+
+		It("should regenerate the claim when the volume is resized", func() {
+			Eventually(capacity).Should(ContainSubstring(`} 2.147483648e+09`))
+
+			pv.Spec.Capacity[corev1.ResourceStorage] = resource.MustParse("4Gi")
+			_, err := kubeClient.CoreV1().PersistentVolumes().Update(context.TODO(), pv, metav1.UpdateOptions{})
+			Expect(err).To(Succeed())
+
+			Eventually(capacity).Should(ContainSubstring(`} 4.294967296e+09`))
+		})
+	})
+})
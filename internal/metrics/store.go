@@ -25,6 +25,7 @@ import (
 	"github.com/sasha-s/go-deadlock"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	kbsm "k8s.io/kube-state-metrics/pkg/metric"
 )
 
 type FamilyByteSlicer interface {
@@ -50,6 +51,13 @@ type MetricsStore struct {
 	// later on zipped with with their corresponding metric families in
 	// MetricStore.WriteAll().
 	headers []string
+	// openMetrics are the metrics in the OpenMetrics format, where counters
+	// carry exemplars, zipped with openMetricsHeaders in WriteAllOpenMetrics().
+	openMetrics        map[types.UID][][]byte
+	openMetricsHeaders []string
+	// familyTypes is the type of each metric family, only counters get
+	// exemplars in the OpenMetrics format
+	familyTypes []kbsm.Type
 
 	// generateMetricsFunc generates metrics based on a given Kubernetes object
 	// and returns them grouped by metric family.
@@ -60,6 +68,14 @@ type MetricsStore struct {
 	meterDefFetcher MeterDefinitionFetcher
 
 	expectedType reflect.Type
+
+	// sharding decides which objects this replica writes metrics for
+	sharding meter_definition.Sharding
+
+	// limiter drops the series of meter definitions over their limit
+	limiter *SeriesLimiter
+	// limited are the objects the limiter has admitted or dropped
+	limited map[types.UID]bool
 }
 
 // NewMetricsStore returns a new MetricsStore
@@ -77,6 +93,8 @@ func NewMetricsStore(
 		meterDefStore:       meterDefStore,
 		expectedType:        expectedType,
 		metrics:             map[types.UID][][]byte{},
+		openMetrics:         map[types.UID][][]byte{},
+		limited:             map[types.UID]bool{},
 	}
 }
 
@@ -88,12 +106,17 @@ func (s *MetricsStore) Start(
 	ch := make(chan *meter_definition.ObjectResourceMessage, 10)
 	s.meterDefStore.RegisterListener(fmt.Sprintf("metricStore-%v", s.expectedType), ch)
 
+	// the channel isn't closed here since the meter definition store
+	// may still send to it, its sends give up once ctx is done
 	go func() {
-		defer close(ch)
-
 		for {
 			select {
 			case msg := <-ch:
+				if msg != nil && msg.Action == meter_definition.BatchMessageAction {
+					s.addBatch(msg.Object)
+					continue
+				}
+
 				if msg == nil || reflect.TypeOf(msg.Object) != s.expectedType {
 					log.Info("received unexpected type", "received", reflect.TypeOf(msg.Object), "expectedType", s.expectedType)
 					continue
@@ -113,6 +136,33 @@ func (s *MetricsStore) Start(
 	}()
 }
 
+// addBatch regenerates the metrics of the objects in a batch. Removed
+// objects are regenerated too since they may still match other
+// meter definitions.
+func (s *MetricsStore) addBatch(obj interface{}) {
+	batch, ok := obj.(*meter_definition.ObjectResourceBatch)
+	if !ok {
+		return
+	}
+
+	values := append(append([]*meter_definition.ObjectResourceValue{}, batch.Added...), batch.Removed...)
+	count := 0
+
+	for _, value := range values {
+		if reflect.TypeOf(value.Object) != s.expectedType {
+			continue
+		}
+
+		_ = s.Add(value.Object)
+		count = count + 1
+	}
+
+	if count > 0 {
+		log.Info("batchMessageAction", "mdef", batch.MeterDef, "expectedType", s.expectedType,
+			"added", len(batch.Added), "removed", len(batch.Removed), "regenerated", count)
+	}
+}
+
 // Implementing k8s.io/client-go/tools/cache.Store interface
 
 // Add inserts adds to the MetricsStore by calling the metrics generator functions and
@@ -126,6 +176,13 @@ func (s *MetricsStore) Add(obj interface{}) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	if !s.sharding.Owns(o.GetUID()) {
+		s.release(o.GetUID())
+		delete(s.metrics, o.GetUID())
+		delete(s.openMetrics, o.GetUID())
+		return nil
+	}
+
 	meterDefs, err := s.meterDefFetcher.GetMeterDefinitions(o)
 
 	if err != nil {
@@ -133,6 +190,27 @@ func (s *MetricsStore) Add(obj interface{}) error {
 	}
 
 	families := s.generateMetricsFunc(obj, meterDefs)
+
+	// an object that no longer matches a meter definition isn't limited
+	if len(meterDefs) == 0 {
+		s.release(o.GetUID())
+	}
+
+	if s.limiter != nil && len(meterDefs) > 0 {
+		admitted := s.limiter.Admit(o.GetUID(), meterDefs, seriesCount(families)/len(meterDefs))
+		s.limited[o.GetUID()] = true
+
+		if len(admitted) == 0 {
+			delete(s.metrics, o.GetUID())
+			delete(s.openMetrics, o.GetUID())
+			return nil
+		}
+
+		if len(admitted) != len(meterDefs) {
+			meterDefs = admitted
+			families = s.generateMetricsFunc(obj, meterDefs)
+		}
+	}
 	familyStrings := make([][]byte, len(families))
 
 	for i, f := range families {
@@ -140,10 +218,30 @@ func (s *MetricsStore) Add(obj interface{}) error {
 	}
 
 	s.metrics[o.GetUID()] = familyStrings
+	s.openMetrics[o.GetUID()] = openMetricsByteSlices(families, s.familyTypes, o, meterDefs)
 
 	return nil
 }
 
+// regenerate generates the metrics of the object with the uid again if
+// the store has metrics for it.
+func (s *MetricsStore) regenerate(uid types.UID) error {
+	s.mutex.RLock()
+	_, ok := s.metrics[uid]
+	s.mutex.RUnlock()
+
+	if !ok || s.meterDefStore == nil {
+		return nil
+	}
+
+	obj, ok := s.meterDefStore.GetObject(uid)
+	if !ok {
+		return nil
+	}
+
+	return s.Add(obj)
+}
+
 // Update updates the existing entry in the MetricsStore.
 func (s *MetricsStore) Update(obj interface{}) error {
 	// TODO: For now, just call Add, in the future one could check if the resource version changed?
@@ -160,11 +258,21 @@ func (s *MetricsStore) Delete(obj interface{}) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	s.release(o.GetUID())
 	delete(s.metrics, o.GetUID())
+	delete(s.openMetrics, o.GetUID())
 
 	return nil
 }
 
+func (s *MetricsStore) release(uid types.UID) {
+	if s.limiter != nil {
+		s.limiter.Release(uid)
+	}
+
+	delete(s.limited, uid)
+}
+
 // List implements the List method of the store interface.
 func (s *MetricsStore) List() []interface{} {
 	return nil
@@ -189,7 +297,13 @@ func (s *MetricsStore) GetByKey(key string) (item interface{}, exists bool, err
 // given list.
 func (s *MetricsStore) Replace(list []interface{}, _ string) error {
 	s.mutex.Lock()
+	// objects that were dropped for every meter definition have no
+	// metrics but are still counted by the limiter
+	for uid := range s.limited {
+		s.release(uid)
+	}
 	s.metrics = map[types.UID][][]byte{}
+	s.openMetrics = map[types.UID][][]byte{}
 	s.mutex.Unlock()
 
 	for _, o := range list {
@@ -221,3 +335,23 @@ func (s *MetricsStore) WriteAll(w io.Writer) {
 		}
 	}
 }
+
+// WriteAllOpenMetrics writes all metrics of the store into the given writer
+// in the OpenMetrics format, without the terminating # EOF.
+func (s *MetricsStore) WriteAllOpenMetrics(w io.Writer) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	headers := s.openMetricsHeaders
+	if headers == nil {
+		headers = s.headers
+	}
+
+	for i, help := range headers {
+		w.Write([]byte(help))
+		w.Write([]byte{'\n'})
+		for _, metricFamilies := range s.openMetrics {
+			w.Write(metricFamilies[i])
+		}
+	}
+}
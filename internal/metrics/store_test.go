@@ -0,0 +1,75 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("MetricsStore", func() {
+	It("should not block or panic on messages sent after shutdown", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		meterDefStore := meter_definition.NewMeterDefinitionStoreBuilder(
+			ctx, logf.Log, nil, nil, nil, nil, nil, nil, nil).NewInstance()
+		store := NewMetricsStore(nil, nil, meterDefStore, fakeMeterDefFetcher{},
+			reflect.TypeOf(&corev1.Pod{}))
+		store.Start(ctx)
+
+		cancel()
+
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+
+			// more messages than the listener buffers
+			for i := 0; i < 20; i++ {
+				Expect(meterDefStore.Add(&marketplacev1alpha1.MeterDefinition{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("meterdef-%d", i),
+						Namespace: "ns",
+						UID:       types.UID(fmt.Sprintf("meterdef-%d", i)),
+					},
+					Spec: marketplacev1alpha1.MeterDefinitionSpec{
+						WorkloadVertexType: marketplacev1alpha1.WorkloadVertexNamespace,
+						Workloads: []marketplacev1alpha1.Workload{
+							{
+								Name:         "pods",
+								WorkloadType: marketplacev1alpha1.WorkloadTypePod,
+								LabelSelector: &metav1.LabelSelector{
+									MatchLabels: map[string]string{"app": "foo"},
+								},
+							},
+						},
+					},
+				})).To(Succeed())
+			}
+		}()
+
+		Eventually(done).Should(BeClosed())
+	})
+})
@@ -17,6 +17,7 @@ package v1alpha1
 import (
 	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	status "github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -44,6 +45,39 @@ type StorageSpec struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:hidden"
 	// +optional
 	EmptyDir *corev1.EmptyDirVolumeSource `json:"emptyDir,omitempty"`
+
+	// AutoExpand expands the prometheus volumes as they fill up. If the
+	// storage class doesn't allow volume expansion, or the volumes are at
+	// their max size, the retention is reduced instead. The retention is
+	// restored once the volumes can be expanded again.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	AutoExpand *StorageAutoExpandSpec `json:"autoExpand,omitempty"`
+}
+
+// StorageAutoExpandSpec is the policy for growing the prometheus volumes.
+type StorageAutoExpandSpec struct {
+	// MaxSize the volumes are expanded to.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Format=quantity
+	MaxSize resource.Quantity `json:"maxSize"`
+
+	// Step is how much the volumes grow per expansion. Default is 10Gi.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Format=quantity
+	// +optional
+	Step *resource.Quantity `json:"step,omitempty"`
+
+	// UsedPercent of a volume at which it's expanded. Default is 80.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	// +optional
+	UsedPercent *int32 `json:"usedPercent,omitempty"`
+
+	// MinRetention is the shortest the retention is reduced to. Default
+	// is 7 days.
+	// +optional
+	MinRetention *metav1.Duration `json:"minRetention,omitempty"`
 }
 
 // PrometheusSpec contains configuration regarding prometheus
@@ -74,6 +108,231 @@ type PrometheusSpec struct {
 	Replicas *int32 `json:"replicas,omitempty"`
 }
 
+// ReportCadence is how often meter reports are generated.
+type ReportCadence string
+
+const (
+	ReportCadenceHourly ReportCadence = "hourly"
+	ReportCadenceDaily  ReportCadence = "daily"
+	ReportCadenceWeekly ReportCadence = "weekly"
+)
+
+// ReportingSpec contains configuration of the meter reports
+// generated by the meterbase.
+type ReportingSpec struct {
+	// Cadence of the meter reports, hourly, daily or weekly. Weekly
+	// reports start on Monday. Default is daily.
+	// +kubebuilder:validation:Enum=hourly;daily;weekly
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Cadence ReportCadence `json:"cadence,omitempty"`
+
+	// Timezone the report periods are aligned to, as an IANA time zone
+	// name such as America/New_York. Default is UTC.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// Retention is how far back reports are kept and missing reports are
+	// created. Older reports are deleted. Default is 720h (30 days).
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Retention *metav1.Duration `json:"retention,omitempty"`
+
+	// Delay after the end of a report period before the report runs, so
+	// late samples are included. Default is 0.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
+
+	// Backfill requests meter reports to be created or re-run for a past
+	// time range.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Backfill *BackfillSpec `json:"backfill,omitempty"`
+}
+
+// BackfillSpec is a request to create or re-run the meter reports of a
+// past time range, for example after a meter definition is fixed. The
+// reports take a new snapshot of the meter definitions and are marked
+// as corrections. Only data still retained by Prometheus is reported.
+type BackfillSpec struct {
+	// ID of the backfill. Set a new ID to run another backfill, even over
+	// the same range.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]{0,18}[a-z0-9])?$`
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	ID string `json:"id"`
+
+	// StartTime of the range. It's aligned to the start of its report period.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	StartTime metav1.Time `json:"startTime"`
+
+	// EndTime of the range. Report periods that haven't ended yet are not
+	// backfilled.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	EndTime metav1.Time `json:"endTime"`
+
+	// MaxConcurrentJobs is the number of backfill report jobs that can run
+	// at the same time. Default is 2.
+	// +kubebuilder:validation:Minimum=1
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	MaxConcurrentJobs *int32 `json:"maxConcurrentJobs,omitempty"`
+}
+
+// BackfillStatus is the progress of the last backfill.
+type BackfillStatus struct {
+	// ID of the backfill.
+	ID string `json:"id"`
+
+	// StartTime is the start of the backfilled range. It's later than the
+	// requested start when Prometheus no longer retains the samples
+	// before it.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Message explains why the backfill doesn't cover the whole requested
+	// range.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Pending is the number of reports waiting for a job.
+	Pending int32 `json:"pending"`
+
+	// Running is the number of reports with a running job.
+	Running int32 `json:"running"`
+
+	// Succeeded is the number of reports with a successful job.
+	Succeeded int32 `json:"succeeded"`
+
+	// Failed is the number of reports with a failed job.
+	Failed int32 `json:"failed"`
+
+	// CompletionTime is when all the report jobs were done.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// LongTermStorageSpec configures storage of the metering samples beyond
+// the local retention of the meterbase Prometheus.
+type LongTermStorageSpec struct {
+	// RemoteWrite targets the meterbase Prometheus sends its samples to.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	RemoteWrite []RemoteWriteSpec `json:"remoteWrite,omitempty"`
+
+	// Thanos adds a Thanos sidecar to the meterbase Prometheus that uploads
+	// its blocks to object storage.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Thanos *ThanosSpec `json:"thanos,omitempty"`
+
+	// QueryService is a service with the Prometheus query API over the long-term
+	// store, like a Thanos querier. Reports for periods older than the local
+	// retention query it instead of the meterbase Prometheus.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	QueryService *common.ServiceReference `json:"queryService,omitempty"`
+}
+
+// RemoteWriteSpec is an endpoint the meterbase Prometheus sends samples to.
+type RemoteWriteSpec struct {
+	// Name of the remote write queue. Must be unique if set.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// URL of the endpoint to send samples to.
+	URL string `json:"url"`
+
+	// RemoteTimeout for requests to the endpoint.
+	// +optional
+	RemoteTimeout string `json:"remoteTimeout,omitempty"`
+
+	// BasicAuth for the endpoint, read from secrets in the meterbase namespace.
+	// +optional
+	BasicAuth *monitoringv1.BasicAuth `json:"basicAuth,omitempty"`
+
+	// BearerTokenSecret is the key of a secret in the meterbase namespace
+	// with the bearer token for the endpoint.
+	// +optional
+	BearerTokenSecret *corev1.SecretKeySelector `json:"bearerTokenSecret,omitempty"`
+
+	// QueueConfig tunes the remote write queue.
+	// +optional
+	QueueConfig *monitoringv1.QueueConfig `json:"queueConfig,omitempty"`
+}
+
+// ThanosSpec configures the Thanos sidecar of the meterbase Prometheus.
+type ThanosSpec struct {
+	// ObjectStorageConfig is the key of a secret in the meterbase namespace
+	// with the Thanos object storage configuration.
+	ObjectStorageConfig corev1.SecretKeySelector `json:"objectStorageConfig"`
+
+	// Resources of the sidecar. Default is not defined.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// ExternalPrometheusSpec points metering at a Prometheus the meterbase
+// doesn't install, like user-workload monitoring.
+type ExternalPrometheusSpec struct {
+	// Service with the Prometheus query API the meter reports query. Its
+	// bearerTokenSecret and tlsConfig.ca are used for auth and must be in
+	// the meterbase namespace. Default auth is the service account token
+	// and the service CA.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	Service common.ServiceReference `json:"service"`
+
+	// ServiceMonitorLabels are added to the metering service monitors and
+	// the PrometheusRule of the alerts so the existing Prometheus selects
+	// them.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	ServiceMonitorLabels map[string]string `json:"serviceMonitorLabels,omitempty"`
+}
+
+// AlertsSpec tunes the alerts of the metering pipeline. The alerts are in
+// a PrometheusRule evaluated by the metering Prometheus.
+type AlertsSpec struct {
+	// Disabled removes the metering alerts.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// MetricStateDownFor is how long metric-state can't be scraped before
+	// MeteringMetricStateDown fires. Default is 10m.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	MetricStateDownFor *metav1.Duration `json:"metricStateDownFor,omitempty"`
+
+	// SeriesAbsentFor is how long the meterdef info series a meter
+	// definition needs can be missing before MeteringSeriesAbsent fires.
+	// Default is 30m.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	SeriesAbsentFor *metav1.Duration `json:"seriesAbsentFor,omitempty"`
+
+	// ReportErroredFor is how long the job of a meter report can stay
+	// errored before MeteringReportErrored fires. Default is 30m.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	ReportErroredFor *metav1.Duration `json:"reportErroredFor,omitempty"`
+
+	// UploadFailingFor is how long the upload of a meter report can keep
+	// failing before MeteringUploadFailing fires. Default is 1h.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	UploadFailingFor *metav1.Duration `json:"uploadFailingFor,omitempty"`
+
+	// StorageUsedPercent is the used share of the Prometheus volume at
+	// which MeteringStorageNearlyFull fires. Default is 85.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	StorageUsedPercent *int32 `json:"storageUsedPercent,omitempty"`
+}
+
 // MeterBaseSpec defines the desired state of MeterBase
 // +k8s:openapi-gen=true
 type MeterBaseSpec struct {
@@ -96,6 +355,29 @@ type MeterBaseSpec struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
 	// +optional
 	AdditionalScrapeConfigs *corev1.SecretKeySelector `json:"additionalScrapeConfigs,omitempty"`
+
+	// Reporting configures the cadence, timezone, retention, delay and
+	// backfill of the meter reports.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Reporting *ReportingSpec `json:"reporting,omitempty"`
+
+	// LongTermStorage configures remote write and a Thanos sidecar so
+	// metering samples outlive the local retention of Prometheus.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	LongTermStorage *LongTermStorageSpec `json:"longTermStorage,omitempty"`
+
+	// ExternalPrometheus uses an existing Prometheus for metering instead
+	// of installing one. Prometheus and LongTermStorage are ignored when set.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	ExternalPrometheus *ExternalPrometheusSpec `json:"externalPrometheus,omitempty"`
+
+	// Alerts tunes the thresholds of the metering alerts.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Alerts *AlertsSpec `json:"alerts,omitempty"`
 }
 
 // MeterBaseStatus defines the observed state of MeterBase.
@@ -126,6 +408,87 @@ type MeterBaseStatus struct {
 	// Total number of unavailable pods targeted by this Prometheus deployment.
 	// +optional
 	UnavailableReplicas *int32 `json:"unavailableReplicas,omitempty"`
+
+	// Backfill is the progress of the last backfill.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	Backfill *BackfillStatus `json:"backfill,omitempty"`
+
+	// PrometheusHealth is the storage and ingestion health of the meterbase
+	// Prometheus. It is refreshed every few minutes.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	PrometheusHealth *PrometheusHealthStatus `json:"prometheusHealth,omitempty"`
+
+	// Storage is what the storage auto expand policy changed.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	Storage *StorageStatus `json:"storage,omitempty"`
+}
+
+// StorageStatus is the size and retention the storage auto expand policy
+// set on the meterbase Prometheus.
+type StorageStatus struct {
+	// Size the volumes were expanded to.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// Retention the Prometheus was reduced to, like 20d.
+	// +optional
+	Retention string `json:"retention,omitempty"`
+
+	// LastActionTime is when the volumes were last expanded or the
+	// retention last reduced.
+	// +optional
+	LastActionTime *metav1.Time `json:"lastActionTime,omitempty"`
+}
+
+// PrometheusHealthStatus is the storage and ingestion health of the
+// meterbase Prometheus.
+type PrometheusHealthStatus struct {
+	// HeadSeries is the number of series in the head block.
+	HeadSeries int64 `json:"headSeries"`
+
+	// IngestionRate is the number of samples appended per second over the
+	// last 5 minutes.
+	IngestionRate int64 `json:"ingestionRate"`
+
+	// DiskUsage is the size of the blocks and the WAL on disk.
+	// +optional
+	DiskUsage *resource.Quantity `json:"diskUsage,omitempty"`
+
+	// DiskCapacity is the storage size of the Prometheus volume.
+	// +optional
+	DiskCapacity *resource.Quantity `json:"diskCapacity,omitempty"`
+
+	// DiskUsedPercent is DiskUsage as a percentage of DiskCapacity.
+	// +optional
+	DiskUsedPercent *int32 `json:"diskUsedPercent,omitempty"`
+
+	// OldestSampleTime is the time of the oldest sample in storage. Samples
+	// older than the retention are deleted.
+	// +optional
+	OldestSampleTime *metav1.Time `json:"oldestSampleTime,omitempty"`
+
+	// Targets is the scrape target health of the jobs metering depends on.
+	// +optional
+	Targets []TargetHealth `json:"targets,omitempty"`
+
+	// LastUpdateTime is when the health was last queried, successfully
+	// or not.
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+// TargetHealth is the number of up and down scrape targets of a job.
+type TargetHealth struct {
+	// Job is the name of the scrape job.
+	Job string `json:"job"`
+
+	// Up is the number of targets scraped successfully.
+	Up int32 `json:"up"`
+
+	// Down is the number of targets that failed their last scrape.
+	Down int32 `json:"down"`
 }
 
 // MeterBase is the resource that sets up Metering for Red Hat Marketplace.
@@ -165,6 +528,49 @@ func init() {
 }
 
 const (
+	// ConditionSeriesAvailable means the metering Prometheus has the
+	// meterdef info series the meter definitions need.
+	ConditionSeriesAvailable status.ConditionType = "SeriesAvailable"
+
+	// Reasons for series available
+	ReasonSeriesPresent     status.ConditionReason = "SeriesPresent"
+	ReasonSeriesMissing     status.ConditionReason = "SeriesMissing"
+	ReasonSeriesQueryFailed status.ConditionReason = "QueryFailed"
+
+	// ConditionStorageHealthy means the meterbase Prometheus has room left
+	// on its volume.
+	ConditionStorageHealthy status.ConditionType = "StorageHealthy"
+
+	// ConditionTargetsHealthy means the scrape targets of the metric-state
+	// and kubelet jobs are up.
+	ConditionTargetsHealthy status.ConditionType = "TargetsHealthy"
+
+	// Reasons for storage and targets healthy
+	ReasonStorageAvailable  status.ConditionReason = "StorageAvailable"
+	ReasonStorageNearlyFull status.ConditionReason = "StorageNearlyFull"
+	ReasonTargetsUp         status.ConditionReason = "TargetsUp"
+	ReasonTargetsDown       status.ConditionReason = "TargetsDown"
+	ReasonHealthQueryFailed status.ConditionReason = "HealthQueryFailed"
+
+	// ConditionStorageAutoExpand means the storage auto expand policy
+	// expanded the prometheus volumes or reduced the retention.
+	ConditionStorageAutoExpand status.ConditionType = "StorageAutoExpand"
+
+	// Reasons for storage auto expand
+	ReasonVolumesExpanded        status.ConditionReason = "VolumesExpanded"
+	ReasonVolumeExpansionFailed  status.ConditionReason = "VolumeExpansionFailed"
+	ReasonRetentionReduced       status.ConditionReason = "RetentionReduced"
+	ReasonRetentionRestored      status.ConditionReason = "RetentionRestored"
+	ReasonAutoExpandLimitReached status.ConditionReason = "AutoExpandLimitReached"
+
+	// ConditionReportingValid means the reporting settings of the
+	// meterbase are valid and meter reports are scheduled.
+	ConditionReportingValid status.ConditionType = "ReportingValid"
+
+	// Reasons for reporting valid
+	ReasonReportingAccepted status.ConditionReason = "ReportingAccepted"
+	ReasonReportingInvalid  status.ConditionReason = "ReportingInvalid"
+
 	// Reasons for install
 	ReasonMeterBaseStartInstall             status.ConditionReason = "StartMeterBaseInstall"
 	ReasonMeterBasePrometheusInstall        status.ConditionReason = "StartMeterBasePrometheusInstall"
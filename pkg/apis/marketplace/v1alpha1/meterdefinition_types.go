@@ -17,6 +17,7 @@ package v1alpha1
 import (
 	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/operator-framework/operator-sdk/pkg/status"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
@@ -30,6 +31,16 @@ const (
 	MeterDefConditionTypeHasResult           status.ConditionType   = "FoundMatches"
 	MeterDefConditionReasonNoResultsInStatus status.ConditionReason = "No results in status"
 	MeterDefConditionReasonResultsInStatus   status.ConditionReason = "Results in status"
+
+	MeterDefConditionTypeReporting       status.ConditionType   = "Reporting"
+	MeterDefConditionReasonNoDataInQuery status.ConditionReason = "No data in query"
+	MeterDefConditionReasonDataInQuery   status.ConditionReason = "Data in query"
+	MeterDefConditionReasonQueryErrored  status.ConditionReason = "Query errored"
+
+	MeterDefConditionTypeSeriesLimited         status.ConditionType   = "SeriesLimited"
+	MeterDefConditionReasonMeterDefSeriesLimit status.ConditionReason = "Meter definition series limit exceeded"
+	MeterDefConditionReasonGlobalSeriesLimit   status.ConditionReason = "Global series limit exceeded"
+	MeterDefConditionReasonWithinSeriesLimits  status.ConditionReason = "Within series limits"
 )
 
 var (
@@ -45,6 +56,42 @@ var (
 		Reason:  MeterDefConditionReasonResultsInStatus,
 		Message: "Meter definition has results.",
 	}
+	MeterDefConditionNoDataInQuery = status.Condition{
+		Type:    MeterDefConditionTypeReporting,
+		Status:  corev1.ConditionFalse,
+		Reason:  MeterDefConditionReasonNoDataInQuery,
+		Message: "Meter definition queries returned no data.",
+	}
+	MeterDefConditionDataInQuery = status.Condition{
+		Type:    MeterDefConditionTypeReporting,
+		Status:  corev1.ConditionTrue,
+		Reason:  MeterDefConditionReasonDataInQuery,
+		Message: "Meter definition queries returned data.",
+	}
+	MeterDefConditionQueryErrored = status.Condition{
+		Type:    MeterDefConditionTypeReporting,
+		Status:  corev1.ConditionFalse,
+		Reason:  MeterDefConditionReasonQueryErrored,
+		Message: "Meter definition queries failed.",
+	}
+	MeterDefConditionMeterDefSeriesLimitExceeded = status.Condition{
+		Type:    MeterDefConditionTypeSeriesLimited,
+		Status:  corev1.ConditionTrue,
+		Reason:  MeterDefConditionReasonMeterDefSeriesLimit,
+		Message: "Meter definition exceeds its series limit, series are dropped.",
+	}
+	MeterDefConditionGlobalSeriesLimitExceeded = status.Condition{
+		Type:    MeterDefConditionTypeSeriesLimited,
+		Status:  corev1.ConditionTrue,
+		Reason:  MeterDefConditionReasonGlobalSeriesLimit,
+		Message: "Metric-state exceeds its global series limit, series are dropped.",
+	}
+	MeterDefConditionWithinSeriesLimits = status.Condition{
+		Type:    MeterDefConditionTypeSeriesLimited,
+		Status:  corev1.ConditionFalse,
+		Reason:  MeterDefConditionReasonWithinSeriesLimits,
+		Message: "Meter definition is within its series limits.",
+	}
 )
 
 // MeterDefinitionSpec defines the desired metering spec
@@ -86,12 +133,28 @@ type MeterDefinitionSpec struct {
 	// +kubebuilder:validation:MinItems=1
 	Workloads []Workload `json:"workloads,omitempty"`
 
-	// Version defines the primary CRD version of the meter. This field is no longer used.
+	// Version defines the version of the meter. It is recorded on each revision
+	// of the meter definition and reported with the metrics. If omitted, the
+	// revision number is used.
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:hidden"
 	// +optional
 	Version *string `json:"meterVersion,omitempty"`
 
+	// EffectiveFrom is the time this version of the meter definition starts
+	// being used for reporting. If omitted, it is effective as soon as it
+	// is applied.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	EffectiveFrom *metav1.Time `json:"effectiveFrom,omitempty"`
+
+	// EffectiveTo is the time this version of the meter definition stops
+	// being used for reporting. If omitted, it is effective until it is
+	// changed or deleted.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	EffectiveTo *metav1.Time `json:"effectiveTo,omitempty"`
+
 	// ServiceMeterLabels name of the meterics you want to track. Use workloads instead.
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:hidden"
@@ -172,9 +235,25 @@ type ByAlphabetical []WorkloadResource
 func (a ByAlphabetical) Len() int      { return len(a) }
 func (a ByAlphabetical) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 func (a ByAlphabetical) Less(i, j int) bool {
-	return strings.Compare(a[i].ReferencedWorkloadName, a[j].ReferencedWorkloadName) > 0 &&
-		strings.Compare(a[i].NamespacedNameReference.Namespace, a[j].NamespacedNameReference.Namespace) > 0 &&
-		strings.Compare(a[i].NamespacedNameReference.Name, a[j].NamespacedNameReference.Name) > 0
+	return a[i].Compare(a[j]) < 0
+}
+
+// Compare orders workload resources by workload name, namespace, name
+// and uid.
+func (w WorkloadResource) Compare(o WorkloadResource) int {
+	if c := strings.Compare(w.ReferencedWorkloadName, o.ReferencedWorkloadName); c != 0 {
+		return c
+	}
+
+	if c := strings.Compare(w.Namespace, o.Namespace); c != 0 {
+		return c
+	}
+
+	if c := strings.Compare(w.Name, o.Name); c != 0 {
+		return c
+	}
+
+	return strings.Compare(string(w.UID), string(o.UID))
 }
 
 func NewWorkloadResource(workload Workload, obj interface{}, scheme *runtime.Scheme) (*WorkloadResource, error) {
@@ -199,14 +278,45 @@ func NewWorkloadResource(workload Workload, obj interface{}, scheme *runtime.Sch
 	}, nil
 }
 
+// WorkloadResourceCount is the number of resources discovered for a
+// workload.
+type WorkloadResourceCount struct {
+	// Name of the workload.
+	Name string `json:"name"`
+
+	// Count of the resources discovered.
+	Count int `json:"count"`
+}
+
+// WorkloadShardStatus is the number of resources discovered by a
+// metric-state shard.
+type WorkloadShardStatus struct {
+	// Shard is the metric-state shard.
+	Shard int32 `json:"shard"`
+
+	// Counts of the resources discovered for each workload.
+	// +optional
+	Counts []WorkloadResourceCount `json:"counts,omitempty"`
+
+	// Total of the resources discovered.
+	Total int `json:"total"`
+}
+
 // WorkloadStatus provides quick status to check if
 // workloads are working correctly
 type WorkloadStatus struct {
 	// Name of the workload, must be unique in a meter definition.
 	Name string `json:"name"`
 
+	// MetricLabel is the label of the metric that was queried.
+	// +optional
+	MetricLabel string `json:"metricLabel,omitempty"`
+
+	// CurrentMetricValue is the value returned by the last
+	// instant query of the metric.
 	CurrentMetricValue string `json:"currentValue"`
 
+	// LastReadTime is the time the value was read from prometheus.
 	LastReadTime metav1.Time `json:"startTime"`
 }
 
@@ -239,10 +349,82 @@ type MeterDefinitionStatus struct {
 	// +optional
 	Conditions status.Conditions `json:"conditions,omitempty"`
 
-	// WorkloadResources is the list of resoruces discovered by
-	// this meter definition
+	// WorkloadResources is a sample of the resources discovered by
+	// this meter definition. The full list is paged from the
+	// metric-state workloads API.
 	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
 	WorkloadResources []WorkloadResource `json:"workloadResource,omitempty"`
+
+	// WorkloadResourceCounts are the number of resources discovered
+	// for each workload.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	WorkloadResourceCounts []WorkloadResourceCount `json:"workloadResourceCounts,omitempty"`
+
+	// TotalWorkloadResources is the number of resources discovered
+	// by this meter definition.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	TotalWorkloadResources int `json:"totalWorkloadResources,omitempty"`
+
+	// WorkloadShards are the resources discovered by each metric-state
+	// shard when metric-state is sharded. The counts and total above
+	// are the sum of the shards.
+	// +optional
+	WorkloadShards []WorkloadShardStatus `json:"workloadShards,omitempty"`
+
+	// ResolvedNamespaces are the namespaces workloads are matched in,
+	// resolved from the workload vertex. "*" means all namespaces.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	ResolvedNamespaces []string `json:"resolvedNamespaces,omitempty"`
+
+	// Results is the latest value read from prometheus for each of
+	// the workload metrics.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	Results []WorkloadStatus `json:"results,omitempty"`
+
+	// Revisions is the history of the meter definition used for
+	// reporting, oldest first.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	Revisions []MeterDefinitionRevision `json:"revisions,omitempty"`
+}
+
+// MeterDefinitionRevision is a version of the meter definition and the
+// time range it is used for reporting.
+type MeterDefinitionRevision struct {
+	// Revision is the generation of the meter definition.
+	Revision int64 `json:"revision"`
+
+	// Version is the meter version reported with the metrics.
+	Version string `json:"version"`
+
+	// EffectiveFrom is the time this revision started being used.
+	EffectiveFrom metav1.Time `json:"effectiveFrom"`
+
+	// EffectiveTo is the time this revision stopped being used.
+	// +optional
+	EffectiveTo *metav1.Time `json:"effectiveTo,omitempty"`
+
+	// Group defines the operator group of the meter
+	Group string `json:"meterGroup"`
+
+	// Kind defines the primary CRD kind of the meter
+	Kind string `json:"meterKind"`
+
+	// Workloads identify the workloads to meter.
+	Workloads []Workload `json:"workloads,omitempty"`
+}
+
+// IsEffectiveAt returns true if the revision is used at time t.
+func (r *MeterDefinitionRevision) IsEffectiveAt(t time.Time) bool {
+	if t.Before(r.EffectiveFrom.Time) {
+		return false
+	}
+
+	return r.EffectiveTo == nil || t.Before(r.EffectiveTo.Time)
 }
 
 // MeterDefinition defines the meter workloads used to enable pay for
@@ -15,6 +15,8 @@
 package v1alpha1
 
 import (
+	"fmt"
+
 	"github.com/operator-framework/operator-sdk/pkg/status"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
 	corev1 "k8s.io/api/core/v1"
@@ -42,6 +44,44 @@ type MeterReportSpec struct {
 	// +optional
 	MeterDefinitions []MeterDefinition `json:"meterDefinitions,omitempty"`
 
+	// Delay after EndTime before the report job is started, so late
+	// samples are included.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
+
+	// Correction is set when the report re-runs a period that may already
+	// have been reported, for example by a backfill.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Correction *ReportCorrection `json:"correction,omitempty"`
+
+	// RerunGeneration re-runs the report when it's increased, even if the
+	// report already finished or ran out of attempts. A running job is
+	// cancelled.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	RerunGeneration int64 `json:"rerunGeneration,omitempty"`
+
+	// Suspend cancels the running job and doesn't start new ones until
+	// it's unset.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// MaxAttempts is the number of failed jobs after which the report
+	// isn't retried until the next rerun. Default is no limit.
+	// +kubebuilder:validation:Minimum=1
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	MaxAttempts *int32 `json:"maxAttempts,omitempty"`
+
+	// RetryBackoff is the wait before retrying a failed job. It doubles
+	// after each failed attempt, up to 24h. Default is 1h.
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +optional
+	RetryBackoff *metav1.Duration `json:"retryBackoff,omitempty"`
+
 	// ExtraArgs is a set of arguments to pass to the job
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="hidden"
@@ -49,6 +89,12 @@ type MeterReportSpec struct {
 	ExtraArgs []string `json:"extraJobArgs,omitempty"`
 }
 
+// ReportCorrection identifies what requested a report to be re-run.
+type ReportCorrection struct {
+	// BackfillID is the ID of the backfill that re-ran the report.
+	BackfillID string `json:"backfillID"`
+}
+
 // MeterReportStatus defines the observed state of MeterReport
 type MeterReportStatus struct {
 	// Conditions represent the latest available observations of an object's stateonfig
@@ -76,15 +122,66 @@ type MeterReportStatus struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
 	// +optional
 	QueryErrorList []string `json:"queryErrorList,omitempty"`
+
+	// ObservedRerunGeneration is the last rerun generation handled.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	ObservedRerunGeneration int64 `json:"observedRerunGeneration,omitempty"`
+
+	// FailedAttempts is the number of failed jobs since the last rerun.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+
+	// AttemptHistory is the outcome of the latest jobs, oldest first.
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +optional
+	AttemptHistory []ReportAttempt `json:"attemptHistory,omitempty"`
 }
 
+// ReportAttemptResult is the outcome of a report job.
+type ReportAttemptResult string
+
 const (
-	ReportConditionTypeJobRunning      status.ConditionType   = "JobRunning"
-	ReportConditionReasonJobSubmitted  status.ConditionReason = "Submitted"
-	ReportConditionReasonJobNotStarted status.ConditionReason = "NotStarted"
-	ReportConditionReasonJobWaiting    status.ConditionReason = "Waiting"
-	ReportConditionReasonJobFinished   status.ConditionReason = "Finished"
-	ReportConditionReasonJobErrored    status.ConditionReason = "Errored"
+	ReportAttemptRunning   ReportAttemptResult = "Running"
+	ReportAttemptSucceeded ReportAttemptResult = "Succeeded"
+	ReportAttemptFailed    ReportAttemptResult = "Failed"
+	ReportAttemptCancelled ReportAttemptResult = "Cancelled"
+)
+
+// ReportAttempt is a job run of the report.
+type ReportAttempt struct {
+	// JobName is the name of the job.
+	JobName string `json:"jobName"`
+
+	// RerunGeneration the job ran for.
+	// +optional
+	RerunGeneration int64 `json:"rerunGeneration,omitempty"`
+
+	// StartTime is when the job was created.
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is when the job was done or cancelled.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Result of the job.
+	Result ReportAttemptResult `json:"result"`
+}
+
+const (
+	ReportConditionTypeJobRunning       status.ConditionType   = "JobRunning"
+	ReportConditionReasonJobSubmitted   status.ConditionReason = "Submitted"
+	ReportConditionReasonJobNotStarted  status.ConditionReason = "NotStarted"
+	ReportConditionReasonJobWaiting     status.ConditionReason = "Waiting"
+	ReportConditionReasonJobFinished    status.ConditionReason = "Finished"
+	ReportConditionReasonJobErrored     status.ConditionReason = "Errored"
+	ReportConditionReasonJobSuspended   status.ConditionReason = "Suspended"
+	ReportConditionReasonJobMaxAttempts status.ConditionReason = "MaxAttemptsReached"
+
+	ReportConditionTypeUploaded         status.ConditionType   = "Uploaded"
+	ReportConditionReasonUploadFinished status.ConditionReason = "UploadFinished"
+	ReportConditionReasonUploadFailed   status.ConditionReason = "UploadFailed"
 )
 
 var (
@@ -118,6 +215,30 @@ var (
 		Reason:  ReportConditionReasonJobErrored,
 		Message: "Job has errored",
 	}
+	ReportConditionJobSuspended = status.Condition{
+		Type:    ReportConditionTypeJobRunning,
+		Status:  corev1.ConditionFalse,
+		Reason:  ReportConditionReasonJobSuspended,
+		Message: "Report is suspended",
+	}
+	ReportConditionJobMaxAttempts = status.Condition{
+		Type:    ReportConditionTypeJobRunning,
+		Status:  corev1.ConditionFalse,
+		Reason:  ReportConditionReasonJobMaxAttempts,
+		Message: "Job has failed the maximum number of attempts",
+	}
+	ReportConditionUploadFinished = status.Condition{
+		Type:    ReportConditionTypeUploaded,
+		Status:  corev1.ConditionTrue,
+		Reason:  ReportConditionReasonUploadFinished,
+		Message: "Report has been uploaded",
+	}
+	ReportConditionUploadFailed = status.Condition{
+		Type:    ReportConditionTypeUploaded,
+		Status:  corev1.ConditionFalse,
+		Reason:  ReportConditionReasonUploadFailed,
+		Message: "Report upload has failed",
+	}
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -143,6 +264,22 @@ type MeterReportList struct {
 	Items           []MeterReport `json:"items"`
 }
 
+// GetJobName returns the name of the report job. Corrections and reruns
+// get their own job so the job of an earlier run isn't reused.
+func (r *MeterReport) GetJobName() string {
+	name := r.Name
+
+	if r.Spec.Correction != nil && r.Spec.Correction.BackfillID != "" {
+		name = name + "-" + r.Spec.Correction.BackfillID
+	}
+
+	if r.Spec.RerunGeneration > 0 {
+		name = fmt.Sprintf("%s-%d", name, r.Spec.RerunGeneration)
+	}
+
+	return name
+}
+
 func init() {
 	SchemeBuilder.Register(&MeterReport{}, &MeterReportList{})
 }
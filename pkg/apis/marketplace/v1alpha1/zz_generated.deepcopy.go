@@ -49,6 +49,47 @@ func (in *AccesKeyIDRef) DeepCopy() *AccesKeyIDRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertsSpec) DeepCopyInto(out *AlertsSpec) {
+	*out = *in
+	if in.MetricStateDownFor != nil {
+		in, out := &in.MetricStateDownFor, &out.MetricStateDownFor
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.SeriesAbsentFor != nil {
+		in, out := &in.SeriesAbsentFor, &out.SeriesAbsentFor
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ReportErroredFor != nil {
+		in, out := &in.ReportErroredFor, &out.ReportErroredFor
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.UploadFailingFor != nil {
+		in, out := &in.UploadFailingFor, &out.UploadFailingFor
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.StorageUsedPercent != nil {
+		in, out := &in.StorageUsedPercent, &out.StorageUsedPercent
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertsSpec.
+func (in *AlertsSpec) DeepCopy() *AlertsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Auth) DeepCopyInto(out *Auth) {
 	*out = *in
@@ -75,6 +116,53 @@ func (in *Auth) DeepCopy() *Auth {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackfillSpec) DeepCopyInto(out *BackfillSpec) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.EndTime.DeepCopyInto(&out.EndTime)
+	if in.MaxConcurrentJobs != nil {
+		in, out := &in.MaxConcurrentJobs, &out.MaxConcurrentJobs
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackfillSpec.
+func (in *BackfillSpec) DeepCopy() *BackfillSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackfillSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackfillStatus) DeepCopyInto(out *BackfillStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackfillStatus.
+func (in *BackfillStatus) DeepCopy() *BackfillStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackfillStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in ByAlphabetical) DeepCopyInto(out *ByAlphabetical) {
 	{
@@ -140,6 +228,30 @@ func (in Header) DeepCopy() Header {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalPrometheusSpec) DeepCopyInto(out *ExternalPrometheusSpec) {
+	*out = *in
+	in.Service.DeepCopyInto(&out.Service)
+	if in.ServiceMonitorLabels != nil {
+		in, out := &in.ServiceMonitorLabels, &out.ServiceMonitorLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalPrometheusSpec.
+func (in *ExternalPrometheusSpec) DeepCopy() *ExternalPrometheusSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalPrometheusSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Hmac) DeepCopyInto(out *Hmac) {
 	*out = *in
@@ -197,6 +309,39 @@ func (in Log) DeepCopy() Log {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LongTermStorageSpec) DeepCopyInto(out *LongTermStorageSpec) {
+	*out = *in
+	if in.RemoteWrite != nil {
+		in, out := &in.RemoteWrite, &out.RemoteWrite
+		*out = make([]RemoteWriteSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Thanos != nil {
+		in, out := &in.Thanos, &out.Thanos
+		*out = new(ThanosSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.QueryService != nil {
+		in, out := &in.QueryService, &out.QueryService
+		*out = new(common.ServiceReference)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LongTermStorageSpec.
+func (in *LongTermStorageSpec) DeepCopy() *LongTermStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LongTermStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MarketplaceConfig) DeepCopyInto(out *MarketplaceConfig) {
 	*out = *in
@@ -408,6 +553,26 @@ func (in *MeterBaseSpec) DeepCopyInto(out *MeterBaseSpec) {
 		*out = new(v1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Reporting != nil {
+		in, out := &in.Reporting, &out.Reporting
+		*out = new(ReportingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LongTermStorage != nil {
+		in, out := &in.LongTermStorage, &out.LongTermStorage
+		*out = new(LongTermStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExternalPrometheus != nil {
+		in, out := &in.ExternalPrometheus, &out.ExternalPrometheus
+		*out = new(ExternalPrometheusSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Alerts != nil {
+		in, out := &in.Alerts, &out.Alerts
+		*out = new(AlertsSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -460,6 +625,21 @@ func (in *MeterBaseStatus) DeepCopyInto(out *MeterBaseStatus) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.Backfill != nil {
+		in, out := &in.Backfill, &out.Backfill
+		*out = new(BackfillStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrometheusHealth != nil {
+		in, out := &in.PrometheusHealth, &out.PrometheusHealth
+		*out = new(PrometheusHealthStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(StorageStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -534,6 +714,34 @@ func (in *MeterDefinitionList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MeterDefinitionRevision) DeepCopyInto(out *MeterDefinitionRevision) {
+	*out = *in
+	in.EffectiveFrom.DeepCopyInto(&out.EffectiveFrom)
+	if in.EffectiveTo != nil {
+		in, out := &in.EffectiveTo, &out.EffectiveTo
+		*out = (*in).DeepCopy()
+	}
+	if in.Workloads != nil {
+		in, out := &in.Workloads, &out.Workloads
+		*out = make([]Workload, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeterDefinitionRevision.
+func (in *MeterDefinitionRevision) DeepCopy() *MeterDefinitionRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(MeterDefinitionRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MeterDefinitionSpec) DeepCopyInto(out *MeterDefinitionSpec) {
 	*out = *in
@@ -559,6 +767,14 @@ func (in *MeterDefinitionSpec) DeepCopyInto(out *MeterDefinitionSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.EffectiveFrom != nil {
+		in, out := &in.EffectiveFrom, &out.EffectiveFrom
+		*out = (*in).DeepCopy()
+	}
+	if in.EffectiveTo != nil {
+		in, out := &in.EffectiveTo, &out.EffectiveTo
+		*out = (*in).DeepCopy()
+	}
 	if in.ServiceMeterLabels != nil {
 		in, out := &in.ServiceMeterLabels, &out.ServiceMeterLabels
 		*out = make([]string, len(*in))
@@ -599,6 +815,37 @@ func (in *MeterDefinitionStatus) DeepCopyInto(out *MeterDefinitionStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.WorkloadResourceCounts != nil {
+		in, out := &in.WorkloadResourceCounts, &out.WorkloadResourceCounts
+		*out = make([]WorkloadResourceCount, len(*in))
+		copy(*out, *in)
+	}
+	if in.WorkloadShards != nil {
+		in, out := &in.WorkloadShards, &out.WorkloadShards
+		*out = make([]WorkloadShardStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ResolvedNamespaces != nil {
+		in, out := &in.ResolvedNamespaces, &out.ResolvedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Results != nil {
+		in, out := &in.Results, &out.Results
+		*out = make([]WorkloadStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]MeterDefinitionRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -706,6 +953,31 @@ func (in *MeterReportSpec) DeepCopyInto(out *MeterReportSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Delay != nil {
+		in, out := &in.Delay, &out.Delay
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Correction != nil {
+		in, out := &in.Correction, &out.Correction
+		*out = new(ReportCorrection)
+		**out = **in
+	}
+	if in.MaxAttempts != nil {
+		in, out := &in.MaxAttempts, &out.MaxAttempts
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RetryBackoff != nil {
+		in, out := &in.RetryBackoff, &out.RetryBackoff
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ExtraArgs != nil {
+		in, out := &in.ExtraArgs, &out.ExtraArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -753,6 +1025,13 @@ func (in *MeterReportStatus) DeepCopyInto(out *MeterReportStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AttemptHistory != nil {
+		in, out := &in.AttemptHistory, &out.AttemptHistory
+		*out = make([]ReportAttempt, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -799,6 +1078,47 @@ func (in *Options) DeepCopy() *Options {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusHealthStatus) DeepCopyInto(out *PrometheusHealthStatus) {
+	*out = *in
+	if in.DiskUsage != nil {
+		in, out := &in.DiskUsage, &out.DiskUsage
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.DiskCapacity != nil {
+		in, out := &in.DiskCapacity, &out.DiskCapacity
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.DiskUsedPercent != nil {
+		in, out := &in.DiskUsedPercent, &out.DiskUsedPercent
+		*out = new(int32)
+		**out = **in
+	}
+	if in.OldestSampleTime != nil {
+		in, out := &in.OldestSampleTime, &out.OldestSampleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetHealth, len(*in))
+		copy(*out, *in)
+	}
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusHealthStatus.
+func (in *PrometheusHealthStatus) DeepCopy() *PrometheusHealthStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusHealthStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 	*out = *in
@@ -1171,6 +1491,105 @@ func (in *RemoteResourceS3Status) DeepCopy() *RemoteResourceS3Status {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteWriteSpec) DeepCopyInto(out *RemoteWriteSpec) {
+	*out = *in
+	if in.BasicAuth != nil {
+		in, out := &in.BasicAuth, &out.BasicAuth
+		*out = new(monitoringv1.BasicAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BearerTokenSecret != nil {
+		in, out := &in.BearerTokenSecret, &out.BearerTokenSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.QueueConfig != nil {
+		in, out := &in.QueueConfig, &out.QueueConfig
+		*out = new(monitoringv1.QueueConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteWriteSpec.
+func (in *RemoteWriteSpec) DeepCopy() *RemoteWriteSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteWriteSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportAttempt) DeepCopyInto(out *ReportAttempt) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportAttempt.
+func (in *ReportAttempt) DeepCopy() *ReportAttempt {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportAttempt)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportCorrection) DeepCopyInto(out *ReportCorrection) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportCorrection.
+func (in *ReportCorrection) DeepCopy() *ReportCorrection {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportCorrection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportingSpec) DeepCopyInto(out *ReportingSpec) {
+	*out = *in
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Delay != nil {
+		in, out := &in.Delay, &out.Delay
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Backfill != nil {
+		in, out := &in.Backfill, &out.Backfill
+		*out = new(BackfillSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportingSpec.
+func (in *ReportingSpec) DeepCopy() *ReportingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Request) DeepCopyInto(out *Request) {
 	*out = *in
@@ -1205,6 +1624,38 @@ func (in *SecretAccessKeyRef) DeepCopy() *SecretAccessKeyRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageAutoExpandSpec) DeepCopyInto(out *StorageAutoExpandSpec) {
+	*out = *in
+	out.MaxSize = in.MaxSize.DeepCopy()
+	if in.Step != nil {
+		in, out := &in.Step, &out.Step
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.UsedPercent != nil {
+		in, out := &in.UsedPercent, &out.UsedPercent
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MinRetention != nil {
+		in, out := &in.MinRetention, &out.MinRetention
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageAutoExpandSpec.
+func (in *StorageAutoExpandSpec) DeepCopy() *StorageAutoExpandSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageAutoExpandSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StorageSpec) DeepCopyInto(out *StorageSpec) {
 	*out = *in
@@ -1219,6 +1670,11 @@ func (in *StorageSpec) DeepCopyInto(out *StorageSpec) {
 		*out = new(v1.EmptyDirVolumeSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AutoExpand != nil {
+		in, out := &in.AutoExpand, &out.AutoExpand
+		*out = new(StorageAutoExpandSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -1232,6 +1688,65 @@ func (in *StorageSpec) DeepCopy() *StorageSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageStatus) DeepCopyInto(out *StorageStatus) {
+	*out = *in
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.LastActionTime != nil {
+		in, out := &in.LastActionTime, &out.LastActionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageStatus.
+func (in *StorageStatus) DeepCopy() *StorageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetHealth) DeepCopyInto(out *TargetHealth) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetHealth.
+func (in *TargetHealth) DeepCopy() *TargetHealth {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetHealth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThanosSpec) DeepCopyInto(out *ThanosSpec) {
+	*out = *in
+	in.ObjectStorageConfig.DeepCopyInto(&out.ObjectStorageConfig)
+	in.Resources.DeepCopyInto(&out.Resources)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThanosSpec.
+func (in *ThanosSpec) DeepCopy() *ThanosSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ThanosSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValueFrom) DeepCopyInto(out *ValueFrom) {
 	*out = *in
@@ -1302,6 +1817,43 @@ func (in *WorkloadResource) DeepCopy() *WorkloadResource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadResourceCount) DeepCopyInto(out *WorkloadResourceCount) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadResourceCount.
+func (in *WorkloadResourceCount) DeepCopy() *WorkloadResourceCount {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadResourceCount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadShardStatus) DeepCopyInto(out *WorkloadShardStatus) {
+	*out = *in
+	if in.Counts != nil {
+		in, out := &in.Counts, &out.Counts
+		*out = make([]WorkloadResourceCount, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadShardStatus.
+func (in *WorkloadShardStatus) DeepCopy() *WorkloadShardStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadShardStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkloadStatus) DeepCopyInto(out *WorkloadStatus) {
 	*out = *in
@@ -17,26 +17,165 @@ package client
 import (
 	"context"
 	"strings"
+	"sync"
+	"time"
 
 	"emperror.dev/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 )
 
+const (
+	// DefaultOwnerMaxDepth is the default number of owners walked up
+	// the controller owner chain. The controller of the object itself
+	// counts as the first owner, so the default matches up to four
+	// levels above it.
+	DefaultOwnerMaxDepth = 5
+
+	// DefaultOwnerCacheTTL is the default time an owner is cached for.
+	DefaultOwnerCacheTTL = 5 * time.Minute
+)
+
+type ownerCacheEntry struct {
+	owner   *metav1.OwnerReference
+	expires time.Time
+}
+
+// FindOwnerHelper finds the controller owners of objects. The controller
+// of each owner is cached by the owner UID until it expires or is
+// invalidated.
 type FindOwnerHelper struct {
 	client *DynamicClient
+
+	maxDepth int
+	ttl      time.Duration
+
+	mutex     sync.Mutex
+	cache     map[types.UID]ownerCacheEntry
+	nextPrune time.Time
+
+	hits    prometheus.Counter
+	misses  prometheus.Counter
+	entries prometheus.GaugeFunc
 }
 
 func NewFindOwnerHelper(
 	dynamicClient *DynamicClient,
 ) *FindOwnerHelper {
-	return &FindOwnerHelper{
-		client: dynamicClient,
+	f := &FindOwnerHelper{
+		client:   dynamicClient,
+		maxDepth: DefaultOwnerMaxDepth,
+		ttl:      DefaultOwnerCacheTTL,
+		cache:    make(map[types.UID]ownerCacheEntry),
+		hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "meterdef_owner_cache_hits_total",
+			Help: "Number of owner lookups served from the cache.",
+		}),
+		misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "meterdef_owner_cache_misses_total",
+			Help: "Number of owner lookups that required a request to the api server.",
+		}),
 	}
+
+	f.entries = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "meterdef_owner_cache_entries",
+		Help: "Number of owners in the cache.",
+	}, func() float64 {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		return float64(len(f.cache))
+	})
+
+	return f
+}
+
+// SetMaxDepth sets the number of owners walked up the owner chain.
+func (f *FindOwnerHelper) SetMaxDepth(maxDepth int) {
+	f.maxDepth = maxDepth
 }
 
+// SetCacheTTL sets how long owners are cached for.
+func (f *FindOwnerHelper) SetCacheTTL(ttl time.Duration) {
+	f.ttl = ttl
+}
+
+// Describe implements prometheus.Collector.
+func (f *FindOwnerHelper) Describe(ch chan<- *prometheus.Desc) {
+	f.hits.Describe(ch)
+	f.misses.Describe(ch)
+	f.entries.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (f *FindOwnerHelper) Collect(ch chan<- prometheus.Metric) {
+	f.hits.Collect(ch)
+	f.misses.Collect(ch)
+	f.entries.Collect(ch)
+}
+
+// Invalidate removes the cached owner of the object with the uid.
+func (f *FindOwnerHelper) Invalidate(uid types.UID) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.cache, uid)
+}
+
+// InvalidateObject removes the cached owners of the object and of the
+// objects it references as owners. It is called when the object changed
+// or was deleted.
+func (f *FindOwnerHelper) InvalidateObject(obj metav1.Object) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.cache, obj.GetUID())
+
+	for _, ref := range obj.GetOwnerReferences() {
+		delete(f.cache, ref.UID)
+	}
+}
+
+// FindOwnerMatching walks up the controller owner chain of the object and
+// returns true if match returns true for one of the owners. At most max
+// depth owners are matched, starting with the controller of the object.
+func (f *FindOwnerHelper) FindOwnerMatching(
+	obj metav1.Object,
+	match func(*metav1.OwnerReference) bool,
+) (bool, error) {
+	owner := metav1.GetControllerOf(obj)
+	namespace := obj.GetNamespace()
+
+	for i := 0; i < f.maxDepth && owner != nil; i++ {
+		if match(owner) {
+			return true, nil
+		}
+
+		var err error
+		owner, err = f.FindOwner(owner.Name, namespace, owner)
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// FindOwner returns the controller of the owner.
 func (f *FindOwnerHelper) FindOwner(name, namespace string, lookupOwner *metav1.OwnerReference) (owner *metav1.OwnerReference, err error) {
+	now := time.Now()
+
+	if owner, ok := f.getCached(lookupOwner.UID, now); ok {
+		f.hits.Inc()
+		return owner, nil
+	}
+
+	f.misses.Inc()
+
 	apiVersionSplit := strings.Split(lookupOwner.APIVersion, "/")
 	var group, version string
 
@@ -59,6 +198,10 @@ func (f *FindOwnerHelper) FindOwner(name, namespace string, lookupOwner *metav1.
 	result, err := resourceClient.Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 
 	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			f.Invalidate(lookupOwner.UID)
+		}
+
 		return nil, errors.Wrap(err, "failed to get resource")
 	}
 
@@ -68,5 +211,53 @@ func (f *FindOwnerHelper) FindOwner(name, namespace string, lookupOwner *metav1.
 	}
 
 	owner = metav1.GetControllerOf(o)
+	f.setCached(lookupOwner.UID, owner, now)
 	return owner, nil
 }
+
+func (f *FindOwnerHelper) getCached(uid types.UID, now time.Time) (*metav1.OwnerReference, bool) {
+	if uid == "" {
+		return nil, false
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	entry, ok := f.cache[uid]
+
+	if !ok {
+		return nil, false
+	}
+
+	if now.After(entry.expires) {
+		delete(f.cache, uid)
+		return nil, false
+	}
+
+	return entry.owner, true
+}
+
+func (f *FindOwnerHelper) setCached(uid types.UID, owner *metav1.OwnerReference, now time.Time) {
+	if uid == "" || f.ttl <= 0 {
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// expired entries of owners that are no longer looked up
+	// are removed once per ttl
+	if now.After(f.nextPrune) {
+		for key, entry := range f.cache {
+			if now.After(entry.expires) {
+				delete(f.cache, key)
+			}
+		}
+		f.nextPrune = now.Add(f.ttl)
+	}
+
+	f.cache[uid] = ownerCacheEntry{
+		owner:   owner,
+		expires: now.Add(f.ttl),
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("FindOwnerHelper", func() {
+	const namespace = "default"
+
+	var (
+		levelGVK = schema.GroupVersionKind{Group: "test.example.com", Version: "v1", Kind: "Level"}
+		appGVK   = schema.GroupVersionKind{Group: "test.example.com", Version: "v1", Kind: "App"}
+	)
+
+	controllerRef := func(obj *unstructured.Unstructured) metav1.OwnerReference {
+		return metav1.OwnerReference{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Name:       obj.GetName(),
+			UID:        obj.GetUID(),
+			Controller: pointer.BoolPtr(true),
+		}
+	}
+
+	newObject := func(gvk schema.GroupVersionKind, name string, owner *unstructured.Unstructured) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName(name)
+		obj.SetNamespace(namespace)
+		obj.SetUID(types.UID(name + "-uid"))
+
+		if owner != nil {
+			obj.SetOwnerReferences([]metav1.OwnerReference{controllerRef(owner)})
+		}
+
+		return obj
+	}
+
+	// chain returns the helper and a workload whose owner at the given
+	// position of its owner chain is an App
+	chain := func(position int) (*FindOwnerHelper, *unstructured.Unstructured) {
+		app := newObject(appGVK, "app", nil)
+		objs := []runtime.Object{app}
+
+		owner := app
+		for i := position - 1; i > 0; i-- {
+			owner = newObject(levelGVK, fmt.Sprintf("level-%d", i), owner)
+			objs = append(objs, owner)
+		}
+
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(levelGVK, meta.RESTScopeNamespace)
+		mapper.Add(appGVK, meta.RESTScopeNamespace)
+
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objs...)
+		helper := NewFindOwnerHelper(NewDynamicClient(dynamicClient, mapper))
+
+		return helper, newObject(levelGVK, "workload", owner)
+	}
+
+	isApp := func(owner *metav1.OwnerReference) bool {
+		return owner.Kind == appGVK.Kind
+	}
+
+	It("should match owners up to the max depth", func() {
+		helper, workload := chain(DefaultOwnerMaxDepth)
+		Expect(helper.FindOwnerMatching(workload, isApp)).To(BeTrue())
+
+		helper, workload = chain(DefaultOwnerMaxDepth + 1)
+		Expect(helper.FindOwnerMatching(workload, isApp)).To(BeFalse())
+
+		helper.SetMaxDepth(DefaultOwnerMaxDepth + 1)
+		Expect(helper.FindOwnerMatching(workload, isApp)).To(BeTrue())
+	})
+
+	It("should count cache hits and misses", func() {
+		helper, workload := chain(3)
+
+		Expect(helper.FindOwnerMatching(workload, isApp)).To(BeTrue())
+		Expect(testutil.ToFloat64(helper.hits)).To(Equal(0.0))
+		Expect(testutil.ToFloat64(helper.misses)).To(Equal(2.0))
+		Expect(testutil.ToFloat64(helper.entries)).To(Equal(2.0))
+
+		Expect(helper.FindOwnerMatching(workload, isApp)).To(BeTrue())
+		Expect(testutil.ToFloat64(helper.hits)).To(Equal(2.0))
+		Expect(testutil.ToFloat64(helper.misses)).To(Equal(2.0))
+	})
+
+	It("should invalidate the owners of changed objects", func() {
+		helper, workload := chain(3)
+
+		Expect(helper.FindOwnerMatching(workload, isApp)).To(BeTrue())
+
+		level := newObject(levelGVK, "level-2", nil)
+		helper.Invalidate(level.GetUID())
+		Expect(testutil.ToFloat64(helper.entries)).To(Equal(1.0))
+
+		helper.InvalidateObject(workload)
+		Expect(testutil.ToFloat64(helper.entries)).To(Equal(0.0))
+	})
+
+	It("should expire cached owners after the ttl", func() {
+		helper, _ := chain(1)
+		helper.SetCacheTTL(time.Minute)
+
+		now := time.Now()
+		owner := &metav1.OwnerReference{Name: "app"}
+		helper.setCached("uid", owner, now)
+
+		cached, ok := helper.getCached("uid", now.Add(30*time.Second))
+		Expect(ok).To(BeTrue())
+		Expect(cached).To(Equal(owner))
+
+		_, ok = helper.getCached("uid", now.Add(2*time.Minute))
+		Expect(ok).To(BeFalse())
+		Expect(helper.cache).To(BeEmpty())
+
+		By("pruning expired entries that are not looked up")
+		helper.setCached("a", owner, now)
+		helper.setCached("b", owner, now.Add(2*time.Minute))
+		Expect(helper.cache).To(HaveLen(1))
+		Expect(helper.cache).To(HaveKey(types.UID("b")))
+
+		By("not caching when the ttl is 0")
+		helper.SetCacheTTL(0)
+		helper.setCached("c", owner, now)
+		Expect(helper.cache).NotTo(HaveKey(types.UID("c")))
+	})
+})
@@ -15,6 +15,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/caarlos0/env/v6"
 )
 
@@ -22,6 +24,8 @@ import (
 type OperatorConfig struct {
 	RelatedImages
 	Features
+	ReportWorkers
+	ClusterSecrets
 }
 
 // RelatedImages stores relatedimages for the operator
@@ -35,11 +39,31 @@ type RelatedImages struct {
 	ConfigMapReloader           string `env:"RELATED_IMAGE_CONFIGMAP_RELOADER" envDefault:"registry.redhat.io/openshift4/ose-configmap-reloader:latest"`
 	PrometheusConfigMapReloader string `env:"RELATED_IMAGE_PROMETHEUS_CONFIGMAP_RELOADER" envDefault:"registry.redhat.io/openshift4/ose-prometheus-config-reloader:latest"`
 	OAuthProxy                  string `env:"RELATED_IMAGE_OAUTH_PROXY" envDefault:"registry.redhat.io/openshift4/ose-oauth-proxy:latest"`
+	Thanos                      string `env:"RELATED_IMAGE_THANOS" envDefault:"registry.redhat.io/openshift4/ose-thanos:latest"`
+	KubeStateMetrics            string `env:"RELATED_IMAGE_KUBE_STATE_METRICS" envDefault:"registry.redhat.io/openshift4/ose-kube-state-metrics:latest"`
 }
 
 // Features store feature flags
 type Features struct {
-	IBMCatalog bool `env:"FEATURE_IBMCATALOG" envDefault:"true"`
+	IBMCatalog       bool `env:"FEATURE_IBMCATALOG" envDefault:"true"`
+	InProcessReports bool `env:"FEATURE_INPROCESS_REPORTS" envDefault:"false"`
+}
+
+// ReportWorkers configures the workers that run reports in the operator
+// when InProcessReports is enabled
+type ReportWorkers struct {
+	Workers   int           `env:"REPORT_WORKERS" envDefault:"2"`
+	Timeout   time.Duration `env:"REPORT_WORKER_TIMEOUT" envDefault:"10m"`
+	CaFile    string        `env:"REPORT_WORKER_CAFILE" envDefault:"/etc/configmaps/operator-cert-ca-bundle/service-ca.crt"`
+	TokenFile string        `env:"REPORT_WORKER_TOKENFILE" envDefault:"/etc/auth-service-account/token"`
+}
+
+// ClusterSecrets name the secrets in the operator namespace that hold the
+// cluster id (key clusterID) and upload token (key token) on clusters
+// that aren't OpenShift
+type ClusterSecrets struct {
+	ClusterIDSecret string `env:"CLUSTER_ID_SECRET" envDefault:"rhm-cluster-id"`
+	UploadSecret    string `env:"UPLOAD_SECRET" envDefault:"rhm-upload-credentials"`
 }
 
 // ProvideConfig gets the config from env vars
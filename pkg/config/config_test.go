@@ -16,6 +16,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -31,6 +32,11 @@ var _ = Describe("Config", func() {
 
 			Expect(cfg.RelatedImages.Reporter).To(Equal("reporter:latest"))
 			Expect(cfg.IBMCatalog).To(BeTrue())
+			Expect(cfg.InProcessReports).To(BeFalse())
+			Expect(cfg.ReportWorkers.Workers).To(Equal(2))
+			Expect(cfg.ReportWorkers.Timeout).To(Equal(10 * time.Minute))
+			Expect(cfg.ClusterSecrets.ClusterIDSecret).To(Equal("rhm-cluster-id"))
+			Expect(cfg.ClusterSecrets.UploadSecret).To(Equal("rhm-upload-credentials"))
 		})
 	})
 
@@ -54,4 +60,27 @@ var _ = Describe("Config", func() {
 			Expect(cfg.RelatedImages.MetricState).To(Equal("foo"))
 		})
 	})
+
+	Context("with in-process reports", func() {
+		BeforeEach(func() {
+			os.Setenv("FEATURE_INPROCESS_REPORTS", "true")
+			os.Setenv("REPORT_WORKERS", "4")
+			os.Setenv("REPORT_WORKER_TIMEOUT", "5m")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("FEATURE_INPROCESS_REPORTS")
+			os.Unsetenv("REPORT_WORKERS")
+			os.Unsetenv("REPORT_WORKER_TIMEOUT")
+		})
+
+		It("should configure the workers", func() {
+			cfg, err := ProvideConfig()
+
+			Expect(err).To(Succeed())
+			Expect(cfg.Features.InProcessReports).To(BeTrue())
+			Expect(cfg.ReportWorkers.Workers).To(Equal(4))
+			Expect(cfg.ReportWorkers.Timeout).To(Equal(5 * time.Minute))
+		})
+	})
 })
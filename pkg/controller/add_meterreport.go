@@ -29,11 +29,12 @@ type MeterReportController struct {
 func ProvideMeterReportController(
 	commandRunner reconcileutils.ClientCommandRunnerProvider,
 	cfg config.OperatorConfig,
+	runnerFactory meterreport.ReportRunnerFactory,
 ) *MeterReportController {
 	return &MeterReportController{
 		baseDefinition: &baseDefinition{
 			AddFunc: func(mgr manager.Manager) error {
-				return meterreport.Add(mgr, commandRunner, cfg)
+				return meterreport.Add(mgr, commandRunner, cfg, runnerFactory)
 			},
 			FlagSetFunc: func() *pflag.FlagSet { return nil },
 		},
@@ -0,0 +1,157 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"time"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/manifests"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	defaultMetricStateDownFor = 10 * time.Minute
+	defaultSeriesAbsentFor    = 30 * time.Minute
+	defaultReportErroredFor   = 30 * time.Minute
+	defaultUploadFailingFor   = time.Hour
+	defaultStorageUsedPercent = 85
+)
+
+// alertThresholds returns the thresholds of the alerts spec, defaulting
+// the ones that aren't set.
+func alertThresholds(spec *marketplacev1alpha1.AlertsSpec) manifests.AlertThresholds {
+	thresholds := manifests.AlertThresholds{
+		MetricStateDownFor: defaultMetricStateDownFor,
+		SeriesAbsentFor:    defaultSeriesAbsentFor,
+		ReportErroredFor:   defaultReportErroredFor,
+		UploadFailingFor:   defaultUploadFailingFor,
+		StorageUsedPercent: defaultStorageUsedPercent,
+	}
+
+	if spec == nil {
+		return thresholds
+	}
+
+	durations := []struct {
+		spec  *metav1.Duration
+		field *time.Duration
+	}{
+		{spec.MetricStateDownFor, &thresholds.MetricStateDownFor},
+		{spec.SeriesAbsentFor, &thresholds.SeriesAbsentFor},
+		{spec.ReportErroredFor, &thresholds.ReportErroredFor},
+		{spec.UploadFailingFor, &thresholds.UploadFailingFor},
+	}
+
+	for _, d := range durations {
+		if d.spec != nil && d.spec.Duration > 0 {
+			*d.field = d.spec.Duration
+		}
+	}
+
+	if spec.StorageUsedPercent != nil && *spec.StorageUsedPercent > 0 {
+		thresholds.StorageUsedPercent = *spec.StorageUsedPercent
+	}
+
+	return thresholds
+}
+
+// addExternalPrometheusLabels adds the labels an external Prometheus
+// selects service monitors and rules by.
+func addExternalPrometheusLabels(
+	instance *marketplacev1alpha1.MeterBase,
+	obj metav1.Object,
+) {
+	if instance.Spec.ExternalPrometheus == nil {
+		return
+	}
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	for k, v := range instance.Spec.ExternalPrometheus.ServiceMonitorLabels {
+		labels[k] = v
+	}
+
+	obj.SetLabels(labels)
+}
+
+// reconcileAlerts installs the operator service monitor, which has the
+// meter report metrics, and the PrometheusRule with the metering alerts.
+// The rule is removed when the alerts are disabled.
+func (r *ReconcileMeterBase) reconcileAlerts(
+	instance *marketplacev1alpha1.MeterBase,
+	factory *manifests.Factory,
+) []ClientAction {
+	args := manifests.CreateOrUpdateFactoryItemArgs{
+		Owner:   instance,
+		Patcher: r.patcher,
+	}
+
+	serviceMonitor := &monitoringv1.ServiceMonitor{}
+	actions := []ClientAction{
+		manifests.CreateOrUpdateFactoryItemAction(
+			serviceMonitor,
+			func() (runtime.Object, error) {
+				sm, err := factory.OperatorServiceMonitor()
+				if err != nil {
+					return nil, err
+				}
+
+				addExternalPrometheusLabels(instance, sm)
+				return sm, nil
+			},
+			args,
+		),
+	}
+
+	newRule := func() (*monitoringv1.PrometheusRule, error) {
+		rule, err := factory.MeteringPrometheusRule(instance, alertThresholds(instance.Spec.Alerts))
+		if err != nil {
+			return nil, err
+		}
+
+		addExternalPrometheusLabels(instance, rule)
+		return rule, nil
+	}
+
+	if instance.Spec.Alerts != nil && instance.Spec.Alerts.Disabled {
+		rule, err := newRule()
+		if err != nil {
+			return []ClientAction{ReturnWithError(err)}
+		}
+
+		return append(actions,
+			HandleResult(
+				GetAction(types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name}, rule),
+				OnContinue(DeleteAction(rule))))
+	}
+
+	rule := &monitoringv1.PrometheusRule{}
+	return append(actions,
+		manifests.CreateOrUpdateFactoryItemAction(
+			rule,
+			func() (runtime.Object, error) {
+				return newRule()
+			},
+			args,
+		))
+}
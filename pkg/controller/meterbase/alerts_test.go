@@ -0,0 +1,240 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/gotidy/ptr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/manifests"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// alertTestFile is a rules unit test file in the promtool format.
+type alertTestFile struct {
+	EvaluationInterval model.Duration   `yaml:"evaluation_interval"`
+	Tests              []alertTestGroup `yaml:"tests"`
+}
+
+type alertTestGroup struct {
+	Name        string         `yaml:"name"`
+	Interval    model.Duration `yaml:"interval"`
+	InputSeries []struct {
+		Series string `yaml:"series"`
+		Values string `yaml:"values"`
+	} `yaml:"input_series"`
+	AlertRuleTests []alertRuleTest `yaml:"alert_rule_test"`
+}
+
+type alertRuleTest struct {
+	EvalTime  model.Duration `yaml:"eval_time"`
+	Alertname string         `yaml:"alertname"`
+	ExpAlerts []struct {
+		ExpLabels      map[string]string `yaml:"exp_labels"`
+		ExpAnnotations map[string]string `yaml:"exp_annotations"`
+	} `yaml:"exp_alerts"`
+}
+
+// runAlertTestGroup loads the input series of the group, evaluates the
+// rules every interval and checks the firing alerts at each eval time.
+func runAlertTestGroup(
+	rule *monitoringv1.PrometheusRule,
+	evalInterval time.Duration,
+	group alertTestGroup,
+) {
+	load := fmt.Sprintf("load %s\n", group.Interval)
+	for _, series := range group.InputSeries {
+		load = load + fmt.Sprintf("  %s %s\n", series.Series, series.Values)
+	}
+
+	test, err := promql.NewTest(GinkgoT(), load)
+	Expect(err).To(Succeed())
+	defer test.Close()
+	Expect(test.Run()).To(Succeed())
+
+	alertingRules := map[string]*rules.AlertingRule{}
+	for _, g := range rule.Spec.Groups {
+		for _, r := range g.Rules {
+			expr, err := parser.ParseExpr(r.Expr.String())
+			Expect(err).To(Succeed(), r.Alert)
+
+			hold, err := model.ParseDuration(r.For)
+			Expect(err).To(Succeed(), r.Alert)
+
+			alertingRules[r.Alert] = rules.NewAlertingRule(
+				r.Alert, expr, time.Duration(hold),
+				labels.FromMap(r.Labels), labels.FromMap(r.Annotations), nil,
+				true, kitlog.NewNopLogger())
+		}
+	}
+
+	evalTests := map[time.Duration][]alertRuleTest{}
+	maxEvalTime := time.Duration(0)
+	for _, t := range group.AlertRuleTests {
+		evalTime := time.Duration(t.EvalTime)
+		evalTests[evalTime] = append(evalTests[evalTime], t)
+
+		if evalTime > maxEvalTime {
+			maxEvalTime = evalTime
+		}
+	}
+
+	query := rules.EngineQueryFunc(test.QueryEngine(), test.Queryable())
+
+	for ts := time.Duration(0); ts <= maxEvalTime; ts = ts + evalInterval {
+		for _, r := range alertingRules {
+			_, err := r.Eval(test.Context(), time.Unix(0, 0).UTC().Add(ts), query, nil)
+			Expect(err).To(Succeed(), r.Name())
+		}
+
+		for _, t := range evalTests[ts] {
+			r, ok := alertingRules[t.Alertname]
+			Expect(ok).To(BeTrue(), "alert %s not found", t.Alertname)
+
+			type alert struct {
+				Labels, Annotations map[string]string
+			}
+
+			firing := []alert{}
+			for _, a := range r.ActiveAlerts() {
+				if a.State != rules.StateFiring {
+					continue
+				}
+
+				got := alert{Labels: a.Labels.Map()}
+				delete(got.Labels, "alertname")
+				if len(t.ExpAlerts) > 0 && t.ExpAlerts[0].ExpAnnotations != nil {
+					got.Annotations = a.Annotations.Map()
+				}
+				firing = append(firing, got)
+			}
+
+			expected := []alert{}
+			for _, exp := range t.ExpAlerts {
+				expected = append(expected, alert{Labels: exp.ExpLabels, Annotations: exp.ExpAnnotations})
+			}
+
+			Expect(firing).To(ConsistOf(expected), "%s: %s at %s", group.Name, t.Alertname, t.EvalTime)
+		}
+	}
+}
+
+var _ = Describe("Alerts", func() {
+	const namespace = "openshift-redhat-marketplace"
+
+	var (
+		instance *marketplacev1alpha1.MeterBase
+		factory  *manifests.Factory
+	)
+
+	BeforeEach(func() {
+		instance = &marketplacev1alpha1.MeterBase{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rhm-marketplaceconfig-meterbase",
+				Namespace: namespace,
+			},
+			Spec: marketplacev1alpha1.MeterBaseSpec{
+				Enabled: true,
+			},
+		}
+		factory = manifests.NewFactory(namespace, manifests.NewDefaultConfig())
+	})
+
+	It("should default the thresholds", func() {
+		Expect(alertThresholds(nil)).To(Equal(manifests.AlertThresholds{
+			MetricStateDownFor: 10 * time.Minute,
+			SeriesAbsentFor:    30 * time.Minute,
+			ReportErroredFor:   30 * time.Minute,
+			UploadFailingFor:   time.Hour,
+			StorageUsedPercent: 85,
+		}))
+
+		thresholds := alertThresholds(&marketplacev1alpha1.AlertsSpec{
+			UploadFailingFor:   &metav1.Duration{Duration: 4 * time.Hour},
+			StorageUsedPercent: ptr.Int32(95),
+		})
+		Expect(thresholds.UploadFailingFor).To(Equal(4 * time.Hour))
+		Expect(thresholds.StorageUsedPercent).To(Equal(int32(95)))
+		Expect(thresholds.MetricStateDownFor).To(Equal(10 * time.Minute))
+	})
+
+	It("should fill the thresholds in the rules", func() {
+		rule, err := factory.MeteringPrometheusRule(instance, alertThresholds(&marketplacev1alpha1.AlertsSpec{
+			MetricStateDownFor: &metav1.Duration{Duration: 90 * time.Minute},
+			StorageUsedPercent: ptr.Int32(95),
+		}))
+		Expect(err).To(Succeed())
+		Expect(rule.Namespace).To(Equal(namespace))
+		Expect(rule.Labels).To(HaveKeyWithValue("marketplace.redhat.com/metering", "true"))
+
+		rulesByAlert := map[string]*monitoringv1.Rule{}
+		for i, r := range rule.Spec.Groups[0].Rules {
+			rulesByAlert[r.Alert] = &rule.Spec.Groups[0].Rules[i]
+		}
+
+		Expect(rulesByAlert).To(HaveLen(5))
+		Expect(rulesByAlert["MeteringMetricStateDown"].For).To(Equal("90m"))
+		Expect(rulesByAlert["MeteringUploadFailing"].For).To(Equal("1h"))
+		Expect(rulesByAlert["MeteringStorageNearlyFull"].Expr.String()).To(ContainSubstring("* 100 > 95"))
+		Expect(rulesByAlert["MeteringStorageNearlyFull"].Expr.String()).To(
+			ContainSubstring(`persistentvolumeclaim=~"prometheus-rhm-marketplaceconfig-meterbase-db-prometheus-rhm-marketplaceconfig-meterbase-[0-9]+"`))
+	})
+
+	It("should leave out the storage alert for an external prometheus", func() {
+		instance.Spec.ExternalPrometheus = &marketplacev1alpha1.ExternalPrometheusSpec{
+			ServiceMonitorLabels: map[string]string{"openshift.io/user-monitoring": "true"},
+		}
+
+		rule, err := factory.MeteringPrometheusRule(instance, alertThresholds(nil))
+		Expect(err).To(Succeed())
+
+		addExternalPrometheusLabels(instance, rule)
+		Expect(rule.Labels).To(HaveKeyWithValue("openshift.io/user-monitoring", "true"))
+
+		for _, r := range rule.Spec.Groups[0].Rules {
+			Expect(r.Alert).ToNot(Equal("MeteringStorageNearlyFull"))
+		}
+	})
+
+	It("should pass the rule unit tests", func() {
+		rule, err := factory.MeteringPrometheusRule(instance, alertThresholds(nil))
+		Expect(err).To(Succeed())
+
+		content, err := ioutil.ReadFile("testdata/alerts_test.yaml")
+		Expect(err).To(Succeed())
+
+		file := alertTestFile{}
+		Expect(yaml.UnmarshalStrict(content, &file)).To(Succeed())
+		Expect(file.Tests).ToNot(BeEmpty())
+
+		for _, group := range file.Tests {
+			By(group.Name)
+			runAlertTestGroup(rule, time.Duration(file.EvaluationInterval), group)
+		}
+	})
+})
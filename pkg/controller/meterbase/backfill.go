@@ -0,0 +1,203 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	merrors "emperror.dev/errors"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const defaultBackfillConcurrentJobs = 2
+
+// activeBackfill returns the backfill of the meterbase if it hasn't
+// completed yet.
+func activeBackfill(instance *marketplacev1alpha1.MeterBase) *marketplacev1alpha1.BackfillSpec {
+	if instance.Spec.Reporting == nil || instance.Spec.Reporting.Backfill == nil {
+		return nil
+	}
+
+	backfill := instance.Spec.Reporting.Backfill
+	backfillStatus := instance.Status.Backfill
+
+	if backfillStatus != nil && backfillStatus.ID == backfill.ID && backfillStatus.CompletionTime != nil {
+		return nil
+	}
+
+	return backfill
+}
+
+// backfillStart returns the start of the backfill clamped to the first
+// report period prometheus still has all the samples of, and a message if
+// it was clamped. Reports older than the local retention of prometheus
+// can only be backfilled from a long-term store or an external prometheus.
+func backfillStart(
+	instance *marketplacev1alpha1.MeterBase,
+	backfill *marketplacev1alpha1.BackfillSpec,
+	schedule *reportSchedule,
+	retention time.Duration,
+	now time.Time,
+) (time.Time, string) {
+	start := backfill.StartTime.Time
+
+	if instance.Spec.ExternalPrometheus != nil || retention <= 0 {
+		return start, ""
+	}
+
+	if lts := instance.Spec.LongTermStorage; lts != nil && lts.QueryService != nil {
+		return start, ""
+	}
+
+	retainedSince := now.Add(-retention)
+	if !start.Before(retainedSince) {
+		return start, ""
+	}
+
+	clamped := schedule.truncate(retainedSince)
+	if clamped.Before(retainedSince) {
+		clamped = schedule.next(clamped)
+	}
+
+	return clamped, fmt.Sprintf(
+		"start time %s is before the prometheus retention of %s, backfilling from %s",
+		start.UTC().Format(time.RFC3339), retention, clamped.UTC().Format(time.RFC3339))
+}
+
+// isBackfilled returns true if the report was already re-run by the backfill.
+func isBackfilled(report *marketplacev1alpha1.MeterReport, backfillID string) bool {
+	return report.Spec.Correction != nil && report.Spec.Correction.BackfillID == backfillID
+}
+
+// reconcileBackfill creates or re-runs the reports of the backfill
+// periods, keeping at most MaxConcurrentJobs of their jobs running. It
+// returns the action to update the backfill status if it changed.
+func (r *ReconcileMeterBase) reconcileBackfill(
+	instance *marketplacev1alpha1.MeterBase,
+	backfill *marketplacev1alpha1.BackfillSpec,
+	schedule *reportSchedule,
+	reports []marketplacev1alpha1.MeterReport,
+	now time.Time,
+	request reconcile.Request,
+) (ClientAction, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name, "backfill", backfill.ID)
+
+	if !backfill.StartTime.Before(&backfill.EndTime) {
+		return nil, merrors.New("backfill start time must be before end time")
+	}
+
+	maxJobs := int32(defaultBackfillConcurrentJobs)
+	if backfill.MaxConcurrentJobs != nil && *backfill.MaxConcurrentJobs > 0 {
+		maxJobs = *backfill.MaxConcurrentJobs
+	}
+
+	byName := make(map[string]*marketplacev1alpha1.MeterReport, len(reports))
+	for i := range reports {
+		byName[reports[i].Name] = &reports[i]
+	}
+
+	retention := r.prometheusRetention(instance)
+	start, message := backfillStart(instance, backfill, schedule, retention, now)
+
+	startTime := metav1.NewTime(start)
+	backfillStatus := &marketplacev1alpha1.BackfillStatus{
+		ID:        backfill.ID,
+		StartTime: &startTime,
+		Message:   message,
+	}
+
+	// keep the stored time so the status only changes with the start
+	if old := instance.Status.Backfill; old != nil && old.StartTime != nil && old.StartTime.Equal(&startTime) {
+		backfillStatus.StartTime = old.StartTime
+	}
+
+	if message != "" {
+		reqLogger.Info("clamping backfill start", "message", message)
+	}
+
+	// nothing is left to report if the whole range is past the retention
+	if !start.Before(backfill.EndTime.Time) {
+		backfillStatus.Message = fmt.Sprintf(
+			"the backfill range ends before the prometheus retention of %s, no reports are backfilled", retention)
+	}
+
+	for _, period := range schedule.backfillPeriods(start, backfill.EndTime.Time, now) {
+		name := schedule.reportName(period)
+		report, found := byName[name]
+
+		switch {
+		case found && isBackfilled(report, backfill.ID):
+			jr := report.Status.AssociatedJob
+
+			switch {
+			case jr == nil || jr.Name != report.GetJobName() || !jr.IsDone():
+				backfillStatus.Running++
+			case jr.IsSuccessful():
+				backfillStatus.Succeeded++
+			default:
+				backfillStatus.Failed++
+			}
+		case backfillStatus.Running >= maxJobs:
+			backfillStatus.Pending++
+		case found:
+			// re-run with a new snapshot of the meter definitions, which
+			// also resets the failed attempts of the report
+			report = report.DeepCopy()
+			report.Spec.StartTime = metav1.NewTime(period.Start)
+			report.Spec.EndTime = metav1.NewTime(period.End)
+			report.Spec.PrometheusService = reportPrometheusService(instance, retention, period.Start, now)
+			report.Spec.MeterDefinitions = nil
+			report.Spec.Correction = &marketplacev1alpha1.ReportCorrection{BackfillID: backfill.ID}
+			report.Spec.RerunGeneration++
+
+			if err := r.client.Update(context.TODO(), report); err != nil {
+				return nil, err
+			}
+
+			reqLogger.Info("Re-running Report", "Resource", name)
+			backfillStatus.Running++
+		default:
+			report = r.newMeterReport(request.Namespace, period.Start, period.End, name, instance)
+			report.Spec.Delay = &metav1.Duration{Duration: schedule.delay}
+			report.Spec.Correction = &marketplacev1alpha1.ReportCorrection{BackfillID: backfill.ID}
+
+			if err := r.client.Create(context.TODO(), report); err != nil {
+				return nil, err
+			}
+
+			reqLogger.Info("Created Backfill Report", "Resource", name)
+			backfillStatus.Running++
+		}
+	}
+
+	if backfillStatus.Pending == 0 && backfillStatus.Running == 0 {
+		completionTime := metav1.NewTime(now)
+		backfillStatus.CompletionTime = &completionTime
+		reqLogger.Info("backfill complete", "succeeded", backfillStatus.Succeeded, "failed", backfillStatus.Failed)
+	}
+
+	if reflect.DeepEqual(instance.Status.Backfill, backfillStatus) {
+		return nil, nil
+	}
+
+	instance.Status.Backfill = backfillStatus
+	return UpdateAction(instance, UpdateStatusOnly(true)), nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"time"
+
+	"github.com/gotidy/ptr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Backfill", func() {
+	var (
+		scheme   *runtime.Scheme
+		ctrl     *ReconcileMeterBase
+		cc       ClientCommandRunner
+		instance *marketplacev1alpha1.MeterBase
+		schedule *reportSchedule
+		request  reconcile.Request
+
+		day = time.Date(2020, 9, 16, 0, 0, 0, 0, time.UTC)
+		now = day.Add(time.Hour)
+	)
+
+	listReports := func() map[string]marketplacev1alpha1.MeterReport {
+		list := &marketplacev1alpha1.MeterReportList{}
+		Expect(ctrl.client.List(context.TODO(), list, client.InNamespace(namespace))).To(Succeed())
+
+		reports := map[string]marketplacev1alpha1.MeterReport{}
+		for _, report := range list.Items {
+			reports[report.Name] = report
+		}
+		return reports
+	}
+
+	reconcileBackfill := func() {
+		reports := listReports()
+		items := make([]marketplacev1alpha1.MeterReport, 0, len(reports))
+		for _, report := range reports {
+			items = append(items, report)
+		}
+
+		backfill := activeBackfill(instance)
+		Expect(backfill).ToNot(BeNil())
+
+		action, err := ctrl.reconcileBackfill(instance, backfill, schedule, items, now, request)
+		Expect(err).To(Succeed())
+
+		if action != nil {
+			result, err := cc.Do(context.TODO(), action)
+			Expect(err).To(Succeed())
+			Expect(result.Is(Error)).To(BeFalse())
+		}
+	}
+
+	finishJob := func(name string, succeeded bool) {
+		report := &marketplacev1alpha1.MeterReport{}
+		Expect(ctrl.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, report)).To(Succeed())
+
+		report.Status.AssociatedJob = &common.JobReference{
+			Name:      report.GetJobName(),
+			Namespace: namespace,
+		}
+		if succeeded {
+			report.Status.AssociatedJob.Succeeded = 1
+		} else {
+			report.Status.AssociatedJob.Failed = 1
+		}
+
+		Expect(ctrl.client.Status().Update(context.TODO(), report)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		scheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(marketplacev1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+
+		instance = &marketplacev1alpha1.MeterBase{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rhm-marketplaceconfig-meterbase",
+				Namespace: namespace,
+			},
+			Spec: marketplacev1alpha1.MeterBaseSpec{
+				Reporting: &marketplacev1alpha1.ReportingSpec{
+					Backfill: &marketplacev1alpha1.BackfillSpec{
+						ID:                "fix-1",
+						StartTime:         metav1.NewTime(day.AddDate(0, 0, -3).Add(5 * time.Hour)),
+						EndTime:           metav1.NewTime(now),
+						MaxConcurrentJobs: ptr.Int32(2),
+					},
+				},
+			},
+		}
+
+		schedule, err = newReportSchedule(instance.Spec.Reporting)
+		Expect(err).To(Succeed())
+
+		existing := &marketplacev1alpha1.MeterReport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "meter-report-2020-09-14",
+				Namespace: namespace,
+			},
+			Spec: marketplacev1alpha1.MeterReportSpec{
+				StartTime: metav1.NewTime(day.AddDate(0, 0, -2)),
+				EndTime:   metav1.NewTime(day.AddDate(0, 0, -1)),
+				MeterDefinitions: []marketplacev1alpha1.MeterDefinition{
+					{ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: namespace}},
+				},
+			},
+			Status: marketplacev1alpha1.MeterReportStatus{
+				AssociatedJob: &common.JobReference{
+					Name:      "meter-report-2020-09-14",
+					Namespace: namespace,
+					Succeeded: 1,
+				},
+			},
+		}
+
+		k8sClient := fake.NewFakeClientWithScheme(scheme, instance.DeepCopy(), existing)
+		cc = NewClientCommand(k8sClient, scheme, logf.Log)
+		ctrl = &ReconcileMeterBase{client: k8sClient, scheme: scheme}
+		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: instance.Name, Namespace: namespace}}
+	})
+
+	It("should align the backfill to report periods that have ended", func() {
+		periods := schedule.backfillPeriods(
+			instance.Spec.Reporting.Backfill.StartTime.Time,
+			instance.Spec.Reporting.Backfill.EndTime.Time,
+			now)
+
+		Expect(periods).To(HaveLen(3))
+		Expect(periods[0].Start).To(Equal(day.AddDate(0, 0, -3)))
+		Expect(periods[2].End).To(Equal(day))
+	})
+
+	It("should re-run reports as corrections with a limit on jobs", func() {
+		reconcileBackfill()
+
+		reports := listReports()
+		Expect(reports).To(HaveLen(2))
+
+		created := reports["meter-report-2020-09-13"]
+		Expect(created.Spec.Correction).To(Equal(&marketplacev1alpha1.ReportCorrection{BackfillID: "fix-1"}))
+		Expect(created.GetJobName()).To(Equal("meter-report-2020-09-13-fix-1"))
+
+		rerun := reports["meter-report-2020-09-14"]
+		Expect(rerun.Spec.Correction).ToNot(BeNil())
+		Expect(rerun.Spec.MeterDefinitions).To(BeEmpty())
+		Expect(rerun.Spec.RerunGeneration).To(Equal(int64(1)))
+		Expect(rerun.GetJobName()).To(Equal("meter-report-2020-09-14-fix-1-1"))
+
+		start := instance.Spec.Reporting.Backfill.StartTime
+		Expect(instance.Status.Backfill).To(Equal(&marketplacev1alpha1.BackfillStatus{
+			ID:        "fix-1",
+			StartTime: &start,
+			Pending:   1,
+			Running:   2,
+		}))
+
+		By("finishing the running jobs")
+		finishJob("meter-report-2020-09-13", true)
+		finishJob("meter-report-2020-09-14", false)
+
+		reconcileBackfill()
+
+		reports = listReports()
+		Expect(reports).To(HaveKey("meter-report-2020-09-15"))
+		Expect(instance.Status.Backfill.Running).To(Equal(int32(1)))
+		Expect(instance.Status.Backfill.Pending).To(BeZero())
+
+		finishJob("meter-report-2020-09-15", true)
+		reconcileBackfill()
+
+		Expect(instance.Status.Backfill.Succeeded).To(Equal(int32(2)))
+		Expect(instance.Status.Backfill.Failed).To(Equal(int32(1)))
+		Expect(instance.Status.Backfill.CompletionTime).ToNot(BeNil())
+		Expect(activeBackfill(instance)).To(BeNil())
+	})
+
+	It("should clamp the start to the prometheus retention", func() {
+		backfill := instance.Spec.Reporting.Backfill
+
+		start, message := backfillStart(instance, backfill, schedule, 48*time.Hour, now)
+		Expect(start).To(Equal(day.AddDate(0, 0, -1)))
+		Expect(message).To(Equal("start time 2020-09-13T05:00:00Z is before the prometheus retention of 48h0m0s, backfilling from 2020-09-15T00:00:00Z"))
+
+		start, message = backfillStart(instance, backfill, schedule, 30*24*time.Hour, now)
+		Expect(start).To(Equal(backfill.StartTime.Time))
+		Expect(message).To(BeEmpty())
+
+		By("querying the long-term store")
+		instance.Spec.LongTermStorage = &marketplacev1alpha1.LongTermStorageSpec{
+			QueryService: &common.ServiceReference{Name: "thanos-query", Namespace: namespace},
+		}
+
+		start, message = backfillStart(instance, backfill, schedule, 48*time.Hour, now)
+		Expect(start).To(Equal(backfill.StartTime.Time))
+		Expect(message).To(BeEmpty())
+	})
+
+	It("should complete a backfill past the prometheus retention", func() {
+		instance.Spec.Reporting.Backfill.StartTime = metav1.NewTime(day.AddDate(0, -3, 0))
+		instance.Spec.Reporting.Backfill.EndTime = metav1.NewTime(day.AddDate(0, -2, 0))
+
+		reconcileBackfill()
+
+		Expect(listReports()).To(HaveLen(1))
+		Expect(instance.Status.Backfill.StartTime.Time).To(Equal(day.AddDate(0, 0, -29)))
+		Expect(instance.Status.Backfill.Message).To(ContainSubstring("no reports are backfilled"))
+		Expect(instance.Status.Backfill.CompletionTime).ToNot(BeNil())
+		Expect(activeBackfill(instance)).To(BeNil())
+	})
+
+	It("should keep backfill reports until the backfill completes", func() {
+		reconcileBackfill()
+
+		items := []marketplacev1alpha1.MeterReport{}
+		for _, report := range listReports() {
+			items = append(items, report)
+		}
+
+		// reports of a day are kept
+		schedule.retention = time.Hour
+
+		kept, err := ctrl.removeOldReports(items, schedule, now, activeBackfill(instance), request)
+		Expect(err).To(Succeed())
+		Expect(kept).To(HaveLen(2))
+
+		kept, err = ctrl.removeOldReports(items, schedule, now, nil, request)
+		Expect(err).To(Succeed())
+		Expect(kept).To(BeEmpty())
+		Expect(listReports()).To(BeEmpty())
+	})
+})
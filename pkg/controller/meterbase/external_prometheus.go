@@ -0,0 +1,213 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	defaultCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+	defaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	infoSeriesQuery   = `count by (__name__) ({__name__=~"meterdef_.+_info"})`
+	infoSeriesTimeout = 30 * time.Second
+)
+
+// infoSeriesName is the metric-state series a workload type is joined on.
+func infoSeriesName(workloadType marketplacev1alpha1.WorkloadType) string {
+	switch workloadType {
+	case marketplacev1alpha1.WorkloadTypePVC:
+		return "meterdef_persistentvolumeclaim_info"
+	case marketplacev1alpha1.WorkloadTypePod:
+		return "meterdef_pod_info"
+	case marketplacev1alpha1.WorkloadTypeService, marketplacev1alpha1.WorkloadTypeServiceMonitor:
+		return "meterdef_service_info"
+	default:
+		return ""
+	}
+}
+
+// requiredInfoSeries returns the sorted info series the meter definitions
+// need to be reported.
+func requiredInfoSeries(defs []marketplacev1alpha1.MeterDefinition) []string {
+	names := map[string]bool{}
+
+	for _, def := range defs {
+		for _, workload := range def.Spec.Workloads {
+			if name := infoSeriesName(workload.WorkloadType); name != "" {
+				names[name] = true
+			}
+		}
+	}
+
+	required := make([]string, 0, len(names))
+	for name := range names {
+		required = append(required, name)
+	}
+
+	sort.Strings(required)
+	return required
+}
+
+// seriesCondition returns the SeriesAvailable condition for the required
+// series given the series prometheus has.
+func seriesCondition(required, present []string) status.Condition {
+	has := map[string]bool{}
+	for _, name := range present {
+		has[name] = true
+	}
+
+	missing := []string{}
+	for _, name := range required {
+		if !has[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) != 0 {
+		return status.Condition{
+			Type:    marketplacev1alpha1.ConditionSeriesAvailable,
+			Status:  corev1.ConditionFalse,
+			Reason:  marketplacev1alpha1.ReasonSeriesMissing,
+			Message: fmt.Sprintf("prometheus is missing series %s, check the metric-state service monitor is selected", strings.Join(missing, ", ")),
+		}
+	}
+
+	return status.Condition{
+		Type:    marketplacev1alpha1.ConditionSeriesAvailable,
+		Status:  corev1.ConditionTrue,
+		Reason:  marketplacev1alpha1.ReasonSeriesPresent,
+		Message: "prometheus has the series required by the meter definitions",
+	}
+}
+
+// reconcileExternalPrometheus checks the external prometheus of the
+// meterbase has the info series metric-state exports and records the
+// result as the SeriesAvailable condition.
+func (r *ReconcileMeterBase) reconcileExternalPrometheus(
+	cc ClientCommandRunner,
+	instance *marketplacev1alpha1.MeterBase,
+	reqLogger logr.Logger,
+) (*ExecResult, error) {
+	defs := &marketplacev1alpha1.MeterDefinitionList{}
+
+	if result, err := cc.Do(context.TODO(), ListAction(defs, client.InNamespace(""))); !result.Is(Continue) {
+		return result, err
+	}
+
+	required := requiredInfoSeries(defs.Items)
+	cond := seriesCondition(required, nil)
+
+	if len(required) != 0 {
+		present, err := r.queryInfoSeries(instance)
+
+		if err != nil {
+			reqLogger.Error(err, "failed to query external prometheus")
+			cond = status.Condition{
+				Type:    marketplacev1alpha1.ConditionSeriesAvailable,
+				Status:  corev1.ConditionFalse,
+				Reason:  marketplacev1alpha1.ReasonSeriesQueryFailed,
+				Message: err.Error(),
+			}
+		} else {
+			cond = seriesCondition(required, present)
+		}
+	}
+
+	if instance.Status.Conditions == nil {
+		instance.Status.Conditions = &status.Conditions{}
+	}
+
+	return cc.Do(context.TODO(), UpdateStatusCondition(instance, instance.Status.Conditions, cond))
+}
+
+// queryInfoSeries returns the names of the meterdef info series in the
+// external prometheus.
+func (r *ReconcileMeterBase) queryInfoSeries(
+	instance *marketplacev1alpha1.MeterBase,
+) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), infoSeriesTimeout)
+	defer cancel()
+
+	api, err := r.prometheusAPI(ctx, instance.Namespace, &instance.Spec.ExternalPrometheus.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, err := api.Query(ctx, infoSeriesQuery, time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query prometheus")
+	}
+
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return nil, errors.Errorf("unexpected result type %s", value.Type())
+	}
+
+	present := make([]string, 0, len(vector))
+	for _, sample := range vector {
+		present = append(present, string(sample.Metric[model.MetricNameLabel]))
+	}
+
+	return present, nil
+}
+
+// prometheusAPI returns a client for the query API of the prometheus behind
+// ref. Its token and CA secrets are read from namespace.
+func (r *ReconcileMeterBase) prometheusAPI(
+	ctx context.Context,
+	namespace string,
+	ref *common.ServiceReference,
+) (v1.API, error) {
+	service := &corev1.Service{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, service); err != nil {
+		return nil, errors.Wrap(err, "failed to get prometheus service")
+	}
+
+	auth, err := prom.LoadServiceAuth(ctx, r.client, namespace, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	caFile := defaultCAFile
+	if len(auth.CAData) != 0 {
+		caFile = ""
+	}
+
+	promClient, err := prom.NewSecureClientForServiceAuth(service, ref.TargetPort, auth, caFile, defaultTokenFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return v1.NewAPI(promClient), nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"time"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/manifests"
+	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ExternalPrometheus", func() {
+	const namespace = "openshift-redhat-marketplace"
+
+	var instance *marketplacev1alpha1.MeterBase
+
+	BeforeEach(func() {
+		instance = &marketplacev1alpha1.MeterBase{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rhm-marketplaceconfig-meterbase",
+				Namespace: namespace,
+			},
+			Spec: marketplacev1alpha1.MeterBaseSpec{
+				Enabled: true,
+				ExternalPrometheus: &marketplacev1alpha1.ExternalPrometheusSpec{
+					Service: common.ServiceReference{
+						Name:       "thanos-querier",
+						Namespace:  "openshift-monitoring",
+						TargetPort: intstr.FromString("web"),
+						BearerTokenSecret: corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "prometheus-token"},
+							Key:                  "token",
+						},
+						TLSConfig: &monitoringv1.TLSConfig{
+							CA: monitoringv1.SecretOrConfigMap{
+								ConfigMap: &corev1.ConfigMapKeySelector{
+									LocalObjectReference: corev1.LocalObjectReference{Name: "prometheus-ca"},
+									Key:                  "ca.crt",
+								},
+							},
+						},
+					},
+					ServiceMonitorLabels: map[string]string{"openshift.io/user-monitoring": "true"},
+				},
+			},
+		}
+	})
+
+	It("should report against the external prometheus", func() {
+		now := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+
+		service := reportPrometheusService(instance, 30*24*time.Hour, now.Add(-24*time.Hour), now)
+		Expect(service.Name).To(Equal("thanos-querier"))
+		Expect(service.Namespace).To(Equal("openshift-monitoring"))
+
+		factory := manifests.NewFactory(namespace, manifests.NewDefaultConfig())
+		job, err := factory.ReporterJob(&marketplacev1alpha1.MeterReport{
+			ObjectMeta: metav1.ObjectMeta{Name: "meter-report-2020-10-01", Namespace: namespace},
+			Spec: marketplacev1alpha1.MeterReportSpec{
+				PrometheusService: service,
+			},
+		})
+		Expect(err).To(Succeed())
+
+		container := job.Spec.Template.Spec.Containers[0]
+		Expect(container.Args).To(ContainElements(
+			"/etc/prometheus-auth/prometheus-token/token",
+			"/etc/prometheus-auth/prometheus-ca/ca.crt",
+		))
+		Expect(container.Args).ToNot(ContainElement("/etc/auth-service-account/token"))
+		Expect(container.VolumeMounts).To(HaveLen(4))
+		Expect(job.Spec.Template.Spec.Volumes).To(HaveLen(4))
+	})
+
+	It("should read the token and ca from the meterbase namespace", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+
+		client := fake.NewFakeClientWithScheme(scheme,
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "prometheus-token", Namespace: namespace},
+				Data:       map[string][]byte{"token": []byte("abc")},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "prometheus-ca", Namespace: namespace},
+				Data:       map[string]string{"ca.crt": "pem"},
+			},
+		)
+
+		auth, err := prom.LoadServiceAuth(context.TODO(), client, namespace, &instance.Spec.ExternalPrometheus.Service)
+		Expect(err).To(Succeed())
+		Expect(auth.Token).To(Equal("abc"))
+		Expect(string(auth.CAData)).To(Equal("pem"))
+
+		_, err = prom.LoadServiceAuth(context.TODO(), client, "other", &instance.Spec.ExternalPrometheus.Service)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should require the info series of the meter definitions", func() {
+		defs := []marketplacev1alpha1.MeterDefinition{
+			{Spec: marketplacev1alpha1.MeterDefinitionSpec{
+				Workloads: []marketplacev1alpha1.Workload{
+					{WorkloadType: marketplacev1alpha1.WorkloadTypePod},
+					{WorkloadType: marketplacev1alpha1.WorkloadTypeServiceMonitor},
+				},
+			}},
+			{Spec: marketplacev1alpha1.MeterDefinitionSpec{
+				Workloads: []marketplacev1alpha1.Workload{
+					{WorkloadType: marketplacev1alpha1.WorkloadTypeService},
+				},
+			}},
+		}
+
+		required := requiredInfoSeries(defs)
+		Expect(required).To(Equal([]string{"meterdef_pod_info", "meterdef_service_info"}))
+
+		cond := seriesCondition(required, []string{"meterdef_pod_info"})
+		Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonSeriesMissing))
+		Expect(cond.Message).To(ContainSubstring("meterdef_service_info"))
+
+		cond = seriesCondition(required, []string{"meterdef_pod_info", "meterdef_service_info"})
+		Expect(cond.Status).To(Equal(corev1.ConditionTrue))
+
+		Expect(requiredInfoSeries(nil)).To(BeEmpty())
+	})
+})
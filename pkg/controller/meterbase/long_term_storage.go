@@ -0,0 +1,88 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"time"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/manifests"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// prometheusRetention returns the retention of the meterbase prometheus.
+// It's read from the Prometheus resource, falling back to the default
+// retention until the resource is created.
+func (r *ReconcileMeterBase) prometheusRetention(
+	instance *marketplacev1alpha1.MeterBase,
+) time.Duration {
+	retention := manifests.NewDefaultConfig().PrometheusConfig.Retention
+
+	prometheus := &monitoringv1.Prometheus{}
+	key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+
+	if err := r.client.Get(context.TODO(), key, prometheus); err == nil && prometheus.Spec.Retention != "" {
+		retention = prometheus.Spec.Retention
+	}
+
+	duration, err := model.ParseDuration(retention)
+	if err != nil {
+		log.Error(err, "failed to parse prometheus retention", "retention", retention)
+		return 0
+	}
+
+	return time.Duration(duration)
+}
+
+// reportPrometheusService returns the service queried by a report that
+// starts at start. Reports that start before the local retention of
+// prometheus query the long-term store of the meterbase if it has one.
+// An external prometheus is always queried.
+func reportPrometheusService(
+	instance *marketplacev1alpha1.MeterBase,
+	retention time.Duration,
+	start time.Time,
+	now time.Time,
+) *common.ServiceReference {
+	if ext := instance.Spec.ExternalPrometheus; ext != nil {
+		return ext.Service.DeepCopy()
+	}
+
+	local := &common.ServiceReference{
+		Name:       promServiceName,
+		Namespace:  instance.Namespace,
+		TargetPort: intstr.FromString("rbac"),
+	}
+
+	lts := instance.Spec.LongTermStorage
+	if lts == nil || lts.QueryService == nil {
+		return local
+	}
+
+	if retention <= 0 {
+		return local
+	}
+
+	if !start.Before(now.Add(-retention)) {
+		return local
+	}
+
+	return lts.QueryService.DeepCopy()
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"time"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/manifests"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("LongTermStorage", func() {
+	var instance *marketplacev1alpha1.MeterBase
+
+	BeforeEach(func() {
+		instance = &marketplacev1alpha1.MeterBase{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rhm-marketplaceconfig-meterbase",
+				Namespace: "openshift-redhat-marketplace",
+			},
+			Spec: marketplacev1alpha1.MeterBaseSpec{
+				Enabled: true,
+				Prometheus: &marketplacev1alpha1.PrometheusSpec{
+					Storage: marketplacev1alpha1.StorageSpec{
+						Size: resource.MustParse("30Gi"),
+					},
+				},
+				LongTermStorage: &marketplacev1alpha1.LongTermStorageSpec{
+					RemoteWrite: []marketplacev1alpha1.RemoteWriteSpec{
+						{
+							Name: "archive",
+							URL:  "https://archive.example.com/api/v1/write",
+							BearerTokenSecret: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "archive-token"},
+								Key:                  "token",
+							},
+							QueueConfig: &monitoringv1.QueueConfig{MaxShards: 10},
+						},
+					},
+					Thanos: &marketplacev1alpha1.ThanosSpec{
+						ObjectStorageConfig: corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "thanos-objstore"},
+							Key:                  "objstore.yaml",
+						},
+					},
+					QueryService: &common.ServiceReference{
+						Name:       "thanos-querier",
+						Namespace:  "openshift-redhat-marketplace",
+						TargetPort: intstr.FromString("https"),
+					},
+				},
+			},
+		}
+	})
+
+	It("should render remote write and the thanos sidecar", func() {
+		factory := manifests.NewFactory(instance.Namespace, manifests.NewDefaultConfig())
+		prom, err := factory.NewPrometheusDeployment(instance, nil)
+		Expect(err).To(Succeed())
+
+		Expect(prom.Spec.RemoteWrite).To(HaveLen(1))
+		Expect(prom.Spec.RemoteWrite[0].URL).To(Equal("https://archive.example.com/api/v1/write"))
+		Expect(prom.Spec.RemoteWrite[0].BearerTokenFile).To(Equal("/etc/prometheus/secrets/archive-token/token"))
+		Expect(prom.Spec.RemoteWrite[0].QueueConfig.MaxShards).To(Equal(10))
+		Expect(prom.Spec.Secrets).To(ContainElement("archive-token"))
+
+		Expect(prom.Spec.Thanos).ToNot(BeNil())
+		Expect(prom.Spec.Thanos.ObjectStorageConfig.Name).To(Equal("thanos-objstore"))
+		Expect(*prom.Spec.Thanos.Image).ToNot(BeEmpty())
+	})
+
+	It("should query the long-term store for periods past the local retention", func() {
+		now := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+		retention := 30 * 24 * time.Hour
+
+		service := reportPrometheusService(instance, retention, now.Add(-24*time.Hour), now)
+		Expect(service.Name).To(Equal(promServiceName))
+
+		service = reportPrometheusService(instance, retention, now.Add(-45*24*time.Hour), now)
+		Expect(service.Name).To(Equal("thanos-querier"))
+
+		service = reportPrometheusService(instance, 60*24*time.Hour, now.Add(-45*24*time.Hour), now)
+		Expect(service.Name).To(Equal(promServiceName))
+
+		instance.Spec.LongTermStorage.QueryService = nil
+		service = reportPrometheusService(instance, retention, now.Add(-45*24*time.Hour), now)
+		Expect(service.Name).To(Equal(promServiceName))
+	})
+
+	It("should read the retention from the prometheus resource", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(monitoringv1.AddToScheme(scheme)).To(Succeed())
+
+		ctrl := &ReconcileMeterBase{client: fake.NewFakeClientWithScheme(scheme), scheme: scheme}
+		Expect(ctrl.prometheusRetention(instance)).To(Equal(30 * 24 * time.Hour))
+
+		ctrl.client = fake.NewFakeClientWithScheme(scheme, &monitoringv1.Prometheus{
+			ObjectMeta: metav1.ObjectMeta{Name: instance.Name, Namespace: instance.Namespace},
+			Spec:       monitoringv1.PrometheusSpec{Retention: "15d"},
+		})
+		Expect(ctrl.prometheusRetention(instance)).To(Equal(15 * 24 * time.Hour))
+	})
+})
@@ -33,17 +33,18 @@ import (
 	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	status "github.com/operator-framework/operator-sdk/pkg/status"
-	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
 	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
 	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils"
 	"github.com/spf13/pflag"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -99,6 +100,7 @@ type ReconcileMeterBase struct {
 	opts       *MeterbaseOpts
 	ccprovider ClientCommandRunnerProvider
 	patcher    patch.Patcher
+	recorder   record.EventRecorder
 }
 
 // newReconciler returns a new reconcile.Reconciler
@@ -115,6 +117,7 @@ func newReconciler(
 		ccprovider: ccprovider,
 		patcher:    patch.RHMDefaultPatcher,
 		opts:       promOpts,
+		recorder:   mgr.GetEventRecorderFor("meterbase-controller"),
 	}
 }
 
@@ -239,6 +242,11 @@ func (r *ReconcileMeterBase) Reconcile(request reconcile.Request) (reconcile.Res
 	}
 
 	c := manifests.NewDefaultConfig()
+	if err := c.LoadPlatform(manifests.InfrastructureLoader(context.TODO(), r.client)); err != nil {
+		reqLogger.Error(err, "failed to load platform")
+		return reconcile.Result{}, err
+	}
+
 	factory := manifests.NewFactory(instance.Namespace, c)
 
 	// Execute the finalizer, will only run if we are in delete state
@@ -250,6 +258,7 @@ func (r *ReconcileMeterBase) Reconcile(request reconcile.Request) (reconcile.Res
 				Do(r.uninstallPrometheusOperator(instance, factory)...),
 				Do(r.uninstallPrometheus(instance, factory)...),
 				Do(r.uninstallMetricState(instance, factory)...),
+				Do(r.uninstallKubeStateMetrics(instance, factory)...),
 			)),
 	); !result.Is(Continue) {
 
@@ -298,12 +307,37 @@ func (r *ReconcileMeterBase) Reconcile(request reconcile.Request) (reconcile.Res
 
 	cfg := &corev1.Secret{}
 	prometheus := &monitoringv1.Prometheus{}
-	if result, _ := cc.Do(context.TODO(),
-		Do(r.reconcilePrometheusOperator(instance, factory)...),
-		Do(r.installMetricStateDeployment(instance, factory)...),
-		Do(r.reconcileAdditionalConfigSecret(cc, instance, prometheus, factory, cfg)...),
-		Do(r.reconcilePrometheus(instance, prometheus, factory, cfg)...),
-	); !result.Is(Continue) {
+	installActions := []ClientAction{
+		Do(r.installMetricState(instance, factory)...),
+	}
+
+	// An external prometheus only needs metric-state to be scraped
+	if instance.Spec.ExternalPrometheus == nil {
+		installActions = []ClientAction{
+			Do(r.reconcilePrometheusOperator(instance, factory)...),
+			Do(r.installMetricState(instance, factory)...),
+			Do(r.reconcileAdditionalConfigSecret(cc, instance, prometheus, c, factory, cfg)...),
+			Do(r.reconcilePrometheus(instance, prometheus, c, factory, cfg)...),
+		}
+
+		// Only OpenShift comes with kube-state-metrics
+		if !c.IsOpenShift() {
+			installActions = append([]ClientAction{
+				Do(r.installKubeStateMetrics(instance, factory)...),
+			}, installActions...)
+		}
+	}
+
+	// OpenShift signs our serving certs, elsewhere we sign them ourselves
+	if !c.IsOpenShift() {
+		installActions = append([]ClientAction{
+			Do(r.reconcileServingCerts(instance, factory, time.Now())...),
+		}, installActions...)
+	}
+
+	installActions = append(installActions, Do(r.reconcileAlerts(instance, factory)...))
+
+	if result, _ := cc.Do(context.TODO(), installActions...); !result.Is(Continue) {
 		if result.Is(Error) {
 			reqLogger.Error(result, "error in reconcile")
 			return result.ReturnWithError(merrors.Wrap(result, "error creating prometheus"))
@@ -320,7 +354,15 @@ func (r *ReconcileMeterBase) Reconcile(request reconcile.Request) (reconcile.Res
 	// Set status for prometheus
 
 	prometheusStatefulset := &appsv1.StatefulSet{}
-	if result, err := cc.Do(
+	if instance.Spec.ExternalPrometheus != nil {
+		if result, err := r.reconcileExternalPrometheus(cc, instance, reqLogger); !result.Is(Continue) {
+			if err != nil {
+				return result.ReturnWithError(merrors.Wrap(err, "error checking external prometheus"))
+			}
+
+			return result.Return()
+		}
+	} else if result, err := cc.Do(
 		context.TODO(),
 		GetAction(types.NamespacedName{
 			Namespace: instance.Namespace,
@@ -386,45 +428,89 @@ func (r *ReconcileMeterBase) Reconcile(request reconcile.Request) (reconcile.Res
 		return result.Return()
 	}
 
+	// An external prometheus's storage isn't ours to watch
+	if instance.Spec.ExternalPrometheus == nil {
+		if result, err := r.reconcilePrometheusHealth(cc, instance, reqLogger, r.queryPrometheusHealth, time.Now()); !result.Is(Continue) {
+			if err != nil {
+				return result.ReturnWithError(merrors.Wrap(err, "error updating prometheus health"))
+			}
+
+			return result.Return()
+		}
+
+		if result, err := r.reconcileStorageAutoExpand(cc, instance, reqLogger, time.Now()); !result.Is(Continue) {
+			if err != nil {
+				return result.ReturnWithError(merrors.Wrap(err, "error expanding prometheus storage"))
+			}
+
+			return result.Return()
+		}
+	}
+
+	schedule, scheduleErr := newReportSchedule(instance.Spec.Reporting)
+
+	if instance.Status.Conditions == nil {
+		instance.Status.Conditions = &status.Conditions{}
+	}
+
+	// the condition is only kept once the settings were invalid
+	if scheduleErr != nil || instance.Status.Conditions.GetCondition(marketplacev1alpha1.ConditionReportingValid) != nil {
+		if result, err := cc.Do(context.TODO(), UpdateStatusCondition(instance, instance.Status.Conditions, reportingCondition(scheduleErr))); result.Is(Error) || result.Is(Requeue) {
+			if err != nil {
+				return result.ReturnWithError(merrors.Wrap(err, "error updating reporting condition"))
+			}
+
+			return result.Return()
+		}
+	}
+
+	if scheduleErr != nil {
+		reqLogger.Error(scheduleErr, "invalid reporting settings")
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	backfill := activeBackfill(instance)
+
 	meterReportList := &marketplacev1alpha1.MeterReportList{}
 	if result, err := cc.Do(
 		context.TODO(),
 		HandleResult(
 			ListAction(meterReportList, client.InNamespace(request.Namespace)),
 			OnContinue(Call(func() (ClientAction, error) {
-				loc := time.UTC
-				dateRangeInDays := -30
-
-				meterReportNames := r.sortMeterReports(meterReportList)
+				now := time.Now()
 
 				// prune old reports
-				meterReportNames, err := r.removeOldReports(meterReportNames, loc, dateRangeInDays, request)
+				reports, err := r.removeOldReports(meterReportList.Items, schedule, now, backfill, request)
 				if err != nil {
 					reqLogger.Error(err, err.Error())
 				}
 
 				// fill in gaps of missing reports
-				// we want the min date to be install date - 1 day
-				endDate := time.Now().In(loc)
+				// we want the min date to be the install date
+				minDate := instance.ObjectMeta.CreationTimestamp.Time
 
-				minDate := instance.ObjectMeta.CreationTimestamp.Time.In(loc)
-				minDate = utils.TruncateTime(minDate, loc)
+				expected := schedule.expectedPeriods(now, minDate)
+				missing := schedule.missingPeriods(expected, reports)
 
-				expectedCreatedDates := r.generateExpectedDates(endDate, loc, dateRangeInDays, minDate)
-				foundCreatedDates, err := r.generateFoundCreatedDates(meterReportNames)
+				log.Info("report periods", "expected", len(expected), "missing", len(missing), "min", minDate)
+				err = r.createReportIfNotFound(missing, reports, schedule, request, instance)
 
 				if err != nil {
 					return nil, err
 				}
 
-				log.Info("report dates", "expected", expectedCreatedDates, "found", foundCreatedDates, "min", minDate)
-				err = r.createReportIfNotFound(expectedCreatedDates, foundCreatedDates, request, instance)
-
-				if err != nil {
-					return nil, err
+				if backfill == nil {
+					return nil, nil
 				}
 
-				return nil, nil
+				// re-list so reports created above are seen by the backfill
+				backfillReportList := &marketplacev1alpha1.MeterReportList{}
+				return HandleResult(
+					ListAction(backfillReportList, client.InNamespace(request.Namespace)),
+					OnContinue(Call(func() (ClientAction, error) {
+						return r.reconcileBackfill(instance, backfill, schedule, backfillReportList.Items, now, request)
+					})),
+				), nil
 			})),
 			OnNotFound(Call(func() (ClientAction, error) {
 				log.Info("can't find meter report list, requeuing")
@@ -440,59 +526,92 @@ func (r *ReconcileMeterBase) Reconcile(request reconcile.Request) (reconcile.Res
 	}
 
 	reqLogger.Info("finished reconciling")
-	return reconcile.Result{RequeueAfter: time.Hour * 1}, nil
+
+	if backfill != nil {
+		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+	}
+
+	requeueAfter := time.Hour * 1
+	if schedule.cadence == marketplacev1alpha1.ReportCadenceHourly {
+		requeueAfter = time.Minute * 15
+	}
+
+	// come back in time to refresh the prometheus health
+	if instance.Spec.ExternalPrometheus == nil && requeueAfter > prometheusHealthInterval {
+		requeueAfter = prometheusHealthInterval
+	}
+
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
 }
 
-const promServiceName = "rhm-prometheus-meterbase"
+const promServiceName = utils.METERBASE_PROMETHEUS_SERVICE_NAME
 
-func (r *ReconcileMeterBase) createReportIfNotFound(expectedCreatedDates []string, foundCreatedDates []string, request reconcile.Request, instance *marketplacev1alpha1.MeterBase) error {
+func (r *ReconcileMeterBase) createReportIfNotFound(
+	missing []reportPeriod,
+	reports []marketplacev1alpha1.MeterReport,
+	schedule *reportSchedule,
+	request reconcile.Request,
+	instance *marketplacev1alpha1.MeterBase,
+) error {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 
-	// find the diff between the dates we expect and the dates found on the cluster and create any missing reports
-	missingReports := utils.FindDiff(expectedCreatedDates, foundCreatedDates)
-	for _, missingReportDateString := range missingReports {
-		missingReportName := r.newMeterReportNameFromString(missingReportDateString)
-		missingReportStartDate, _ := time.Parse(utils.DATE_FORMAT, missingReportDateString)
-		missingReportEndDate := missingReportStartDate.AddDate(0, 0, 1)
+	names := make(map[string]bool, len(reports))
+	for _, report := range reports {
+		names[report.Name] = true
+	}
+
+	// create a report for each period that isn't covered by the reports on the cluster
+	for _, period := range missing {
+		missingReportName := schedule.reportName(period)
+
+		if names[missingReportName] {
+			reqLogger.Info("report name already in use, skipping", "Resource", missingReportName)
+			continue
+		}
+
+		missingMeterReport := r.newMeterReport(request.Namespace, period.Start, period.End, missingReportName, instance)
+		missingMeterReport.Spec.Delay = &metav1.Duration{Duration: schedule.delay}
 
-		missingMeterReport := r.newMeterReport(request.Namespace, missingReportStartDate, missingReportEndDate, missingReportName, instance, promServiceName)
 		err := r.client.Create(context.TODO(), missingMeterReport)
 		if err != nil {
 			return err
 		}
+		names[missingReportName] = true
 		reqLogger.Info("Created Missing Report", "Resource", missingReportName)
 	}
 
 	return nil
 }
 
-func (r *ReconcileMeterBase) removeOldReports(meterReportNames []string, loc *time.Location, dateRange int, request reconcile.Request) ([]string, error) {
+func (r *ReconcileMeterBase) removeOldReports(
+	reports []marketplacev1alpha1.MeterReport,
+	schedule *reportSchedule,
+	now time.Time,
+	backfill *marketplacev1alpha1.BackfillSpec,
+	request reconcile.Request,
+) ([]marketplacev1alpha1.MeterReport, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
-	limit := utils.TruncateTime(time.Now(), loc).AddDate(0, 0, dateRange)
-	for _, reportName := range meterReportNames {
-		dateCreated, err := r.retrieveCreatedDate(reportName)
+	limit := schedule.retentionLimit(now)
 
-		if err != nil {
+	kept := make([]marketplacev1alpha1.MeterReport, 0, len(reports))
+	for i := range reports {
+		report := &reports[i]
+
+		// reports of a running backfill are kept until it completes
+		if !report.Spec.StartTime.Time.Before(limit) ||
+			(backfill != nil && isBackfilled(report, backfill.ID)) {
+			kept = append(kept, *report)
 			continue
 		}
 
-		if dateCreated.Before(limit) {
-			reqLogger.Info("Deleting Report", "Resource", reportName)
-			meterReportNames = utils.RemoveKey(meterReportNames, reportName)
-			deleteReport := &marketplacev1alpha1.MeterReport{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      reportName,
-					Namespace: request.Namespace,
-				},
-			}
-			err := r.client.Delete(context.TODO(), deleteReport)
-			if err != nil {
-				return nil, err
-			}
+		reqLogger.Info("Deleting Report", "Resource", report.Name)
+		err := r.client.Delete(context.TODO(), report)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return kept, err
 		}
 	}
 
-	return meterReportNames, nil
+	return kept, nil
 }
 
 // configPath: /etc/config/prometheus.yml
@@ -506,88 +625,16 @@ type MeterbaseOpts struct {
 	corev1.PullPolicy
 }
 
-func (r *ReconcileMeterBase) sortMeterReports(meterReportList *marketplacev1alpha1.MeterReportList) []string {
-
-	var meterReportNames []string
-	for _, report := range meterReportList.Items {
-		meterReportNames = append(meterReportNames, report.Name)
-	}
-
-	sort.Strings(meterReportNames)
-	return meterReportNames
-}
-
-func (r *ReconcileMeterBase) retrieveCreatedDate(reportName string) (time.Time, error) {
-	splitStr := strings.SplitN(reportName, "-", 3)
-
-	if len(splitStr) != 3 {
-		return time.Now(), errors.New("failed to get date")
-	}
-
-	dateString := splitStr[2:]
-	return time.Parse(utils.DATE_FORMAT, strings.Join(dateString, ""))
-}
-
-func (r *ReconcileMeterBase) newMeterReportNameFromDate(date time.Time) string {
-	dateSuffix := strings.Join(strings.Fields(date.String())[:1], "")
-	return fmt.Sprintf("%s%s", utils.METER_REPORT_PREFIX, dateSuffix)
-}
-
-func (r *ReconcileMeterBase) newMeterReportNameFromString(dateString string) string {
-	dateSuffix := dateString
-	return fmt.Sprintf("%s%s", utils.METER_REPORT_PREFIX, dateSuffix)
-}
-
-func (r *ReconcileMeterBase) generateFoundCreatedDates(meterReportNames []string) ([]string, error) {
-	var foundCreatedDates []string
-	for _, reportName := range meterReportNames {
-		splitStr := strings.SplitN(reportName, "-", 3)
-
-		if len(splitStr) != 3 {
-			log.Info("meterreport name was irregular", "name", reportName)
-			continue
-		}
-
-		dateString := splitStr[2:]
-		foundCreatedDates = append(foundCreatedDates, strings.Join(dateString, ""))
-	}
-	return foundCreatedDates, nil
-}
-
-func (r *ReconcileMeterBase) generateExpectedDates(endTime time.Time, loc *time.Location, dateRange int, minDate time.Time) []string {
-	// set start date
-	startDate := utils.TruncateTime(endTime, loc).AddDate(0, 0, dateRange)
-
-	if minDate.After(startDate) {
-		startDate = utils.TruncateTime(minDate, loc)
-	}
-
-	// set end date
-	endDate := utils.TruncateTime(endTime, loc)
-
-	// loop through the range of dates we expect
-	var expectedCreatedDates []string
-	for d := startDate; d.After(endDate) == false; d = d.AddDate(0, 0, 1) {
-		expectedCreatedDates = append(expectedCreatedDates, d.Format(utils.DATE_FORMAT))
-	}
-
-	return expectedCreatedDates
-}
-
-func (r *ReconcileMeterBase) newMeterReport(namespace string, startTime time.Time, endTime time.Time, meterReportName string, instance *marketplacev1alpha1.MeterBase, prometheusServiceName string) *marketplacev1alpha1.MeterReport {
+func (r *ReconcileMeterBase) newMeterReport(namespace string, startTime time.Time, endTime time.Time, meterReportName string, instance *marketplacev1alpha1.MeterBase) *marketplacev1alpha1.MeterReport {
 	return &marketplacev1alpha1.MeterReport{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      meterReportName,
 			Namespace: namespace,
 		},
 		Spec: marketplacev1alpha1.MeterReportSpec{
-			StartTime: metav1.NewTime(startTime),
-			EndTime:   metav1.NewTime(endTime),
-			PrometheusService: &common.ServiceReference{
-				Name:       prometheusServiceName,
-				Namespace:  instance.Namespace,
-				TargetPort: intstr.FromString("rbac"),
-			},
+			StartTime:         metav1.NewTime(startTime),
+			EndTime:           metav1.NewTime(endTime),
+			PrometheusService: reportPrometheusService(instance, r.prometheusRetention(instance), startTime, time.Now()),
 		},
 	}
 }
@@ -681,13 +728,25 @@ func (r *ReconcileMeterBase) reconcilePrometheusOperator(
 	}
 }
 
-func (r *ReconcileMeterBase) installMetricStateDeployment(
+// metricStateDeployment is the Deployment metric-state ran as before
+// it was sharded over the replicas of a StatefulSet.
+func metricStateDeployment(namespace string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rhm-metric-state",
+			Namespace: namespace,
+		},
+	}
+}
+
+func (r *ReconcileMeterBase) installMetricState(
 	instance *marketplacev1alpha1.MeterBase,
 	factory *manifests.Factory,
 ) []ClientAction {
-	deployment := &appsv1.Deployment{}
+	statefulSet := &appsv1.StatefulSet{}
 	service := &corev1.Service{}
 	serviceMonitor := &monitoringv1.ServiceMonitor{}
+	deployment := metricStateDeployment(instance.Namespace)
 
 	args := manifests.CreateOrUpdateFactoryItemArgs{
 		Owner:   instance,
@@ -695,10 +754,13 @@ func (r *ReconcileMeterBase) installMetricStateDeployment(
 	}
 
 	return []ClientAction{
+		HandleResult(
+			GetAction(types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name}, deployment),
+			OnContinue(DeleteAction(deployment))),
 		manifests.CreateOrUpdateFactoryItemAction(
-			deployment,
+			statefulSet,
 			func() (runtime.Object, error) {
-				return factory.MetricStateDeployment()
+				return factory.MetricStateStatefulSet()
 			},
 			args,
 		),
@@ -712,7 +774,40 @@ func (r *ReconcileMeterBase) installMetricStateDeployment(
 		manifests.CreateOrUpdateFactoryItemAction(
 			serviceMonitor,
 			func() (runtime.Object, error) {
-				return factory.MetricStateServiceMonitor()
+				sm, err := factory.MetricStateServiceMonitor()
+				if err != nil {
+					return nil, err
+				}
+
+				addExternalPrometheusLabels(instance, sm)
+				return sm, nil
+			},
+			args,
+		),
+	}
+}
+
+func (r *ReconcileMeterBase) installKubeStateMetrics(
+	instance *marketplacev1alpha1.MeterBase,
+	factory *manifests.Factory,
+) []ClientAction {
+	args := manifests.CreateOrUpdateFactoryItemArgs{
+		Owner:   instance,
+		Patcher: r.patcher,
+	}
+
+	return []ClientAction{
+		manifests.CreateOrUpdateFactoryItemAction(
+			&appsv1.Deployment{},
+			func() (runtime.Object, error) {
+				return factory.KubeStateMetricsDeployment()
+			},
+			args,
+		),
+		manifests.CreateOrUpdateFactoryItemAction(
+			&corev1.Service{},
+			func() (runtime.Object, error) {
+				return factory.KubeStateMetricsService()
 			},
 			args,
 		),
@@ -757,11 +852,12 @@ func (r *ReconcileMeterBase) reconcileAdditionalConfigSecret(
 	cc ClientCommandRunner,
 	instance *marketplacev1alpha1.MeterBase,
 	prometheus *monitoringv1.Prometheus,
+	c *manifests.Config,
 	factory *manifests.Factory,
 	additionalConfigSecret *corev1.Secret,
 ) []ClientAction {
-	openshiftKubeletMonitor := &monitoringv1.ServiceMonitor{}
-	openshiftKubeStateMonitor := &monitoringv1.ServiceMonitor{}
+	kubeletMonitor := &monitoringv1.ServiceMonitor{}
+	kubeStateMonitor := &monitoringv1.ServiceMonitor{}
 	metricStateMonitor := &monitoringv1.ServiceMonitor{}
 	secretsInNamespace := &corev1.SecretList{}
 
@@ -771,30 +867,52 @@ func (r *ReconcileMeterBase) reconcileAdditionalConfigSecret(
 		log.Error(err, "error getting metric state")
 	}
 
+	// OpenShift monitoring scrapes the kubelet and kube-state-metrics,
+	// elsewhere we scrape the kubelet and our own kube-state-metrics
+	getMonitors := []ClientAction{
+		GetAction(types.NamespacedName{
+			Namespace: "openshift-monitoring",
+			Name:      "kubelet",
+		}, kubeletMonitor),
+		GetAction(types.NamespacedName{
+			Namespace: "openshift-monitoring",
+			Name:      "kube-state-metrics",
+		}, kubeStateMonitor),
+	}
+
+	if !c.IsOpenShift() {
+		kubeletMonitor, err = factory.KubeletServiceMonitor()
+		if err != nil {
+			return []ClientAction{ReturnWithError(err)}
+		}
+
+		kubeStateMonitor, err = factory.KubeStateMetricsServiceMonitor()
+		if err != nil {
+			return []ClientAction{ReturnWithError(err)}
+		}
+
+		getMonitors = []ClientAction{}
+	}
+
+	getMonitors = append(getMonitors,
+		GetAction(types.NamespacedName{
+			Namespace: sm.ObjectMeta.Namespace,
+			Name:      sm.ObjectMeta.Name,
+		}, metricStateMonitor),
+		ListAction(secretsInNamespace, client.InNamespace(prometheus.GetNamespace())),
+	)
+
 	return []ClientAction{
 		Do(
 			HandleResult(
-				Do(
-					GetAction(types.NamespacedName{
-						Namespace: "openshift-monitoring",
-						Name:      "kubelet",
-					}, openshiftKubeletMonitor),
-					GetAction(types.NamespacedName{
-						Namespace: "openshift-monitoring",
-						Name:      "kube-state-metrics",
-					}, openshiftKubeStateMonitor),
-					GetAction(types.NamespacedName{
-						Namespace: sm.ObjectMeta.Namespace,
-						Name:      sm.ObjectMeta.Name,
-					}, metricStateMonitor),
-					ListAction(secretsInNamespace, client.InNamespace(prometheus.GetNamespace()))),
+				Do(getMonitors...),
 				OnNotFound(ReturnWithError(errors.New("required serviceMonitor not found"))),
 				OnError(ReturnWithError(errors.New("required serviceMonitor errored")))),
 		),
 		Call(func() (ClientAction, error) {
 			newEndpoints := []monitoringv1.Endpoint{}
 
-			for _, ep := range openshiftKubeStateMonitor.Spec.Endpoints {
+			for _, ep := range kubeStateMonitor.Spec.Endpoints {
 				newEp := ep.DeepCopy()
 				configs := []*monitoringv1.RelabelConfig{
 					{
@@ -807,11 +925,11 @@ func (r *ReconcileMeterBase) reconcileAdditionalConfigSecret(
 				newEndpoints = append(newEndpoints, *newEp)
 			}
 
-			openshiftKubeStateMonitor.Spec.Endpoints = newEndpoints
+			kubeStateMonitor.Spec.Endpoints = newEndpoints
 
 			sMons := map[string]*monitoringv1.ServiceMonitor{
-				"kube-state": openshiftKubeStateMonitor,
-				"kubelet":    openshiftKubeletMonitor,
+				"kube-state": kubeStateMonitor,
+				"kubelet":    kubeletMonitor,
 			}
 			sMons[metricStateMonitor.Name] = metricStateMonitor
 
@@ -833,6 +951,12 @@ func (r *ReconcileMeterBase) reconcileAdditionalConfigSecret(
 				return nil, err
 			}
 
+			cfg, err = appendSelfScrapeConfig(cfg)
+
+			if err != nil {
+				return nil, err
+			}
+
 			sec, err := factory.PrometheusAdditionalConfigSecret(cfg)
 
 			if err != nil {
@@ -863,6 +987,7 @@ func (r *ReconcileMeterBase) reconcileAdditionalConfigSecret(
 func (r *ReconcileMeterBase) reconcilePrometheus(
 	instance *marketplacev1alpha1.MeterBase,
 	prometheus *monitoringv1.Prometheus,
+	c *manifests.Config,
 	factory *manifests.Factory,
 	configSecret *corev1.Secret,
 ) []ClientAction {
@@ -874,6 +999,33 @@ func (r *ReconcileMeterBase) reconcilePrometheus(
 	dataSecret := &corev1.Secret{}
 	kubeletCertsCM := &corev1.ConfigMap{}
 
+	// The kubelet monitor skips verification off OpenShift, so there is no
+	// kubelet serving CA to copy
+	kubeletCABundle := HandleResult(
+		GetAction(
+			types.NamespacedName{Namespace: "openshift-config-managed", Name: "kubelet-serving-ca"},
+			kubeletCertsCM,
+		),
+		OnNotFound(Call(func() (ClientAction, error) {
+			return nil, merrors.New("require kubelet-serving configmap is not found")
+		})),
+		OnContinue(manifests.CreateOrUpdateFactoryItemAction(
+			&corev1.ConfigMap{},
+			func() (runtime.Object, error) {
+				return factory.PrometheusKubeletServingCABundle(kubeletCertsCM.Data)
+			},
+			args,
+		)))
+
+	if !c.IsOpenShift() {
+		kubeletCABundle = manifests.CreateIfNotExistsFactoryItem(
+			&corev1.ConfigMap{},
+			func() (runtime.Object, error) {
+				return factory.PrometheusKubeletServingCABundle(nil)
+			},
+		)
+	}
+
 	return []ClientAction{
 		manifests.CreateIfNotExistsFactoryItem(
 			&corev1.ConfigMap{},
@@ -910,22 +1062,7 @@ func (r *ReconcileMeterBase) reconcilePrometheus(
 			}),
 			OnError(RequeueResponse()),
 		),
-		HandleResult(
-			GetAction(
-				types.NamespacedName{Namespace: "openshift-config-managed", Name: "kubelet-serving-ca"},
-				kubeletCertsCM,
-			),
-			OnNotFound(Call(func() (ClientAction, error) {
-				return nil, merrors.New("require kubelet-serving configmap is not found")
-			})),
-			OnContinue(manifests.CreateOrUpdateFactoryItemAction(
-				&corev1.ConfigMap{},
-				func() (runtime.Object, error) {
-					return factory.PrometheusKubeletServingCABundle(kubeletCertsCM.Data)
-				},
-				args,
-			))),
-
+		kubeletCABundle,
 		manifests.CreateOrUpdateFactoryItemAction(
 			&corev1.Service{},
 			func() (runtime.Object, error) {
@@ -952,6 +1089,14 @@ func (r *ReconcileMeterBase) reconcilePrometheus(
 				updatedPrometheus.Spec.VolumeMounts = expectedPrometheus.Spec.VolumeMounts
 				updatedPrometheus.Spec.AdditionalScrapeConfigs = expectedPrometheus.Spec.AdditionalScrapeConfigs
 				updatedPrometheus.Spec.Containers = expectedPrometheus.Spec.Containers
+				updatedPrometheus.Spec.RemoteWrite = expectedPrometheus.Spec.RemoteWrite
+				updatedPrometheus.Spec.Thanos = expectedPrometheus.Spec.Thanos
+				updatedPrometheus.Spec.Retention = expectedPrometheus.Spec.Retention
+
+				// volumes expanded by the storage auto expand policy
+				if storage := instance.Status.Storage; storage != nil && storage.Size != nil {
+					setStorageRequest(updatedPrometheus.Spec.Storage, *storage.Size)
+				}
 
 				patch, err := r.patcher.Calculate(prometheus, updatedPrometheus)
 				if err != nil {
@@ -1000,14 +1145,35 @@ func (r *ReconcileMeterBase) uninstallMetricState(
 	instance *marketplacev1alpha1.MeterBase,
 	factory *manifests.Factory,
 ) []ClientAction {
-	deployment, _ := factory.MetricStateDeployment()
+	statefulSet, _ := factory.MetricStateStatefulSet()
 	service, _ := factory.MetricStateService()
 	sm, _ := factory.MetricStateServiceMonitor()
+	deployment := metricStateDeployment(statefulSet.Namespace)
 
 	return []ClientAction{
 		HandleResult(
 			GetAction(types.NamespacedName{Namespace: sm.Namespace, Name: sm.Name}, sm),
 			OnContinue(DeleteAction(sm))),
+		HandleResult(
+			GetAction(types.NamespacedName{Namespace: service.Namespace, Name: service.Name}, service),
+			OnContinue(DeleteAction(service))),
+		HandleResult(
+			GetAction(types.NamespacedName{Namespace: statefulSet.Namespace, Name: statefulSet.Name}, statefulSet),
+			OnContinue(DeleteAction(statefulSet))),
+		HandleResult(
+			GetAction(types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name}, deployment),
+			OnContinue(DeleteAction(deployment))),
+	}
+}
+
+func (r *ReconcileMeterBase) uninstallKubeStateMetrics(
+	instance *marketplacev1alpha1.MeterBase,
+	factory *manifests.Factory,
+) []ClientAction {
+	deployment, _ := factory.KubeStateMetricsDeployment()
+	service, _ := factory.KubeStateMetricsService()
+
+	return []ClientAction{
 		HandleResult(
 			GetAction(types.NamespacedName{Namespace: service.Namespace, Name: service.Name}, service),
 			OnContinue(DeleteAction(service))),
@@ -1029,7 +1195,8 @@ func (r *ReconcileMeterBase) uninstallPrometheus(
 	secrets := []*corev1.Secret{secret0, secret1, secret2, secret3}
 	prom, _ := r.newPrometheusOperator(instance, factory, nil)
 	service, _ := factory.PrometheusService(instance.Name)
-	deployment, _ := factory.MetricStateDeployment()
+	statefulSet, _ := factory.MetricStateStatefulSet()
+	deployment := metricStateDeployment(statefulSet.Namespace)
 	service2, _ := factory.MetricStateService()
 	sm, _ := factory.MetricStateServiceMonitor()
 
@@ -1052,11 +1219,14 @@ func (r *ReconcileMeterBase) uninstallPrometheus(
 			GetAction(types.NamespacedName{Namespace: sm.Namespace, Name: sm.Name}, sm),
 			OnContinue(DeleteAction(sm))),
 		HandleResult(
-			GetAction(types.NamespacedName{Namespace: service2.Namespace, Name: service2.Name}, deployment),
+			GetAction(types.NamespacedName{Namespace: service2.Namespace, Name: service2.Name}, service2),
 			OnContinue(DeleteAction(service2))),
 		HandleResult(
 			GetAction(types.NamespacedName{Namespace: service.Namespace, Name: service.Name}, service),
 			OnContinue(DeleteAction(service))),
+		HandleResult(
+			GetAction(types.NamespacedName{Namespace: statefulSet.Namespace, Name: statefulSet.Name}, statefulSet),
+			OnContinue(DeleteAction(statefulSet))),
 		HandleResult(
 			GetAction(types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name}, deployment),
 			OnContinue(DeleteAction(deployment))),
@@ -1128,6 +1298,16 @@ func (r *ReconcileMeterBase) newPrometheusOperator(
 ) (*monitoringv1.Prometheus, error) {
 	prom, err := factory.NewPrometheusDeployment(cr, cfg)
 
+	// the storage auto expand policy reduced the retention
+	if storage := cr.Status.Storage; err == nil && storage != nil && storage.Retention != "" {
+		prom.Spec.Retention = storage.Retention
+	}
+
+	// or expanded the volumes
+	if storage := cr.Status.Storage; err == nil && storage != nil && storage.Size != nil {
+		setStorageRequest(prom.Spec.Storage, *storage.Size)
+	}
+
 	if cr.Spec.Prometheus.Storage.Class == nil {
 		defaultClass, err := utils.GetDefaultStorageClass(r.client)
 
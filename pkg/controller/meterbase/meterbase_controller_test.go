@@ -19,6 +19,10 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var _ = Describe("MeterbaseController", func() {
@@ -31,25 +35,113 @@ var _ = Describe("MeterbaseController", func() {
 
 	Describe("check date functions", func() {
 		var (
-			ctrl *ReconcileMeterBase
+			schedule *reportSchedule
+			err      error
 		)
 
 		BeforeEach(func() {
-			ctrl = &ReconcileMeterBase{}
+			schedule, err = newReportSchedule(nil)
+			Expect(err).To(Succeed())
 		})
 
 		It("reports should calculate the correct dates to create", func() {
 			endDate := time.Now().UTC()
-			endDate = endDate.AddDate(0, 0, 0)
-			minDate := endDate.AddDate(0, 0, 0)
+			minDate := endDate
 
-			exp := ctrl.generateExpectedDates(endDate, time.UTC, -30, minDate)
+			exp := schedule.expectedPeriods(endDate, minDate)
 			Expect(exp).To(HaveLen(1))
 
 			minDate = endDate.AddDate(0, 0, -2)
 
-			exp = ctrl.generateExpectedDates(endDate, time.UTC, -30, minDate)
+			exp = schedule.expectedPeriods(endDate, minDate)
 			Expect(exp).To(HaveLen(3))
+			Expect(schedule.reportName(exp[0])).To(Equal(
+				utils.METER_REPORT_PREFIX + minDate.Format(utils.DATE_FORMAT)))
+		})
+
+		It("should reject bad settings", func() {
+			_, err = newReportSchedule(&marketplacev1alpha1.ReportingSpec{Timezone: "Not/AZone"})
+			Expect(err).To(HaveOccurred())
+
+			_, err = newReportSchedule(&marketplacev1alpha1.ReportingSpec{Cadence: "monthly"})
+			Expect(err).To(HaveOccurred())
+
+			cond := reportingCondition(err)
+			Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonReportingInvalid))
+			Expect(cond.Message).To(ContainSubstring(`unsupported report cadence "monthly"`))
+
+			cond = reportingCondition(nil)
+			Expect(cond.Status).To(Equal(corev1.ConditionTrue))
+			Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonReportingAccepted))
+		})
+
+		It("should follow the cadence and timezone", func() {
+			schedule, err = newReportSchedule(&marketplacev1alpha1.ReportingSpec{
+				Cadence:   marketplacev1alpha1.ReportCadenceWeekly,
+				Timezone:  "America/New_York",
+				Retention: &metav1.Duration{Duration: 21 * 24 * time.Hour},
+			})
+			Expect(err).To(Succeed())
+
+			// a wednesday
+			now := time.Date(2020, 9, 16, 2, 0, 0, 0, time.UTC)
+			exp := schedule.expectedPeriods(now, time.Time{})
+			Expect(exp).To(HaveLen(4))
+
+			for _, period := range exp {
+				Expect(period.Start.Weekday()).To(Equal(time.Monday))
+				Expect(period.Start.Location().String()).To(Equal("America/New_York"))
+				Expect(period.End).To(Equal(period.Start.AddDate(0, 0, 7)))
+			}
+
+			// still tuesday in new york
+			Expect(schedule.reportName(exp[3])).To(Equal("meter-report-2020-09-14"))
+
+			schedule, err = newReportSchedule(&marketplacev1alpha1.ReportingSpec{
+				Cadence:   marketplacev1alpha1.ReportCadenceHourly,
+				Retention: &metav1.Duration{Duration: 2 * time.Hour},
+			})
+			Expect(err).To(Succeed())
+
+			exp = schedule.expectedPeriods(now.Add(30*time.Minute), time.Time{})
+			Expect(exp).To(HaveLen(3))
+			Expect(schedule.reportName(exp[0])).To(Equal("meter-report-2020-09-16-0000"))
+			Expect(schedule.reportName(exp[2])).To(Equal("meter-report-2020-09-16-0200"))
+		})
+
+		It("should only fill the gaps not covered by reports", func() {
+			day := time.Date(2020, 9, 16, 0, 0, 0, 0, time.UTC)
+			report := func(start, end time.Time) marketplacev1alpha1.MeterReport {
+				return marketplacev1alpha1.MeterReport{
+					Spec: marketplacev1alpha1.MeterReportSpec{
+						StartTime: metav1.NewTime(start),
+						EndTime:   metav1.NewTime(end),
+					},
+				}
+			}
+
+			exp := schedule.expectedPeriods(day.Add(time.Hour), day.AddDate(0, 0, -2))
+			Expect(exp).To(HaveLen(3))
+
+			// daily report two days ago and hourly reports from before a cadence change
+			missing := schedule.missingPeriods(exp, []marketplacev1alpha1.MeterReport{
+				report(day.AddDate(0, 0, -2), day.AddDate(0, 0, -1)),
+				report(day.AddDate(0, 0, -1), day.AddDate(0, 0, -1).Add(time.Hour)),
+				report(day.AddDate(0, 0, -1).Add(time.Hour), day.AddDate(0, 0, -1).Add(2*time.Hour)),
+			})
+
+			Expect(missing).To(Equal([]reportPeriod{
+				{Start: day.AddDate(0, 0, -1).Add(2 * time.Hour), End: day},
+				{Start: day, End: day.AddDate(0, 0, 1)},
+			}))
+			Expect(schedule.reportName(missing[0])).To(Equal("meter-report-2020-09-15-0200"))
+			Expect(schedule.reportName(missing[1])).To(Equal("meter-report-2020-09-16"))
+		})
+
+		It("should prune reports past the retention", func() {
+			now := time.Date(2020, 9, 16, 1, 0, 0, 0, time.UTC)
+			Expect(schedule.retentionLimit(now)).To(Equal(now.AddDate(0, 0, -30).Truncate(24 * time.Hour)))
 		})
 	})
 })
@@ -21,11 +21,31 @@ import (
 	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	promop "github.com/coreos/prometheus-operator/pkg/prometheus"
 	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
+	yaml "gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// appendSelfScrapeConfig adds a job scraping the meterbase Prometheus itself
+// to the generated scrape configs. Its storage health is read from them.
+func appendSelfScrapeConfig(cfg []byte) ([]byte, error) {
+	scrapeConfigs := []yaml.MapSlice{}
+
+	if err := yaml.Unmarshal(cfg, &scrapeConfigs); err != nil {
+		return nil, err
+	}
+
+	scrapeConfigs = append(scrapeConfigs, yaml.MapSlice{
+		{Key: "job_name", Value: selfScrapeJob},
+		{Key: "static_configs", Value: []yaml.MapSlice{
+			{{Key: "targets", Value: []string{selfScrapeTarget}}},
+		}},
+	})
+
+	return yaml.Marshal(scrapeConfigs)
+}
+
 func loadBasicAuthSecrets(
 	client client.Client,
 	mons map[string]*monitoringv1.ServiceMonitor,
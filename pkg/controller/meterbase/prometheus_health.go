@@ -0,0 +1,381 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"github.com/gotidy/ptr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	selfScrapeJob    = "rhm-prometheus-meterbase"
+	selfScrapeTarget = "localhost:9090"
+	metricStateJob   = "rhm-metric-state-service"
+	kubeletJob       = "kubelet"
+
+	prometheusHealthInterval = 5 * time.Minute
+	prometheusHealthTimeout  = 10 * time.Second
+)
+
+var (
+	headSeriesQuery    = fmt.Sprintf(`max(prometheus_tsdb_head_series{job="%s"})`, selfScrapeJob)
+	ingestionRateQuery = fmt.Sprintf(`max(rate(prometheus_tsdb_head_samples_appended_total{job="%s"}[5m]))`, selfScrapeJob)
+	diskUsageQuery     = fmt.Sprintf(`max(prometheus_tsdb_storage_blocks_bytes{job="%[1]s"} + prometheus_tsdb_wal_storage_size_bytes{job="%[1]s"})`, selfScrapeJob)
+	oldestSampleQuery  = fmt.Sprintf(`min(prometheus_tsdb_lowest_timestamp_seconds{job="%s"})`, selfScrapeJob)
+
+	// healthTargetJobs are the scrape jobs metering can't report without.
+	healthTargetJobs  = []string{kubeletJob, metricStateJob}
+	targetsUpQuery    = fmt.Sprintf(`sum by (job) (up{job=~"%s"})`, strings.Join(healthTargetJobs, "|"))
+	targetsCountQuery = fmt.Sprintf(`count by (job) (up{job=~"%s"})`, strings.Join(healthTargetJobs, "|"))
+)
+
+// prometheusHealthQuery returns the health of the meterbase prometheus.
+type prometheusHealthQuery func(
+	instance *marketplacev1alpha1.MeterBase,
+	now time.Time,
+) (*marketplacev1alpha1.PrometheusHealthStatus, error)
+
+// reconcilePrometheusHealth queries the storage and ingestion health of the
+// meterbase prometheus and records it on the status with the StorageHealthy
+// and TargetsHealthy conditions. It's queried at most every
+// prometheusHealthInterval, failed queries included.
+func (r *ReconcileMeterBase) reconcilePrometheusHealth(
+	cc ClientCommandRunner,
+	instance *marketplacev1alpha1.MeterBase,
+	reqLogger logr.Logger,
+	query prometheusHealthQuery,
+	now time.Time,
+) (*ExecResult, error) {
+	health := instance.Status.PrometheusHealth
+	if health != nil && now.Sub(health.LastUpdateTime.Time) < prometheusHealthInterval {
+		return NewExecResult(Continue, reconcile.Result{}, nil), nil
+	}
+
+	if instance.Status.Conditions == nil {
+		instance.Status.Conditions = &status.Conditions{}
+	}
+
+	health, err := query(instance, now)
+
+	if err != nil {
+		reqLogger.Error(err, "failed to query prometheus health")
+
+		// the last health is kept, the attempt delays the next query
+		health = &marketplacev1alpha1.PrometheusHealthStatus{}
+		if instance.Status.PrometheusHealth != nil {
+			health = instance.Status.PrometheusHealth.DeepCopy()
+		}
+		health.LastUpdateTime = metav1.NewTime(now)
+		instance.Status.PrometheusHealth = health
+
+		for _, condType := range []status.ConditionType{
+			marketplacev1alpha1.ConditionStorageHealthy,
+			marketplacev1alpha1.ConditionTargetsHealthy,
+		} {
+			instance.Status.Conditions.SetCondition(status.Condition{
+				Type:    condType,
+				Status:  corev1.ConditionUnknown,
+				Reason:  marketplacev1alpha1.ReasonHealthQueryFailed,
+				Message: err.Error(),
+			})
+		}
+
+		return cc.Do(context.TODO(), UpdateAction(instance, UpdateStatusOnly(true)))
+	}
+
+	var expandedSize *resource.Quantity
+	if instance.Status.Storage != nil {
+		expandedSize = instance.Status.Storage.Size
+	}
+
+	setDiskCapacity(health, instance.Spec.Prometheus, expandedSize)
+
+	instance.Status.PrometheusHealth = health
+	instance.Status.Conditions.SetCondition(storageCondition(
+		health,
+		alertThresholds(instance.Spec.Alerts).StorageUsedPercent,
+		model.Duration(r.prometheusRetention(instance)).String(),
+	))
+	instance.Status.Conditions.SetCondition(targetsCondition(health, healthTargetJobs))
+
+	return cc.Do(context.TODO(), UpdateAction(instance, UpdateStatusOnly(true)))
+}
+
+// queryPrometheusHealth queries the health of the meterbase prometheus
+// through its service.
+func (r *ReconcileMeterBase) queryPrometheusHealth(
+	instance *marketplacev1alpha1.MeterBase,
+	now time.Time,
+) (*marketplacev1alpha1.PrometheusHealthStatus, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), prometheusHealthTimeout)
+	defer cancel()
+
+	api, err := r.prometheusAPI(ctx, instance.Namespace, &common.ServiceReference{
+		Name:       promServiceName,
+		Namespace:  instance.Namespace,
+		TargetPort: intstr.FromString("rbac"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return collectPrometheusHealth(ctx, api, now)
+}
+
+// collectPrometheusHealth runs the health queries against api. Values that
+// aren't scraped yet are left empty.
+func collectPrometheusHealth(
+	ctx context.Context,
+	api v1.API,
+	now time.Time,
+) (*marketplacev1alpha1.PrometheusHealthStatus, error) {
+	health := &marketplacev1alpha1.PrometheusHealthStatus{
+		LastUpdateTime: metav1.NewTime(now),
+	}
+
+	if value, ok, err := queryScalar(ctx, api, headSeriesQuery, now); err != nil {
+		return nil, err
+	} else if ok {
+		health.HeadSeries = int64(value)
+	}
+
+	if value, ok, err := queryScalar(ctx, api, ingestionRateQuery, now); err != nil {
+		return nil, err
+	} else if ok {
+		health.IngestionRate = int64(math.Round(value))
+	}
+
+	if value, ok, err := queryScalar(ctx, api, diskUsageQuery, now); err != nil {
+		return nil, err
+	} else if ok {
+		health.DiskUsage = resource.NewQuantity(int64(value), resource.BinarySI)
+	}
+
+	if value, ok, err := queryScalar(ctx, api, oldestSampleQuery, now); err != nil {
+		return nil, err
+	} else if ok {
+		oldest := metav1.NewTime(time.Unix(0, int64(value*float64(time.Second))).UTC())
+		health.OldestSampleTime = &oldest
+	}
+
+	up, err := queryByJob(ctx, api, targetsUpQuery, now)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := queryByJob(ctx, api, targetsCountQuery, now)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range healthTargetJobs {
+		if _, ok := count[job]; !ok {
+			continue
+		}
+
+		health.Targets = append(health.Targets, marketplacev1alpha1.TargetHealth{
+			Job:  job,
+			Up:   int32(up[job]),
+			Down: int32(count[job] - up[job]),
+		})
+	}
+
+	return health, nil
+}
+
+// queryScalar returns the value of a query with a single sample. It's not
+// ok if the query has no result.
+func queryScalar(
+	ctx context.Context,
+	api v1.API,
+	query string,
+	now time.Time,
+) (float64, bool, error) {
+	vector, err := queryVector(ctx, api, query, now)
+	if err != nil || len(vector) == 0 {
+		return 0, false, err
+	}
+
+	value := float64(vector[0].Value)
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false, nil
+	}
+
+	return value, true, nil
+}
+
+// queryByJob returns the values of a query aggregated by job.
+func queryByJob(
+	ctx context.Context,
+	api v1.API,
+	query string,
+	now time.Time,
+) (map[string]float64, error) {
+	vector, err := queryVector(ctx, api, query, now)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64, len(vector))
+	for _, sample := range vector {
+		values[string(sample.Metric["job"])] = float64(sample.Value)
+	}
+
+	return values, nil
+}
+
+func queryVector(
+	ctx context.Context,
+	api v1.API,
+	query string,
+	now time.Time,
+) (model.Vector, error) {
+	value, _, err := api.Query(ctx, query, now)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query prometheus: %s", query)
+	}
+
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return nil, errors.Errorf("unexpected result type %s", value.Type())
+	}
+
+	return vector, nil
+}
+
+// setDiskCapacity sets the capacity of the prometheus volume and the
+// percentage of it in use. The capacity is the larger of the size of the
+// spec and the size the volume was expanded to. An emptyDir or an unset
+// size has no capacity.
+func setDiskCapacity(
+	health *marketplacev1alpha1.PrometheusHealthStatus,
+	spec *marketplacev1alpha1.PrometheusSpec,
+	expandedSize *resource.Quantity,
+) {
+	if spec == nil || spec.Storage.EmptyDir != nil || spec.Storage.Size.IsZero() {
+		return
+	}
+
+	capacity := spec.Storage.Size.DeepCopy()
+	if expandedSize != nil && expandedSize.Cmp(capacity) > 0 {
+		capacity = expandedSize.DeepCopy()
+	}
+	health.DiskCapacity = &capacity
+
+	if health.DiskUsage == nil {
+		return
+	}
+
+	percent := float64(health.DiskUsage.Value()) / float64(capacity.Value()) * 100
+	health.DiskUsedPercent = ptr.Int32(int32(math.Round(percent)))
+}
+
+// storageCondition is the StorageHealthy condition of the health. Storage
+// is unhealthy once the used percentage reaches the threshold.
+func storageCondition(
+	health *marketplacev1alpha1.PrometheusHealthStatus,
+	threshold int32,
+	retention string,
+) status.Condition {
+	cond := status.Condition{
+		Type:   marketplacev1alpha1.ConditionStorageHealthy,
+		Status: corev1.ConditionTrue,
+		Reason: marketplacev1alpha1.ReasonStorageAvailable,
+	}
+
+	var usage string
+	switch {
+	case health.DiskUsedPercent != nil:
+		usage = fmt.Sprintf("Prometheus storage is %d%% full (%s of %s)",
+			*health.DiskUsedPercent, health.DiskUsage.String(), health.DiskCapacity.String())
+	case health.DiskUsage != nil:
+		usage = fmt.Sprintf("Prometheus storage uses %s", health.DiskUsage.String())
+	default:
+		usage = "Prometheus storage usage is not scraped yet"
+	}
+
+	if health.DiskUsedPercent != nil && *health.DiskUsedPercent >= threshold {
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = marketplacev1alpha1.ReasonStorageNearlyFull
+		usage = usage + ", samples may be lost before they are reported"
+	}
+
+	if health.OldestSampleTime != nil {
+		usage = fmt.Sprintf("%s; oldest sample is from %s and samples are kept for %s",
+			usage, health.OldestSampleTime.UTC().Format(time.RFC3339), retention)
+	}
+
+	cond.Message = usage
+	return cond
+}
+
+// targetsCondition is the TargetsHealthy condition of the health. Targets
+// are unhealthy if a job has no targets or any of them are down.
+func targetsCondition(
+	health *marketplacev1alpha1.PrometheusHealthStatus,
+	jobs []string,
+) status.Condition {
+	targets := make(map[string]marketplacev1alpha1.TargetHealth, len(health.Targets))
+	for _, target := range health.Targets {
+		targets[target.Job] = target
+	}
+
+	problems := []string{}
+	for _, job := range jobs {
+		target, ok := targets[job]
+
+		switch {
+		case !ok || target.Up+target.Down == 0:
+			problems = append(problems, fmt.Sprintf("%s has no targets", job))
+		case target.Down > 0:
+			problems = append(problems, fmt.Sprintf("%s has %d of %d targets down",
+				job, target.Down, target.Up+target.Down))
+		}
+	}
+
+	if len(problems) != 0 {
+		return status.Condition{
+			Type:    marketplacev1alpha1.ConditionTargetsHealthy,
+			Status:  corev1.ConditionFalse,
+			Reason:  marketplacev1alpha1.ReasonTargetsDown,
+			Message: strings.Join(problems, "; "),
+		}
+	}
+
+	return status.Condition{
+		Type:    marketplacev1alpha1.ConditionTargetsHealthy,
+		Status:  corev1.ConditionTrue,
+		Reason:  marketplacev1alpha1.ReasonTargetsUp,
+		Message: "All scrape targets are up",
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	yaml "gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fakeQueryAPI answers queries from a map of query to vector.
+type fakeQueryAPI struct {
+	v1.API
+	results map[string]model.Vector
+}
+
+func (f *fakeQueryAPI) Query(
+	ctx context.Context,
+	query string,
+	ts time.Time,
+) (model.Value, v1.Warnings, error) {
+	return f.results[query], nil, nil
+}
+
+var _ = Describe("PrometheusHealth", func() {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	sample := func(value float64, labels ...string) *model.Sample {
+		metric := model.Metric{}
+		for i := 0; i+1 < len(labels); i += 2 {
+			metric[model.LabelName(labels[i])] = model.LabelValue(labels[i+1])
+		}
+		return &model.Sample{Metric: metric, Value: model.SampleValue(value)}
+	}
+
+	It("should map the query results to the health", func() {
+		api := &fakeQueryAPI{results: map[string]model.Vector{
+			headSeriesQuery:    {sample(12345)},
+			ingestionRateQuery: {sample(210.6)},
+			diskUsageQuery:     {sample(34 * 1024 * 1024 * 1024)},
+			oldestSampleQuery:  {sample(float64(now.Add(-72 * time.Hour).Unix()))},
+			targetsUpQuery: {
+				sample(2, "job", kubeletJob),
+				sample(1, "job", metricStateJob),
+			},
+			targetsCountQuery: {
+				sample(3, "job", kubeletJob),
+				sample(1, "job", metricStateJob),
+			},
+		}}
+
+		health, err := collectPrometheusHealth(context.TODO(), api, now)
+		Expect(err).To(Succeed())
+		Expect(health.HeadSeries).To(Equal(int64(12345)))
+		Expect(health.IngestionRate).To(Equal(int64(211)))
+		Expect(health.DiskUsage.String()).To(Equal("34Gi"))
+		Expect(health.OldestSampleTime.Time).To(Equal(now.Add(-72 * time.Hour)))
+		Expect(health.LastUpdateTime.Time).To(Equal(now))
+		Expect(health.Targets).To(Equal([]marketplacev1alpha1.TargetHealth{
+			{Job: kubeletJob, Up: 2, Down: 1},
+			{Job: metricStateJob, Up: 1, Down: 0},
+		}))
+
+		setDiskCapacity(health, &marketplacev1alpha1.PrometheusSpec{
+			Storage: marketplacev1alpha1.StorageSpec{Size: resource.MustParse("40Gi")},
+		}, nil)
+		Expect(health.DiskCapacity.String()).To(Equal("40Gi"))
+		Expect(*health.DiskUsedPercent).To(Equal(int32(85)))
+
+		cond := storageCondition(health, 85, "30d")
+		Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonStorageNearlyFull))
+		Expect(cond.Message).To(ContainSubstring("85% full (34Gi of 40Gi)"))
+		Expect(cond.Message).To(ContainSubstring("oldest sample is from 2020-09-28T12:00:00Z"))
+
+		cond = storageCondition(health, 90, "30d")
+		Expect(cond.Status).To(Equal(corev1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonStorageAvailable))
+
+		cond = targetsCondition(health, healthTargetJobs)
+		Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonTargetsDown))
+		Expect(cond.Message).To(Equal("kubelet has 1 of 3 targets down"))
+	})
+
+	It("should leave values that aren't scraped yet empty", func() {
+		health, err := collectPrometheusHealth(context.TODO(), &fakeQueryAPI{}, now)
+		Expect(err).To(Succeed())
+		Expect(health.HeadSeries).To(BeZero())
+		Expect(health.DiskUsage).To(BeNil())
+		Expect(health.OldestSampleTime).To(BeNil())
+		Expect(health.Targets).To(BeEmpty())
+
+		setDiskCapacity(health, &marketplacev1alpha1.PrometheusSpec{
+			Storage: marketplacev1alpha1.StorageSpec{Size: resource.MustParse("40Gi")},
+		}, nil)
+		Expect(health.DiskCapacity).ToNot(BeNil())
+		Expect(health.DiskUsedPercent).To(BeNil())
+		Expect(storageCondition(health, 85, "30d").Status).To(Equal(corev1.ConditionTrue))
+
+		cond := targetsCondition(health, healthTargetJobs)
+		Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+		Expect(cond.Message).To(Equal("kubelet has no targets; rhm-metric-state-service has no targets"))
+	})
+
+	It("should have no capacity for an emptyDir", func() {
+		health := &marketplacev1alpha1.PrometheusHealthStatus{
+			DiskUsage: resource.NewQuantity(1024, resource.BinarySI),
+		}
+
+		setDiskCapacity(health, &marketplacev1alpha1.PrometheusSpec{
+			Storage: marketplacev1alpha1.StorageSpec{
+				Size:     resource.MustParse("40Gi"),
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		}, nil)
+		Expect(health.DiskCapacity).To(BeNil())
+		Expect(health.DiskUsedPercent).To(BeNil())
+	})
+
+	It("should report all targets up", func() {
+		health := &marketplacev1alpha1.PrometheusHealthStatus{
+			Targets: []marketplacev1alpha1.TargetHealth{
+				{Job: kubeletJob, Up: 3},
+				{Job: metricStateJob, Up: 1},
+			},
+		}
+
+		cond := targetsCondition(health, healthTargetJobs)
+		Expect(cond.Status).To(Equal(corev1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonTargetsUp))
+	})
+
+	Context("reconcile", func() {
+		var (
+			ctrl     *ReconcileMeterBase
+			cc       ClientCommandRunner
+			instance *marketplacev1alpha1.MeterBase
+			api      *fakeQueryAPI
+			queryErr error
+			queries  int
+		)
+
+		query := func(instance *marketplacev1alpha1.MeterBase, now time.Time) (*marketplacev1alpha1.PrometheusHealthStatus, error) {
+			queries++
+			if queryErr != nil {
+				return nil, queryErr
+			}
+			return collectPrometheusHealth(context.TODO(), api, now)
+		}
+
+		reconcileHealth := func(now time.Time) {
+			_, err := ctrl.reconcilePrometheusHealth(cc, instance, logf.Log, query, now)
+			Expect(err).To(Succeed())
+
+			instance = &marketplacev1alpha1.MeterBase{}
+			Expect(ctrl.client.Get(context.TODO(), types.NamespacedName{
+				Name: "rhm-marketplaceconfig-meterbase", Namespace: namespace,
+			}, instance)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(marketplacev1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+
+			instance = &marketplacev1alpha1.MeterBase{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rhm-marketplaceconfig-meterbase",
+					Namespace: namespace,
+				},
+				Spec: marketplacev1alpha1.MeterBaseSpec{
+					Prometheus: &marketplacev1alpha1.PrometheusSpec{
+						Storage: marketplacev1alpha1.StorageSpec{Size: resource.MustParse("40Gi")},
+					},
+				},
+			}
+
+			k8sClient := fake.NewFakeClientWithScheme(scheme, instance.DeepCopy())
+			cc = NewClientCommand(k8sClient, scheme, logf.Log)
+			ctrl = &ReconcileMeterBase{client: k8sClient, scheme: scheme}
+
+			queryErr = nil
+			queries = 0
+			api = &fakeQueryAPI{results: map[string]model.Vector{
+				headSeriesQuery: {sample(12345)},
+				diskUsageQuery:  {sample(36 * 1024 * 1024 * 1024)},
+				targetsUpQuery: {
+					sample(2, "job", kubeletJob),
+				},
+				targetsCountQuery: {
+					sample(3, "job", kubeletJob),
+				},
+			}}
+		})
+
+		It("should set the storage nearly full and targets down conditions", func() {
+			reconcileHealth(now)
+
+			Expect(instance.Status.PrometheusHealth).ToNot(BeNil())
+			Expect(*instance.Status.PrometheusHealth.DiskUsedPercent).To(Equal(int32(90)))
+
+			cond := instance.Status.Conditions.GetCondition(marketplacev1alpha1.ConditionStorageHealthy)
+			Expect(cond).ToNot(BeNil())
+			Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonStorageNearlyFull))
+			Expect(cond.Message).To(ContainSubstring("90% full (36Gi of 40Gi)"))
+
+			cond = instance.Status.Conditions.GetCondition(marketplacev1alpha1.ConditionTargetsHealthy)
+			Expect(cond).ToNot(BeNil())
+			Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonTargetsDown))
+			Expect(cond.Message).To(Equal("kubelet has 1 of 3 targets down; rhm-metric-state-service has no targets"))
+		})
+
+		It("should record the attempt of a failed query", func() {
+			reconcileHealth(now)
+			Expect(queries).To(Equal(1))
+
+			queryErr = errors.New("connection refused")
+			later := now.Add(prometheusHealthInterval)
+			reconcileHealth(later)
+			Expect(queries).To(Equal(2))
+
+			health := instance.Status.PrometheusHealth
+			Expect(health.LastUpdateTime.Time.Equal(later)).To(BeTrue())
+			Expect(health.HeadSeries).To(Equal(int64(12345)))
+
+			for _, condType := range []status.ConditionType{
+				marketplacev1alpha1.ConditionStorageHealthy,
+				marketplacev1alpha1.ConditionTargetsHealthy,
+			} {
+				cond := instance.Status.Conditions.GetCondition(condType)
+				Expect(cond).ToNot(BeNil())
+				Expect(cond.Status).To(Equal(corev1.ConditionUnknown))
+				Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonHealthQueryFailed))
+			}
+
+			reconcileHealth(later.Add(time.Minute))
+			Expect(queries).To(Equal(2))
+		})
+
+		It("should record the attempt of a failed first query", func() {
+			queryErr = errors.New("connection refused")
+			reconcileHealth(now)
+
+			Expect(instance.Status.PrometheusHealth).ToNot(BeNil())
+			Expect(instance.Status.PrometheusHealth.LastUpdateTime.Time.Equal(now)).To(BeTrue())
+
+			reconcileHealth(now.Add(time.Minute))
+			Expect(queries).To(Equal(1))
+		})
+	})
+
+	It("should add the self scrape job to the scrape configs", func() {
+		cfg, err := appendSelfScrapeConfig([]byte("- job_name: kubelet\n"))
+		Expect(err).To(Succeed())
+
+		scrapeConfigs := []map[string]interface{}{}
+		Expect(yaml.Unmarshal(cfg, &scrapeConfigs)).To(Succeed())
+		Expect(scrapeConfigs).To(HaveLen(2))
+		Expect(scrapeConfigs[0]["job_name"]).To(Equal("kubelet"))
+		Expect(scrapeConfigs[1]["job_name"]).To(Equal(selfScrapeJob))
+		Expect(string(cfg)).To(ContainSubstring("- localhost:9090"))
+	})
+})
@@ -0,0 +1,223 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"fmt"
+	"time"
+
+	merrors "emperror.dev/errors"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultReportRetention = 30 * 24 * time.Hour
+
+	// hourlyReportNameFormat names hourly reports and reports that don't
+	// start at midnight
+	hourlyReportNameFormat = utils.DATE_FORMAT + "-1504"
+)
+
+// reportPeriod is the time range of a meter report.
+type reportPeriod struct {
+	Start, End time.Time
+}
+
+// reportSchedule generates the report periods from the reporting
+// section of the meterbase.
+type reportSchedule struct {
+	cadence   marketplacev1alpha1.ReportCadence
+	loc       *time.Location
+	retention time.Duration
+	delay     time.Duration
+}
+
+// reportingCondition is the ReportingValid condition for the error
+// returned by newReportSchedule.
+func reportingCondition(err error) status.Condition {
+	if err != nil {
+		return status.Condition{
+			Type:    marketplacev1alpha1.ConditionReportingValid,
+			Status:  corev1.ConditionFalse,
+			Reason:  marketplacev1alpha1.ReasonReportingInvalid,
+			Message: fmt.Sprintf("meter reports are not created: %s", err.Error()),
+		}
+	}
+
+	return status.Condition{
+		Type:    marketplacev1alpha1.ConditionReportingValid,
+		Status:  corev1.ConditionTrue,
+		Reason:  marketplacev1alpha1.ReasonReportingAccepted,
+		Message: "meter reports are created on the reporting schedule",
+	}
+}
+
+func newReportSchedule(spec *marketplacev1alpha1.ReportingSpec) (*reportSchedule, error) {
+	schedule := &reportSchedule{
+		cadence:   marketplacev1alpha1.ReportCadenceDaily,
+		loc:       time.UTC,
+		retention: defaultReportRetention,
+	}
+
+	if spec == nil {
+		return schedule, nil
+	}
+
+	switch spec.Cadence {
+	case "":
+	case marketplacev1alpha1.ReportCadenceHourly,
+		marketplacev1alpha1.ReportCadenceDaily,
+		marketplacev1alpha1.ReportCadenceWeekly:
+		schedule.cadence = spec.Cadence
+	default:
+		return nil, merrors.Errorf("unsupported report cadence %q", spec.Cadence)
+	}
+
+	if spec.Timezone != "" {
+		loc, err := time.LoadLocation(spec.Timezone)
+		if err != nil {
+			return nil, merrors.Wrap(err, "invalid report timezone")
+		}
+		schedule.loc = loc
+	}
+
+	if spec.Retention != nil && spec.Retention.Duration > 0 {
+		schedule.retention = spec.Retention.Duration
+	}
+
+	if spec.Delay != nil && spec.Delay.Duration > 0 {
+		schedule.delay = spec.Delay.Duration
+	}
+
+	return schedule, nil
+}
+
+// truncate returns the start of the period t is in.
+func (s *reportSchedule) truncate(t time.Time) time.Time {
+	t = t.In(s.loc)
+
+	switch s.cadence {
+	case marketplacev1alpha1.ReportCadenceHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, s.loc)
+	case marketplacev1alpha1.ReportCadenceWeekly:
+		day := utils.TruncateTime(t, s.loc)
+		// weeks start on monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return utils.TruncateTime(t, s.loc)
+	}
+}
+
+// next returns the start of the period after the one starting at start.
+func (s *reportSchedule) next(start time.Time) time.Time {
+	switch s.cadence {
+	case marketplacev1alpha1.ReportCadenceHourly:
+		return start.Add(time.Hour)
+	case marketplacev1alpha1.ReportCadenceWeekly:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// retentionLimit is the start of the oldest period that is kept.
+func (s *reportSchedule) retentionLimit(now time.Time) time.Time {
+	return s.truncate(now.Add(-s.retention))
+}
+
+// expectedPeriods returns the periods from the retention limit, or the
+// period minDate is in if that's later, up to the current period.
+func (s *reportSchedule) expectedPeriods(now, minDate time.Time) []reportPeriod {
+	start := s.retentionLimit(now)
+
+	if min := s.truncate(minDate); min.After(start) {
+		start = min
+	}
+
+	end := s.truncate(now)
+
+	var periods []reportPeriod
+	for d := start; !d.After(end); d = s.next(d) {
+		periods = append(periods, reportPeriod{Start: d, End: s.next(d)})
+	}
+
+	return periods
+}
+
+// backfillPeriods returns the periods from the one start is in up to
+// end. Periods that haven't ended by now are left out.
+func (s *reportSchedule) backfillPeriods(start, end, now time.Time) []reportPeriod {
+	var periods []reportPeriod
+	for d := s.truncate(start); d.Before(end); d = s.next(d) {
+		period := reportPeriod{Start: d, End: s.next(d)}
+
+		if period.End.After(now) {
+			break
+		}
+
+		periods = append(periods, period)
+	}
+
+	return periods
+}
+
+// missingPeriods returns the expected periods that aren't covered by the
+// reports. If the cadence changed, a period can be covered in part by
+// reports of the old cadence; only the rest of it is missing.
+func (s *reportSchedule) missingPeriods(
+	expected []reportPeriod,
+	reports []marketplacev1alpha1.MeterReport,
+) []reportPeriod {
+	var missing []reportPeriod
+
+	for _, period := range expected {
+		start := period.Start
+
+		for covered := true; covered && start.Before(period.End); {
+			covered = false
+
+			for _, report := range reports {
+				if !report.Spec.StartTime.Time.After(start) && report.Spec.EndTime.Time.After(start) {
+					start = report.Spec.EndTime.Time.In(s.loc)
+					covered = true
+				}
+			}
+		}
+
+		if start.Before(period.End) {
+			missing = append(missing, reportPeriod{Start: start, End: period.End})
+		}
+	}
+
+	return missing
+}
+
+// reportName returns the name of the report of the period, named after
+// its start in the report timezone. Hourly reports and reports that don't
+// start at midnight get the hour in the name.
+func (s *reportSchedule) reportName(period reportPeriod) string {
+	start := period.Start.In(s.loc)
+	format := utils.DATE_FORMAT
+
+	if s.cadence == marketplacev1alpha1.ReportCadenceHourly ||
+		!start.Equal(utils.TruncateTime(start, s.loc)) {
+		format = hourlyReportNameFormat
+	}
+
+	return fmt.Sprintf("%s%s", utils.METER_REPORT_PREFIX, start.Format(format))
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"time"
+
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/manifests"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// reconcileServingCerts stands in for the OpenShift service-ca on other
+// clusters. It keeps a CA secret, signs the serving certificates our
+// services name in their serving cert annotation and publishes the CA in
+// the serving certs bundles.
+func (r *ReconcileMeterBase) reconcileServingCerts(
+	instance *marketplacev1alpha1.MeterBase,
+	factory *manifests.Factory,
+	now time.Time,
+) []ClientAction {
+	var ca *manifests.ServingCA
+	caSecret := &corev1.Secret{}
+
+	actions := []ClientAction{
+		HandleResult(
+			GetAction(types.NamespacedName{Name: manifests.ServingCASecretName, Namespace: instance.Namespace}, caSecret),
+			OnNotFound(Call(func() (ClientAction, error) {
+				newCA, err := manifests.NewServingCA(now)
+				if err != nil {
+					return nil, err
+				}
+
+				return CreateAction(newCA.Secret(instance.Namespace), CreateWithAddOwner(instance)), nil
+			})),
+			OnContinue(Call(func() (ClientAction, error) {
+				loaded, err := manifests.LoadServingCA(caSecret)
+				if err == nil && !loaded.NeedsRenewal(now) {
+					ca = loaded
+					return nil, nil
+				}
+
+				newCA, err := manifests.NewServingCA(now)
+				if err != nil {
+					return nil, err
+				}
+
+				caSecret.Type = corev1.SecretTypeTLS
+				caSecret.Data = newCA.Secret(instance.Namespace).Data
+				return UpdateAction(caSecret), nil
+			})),
+		),
+	}
+
+	services := []func() (*corev1.Service, error){factory.MetricStateService}
+	if instance.Spec.ExternalPrometheus == nil {
+		services = append(services,
+			factory.NewPrometheusOperatorService,
+			func() (*corev1.Service, error) {
+				return factory.PrometheusService(instance.Name)
+			},
+		)
+	}
+
+	for _, newService := range services {
+		service, err := newService()
+		if err != nil {
+			return []ClientAction{ReturnWithError(err)}
+		}
+
+		actions = append(actions, r.reconcileServingCert(instance, service, &ca, now))
+	}
+
+	caBundles := []func() (*corev1.ConfigMap, error){
+		factory.PrometheusServingCertsCABundle,
+		factory.NewPrometheusOperatorCertsCABundle,
+	}
+
+	for _, newCABundle := range caBundles {
+		caBundle, err := newCABundle()
+		if err != nil {
+			return []ClientAction{ReturnWithError(err)}
+		}
+
+		actions = append(actions, reconcileServingCABundle(instance, caBundle, &ca))
+	}
+
+	return actions
+}
+
+func (r *ReconcileMeterBase) reconcileServingCert(
+	instance *marketplacev1alpha1.MeterBase,
+	service *corev1.Service,
+	ca **manifests.ServingCA,
+	now time.Time,
+) ClientAction {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{
+		Name:      service.Annotations[manifests.ServingCertSecretAnnotation],
+		Namespace: service.Namespace,
+	}
+
+	return HandleResult(
+		GetAction(key, secret),
+		OnNotFound(Call(func() (ClientAction, error) {
+			newSecret, err := (*ca).ServingCertSecret(service, now)
+			if err != nil {
+				return nil, err
+			}
+
+			return CreateAction(newSecret, CreateWithAddOwner(instance)), nil
+		})),
+		OnContinue(Call(func() (ClientAction, error) {
+			if (*ca).ServingCertValid(secret, service, now) {
+				return nil, nil
+			}
+
+			newSecret, err := (*ca).ServingCertSecret(service, now)
+			if err != nil {
+				return nil, err
+			}
+
+			secret.Type = newSecret.Type
+			secret.Data = newSecret.Data
+			return UpdateAction(secret), nil
+		})),
+	)
+}
+
+func reconcileServingCABundle(
+	instance *marketplacev1alpha1.MeterBase,
+	caBundle *corev1.ConfigMap,
+	ca **manifests.ServingCA,
+) ClientAction {
+	cm := &corev1.ConfigMap{}
+
+	return HandleResult(
+		GetAction(types.NamespacedName{Name: caBundle.Name, Namespace: caBundle.Namespace}, cm),
+		OnNotFound(Call(func() (ClientAction, error) {
+			caBundle.Data = map[string]string{manifests.ServingCAKey: string((*ca).CertPEM)}
+			return CreateAction(caBundle, CreateWithAddOwner(instance)), nil
+		})),
+		OnContinue(Call(func() (ClientAction, error) {
+			if cm.Data[manifests.ServingCAKey] == string((*ca).CertPEM) {
+				return nil, nil
+			}
+
+			if cm.Data == nil {
+				cm.Data = map[string]string{}
+			}
+
+			cm.Data[manifests.ServingCAKey] = string((*ca).CertPEM)
+			return UpdateAction(cm), nil
+		})),
+	)
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/manifests"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("ServingCerts", func() {
+	var (
+		ctrl     *ReconcileMeterBase
+		cc       ClientCommandRunner
+		instance *marketplacev1alpha1.MeterBase
+		factory  *manifests.Factory
+	)
+
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(marketplacev1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+
+		instance = &marketplacev1alpha1.MeterBase{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rhm-marketplaceconfig-meterbase",
+				Namespace: namespace,
+			},
+		}
+
+		k8sClient := fake.NewFakeClientWithScheme(scheme, instance.DeepCopy())
+		cc = NewClientCommand(k8sClient, scheme, logf.Log)
+		ctrl = &ReconcileMeterBase{client: k8sClient, scheme: scheme}
+		factory = manifests.NewFactory(namespace, manifests.NewDefaultConfig())
+	})
+
+	// reconcile runs the certs until nothing changes
+	reconcile := func(now time.Time) {
+		for i := 0; i < 10; i++ {
+			result, err := cc.Do(context.TODO(), Do(ctrl.reconcileServingCerts(instance, factory, now)...))
+			Expect(err).To(Succeed())
+			if result.Is(Continue) {
+				return
+			}
+			Expect(result.Is(Requeue)).To(BeTrue())
+		}
+		Fail("serving certs did not settle")
+	}
+
+	getSecret := func(name string) *corev1.Secret {
+		secret := &corev1.Secret{}
+		Expect(ctrl.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, secret)).To(Succeed())
+		return secret
+	}
+
+	parse := func(data []byte) *x509.Certificate {
+		block, _ := pem.Decode(data)
+		Expect(block).ToNot(BeNil())
+		cert, err := x509.ParseCertificate(block.Bytes)
+		Expect(err).To(Succeed())
+		return cert
+	}
+
+	It("should sign the serving certs and publish the ca", func() {
+		reconcile(now)
+
+		caPEM := getSecret(manifests.ServingCASecretName).Data[corev1.TLSCertKey]
+		roots := x509.NewCertPool()
+		Expect(roots.AppendCertsFromPEM(caPEM)).To(BeTrue())
+
+		for name, host := range map[string]string{
+			"rhm-metric-state-tls":         "rhm-metric-state-service." + namespace + ".svc",
+			"rhm-prometheus-meterbase-tls": "rhm-prometheus-meterbase." + namespace + ".svc",
+			"prometheus-operator-tls":      "prometheus-operator." + namespace + ".svc",
+		} {
+			secret := getSecret(name)
+			Expect(secret.Type).To(Equal(corev1.SecretTypeTLS))
+			Expect(secret.Data[corev1.TLSPrivateKeyKey]).ToNot(BeEmpty())
+
+			_, err := parse(secret.Data[corev1.TLSCertKey]).Verify(x509.VerifyOptions{
+				DNSName:     host,
+				Roots:       roots,
+				CurrentTime: now,
+			})
+			Expect(err).To(Succeed(), name)
+		}
+
+		for _, name := range []string{"serving-certs-ca-bundle", "operator-certs-ca-bundle"} {
+			cm := &corev1.ConfigMap{}
+			Expect(ctrl.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, cm)).To(Succeed())
+			Expect(cm.Data[manifests.ServingCAKey]).To(Equal(string(caPEM)), name)
+		}
+
+		result, err := cc.Do(context.TODO(), Do(ctrl.reconcileServingCerts(instance, factory, now)...))
+		Expect(err).To(Succeed())
+		Expect(result.Is(Continue)).To(BeTrue())
+	})
+
+	It("should renew serving certs before they expire", func() {
+		reconcile(now)
+		caPEM := getSecret(manifests.ServingCASecretName).Data[corev1.TLSCertKey]
+		old := parse(getSecret("rhm-metric-state-tls").Data[corev1.TLSCertKey])
+
+		later := old.NotAfter.Add(-manifests.ServingCertRenewBefore)
+		reconcile(later)
+
+		Expect(getSecret(manifests.ServingCASecretName).Data[corev1.TLSCertKey]).To(Equal(caPEM))
+		renewed := parse(getSecret("rhm-metric-state-tls").Data[corev1.TLSCertKey])
+		Expect(renewed.SerialNumber).ToNot(Equal(old.SerialNumber))
+		Expect(renewed.NotAfter.After(later.Add(manifests.ServingCertRenewBefore))).To(BeTrue())
+	})
+
+	It("should only sign metric-state with an external prometheus", func() {
+		instance.Spec.ExternalPrometheus = &marketplacev1alpha1.ExternalPrometheusSpec{}
+		reconcile(now)
+
+		getSecret("rhm-metric-state-tls")
+		secret := &corev1.Secret{}
+		err := ctrl.client.Get(context.TODO(), types.NamespacedName{Name: "prometheus-operator-tls", Namespace: namespace}, secret)
+		Expect(err).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,426 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/prometheus/common/model"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultAutoExpandUsedPercent  = 80
+	defaultAutoExpandMinRetention = 7 * 24 * time.Hour
+
+	// autoExpandCooldown gives an expansion or a reduced retention time to
+	// show in the storage usage before the policy acts again.
+	autoExpandCooldown = time.Hour
+)
+
+var defaultAutoExpandStep = resource.MustParse("10Gi")
+
+// storagePlan is what the auto expand policy does about a full volume,
+// either expand it to size or reduce the retention. A retention reduced
+// before is restored once the volumes can be expanded again.
+type storagePlan struct {
+	size             *resource.Quantity
+	retention        string
+	restoreRetention bool
+	condition        status.Condition
+}
+
+// planStorage returns what to do about volumes of size that are
+// usedPercent full. It's nil if they're under the threshold of the policy
+// and the retention isn't reduced or can't be restored yet.
+func planStorage(
+	policy *marketplacev1alpha1.StorageAutoExpandSpec,
+	usedPercent int32,
+	size resource.Quantity,
+	retention time.Duration,
+	reduced bool,
+	storageClass string,
+	expandable bool,
+) *storagePlan {
+	threshold := int32(defaultAutoExpandUsedPercent)
+	if policy.UsedPercent != nil {
+		threshold = *policy.UsedPercent
+	}
+
+	// the max size or the storage class changed since the retention was
+	// reduced
+	headroom := expandable && size.Cmp(policy.MaxSize) < 0
+
+	if usedPercent < threshold {
+		if !reduced || !headroom {
+			return nil
+		}
+
+		return &storagePlan{
+			restoreRetention: true,
+			condition: status.Condition{
+				Type:   marketplacev1alpha1.ConditionStorageAutoExpand,
+				Status: corev1.ConditionTrue,
+				Reason: marketplacev1alpha1.ReasonRetentionRestored,
+				Message: fmt.Sprintf("Prometheus storage is %d%% full and the volumes can be expanded up to %s, restored the retention from %s",
+					usedPercent, policy.MaxSize.String(), model.Duration(retention).String()),
+			},
+		}
+	}
+
+	if headroom {
+		step := defaultAutoExpandStep
+		if policy.Step != nil {
+			step = *policy.Step
+		}
+
+		newSize := size.DeepCopy()
+		newSize.Add(step)
+
+		if newSize.Cmp(policy.MaxSize) > 0 {
+			newSize = policy.MaxSize.DeepCopy()
+		}
+
+		message := fmt.Sprintf("Prometheus storage is %d%% full, expanded the volumes from %s to %s",
+			usedPercent, size.String(), newSize.String())
+		if reduced {
+			message = fmt.Sprintf("%s and restored the retention from %s",
+				message, model.Duration(retention).String())
+		}
+
+		return &storagePlan{
+			size:             &newSize,
+			restoreRetention: reduced,
+			condition: status.Condition{
+				Type:    marketplacev1alpha1.ConditionStorageAutoExpand,
+				Status:  corev1.ConditionTrue,
+				Reason:  marketplacev1alpha1.ReasonVolumesExpanded,
+				Message: message,
+			},
+		}
+	}
+
+	cause := fmt.Sprintf("storage class %q doesn't allow volume expansion", storageClass)
+	if expandable {
+		cause = fmt.Sprintf("the volumes are at their max size %s", policy.MaxSize.String())
+	}
+
+	minRetention := defaultAutoExpandMinRetention
+	if policy.MinRetention != nil && policy.MinRetention.Duration > 0 {
+		minRetention = policy.MinRetention.Duration
+	}
+
+	if retention <= minRetention {
+		return &storagePlan{
+			condition: status.Condition{
+				Type:   marketplacev1alpha1.ConditionStorageAutoExpand,
+				Status: corev1.ConditionFalse,
+				Reason: marketplacev1alpha1.ReasonAutoExpandLimitReached,
+				Message: fmt.Sprintf("Prometheus storage is %d%% full, %s and the retention is at its min %s, samples may be lost before they are reported",
+					usedPercent, cause, model.Duration(retention).String()),
+			},
+		}
+	}
+
+	// drop a quarter of the samples at a time
+	newRetention := (retention * 3 / 4).Truncate(time.Hour)
+	if newRetention < minRetention {
+		newRetention = minRetention
+	}
+
+	return &storagePlan{
+		retention: model.Duration(newRetention).String(),
+		condition: status.Condition{
+			Type:   marketplacev1alpha1.ConditionStorageAutoExpand,
+			Status: corev1.ConditionTrue,
+			Reason: marketplacev1alpha1.ReasonRetentionReduced,
+			Message: fmt.Sprintf("Prometheus storage is %d%% full and %s, reduced the retention from %s to %s",
+				usedPercent, cause, model.Duration(retention).String(), model.Duration(newRetention).String()),
+		},
+	}
+}
+
+// reconcileStorageAutoExpand applies the auto expand policy of the
+// meterbase to the volumes of the prometheus statefulset once their usage
+// reaches its threshold. What it does is recorded as an event, the
+// StorageAutoExpand condition and the storage status.
+func (r *ReconcileMeterBase) reconcileStorageAutoExpand(
+	cc ClientCommandRunner,
+	instance *marketplacev1alpha1.MeterBase,
+	reqLogger logr.Logger,
+	now time.Time,
+) (*ExecResult, error) {
+	continueResult := NewExecResult(Continue, reconcile.Result{}, nil)
+
+	if instance.Spec.Prometheus == nil {
+		return continueResult, nil
+	}
+
+	policy := instance.Spec.Prometheus.Storage.AutoExpand
+	health := instance.Status.PrometheusHealth
+	storage := instance.Status.Storage
+
+	switch {
+	case policy == nil, instance.Spec.Prometheus.Storage.EmptyDir != nil:
+		return continueResult, nil
+	case health == nil || health.DiskUsedPercent == nil:
+		return continueResult, nil
+	case storage != nil && storage.LastActionTime != nil && now.Sub(storage.LastActionTime.Time) < autoExpandCooldown:
+		return continueResult, nil
+	}
+
+	pvcs, err := r.prometheusVolumeClaims(instance)
+	if err != nil {
+		return NewExecResult(Error, reconcile.Result{}, err), err
+	}
+
+	if len(pvcs) == 0 {
+		return continueResult, nil
+	}
+
+	var size resource.Quantity
+	for _, pvc := range pvcs {
+		request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+
+		// an expansion is still in progress
+		if capacity.Cmp(request) < 0 {
+			reqLogger.Info("prometheus volume is expanding", "pvc", pvc.Name)
+			return continueResult, nil
+		}
+
+		if request.Cmp(size) > 0 {
+			size = request
+		}
+	}
+
+	storageClass, expandable, err := r.volumeExpansionAllowed(&pvcs[0])
+	if err != nil {
+		return NewExecResult(Error, reconcile.Result{}, err), err
+	}
+
+	retention := r.prometheusRetention(instance)
+	reduced := storage != nil && storage.Retention != ""
+	plan := planStorage(policy, *health.DiskUsedPercent, size, retention, reduced, storageClass, expandable)
+	if plan == nil {
+		return continueResult, nil
+	}
+
+	if storage == nil {
+		storage = &marketplacev1alpha1.StorageStatus{}
+	}
+
+	if plan.size != nil {
+		expanded := 0
+		failures := []string{}
+
+		for i := range pvcs {
+			pvc := &pvcs[i]
+			if pvc.Spec.Resources.Requests == nil {
+				pvc.Spec.Resources.Requests = corev1.ResourceList{}
+			}
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = plan.size.DeepCopy()
+
+			if result, err := cc.Do(context.TODO(), UpdateAction(pvc)); result.Is(Error) {
+				reqLogger.Error(err, "failed to expand prometheus volume", "pvc", pvc.Name)
+				failures = append(failures, fmt.Sprintf("volume %s: %v", pvc.Name, err))
+				continue
+			}
+
+			expanded++
+		}
+
+		// volumes that were expanded can't shrink again, so the size is
+		// kept even if others failed
+		if expanded != 0 {
+			storage.Size = plan.size
+
+			if err := r.expandPrometheusVolumeClaimTemplate(cc, instance, *plan.size); err != nil {
+				reqLogger.Error(err, "failed to expand prometheus volume claim template")
+				failures = append(failures, fmt.Sprintf("prometheus %s: %v", instance.Name, err))
+			}
+		}
+
+		if len(failures) != 0 {
+			plan.restoreRetention = false
+			plan.condition = status.Condition{
+				Type:   marketplacev1alpha1.ConditionStorageAutoExpand,
+				Status: corev1.ConditionFalse,
+				Reason: marketplacev1alpha1.ReasonVolumeExpansionFailed,
+				Message: fmt.Sprintf("Failed to expand %d of %d volumes to %s: %s",
+					len(pvcs)-expanded, len(pvcs), plan.size.String(), strings.Join(failures, "; ")),
+			}
+		}
+	}
+
+	if plan.retention != "" {
+		storage.Retention = plan.retention
+	}
+
+	if plan.restoreRetention {
+		storage.Retention = ""
+	}
+
+	eventType := corev1.EventTypeNormal
+	if plan.condition.Status != corev1.ConditionTrue {
+		eventType = corev1.EventTypeWarning
+	}
+
+	r.recorder.Event(instance, eventType, string(plan.condition.Reason), plan.condition.Message)
+	reqLogger.Info("applied storage auto expand policy", "reason", plan.condition.Reason, "message", plan.condition.Message)
+
+	storage.LastActionTime = &metav1.Time{Time: now}
+	instance.Status.Storage = storage
+
+	if instance.Status.Conditions == nil {
+		instance.Status.Conditions = &status.Conditions{}
+	}
+
+	instance.Status.Conditions.SetCondition(plan.condition)
+
+	return cc.Do(context.TODO(), UpdateAction(instance, UpdateStatusOnly(true)))
+}
+
+// expandPrometheusVolumeClaimTemplate raises the storage request of the
+// volume claim template of the prometheus to size, so the volumes of a
+// recreated statefulset keep it.
+func (r *ReconcileMeterBase) expandPrometheusVolumeClaimTemplate(
+	cc ClientCommandRunner,
+	instance *marketplacev1alpha1.MeterBase,
+	size resource.Quantity,
+) error {
+	prometheus := &monitoringv1.Prometheus{}
+	key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+
+	if err := r.client.Get(context.TODO(), key, prometheus); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to get prometheus")
+	}
+
+	if !setStorageRequest(prometheus.Spec.Storage, size) {
+		return nil
+	}
+
+	if result, err := cc.Do(context.TODO(), UpdateAction(prometheus)); result.Is(Error) {
+		return err
+	}
+
+	return nil
+}
+
+// setStorageRequest raises the request of the volume claim template of
+// storage to size. It's false if the request is already at least size.
+func setStorageRequest(storage *monitoringv1.StorageSpec, size resource.Quantity) bool {
+	if storage == nil || storage.EmptyDir != nil {
+		return false
+	}
+
+	requests := storage.VolumeClaimTemplate.Spec.Resources.Requests
+	if request, ok := requests[corev1.ResourceStorage]; ok && request.Cmp(size) >= 0 {
+		return false
+	}
+
+	if requests == nil {
+		requests = corev1.ResourceList{}
+		storage.VolumeClaimTemplate.Spec.Resources.Requests = requests
+	}
+
+	requests[corev1.ResourceStorage] = size.DeepCopy()
+	return true
+}
+
+// prometheusVolumeClaims returns the claims of the volume claim template of
+// the prometheus statefulset.
+func (r *ReconcileMeterBase) prometheusVolumeClaims(
+	instance *marketplacev1alpha1.MeterBase,
+) ([]corev1.PersistentVolumeClaim, error) {
+	sts := &appsv1.StatefulSet{}
+	key := types.NamespacedName{Name: fmt.Sprintf("prometheus-%s", instance.Name), Namespace: instance.Namespace}
+
+	if err := r.client.Get(context.TODO(), key, sts); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to get prometheus statefulset")
+	}
+
+	if len(sts.Spec.VolumeClaimTemplates) == 0 {
+		return nil, nil
+	}
+
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+
+	template := sts.Spec.VolumeClaimTemplates[0].Name
+	pvcs := []corev1.PersistentVolumeClaim{}
+
+	for i := int32(0); i < replicas; i++ {
+		pvc := corev1.PersistentVolumeClaim{}
+		key := types.NamespacedName{Name: fmt.Sprintf("%s-%s-%d", template, sts.Name, i), Namespace: sts.Namespace}
+
+		if err := r.client.Get(context.TODO(), key, &pvc); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrap(err, "failed to get prometheus volume claim")
+		}
+
+		pvcs = append(pvcs, pvc)
+	}
+
+	return pvcs, nil
+}
+
+// volumeExpansionAllowed returns the storage class of the claim and if it
+// allows volume expansion.
+func (r *ReconcileMeterBase) volumeExpansionAllowed(
+	pvc *corev1.PersistentVolumeClaim,
+) (string, bool, error) {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return "", false, nil
+	}
+
+	name := *pvc.Spec.StorageClassName
+	storageClass := &storagev1.StorageClass{}
+
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: name}, storageClass); err != nil {
+		if kerrors.IsNotFound(err) {
+			return name, false, nil
+		}
+		return name, false, errors.Wrap(err, "failed to get storage class")
+	}
+
+	return name, storageClass.AllowVolumeExpansion != nil && *storageClass.AllowVolumeExpansion, nil
+}
@@ -0,0 +1,346 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterbase
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/gotidy/ptr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("StorageAutoExpand", func() {
+	const (
+		namespace = "openshift-redhat-marketplace"
+		name      = "rhm-marketplaceconfig-meterbase"
+		day       = 24 * time.Hour
+	)
+
+	var policy *marketplacev1alpha1.StorageAutoExpandSpec
+
+	BeforeEach(func() {
+		policy = &marketplacev1alpha1.StorageAutoExpandSpec{
+			MaxSize: resource.MustParse("100Gi"),
+			Step:    resource.NewQuantity(20*1024*1024*1024, resource.BinarySI),
+		}
+	})
+
+	Context("planning", func() {
+		size := resource.MustParse("40Gi")
+
+		It("should do nothing under the threshold", func() {
+			Expect(planStorage(policy, 79, size, 30*day, false, "gp2", true)).To(BeNil())
+
+			policy.UsedPercent = ptr.Int32(90)
+			Expect(planStorage(policy, 85, size, 30*day, false, "gp2", true)).To(BeNil())
+		})
+
+		It("should expand by the step up to the max size", func() {
+			plan := planStorage(policy, 80, size, 30*day, false, "gp2", true)
+			Expect(plan.size.String()).To(Equal("60Gi"))
+			Expect(plan.retention).To(BeEmpty())
+			Expect(plan.condition.Status).To(Equal(corev1.ConditionTrue))
+			Expect(plan.condition.Reason).To(Equal(marketplacev1alpha1.ReasonVolumesExpanded))
+			Expect(plan.condition.Message).To(ContainSubstring("from 40Gi to 60Gi"))
+
+			plan = planStorage(policy, 80, resource.MustParse("90Gi"), 30*day, false, "gp2", true)
+			Expect(plan.size.String()).To(Equal("100Gi"))
+		})
+
+		It("should reduce the retention if the storage class can't expand", func() {
+			plan := planStorage(policy, 90, size, 30*day, false, "standard", false)
+			Expect(plan.size).To(BeNil())
+			Expect(plan.retention).To(Equal("540h"))
+			Expect(plan.condition.Reason).To(Equal(marketplacev1alpha1.ReasonRetentionReduced))
+			Expect(plan.condition.Message).To(ContainSubstring(`storage class "standard" doesn't allow volume expansion`))
+		})
+
+		It("should reduce the retention at the max size down to the min", func() {
+			plan := planStorage(policy, 90, policy.MaxSize, 8*day, false, "gp2", true)
+			Expect(plan.size).To(BeNil())
+			Expect(plan.retention).To(Equal("1w"))
+			Expect(plan.condition.Message).To(ContainSubstring("at their max size 100Gi"))
+
+			plan = planStorage(policy, 90, policy.MaxSize, 7*day, false, "gp2", true)
+			Expect(plan.retention).To(BeEmpty())
+			Expect(plan.condition.Status).To(Equal(corev1.ConditionFalse))
+			Expect(plan.condition.Reason).To(Equal(marketplacev1alpha1.ReasonAutoExpandLimitReached))
+
+			policy.MinRetention = &metav1.Duration{Duration: 2 * day}
+			plan = planStorage(policy, 90, policy.MaxSize, 7*day, false, "gp2", true)
+			Expect(plan.retention).To(Equal("126h"))
+		})
+
+		It("should restore a reduced retention once the volumes can expand", func() {
+			Expect(planStorage(policy, 50, policy.MaxSize, 20*day, true, "gp2", true)).To(BeNil())
+			Expect(planStorage(policy, 50, size, 20*day, true, "standard", false)).To(BeNil())
+
+			plan := planStorage(policy, 50, size, 20*day, true, "gp2", true)
+			Expect(plan.size).To(BeNil())
+			Expect(plan.restoreRetention).To(BeTrue())
+			Expect(plan.condition.Reason).To(Equal(marketplacev1alpha1.ReasonRetentionRestored))
+
+			plan = planStorage(policy, 90, size, 20*day, true, "gp2", true)
+			Expect(plan.size.String()).To(Equal("60Gi"))
+			Expect(plan.restoreRetention).To(BeTrue())
+			Expect(plan.condition.Message).To(ContainSubstring("restored the retention from 20d"))
+		})
+	})
+
+	Context("reconciling", func() {
+		var (
+			instance *marketplacev1alpha1.MeterBase
+			ctrl     *ReconcileMeterBase
+			cc       ClientCommandRunner
+			recorder *record.FakeRecorder
+			now      time.Time
+
+			failingUpdates map[string]bool
+		)
+
+		storageRequest := func(claim *corev1.PersistentVolumeClaim) string {
+			Expect(ctrl.client.Get(context.TODO(), types.NamespacedName{Name: claim.Name, Namespace: namespace}, claim)).To(Succeed())
+			request := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+			return request.String()
+		}
+
+		prometheusStorageRequest := func() string {
+			prometheus := &monitoringv1.Prometheus{}
+			Expect(ctrl.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, prometheus)).To(Succeed())
+			request := prometheus.Spec.Storage.VolumeClaimTemplate.Spec.Resources.Requests[corev1.ResourceStorage]
+			return request.String()
+		}
+
+		pvc := func(i string) *corev1.PersistentVolumeClaim {
+			return &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "prometheus-" + name + "-db-prometheus-" + name + "-" + i,
+					Namespace: namespace,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: ptr.String("gp2"),
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("40Gi")},
+					},
+				},
+				Status: corev1.PersistentVolumeClaimStatus{
+					Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("40Gi")},
+				},
+			}
+		}
+
+		setup := func(allowExpansion bool) {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(marketplacev1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+			Expect(monitoringv1.AddToScheme(scheme)).To(Succeed())
+
+			prometheus := &monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Spec: monitoringv1.PrometheusSpec{
+					Storage: &monitoringv1.StorageSpec{
+						VolumeClaimTemplate: monitoringv1.EmbeddedPersistentVolumeClaim{
+							Spec: corev1.PersistentVolumeClaimSpec{
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("40Gi")},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			sts := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "prometheus-" + name, Namespace: namespace},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas: ptr.Int32(2),
+					VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+						{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-" + name + "-db"}},
+					},
+				},
+			}
+
+			storageClass := &storagev1.StorageClass{
+				ObjectMeta:           metav1.ObjectMeta{Name: "gp2"},
+				AllowVolumeExpansion: ptr.Bool(allowExpansion),
+			}
+
+			k8sClient := &failingUpdateClient{
+				Client: fake.NewFakeClientWithScheme(scheme,
+					instance.DeepCopy(), sts, storageClass, prometheus, pvc("0"), pvc("1")),
+				failing: failingUpdates,
+			}
+			cc = NewClientCommand(k8sClient, scheme, logf.Log)
+			recorder = record.NewFakeRecorder(10)
+			ctrl = &ReconcileMeterBase{client: k8sClient, scheme: scheme, recorder: recorder}
+		}
+
+		BeforeEach(func() {
+			failingUpdates = map[string]bool{}
+			now = time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+			instance = &marketplacev1alpha1.MeterBase{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Spec: marketplacev1alpha1.MeterBaseSpec{
+					Enabled: true,
+					Prometheus: &marketplacev1alpha1.PrometheusSpec{
+						Storage: marketplacev1alpha1.StorageSpec{
+							Size:       resource.MustParse("40Gi"),
+							AutoExpand: policy,
+						},
+					},
+				},
+				Status: marketplacev1alpha1.MeterBaseStatus{
+					PrometheusHealth: &marketplacev1alpha1.PrometheusHealthStatus{
+						DiskUsedPercent: ptr.Int32(85),
+					},
+				},
+			}
+		})
+
+		It("should expand the volumes of the statefulset", func() {
+			setup(true)
+
+			result, err := ctrl.reconcileStorageAutoExpand(cc, instance, logf.Log, now)
+			Expect(err).To(Succeed())
+			Expect(result.Is(Requeue)).To(BeTrue())
+
+			for _, i := range []string{"0", "1"} {
+				claim := &corev1.PersistentVolumeClaim{}
+				Expect(ctrl.client.Get(context.TODO(), types.NamespacedName{Name: pvc(i).Name, Namespace: namespace}, claim)).To(Succeed())
+				request := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+				Expect(request.String()).To(Equal("60Gi"))
+			}
+
+			Expect(prometheusStorageRequest()).To(Equal("60Gi"))
+			Expect(instance.Status.Storage.Size.String()).To(Equal("60Gi"))
+			Expect(instance.Status.Storage.Retention).To(BeEmpty())
+			Expect(instance.Status.Storage.LastActionTime.Time).To(Equal(now))
+			Expect(instance.Status.Conditions.IsTrueFor(marketplacev1alpha1.ConditionStorageAutoExpand)).To(BeTrue())
+			Expect(<-recorder.Events).To(HavePrefix("Normal VolumesExpanded"))
+
+			// the volumes are expanding and the policy waits for them
+			instance.Status.Storage.LastActionTime = &metav1.Time{Time: now.Add(-2 * time.Hour)}
+			result, err = ctrl.reconcileStorageAutoExpand(cc, instance, logf.Log, now)
+			Expect(err).To(Succeed())
+			Expect(result.Is(Continue)).To(BeTrue())
+			Expect(recorder.Events).To(BeEmpty())
+		})
+
+		It("should reduce the retention if the storage class can't expand", func() {
+			setup(false)
+
+			_, err := ctrl.reconcileStorageAutoExpand(cc, instance, logf.Log, now)
+			Expect(err).To(Succeed())
+
+			claim := &corev1.PersistentVolumeClaim{}
+			Expect(ctrl.client.Get(context.TODO(), types.NamespacedName{Name: pvc("0").Name, Namespace: namespace}, claim)).To(Succeed())
+			request := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+			Expect(request.String()).To(Equal("40Gi"))
+
+			Expect(instance.Status.Storage.Size).To(BeNil())
+			Expect(instance.Status.Storage.Retention).To(Equal("540h"))
+			Expect(instance.Status.Conditions.GetCondition(marketplacev1alpha1.ConditionStorageAutoExpand).Reason).
+				To(Equal(marketplacev1alpha1.ReasonRetentionReduced))
+			Expect(<-recorder.Events).To(HavePrefix("Normal RetentionReduced"))
+
+			// nothing more is done until the cooldown is over
+			instance.Status.PrometheusHealth.DiskUsedPercent = ptr.Int32(95)
+			_, err = ctrl.reconcileStorageAutoExpand(cc, instance, logf.Log, now.Add(30*time.Minute))
+			Expect(err).To(Succeed())
+			Expect(instance.Status.Storage.Retention).To(Equal("540h"))
+			Expect(recorder.Events).To(BeEmpty())
+		})
+
+		It("should record the size of partly expanded volumes", func() {
+			failingUpdates[pvc("1").Name] = true
+			setup(true)
+
+			_, err := ctrl.reconcileStorageAutoExpand(cc, instance, logf.Log, now)
+			Expect(err).To(Succeed())
+
+			Expect(storageRequest(pvc("0"))).To(Equal("60Gi"))
+			Expect(storageRequest(pvc("1"))).To(Equal("40Gi"))
+			Expect(prometheusStorageRequest()).To(Equal("60Gi"))
+
+			Expect(instance.Status.Storage.Size.String()).To(Equal("60Gi"))
+			cond := instance.Status.Conditions.GetCondition(marketplacev1alpha1.ConditionStorageAutoExpand)
+			Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(marketplacev1alpha1.ReasonVolumeExpansionFailed))
+			Expect(cond.Message).To(ContainSubstring("Failed to expand 1 of 2 volumes to 60Gi"))
+			Expect(<-recorder.Events).To(HavePrefix("Warning VolumeExpansionFailed"))
+		})
+
+		It("should restore the retention once the volumes can expand", func() {
+			instance.Status.PrometheusHealth.DiskUsedPercent = ptr.Int32(50)
+			instance.Status.Storage = &marketplacev1alpha1.StorageStatus{
+				Retention:      "540h",
+				LastActionTime: &metav1.Time{Time: now.Add(-2 * time.Hour)},
+			}
+			setup(true)
+
+			_, err := ctrl.reconcileStorageAutoExpand(cc, instance, logf.Log, now)
+			Expect(err).To(Succeed())
+
+			Expect(storageRequest(pvc("0"))).To(Equal("40Gi"))
+			Expect(instance.Status.Storage.Retention).To(BeEmpty())
+			Expect(instance.Status.Conditions.GetCondition(marketplacev1alpha1.ConditionStorageAutoExpand).Reason).
+				To(Equal(marketplacev1alpha1.ReasonRetentionRestored))
+			Expect(<-recorder.Events).To(HavePrefix("Normal RetentionRestored"))
+		})
+
+		It("should do nothing without a policy", func() {
+			instance.Spec.Prometheus.Storage.AutoExpand = nil
+			setup(true)
+
+			_, err := ctrl.reconcileStorageAutoExpand(cc, instance, logf.Log, now)
+			Expect(err).To(Succeed())
+			Expect(instance.Status.Storage).To(BeNil())
+			Expect(recorder.Events).To(BeEmpty())
+		})
+	})
+})
+
+// failingUpdateClient fails the updates of the named objects.
+type failingUpdateClient struct {
+	client.Client
+	failing map[string]bool
+}
+
+func (c *failingUpdateClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if accessor, err := meta.Accessor(obj); err == nil && c.failing[accessor.GetName()] {
+		return errors.New("update failed")
+	}
+
+	return c.Client.Update(ctx, obj, opts...)
+}
@@ -100,7 +100,15 @@ type ReconcileMeterDefinition struct {
 	patcher    patch.Patcher
 }
 
-type MeterDefOpts struct{}
+type MeterDefOpts struct {
+	// ResultsInterval is how often the workload results are queried
+	// from prometheus.
+	ResultsInterval time.Duration
+
+	// CaFile and TokenFile are used to authenticate with prometheus.
+	CaFile    string
+	TokenFile string
+}
 
 // Reconcile reads that state of the cluster for a MeterDefinition object and makes changes based on the state read
 // and what is in the MeterDefinition.Spec
@@ -147,6 +155,14 @@ func (r *ReconcileMeterDefinition) Reconcile(request reconcile.Request) (reconci
 		queue = instance.Status.Conditions.SetCondition(v1alpha1.MeterDefConditionHasResults)
 	}
 
+	if updateRevisions(instance, time.Now()) {
+		queue = true
+	}
+
+	if r.updateWorkloadResults(cc, instance, reqLogger) {
+		queue = true
+	}
+
 	result, _ = cc.Do(
 		context.TODO(),
 		Call(func() (ClientAction, error) {
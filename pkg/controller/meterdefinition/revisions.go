@@ -0,0 +1,111 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterdefinition
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// revisionRetention is how long a revision is kept after it stops
+// being effective. It needs to cover the reports that can still be
+// generated for that time.
+const revisionRetention = 60 * 24 * time.Hour
+
+// updateRevisions records a new revision when the meter definition spec
+// has changed and drops revisions that are past retention. Returns true
+// if the status changed.
+func updateRevisions(instance *v1alpha1.MeterDefinition, now time.Time) bool {
+	revisions := instance.Status.Revisions
+	changed := false
+
+	if len(revisions) == 0 || revisions[len(revisions)-1].Revision != instance.Generation {
+		revisions = addRevision(revisions, newRevision(instance, now))
+		changed = true
+	}
+
+	retained := make([]v1alpha1.MeterDefinitionRevision, 0, len(revisions))
+	for _, rev := range revisions {
+		if rev.EffectiveTo != nil && rev.EffectiveTo.Time.Add(revisionRetention).Before(now) {
+			changed = true
+			continue
+		}
+		retained = append(retained, rev)
+	}
+
+	if changed {
+		instance.Status.Revisions = retained
+	}
+
+	return changed
+}
+
+func newRevision(instance *v1alpha1.MeterDefinition, now time.Time) v1alpha1.MeterDefinitionRevision {
+	version := strconv.FormatInt(instance.Generation, 10)
+	if instance.Spec.Version != nil && *instance.Spec.Version != "" {
+		version = *instance.Spec.Version
+	}
+
+	effectiveFrom := metav1.NewTime(now)
+	switch {
+	case instance.Spec.EffectiveFrom != nil:
+		effectiveFrom = *instance.Spec.EffectiveFrom
+	case len(instance.Status.Revisions) == 0 && !instance.CreationTimestamp.IsZero():
+		effectiveFrom = instance.CreationTimestamp
+	}
+
+	rev := v1alpha1.MeterDefinitionRevision{
+		Revision:      instance.Generation,
+		Version:       version,
+		EffectiveFrom: effectiveFrom,
+		EffectiveTo:   instance.Spec.EffectiveTo.DeepCopy(),
+		Group:         instance.Spec.Group,
+		Kind:          instance.Spec.Kind,
+	}
+
+	for _, workload := range instance.Spec.Workloads {
+		rev.Workloads = append(rev.Workloads, *workload.DeepCopy())
+	}
+
+	return rev
+}
+
+// addRevision appends the revision and ends the previous revisions when
+// the new one becomes effective. Previous revisions that would not have
+// become effective before the new one are dropped.
+func addRevision(
+	revisions []v1alpha1.MeterDefinitionRevision,
+	rev v1alpha1.MeterDefinitionRevision,
+) []v1alpha1.MeterDefinitionRevision {
+	result := make([]v1alpha1.MeterDefinitionRevision, 0, len(revisions)+1)
+
+	for _, prev := range revisions {
+		if !prev.EffectiveFrom.Before(&rev.EffectiveFrom) {
+			continue
+		}
+
+		if prev.EffectiveTo == nil || rev.EffectiveFrom.Before(prev.EffectiveTo) {
+			effectiveTo := rev.EffectiveFrom
+			prev.EffectiveTo = &effectiveTo
+		}
+
+		result = append(result, prev)
+	}
+
+	return append(result, rev)
+}
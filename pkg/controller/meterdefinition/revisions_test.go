@@ -0,0 +1,107 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterdefinition
+
+import (
+	"time"
+
+	"github.com/gotidy/ptr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("meter definition revisions", func() {
+	var (
+		now      time.Time
+		meterdef *v1alpha1.MeterDefinition
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+		meterdef = &v1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "foo",
+				Namespace:         "bar",
+				Generation:        1,
+				CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+			},
+			Spec: v1alpha1.MeterDefinitionSpec{
+				Group: "apps.partner.metering.com",
+				Kind:  "App",
+				Workloads: []v1alpha1.Workload{
+					{Name: "pods", WorkloadType: v1alpha1.WorkloadTypePod},
+				},
+			},
+		}
+	})
+
+	It("should record the first revision from creation", func() {
+		Expect(updateRevisions(meterdef, now)).To(BeTrue())
+		Expect(meterdef.Status.Revisions).To(HaveLen(1))
+
+		rev := meterdef.Status.Revisions[0]
+		Expect(rev.Revision).To(Equal(int64(1)))
+		Expect(rev.Version).To(Equal("1"))
+		Expect(rev.EffectiveFrom.Time).To(Equal(meterdef.CreationTimestamp.Time))
+		Expect(rev.EffectiveTo).To(BeNil())
+		Expect(rev.Workloads).To(HaveLen(1))
+
+		Expect(updateRevisions(meterdef, now)).To(BeFalse())
+	})
+
+	It("should end the previous revision when the spec changes", func() {
+		updateRevisions(meterdef, now)
+
+		meterdef.Generation = 2
+		meterdef.Spec.Version = ptr.String("v2")
+		Expect(updateRevisions(meterdef, now.Add(time.Hour))).To(BeTrue())
+		Expect(meterdef.Status.Revisions).To(HaveLen(2))
+
+		Expect(meterdef.Status.Revisions[0].EffectiveTo.Time).To(Equal(now.Add(time.Hour)))
+		Expect(meterdef.Status.Revisions[0].IsEffectiveAt(now)).To(BeTrue())
+		Expect(meterdef.Status.Revisions[1].Version).To(Equal("v2"))
+		Expect(meterdef.Status.Revisions[1].IsEffectiveAt(now)).To(BeFalse())
+		Expect(meterdef.Status.Revisions[1].IsEffectiveAt(now.Add(time.Hour))).To(BeTrue())
+	})
+
+	It("should drop scheduled revisions that are replaced", func() {
+		updateRevisions(meterdef, now)
+
+		future := metav1.NewTime(now.Add(48 * time.Hour))
+		meterdef.Generation = 2
+		meterdef.Spec.EffectiveFrom = &future
+		updateRevisions(meterdef, now)
+
+		meterdef.Generation = 3
+		meterdef.Spec.EffectiveFrom = nil
+		updateRevisions(meterdef, now.Add(time.Hour))
+
+		Expect(meterdef.Status.Revisions).To(HaveLen(2))
+		Expect(meterdef.Status.Revisions[0].Revision).To(Equal(int64(1)))
+		Expect(meterdef.Status.Revisions[1].Revision).To(Equal(int64(3)))
+	})
+
+	It("should drop revisions past retention", func() {
+		updateRevisions(meterdef, now)
+		meterdef.Generation = 2
+		updateRevisions(meterdef, now)
+
+		Expect(updateRevisions(meterdef, now.Add(revisionRetention+time.Hour))).To(BeTrue())
+		Expect(meterdef.Status.Revisions).To(HaveLen(1))
+		Expect(meterdef.Status.Revisions[0].Revision).To(Equal(int64(2)))
+	})
+})
@@ -0,0 +1,291 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterdefinition
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils"
+	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	defaultResultsInterval = 5 * time.Minute
+	defaultCAFile          = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+	defaultTokenFile       = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+func (o *MeterDefOpts) resultsInterval() time.Duration {
+	if o == nil || o.ResultsInterval == 0 {
+		return defaultResultsInterval
+	}
+	return o.ResultsInterval
+}
+
+func (o *MeterDefOpts) caFile() string {
+	if o == nil || o.CaFile == "" {
+		return defaultCAFile
+	}
+	return o.CaFile
+}
+
+func (o *MeterDefOpts) tokenFile() string {
+	if o == nil || o.TokenFile == "" {
+		return defaultTokenFile
+	}
+	return o.TokenFile
+}
+
+// resultsAreStale returns true if the workload results on the status were
+// read longer ago than the interval, or have never been read.
+func resultsAreStale(instance *v1alpha1.MeterDefinition, interval time.Duration, now time.Time) bool {
+	if len(instance.Status.Results) == 0 {
+		return true
+	}
+
+	for _, result := range instance.Status.Results {
+		if result.LastReadTime.Time.Add(interval).Before(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findPrometheusService finds the prometheus service installed by an
+// enabled MeterBase. Returns nil if metering is not enabled.
+func (r *ReconcileMeterDefinition) findPrometheusService(
+	cc ClientCommandRunner,
+) (*corev1.Service, error) {
+	meterBaseList := &v1alpha1.MeterBaseList{}
+
+	result, _ := cc.Do(context.TODO(), ListAction(meterBaseList))
+
+	if !result.Is(Continue) {
+		return nil, result
+	}
+
+	var meterBase *v1alpha1.MeterBase
+	for i := range meterBaseList.Items {
+		if meterBaseList.Items[i].Spec.Enabled {
+			meterBase = &meterBaseList.Items[i]
+			break
+		}
+	}
+
+	if meterBase == nil {
+		return nil, nil
+	}
+
+	service := &corev1.Service{}
+	result, _ = cc.Do(context.TODO(),
+		GetAction(types.NamespacedName{
+			Name:      utils.METERBASE_PROMETHEUS_SERVICE_NAME,
+			Namespace: meterBase.Namespace,
+		}, service),
+	)
+
+	if result.Is(NotFound) {
+		return nil, nil
+	}
+
+	if !result.Is(Continue) {
+		return nil, result
+	}
+
+	return service, nil
+}
+
+// queryWorkloadResults runs an instant query for every metric of every
+// workload and returns the current value per workload metric.
+func queryWorkloadResults(
+	promAPI v1.API,
+	instance *v1alpha1.MeterDefinition,
+	now time.Time,
+	reqLogger logr.Logger,
+) ([]v1alpha1.WorkloadStatus, error) {
+	results := []v1alpha1.WorkloadStatus{}
+
+	for _, workload := range instance.Spec.Workloads {
+		for _, metric := range workload.MetricLabels {
+			aggregateFunc := metric.Aggregation
+			if aggregateFunc == "" {
+				aggregateFunc = "sum"
+			}
+
+			query := &prom.PromQuery{
+				Metric: metric.Label,
+				Type:   workload.WorkloadType,
+				MeterDef: types.NamespacedName{
+					Name:      instance.Name,
+					Namespace: instance.Namespace,
+				},
+				Query:         metric.Query,
+				AggregateFunc: aggregateFunc,
+			}
+
+			value, err := queryInstant(promAPI, query, now, reqLogger)
+
+			if err != nil {
+				return nil, err
+			}
+
+			status := v1alpha1.WorkloadStatus{
+				Name:         workload.Name,
+				MetricLabel:  metric.Label,
+				LastReadTime: metav1.NewTime(now),
+			}
+
+			if value != nil {
+				status.CurrentMetricValue = strconv.FormatFloat(*value, 'f', -1, 64)
+			}
+
+			results = append(results, status)
+		}
+	}
+
+	return results, nil
+}
+
+// queryInstant returns the sum of the vector returned by the query, or nil
+// if the query returned no data.
+func queryInstant(
+	promAPI v1.API,
+	query *prom.PromQuery,
+	now time.Time,
+	reqLogger logr.Logger,
+) (*float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	val, warnings, err := promAPI.Query(ctx, query.String(), now)
+
+	if err != nil {
+		reqLogger.Error(err, "querying prometheus", "query", query.String(), "warnings", warnings)
+		return nil, err
+	}
+
+	if len(warnings) > 0 {
+		reqLogger.Info("warnings", "warnings", warnings)
+	}
+
+	vector, ok := val.(model.Vector)
+
+	if !ok {
+		return nil, errors.NewWithDetails("unexpected query result type", "type", val.Type().String())
+	}
+
+	if len(vector) == 0 {
+		return nil, nil
+	}
+
+	var sum float64
+	for _, sample := range vector {
+		sum = sum + float64(sample.Value)
+	}
+
+	return &sum, nil
+}
+
+// reportingCondition returns the condition describing the results.
+func reportingCondition(results []v1alpha1.WorkloadStatus) status.Condition {
+	noData := []string{}
+
+	for _, result := range results {
+		if result.CurrentMetricValue == "" {
+			noData = append(noData, fmt.Sprintf("%s/%s", result.Name, result.MetricLabel))
+		}
+	}
+
+	if len(noData) == 0 {
+		return v1alpha1.MeterDefConditionDataInQuery
+	}
+
+	cond := v1alpha1.MeterDefConditionNoDataInQuery
+	cond.Message = fmt.Sprintf("%s: %s", cond.Message, strings.Join(noData, ", "))
+	return cond
+}
+
+// updateWorkloadResults queries the MeterBase prometheus for the current
+// value of each workload metric and records them on the status. Returns
+// true if the status changed.
+func (r *ReconcileMeterDefinition) updateWorkloadResults(
+	cc ClientCommandRunner,
+	instance *v1alpha1.MeterDefinition,
+	reqLogger logr.Logger,
+) bool {
+	now := time.Now()
+
+	if len(instance.Status.WorkloadResources) == 0 ||
+		!resultsAreStale(instance, r.opts.resultsInterval(), now) {
+		return false
+	}
+
+	service, err := r.findPrometheusService(cc)
+
+	if err != nil {
+		reqLogger.Error(err, "failed to find prometheus service")
+		return false
+	}
+
+	if service == nil {
+		reqLogger.Info("metering is not enabled, skipping workload results")
+		return false
+	}
+
+	client, err := r.newPrometheusClient(service)
+
+	if err != nil {
+		reqLogger.Error(err, "failed to create prometheus client")
+		return false
+	}
+
+	results, err := queryWorkloadResults(v1.NewAPI(client), instance, now, reqLogger)
+
+	if err != nil {
+		cond := v1alpha1.MeterDefConditionQueryErrored
+		cond.Message = fmt.Sprintf("%s: %s", cond.Message, err.Error())
+		return instance.Status.Conditions.SetCondition(cond)
+	}
+
+	instance.Status.Results = results
+	instance.Status.Conditions.SetCondition(reportingCondition(results))
+	return true
+}
+
+func (r *ReconcileMeterDefinition) newPrometheusClient(service *corev1.Service) (api.Client, error) {
+	return prom.NewSecureClientForService(
+		service,
+		intstr.FromString("rbac"),
+		r.opts.caFile(),
+		r.opts.tokenFile(),
+	)
+}
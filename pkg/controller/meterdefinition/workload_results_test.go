@@ -0,0 +1,115 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterdefinition
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakePromAPI struct {
+	v1.API
+	results map[string]model.Value
+	queries []string
+}
+
+func (f *fakePromAPI) Query(ctx context.Context, query string, ts time.Time) (model.Value, v1.Warnings, error) {
+	f.queries = append(f.queries, query)
+
+	for metric, val := range f.results {
+		if strings.HasSuffix(query, metric+"{})") {
+			return val, nil, nil
+		}
+	}
+
+	return model.Vector{}, nil, nil
+}
+
+var _ = Describe("workload results", func() {
+	var (
+		now      = time.Now()
+		meterdef *v1alpha1.MeterDefinition
+		promAPI  *fakePromAPI
+	)
+
+	BeforeEach(func() {
+		meterdef = &v1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: "bar",
+			},
+			Spec: v1alpha1.MeterDefinitionSpec{
+				Workloads: []v1alpha1.Workload{
+					{
+						Name:         "pods",
+						WorkloadType: v1alpha1.WorkloadTypePod,
+						MetricLabels: []v1alpha1.MeterLabelQuery{
+							{Label: "cpu_usage"},
+							{Label: "memory_usage", Aggregation: "max"},
+						},
+					},
+				},
+			},
+		}
+
+		promAPI = &fakePromAPI{
+			results: map[string]model.Value{
+				"cpu_usage": model.Vector{
+					&model.Sample{Value: 1.5},
+					&model.Sample{Value: 2},
+				},
+			},
+		}
+	})
+
+	It("should record the summed value per workload metric", func() {
+		results, err := queryWorkloadResults(promAPI, meterdef, now, log)
+
+		Expect(err).To(Succeed())
+		Expect(promAPI.queries).To(HaveLen(2))
+		Expect(promAPI.queries[0]).To(HavePrefix("sum by (pod,namespace)"))
+		Expect(promAPI.queries[1]).To(HavePrefix("max by (pod,namespace)"))
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Name).To(Equal("pods"))
+		Expect(results[0].MetricLabel).To(Equal("cpu_usage"))
+		Expect(results[0].CurrentMetricValue).To(Equal("3.5"))
+		Expect(results[1].MetricLabel).To(Equal("memory_usage"))
+		Expect(results[1].CurrentMetricValue).To(BeEmpty())
+
+		cond := reportingCondition(results)
+		Expect(cond.Reason).To(Equal(v1alpha1.MeterDefConditionReasonNoDataInQuery))
+		Expect(cond.Message).To(ContainSubstring("pods/memory_usage"))
+	})
+
+	It("should only query when results are stale", func() {
+		Expect(resultsAreStale(meterdef, time.Minute, now)).To(BeTrue())
+
+		meterdef.Status.Results = []v1alpha1.WorkloadStatus{
+			{Name: "pods", LastReadTime: metav1.NewTime(now.Add(-30 * time.Second))},
+		}
+		Expect(resultsAreStale(meterdef, time.Minute, now)).To(BeFalse())
+
+		meterdef.Status.Results[0].LastReadTime = metav1.NewTime(now.Add(-2 * time.Minute))
+		Expect(resultsAreStale(meterdef, time.Minute, now)).To(BeTrue())
+	})
+})
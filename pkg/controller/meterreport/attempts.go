@@ -0,0 +1,161 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterreport
+
+import (
+	"time"
+
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultRetryBackoff = time.Hour
+	maxRetryBackoff     = 24 * time.Hour
+
+	// maxAttemptHistory is the number of attempts kept in the status
+	maxAttemptHistory = 10
+)
+
+// retryBackoff returns the wait before the next attempt, doubled for each
+// failed attempt after the first.
+func retryBackoff(report *marketplacev1alpha1.MeterReport) time.Duration {
+	backoff := defaultRetryBackoff
+	if report.Spec.RetryBackoff != nil && report.Spec.RetryBackoff.Duration > 0 {
+		backoff = report.Spec.RetryBackoff.Duration
+	}
+
+	for i := int32(1); i < report.Status.FailedAttempts && backoff < maxRetryBackoff; i++ {
+		backoff = backoff * 2
+	}
+
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+
+	return backoff
+}
+
+// attemptsExhausted returns true if the report failed MaxAttempts times
+// since the last rerun.
+func attemptsExhausted(report *marketplacev1alpha1.MeterReport) bool {
+	return report.Spec.MaxAttempts != nil &&
+		report.Status.FailedAttempts >= *report.Spec.MaxAttempts
+}
+
+// lastAttempt returns the latest attempt of the report or nil.
+func lastAttempt(report *marketplacev1alpha1.MeterReport) *marketplacev1alpha1.ReportAttempt {
+	history := report.Status.AttemptHistory
+	if len(history) == 0 {
+		return nil
+	}
+
+	return &history[len(history)-1]
+}
+
+// runningAttempt returns the latest attempt if its job hasn't finished.
+func runningAttempt(report *marketplacev1alpha1.MeterReport) *marketplacev1alpha1.ReportAttempt {
+	attempt := lastAttempt(report)
+	if attempt == nil || attempt.Result != marketplacev1alpha1.ReportAttemptRunning {
+		return nil
+	}
+
+	return attempt
+}
+
+// startAttempt records a running attempt for the job.
+func startAttempt(report *marketplacev1alpha1.MeterReport, jobName string, now metav1.Time) {
+	history := append(report.Status.AttemptHistory, marketplacev1alpha1.ReportAttempt{
+		JobName:         jobName,
+		RerunGeneration: report.Spec.RerunGeneration,
+		StartTime:       now,
+		Result:          marketplacev1alpha1.ReportAttemptRunning,
+	})
+
+	if len(history) > maxAttemptHistory {
+		history = history[len(history)-maxAttemptHistory:]
+	}
+
+	report.Status.AttemptHistory = history
+}
+
+// finishAttempt records the result of the running attempt of the job. It
+// returns false if the job has no running attempt, like when the result
+// was already recorded.
+func finishAttempt(
+	report *marketplacev1alpha1.MeterReport,
+	jobName string,
+	result marketplacev1alpha1.ReportAttemptResult,
+	now metav1.Time,
+) bool {
+	attempt := runningAttempt(report)
+	if attempt == nil || attempt.JobName != jobName {
+		return false
+	}
+
+	attempt.Result = result
+	attempt.CompletionTime = &now
+
+	if result == marketplacev1alpha1.ReportAttemptFailed {
+		report.Status.FailedAttempts++
+	}
+
+	return true
+}
+
+// retryWait returns how long to wait before retrying the job of the
+// report. It's 0 if the last attempt didn't fail or the backoff passed.
+func retryWait(report *marketplacev1alpha1.MeterReport, now time.Time) time.Duration {
+	attempt := lastAttempt(report)
+	if attempt == nil ||
+		attempt.Result != marketplacev1alpha1.ReportAttemptFailed ||
+		attempt.RerunGeneration != report.Spec.RerunGeneration ||
+		attempt.CompletionTime == nil {
+		return 0
+	}
+
+	wait := attempt.CompletionTime.Add(retryBackoff(report)).Sub(now)
+	if wait < 0 {
+		return 0
+	}
+
+	return wait
+}
+
+// attemptRecorded returns true if the last attempt already recorded the
+// result of the done job. A job recreated with the same name after its
+// attempt failed started after the attempt finished.
+func attemptRecorded(report *marketplacev1alpha1.MeterReport, jr *common.JobReference) bool {
+	attempt := lastAttempt(report)
+	if attempt == nil ||
+		attempt.JobName != jr.Name ||
+		attempt.RerunGeneration != report.Spec.RerunGeneration ||
+		attempt.CompletionTime == nil {
+		return false
+	}
+
+	return jr.StartTime == nil || !jr.StartTime.After(attempt.CompletionTime.Time)
+}
+
+// attemptSucceeded returns true if the latest attempt ran the current job
+// of the report and succeeded.
+func attemptSucceeded(report *marketplacev1alpha1.MeterReport) bool {
+	attempt := lastAttempt(report)
+	return attempt != nil &&
+		attempt.Result == marketplacev1alpha1.ReportAttemptSucceeded &&
+		attempt.RerunGeneration == report.Spec.RerunGeneration &&
+		attempt.JobName == report.GetJobName()
+}
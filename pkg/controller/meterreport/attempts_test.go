@@ -0,0 +1,311 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterreport
+
+import (
+	"context"
+	"time"
+
+	"github.com/gotidy/ptr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	openshiftconfigv1 "github.com/openshift/api/config/v1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/config"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/manifests"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	batchv1 "k8s.io/api/batch/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// openshiftInfrastructure is the infrastructure of an OpenShift cluster
+// on AWS.
+func openshiftInfrastructure() *openshiftconfigv1.Infrastructure {
+	return &openshiftconfigv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status: openshiftconfigv1.InfrastructureStatus{
+			Platform: openshiftconfigv1.AWSPlatformType,
+		},
+	}
+}
+
+var _ = Describe("Attempts", func() {
+	var report *marketplacev1alpha1.MeterReport
+
+	BeforeEach(func() {
+		report = &marketplacev1alpha1.MeterReport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "meter-report-2020-09-16",
+				Namespace: "openshift-redhat-marketplace",
+			},
+		}
+	})
+
+	It("should double the backoff up to a day", func() {
+		Expect(retryBackoff(report)).To(Equal(time.Hour))
+
+		report.Spec.RetryBackoff = &metav1.Duration{Duration: 10 * time.Minute}
+		report.Status.FailedAttempts = 3
+		Expect(retryBackoff(report)).To(Equal(40 * time.Minute))
+
+		report.Status.FailedAttempts = 20
+		Expect(retryBackoff(report)).To(Equal(24 * time.Hour))
+	})
+
+	It("should record attempts", func() {
+		now := metav1.Now()
+
+		Expect(finishAttempt(report, "job", marketplacev1alpha1.ReportAttemptFailed, now)).To(BeFalse())
+
+		startAttempt(report, "job", now)
+		Expect(runningAttempt(report)).ToNot(BeNil())
+		Expect(finishAttempt(report, "other", marketplacev1alpha1.ReportAttemptFailed, now)).To(BeFalse())
+		Expect(finishAttempt(report, "job", marketplacev1alpha1.ReportAttemptFailed, now)).To(BeTrue())
+		Expect(finishAttempt(report, "job", marketplacev1alpha1.ReportAttemptFailed, now)).To(BeFalse())
+
+		Expect(runningAttempt(report)).To(BeNil())
+		Expect(report.Status.FailedAttempts).To(Equal(int32(1)))
+		Expect(retryWait(report, now.Time)).To(Equal(time.Hour))
+		Expect(retryWait(report, now.Add(2*time.Hour))).To(BeZero())
+
+		report.Spec.MaxAttempts = ptr.Int32(2)
+		Expect(attemptsExhausted(report)).To(BeFalse())
+
+		for i := 0; i < maxAttemptHistory+5; i++ {
+			startAttempt(report, "job", now)
+			finishAttempt(report, "job", marketplacev1alpha1.ReportAttemptFailed, now)
+		}
+
+		Expect(report.Status.AttemptHistory).To(HaveLen(maxAttemptHistory))
+		Expect(attemptsExhausted(report)).To(BeTrue())
+	})
+
+	Context("reconcile", func() {
+		var (
+			sut     *ReconcileMeterReport
+			request reconcile.Request
+		)
+
+		getReport := func() *marketplacev1alpha1.MeterReport {
+			report := &marketplacev1alpha1.MeterReport{}
+			Expect(sut.client.Get(context.TODO(), request.NamespacedName, report)).To(Succeed())
+			return report
+		}
+
+		getJob := func(name string) (*batchv1.Job, error) {
+			job := &batchv1.Job{}
+			err := sut.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: request.Namespace}, job)
+			return job, err
+		}
+
+		reconcileReport := func() reconcile.Result {
+			result, err := sut.Reconcile(request)
+			Expect(err).To(Succeed())
+			return result
+		}
+
+		failJob := func(name string) {
+			job, err := getJob(name)
+			Expect(err).To(Succeed())
+			job.Status.Failed = *job.Spec.BackoffLimit + 1
+			Expect(sut.client.Status().Update(context.TODO(), job)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(marketplacev1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+			Expect(openshiftconfigv1.AddToScheme(scheme)).To(Succeed())
+
+			report.Spec = marketplacev1alpha1.MeterReportSpec{
+				StartTime:   metav1.NewTime(time.Now().Add(-48 * time.Hour)),
+				EndTime:     metav1.NewTime(time.Now().Add(-24 * time.Hour)),
+				MaxAttempts: ptr.Int32(2),
+				PrometheusService: &common.ServiceReference{
+					Name:      "rhm-prometheus-meterbase",
+					Namespace: report.Namespace,
+				},
+			}
+
+			sut = &ReconcileMeterReport{
+				client:     fake.NewFakeClientWithScheme(scheme, report, openshiftInfrastructure()),
+				scheme:     scheme,
+				ccprovider: &reconcileutils.DefaultCommandRunnerProvider{},
+				cfg:        config.OperatorConfig{},
+			}
+			request = reconcile.Request{NamespacedName: types.NamespacedName{Name: report.Name, Namespace: report.Namespace}}
+		})
+
+		It("should retry failed jobs up to max attempts", func() {
+			reconcileReport()
+			_, err := getJob(report.Name)
+			Expect(err).To(Succeed())
+			Expect(runningAttempt(getReport())).ToNot(BeNil())
+
+			failJob(report.Name)
+			result := reconcileReport()
+			Expect(result.RequeueAfter).To(Equal(time.Hour))
+
+			_, err = getJob(report.Name)
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+
+			current := getReport()
+			Expect(current.Status.FailedAttempts).To(Equal(int32(1)))
+			Expect(current.Status.Conditions.GetCondition(marketplacev1alpha1.ReportConditionTypeJobRunning).Reason).
+				To(Equal(marketplacev1alpha1.ReportConditionReasonJobErrored))
+
+			By("waiting for the backoff")
+			result = reconcileReport()
+			Expect(result.RequeueAfter).To(BeNumerically(">", 59*time.Minute))
+
+			By("rerunning the report")
+			current.Spec.RerunGeneration = 1
+			Expect(sut.client.Update(context.TODO(), current)).To(Succeed())
+
+			reconcileReport()
+			Expect(getReport().Status.FailedAttempts).To(BeZero())
+
+			reconcileReport()
+			_, err = getJob(report.Name + "-1")
+			Expect(err).To(Succeed())
+
+			failJob(report.Name + "-1")
+			reconcileReport()
+
+			current = getReport()
+			current.Spec.RetryBackoff = &metav1.Duration{Duration: time.Nanosecond}
+			Expect(sut.client.Update(context.TODO(), current)).To(Succeed())
+
+			reconcileReport()
+			failJob(report.Name + "-1")
+			result = reconcileReport()
+			Expect(result.RequeueAfter).To(BeZero())
+
+			current = getReport()
+			Expect(current.Status.FailedAttempts).To(Equal(int32(2)))
+			Expect(current.Status.Conditions.GetCondition(marketplacev1alpha1.ReportConditionTypeJobRunning).Reason).
+				To(Equal(marketplacev1alpha1.ReportConditionReasonJobMaxAttempts))
+			Expect(current.Status.AttemptHistory).To(HaveLen(3))
+
+			reconcileReport()
+			_, err = getJob(report.Name + "-1")
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should record jobs that were done before they were seen", func() {
+			reconcileReport()
+
+			// the report lost track of its job, like when the operator was
+			// down while the job ran
+			current := getReport()
+			current.Status.AttemptHistory = nil
+			Expect(sut.client.Status().Update(context.TODO(), current)).To(Succeed())
+
+			started := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+			job, err := getJob(report.Name)
+			Expect(err).To(Succeed())
+			job.Status.StartTime = &started
+			job.Status.Succeeded = 1
+			Expect(sut.client.Status().Update(context.TODO(), job)).To(Succeed())
+
+			reconcileReport()
+
+			current = getReport()
+			Expect(current.Status.AttemptHistory).To(HaveLen(1))
+			Expect(current.Status.AttemptHistory[0].Result).To(Equal(marketplacev1alpha1.ReportAttemptSucceeded))
+			Expect(current.Status.AttemptHistory[0].StartTime.Equal(&started)).To(BeTrue())
+			Expect(current.Status.AttemptHistory[0].CompletionTime).ToNot(BeNil())
+
+			By("not recording the job twice")
+			reconcileReport()
+			Expect(getReport().Status.AttemptHistory).To(HaveLen(1))
+
+			By("counting a failed job")
+			current = getReport()
+			current.Status.AttemptHistory = nil
+			Expect(sut.client.Status().Update(context.TODO(), current)).To(Succeed())
+
+			job, err = getJob(report.Name)
+			Expect(err).To(Succeed())
+			job.Status.Succeeded = 0
+			job.Status.Failed = *job.Spec.BackoffLimit + 1
+			Expect(sut.client.Status().Update(context.TODO(), job)).To(Succeed())
+
+			reconcileReport()
+
+			current = getReport()
+			Expect(current.Status.FailedAttempts).To(Equal(int32(1)))
+			Expect(lastAttempt(current).Result).To(Equal(marketplacev1alpha1.ReportAttemptFailed))
+		})
+
+		It("should pass the cluster secrets to jobs off OpenShift", func() {
+			reconcileReport()
+			job, err := getJob(report.Name)
+			Expect(err).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Args).ToNot(ContainElement("--clusterid-secret"))
+			Expect(sut.client.Delete(context.TODO(), job)).To(Succeed())
+
+			infra := openshiftInfrastructure()
+			Expect(sut.client.Get(context.TODO(), types.NamespacedName{Name: "cluster"}, infra)).To(Succeed())
+			infra.Status.Platform = manifests.PlatformKubernetes
+			Expect(sut.client.Update(context.TODO(), infra)).To(Succeed())
+
+			sut.cfg.ClusterSecrets = config.ClusterSecrets{
+				ClusterIDSecret: "cluster-id",
+				UploadSecret:    "upload-credentials",
+			}
+
+			current := getReport()
+			current.Spec.RerunGeneration = 1
+			Expect(sut.client.Update(context.TODO(), current)).To(Succeed())
+
+			reconcileReport()
+			reconcileReport()
+			job, err = getJob(report.Name + "-1")
+			Expect(err).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Args).To(ContainElements(
+				"--clusterid-secret", "cluster-id", "--upload-secret", "upload-credentials"))
+		})
+
+		It("should cancel the running job when suspended", func() {
+			reconcileReport()
+
+			current := getReport()
+			current.Spec.Suspend = true
+			Expect(sut.client.Update(context.TODO(), current)).To(Succeed())
+
+			reconcileReport()
+
+			_, err := getJob(report.Name)
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+
+			current = getReport()
+			Expect(lastAttempt(current).Result).To(Equal(marketplacev1alpha1.ReportAttemptCancelled))
+			Expect(current.Status.Conditions.GetCondition(marketplacev1alpha1.ReportConditionTypeJobRunning).Reason).
+				To(Equal(marketplacev1alpha1.ReportConditionReasonJobSuspended))
+
+			reconcileReport()
+			_, err = getJob(report.Name)
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
@@ -29,8 +29,10 @@ import (
 	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -48,8 +50,24 @@ func Add(
 	mgr manager.Manager,
 	ccprovider ClientCommandRunnerProvider,
 	cfg config.OperatorConfig,
+	runnerFactory ReportRunnerFactory,
 ) error {
-	return add(mgr, newReconciler(mgr, ccprovider, cfg))
+	var runner ReportRunner
+
+	if runnerFactory != nil {
+		var err error
+		runner, err = runnerFactory(mgr)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the state of the reports is exported for the metering alerts
+	if err := registerReportMetrics(); err != nil {
+		return err
+	}
+
+	return add(mgr, newReconciler(mgr, ccprovider, cfg, runner), runner)
 }
 
 // newReconciler returns a new reconcile.Reconciler
@@ -57,6 +75,7 @@ func newReconciler(
 	mgr manager.Manager,
 	ccprovider ClientCommandRunnerProvider,
 	cfg config.OperatorConfig,
+	runner ReportRunner,
 ) reconcile.Reconciler {
 	return &ReconcileMeterReport{
 		client:     mgr.GetClient(),
@@ -64,11 +83,12 @@ func newReconciler(
 		ccprovider: ccprovider,
 		patcher:    patch.RHMDefaultPatcher,
 		cfg:        cfg,
+		runner:     runner,
 	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r reconcile.Reconciler, runner ReportRunner) error {
 	// Create a new controller
 	c, err := controller.New("meterreport-controller", mgr, controller.Options{Reconciler: r})
 	if err != nil {
@@ -98,6 +118,14 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for finished runs when reports run in the operator
+	if runner != nil {
+		err = c.Watch(&source.Channel{Source: runner.Events()}, &handler.EnqueueRequestForObject{})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -113,6 +141,9 @@ type ReconcileMeterReport struct {
 	cfg        config.OperatorConfig
 	ccprovider ClientCommandRunnerProvider
 	patcher    patch.Patcher
+
+	// runner runs the reports in the operator instead of as jobs if set
+	runner ReportRunner
 }
 
 // Reconcile reads that state of the cluster for a MeterReport object and makes changes based on the state read
@@ -132,6 +163,10 @@ func (r *ReconcileMeterReport) Reconcile(request reconcile.Request) (reconcile.R
 	if result, _ := cc.Do(context.TODO(), GetAction(request.NamespacedName, instance)); !result.Is(Continue) {
 		if result.Is(NotFound) {
 			reqLogger.Info("MeterReport resource not found. Ignoring since object must be deleted.")
+			if r.runner != nil {
+				r.runner.Remove(request.NamespacedName)
+			}
+			deleteReportMetrics(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 
@@ -147,9 +182,56 @@ func (r *ReconcileMeterReport) Reconcile(request reconcile.Request) (reconcile.R
 		instance.Status.Conditions = &conds
 	}
 
+	setReportMetrics(instance)
+
+	// a new rerun generation cancels the running job and resets the attempts
+	if instance.Spec.RerunGeneration != instance.Status.ObservedRerunGeneration {
+		reqLogger.Info("rerunning report", "rerunGeneration", instance.Spec.RerunGeneration)
+
+		if _, err := r.cancelRunningAttempt(instance); err != nil {
+			return reconcile.Result{}, err
+		}
+
+		instance.Status.ObservedRerunGeneration = instance.Spec.RerunGeneration
+		instance.Status.FailedAttempts = 0
+		instance.Status.AssociatedJob = nil
+		instance.Status.Conditions.SetCondition(marketplacev1alpha1.ReportConditionJobNotStarted)
+
+		result, _ := cc.Do(context.TODO(), UpdateAction(instance, UpdateStatusOnly(true)))
+		if result.Is(Error) {
+			reqLogger.Error(result.GetError(), "Failed to update status.")
+		}
+
+		return result.Return()
+	}
+
+	if instance.Spec.Suspend {
+		reqLogger.Info("report is suspended")
+
+		cancelled, err := r.cancelRunningAttempt(instance)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+
+		if instance.Status.Conditions.SetCondition(marketplacev1alpha1.ReportConditionJobSuspended) || cancelled {
+			result, _ := cc.Do(context.TODO(), UpdateAction(instance, UpdateStatusOnly(true)))
+			if result.Is(Error) {
+				reqLogger.Error(result.GetError(), "Failed to update status.")
+				return result.Return()
+			}
+		}
+
+		return reconcile.Result{}, nil
+	}
+
 	job := &batchv1.Job{}
 
 	c := manifests.NewOperatorConfig(r.cfg)
+	if err := c.LoadPlatform(manifests.InfrastructureLoader(context.TODO(), r.client)); err != nil {
+		reqLogger.Error(err, "failed to load platform")
+		return reconcile.Result{}, err
+	}
+
 	factory := manifests.NewFactory(instance.Namespace, c)
 
 	reqLogger.Info("config",
@@ -159,6 +241,11 @@ func (r *ReconcileMeterReport) Reconcile(request reconcile.Request) (reconcile.R
 	endTime := instance.Spec.EndTime.UTC()
 	now := metav1.Now().UTC()
 
+	// wait for the delay so late samples are in prometheus
+	if instance.Spec.Delay != nil {
+		endTime = endTime.Add(instance.Spec.Delay.Duration)
+	}
+
 	reqLogger.Info("time", "now", now, "endTime", endTime)
 
 	if now.UTC().Before(endTime.UTC()) {
@@ -181,29 +268,68 @@ func (r *ReconcileMeterReport) Reconcile(request reconcile.Request) (reconcile.R
 
 	}
 
-	result, _ := cc.Do(
-		context.TODO(),
-		HandleResult(
-			manifests.CreateIfNotExistsFactoryItem(
-				job,
-				func() (runtime.Object, error) {
-					return factory.ReporterJob(instance)
-				}, CreateWithAddOwner(instance),
-			),
-			OnRequeue(UpdateStatusCondition(instance, instance.Status.Conditions, marketplacev1alpha1.ReportConditionJobSubmitted)),
-		),
-	)
+	// the job name changes when the report is corrected by a backfill
+	if attempt := runningAttempt(instance); attempt != nil && attempt.JobName != instance.GetJobName() {
+		if _, err := r.cancelRunningAttempt(instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
 
-	if !result.Is(Continue) {
-		if result.Is(Error) {
-			reqLogger.Error(result.GetError(), "Failed to get create job.")
+	if runningAttempt(instance) == nil {
+		if attemptsExhausted(instance) {
+			reqLogger.Info("report is out of attempts", "failedAttempts", instance.Status.FailedAttempts)
+			result, _ := cc.Do(context.TODO(),
+				UpdateStatusCondition(instance, instance.Status.Conditions, marketplacev1alpha1.ReportConditionJobMaxAttempts),
+			)
+			if result.Is(Error) {
+				reqLogger.Error(result.GetError(), "Failed to update status.")
+				return result.Return()
+			}
+
+			return reconcile.Result{}, nil
 		}
 
-		return result.Return()
+		if wait := retryWait(instance, now); wait > 0 {
+			reqLogger.Info("waiting to retry report", "wait", wait)
+			return reconcile.Result{RequeueAfter: wait}, nil
+		}
 	}
 
+	var result *ExecResult
 	jr := &common.JobReference{}
-	jr.SetFromJob(job)
+
+	if r.runner != nil {
+		// runs are forgotten once their result is recorded
+		if attemptSucceeded(instance) {
+			reqLogger.Info("report already ran")
+			return reconcile.Result{}, nil
+		}
+
+		jr = r.runner.Run(request.NamespacedName, instance.GetJobName())
+	} else {
+		result, _ = cc.Do(
+			context.TODO(),
+			HandleResult(
+				manifests.CreateIfNotExistsFactoryItem(
+					job,
+					func() (runtime.Object, error) {
+						return factory.ReporterJob(instance)
+					}, CreateWithAddOwner(instance),
+				),
+				OnRequeue(UpdateStatusCondition(instance, instance.Status.Conditions, marketplacev1alpha1.ReportConditionJobSubmitted)),
+			),
+		)
+
+		if !result.Is(Continue) {
+			if result.Is(Error) {
+				reqLogger.Error(result.GetError(), "Failed to get create job.")
+			}
+
+			return result.Return()
+		}
+
+		jr.SetFromJob(job)
+	}
 
 	reqLogger.Info("reviewing job", "jr", jr,
 		"active", jr.Active,
@@ -215,7 +341,16 @@ func (r *ReconcileMeterReport) Reconcile(request reconcile.Request) (reconcile.R
 	// if job is not done, then update status and continue
 	if !jr.IsDone() {
 		reqLogger.Info("job not done", "jr", jr)
-		if !reflect.DeepEqual(instance.Status.AssociatedJob, jr) {
+		changed := false
+
+		// a job that isn't done without a running attempt was just created
+		if runningAttempt(instance) == nil {
+			startAttempt(instance, instance.GetJobName(), metav1.Now())
+			instance.Status.Conditions.SetCondition(marketplacev1alpha1.ReportConditionJobSubmitted)
+			changed = true
+		}
+
+		if changed || !reflect.DeepEqual(instance.Status.AssociatedJob, jr) {
 			instance.Status.AssociatedJob = jr
 
 			reqLogger.Info("Updating MeterReport status associatedJob")
@@ -236,26 +371,63 @@ func (r *ReconcileMeterReport) Reconcile(request reconcile.Request) (reconcile.R
 	reqLogger.Info("job is done", "jr", jr)
 	instance.Status.AssociatedJob = jr
 
+	// a job that was already done when it was first seen, like when the
+	// operator was down while it ran, gets an attempt that's finished below
+	if runningAttempt(instance) == nil && !attemptRecorded(instance, jr) {
+		start := metav1.Now()
+		if jr.StartTime != nil {
+			start = *jr.StartTime
+		}
+
+		startAttempt(instance, jr.Name, start)
+	}
+
 	// if report failed
 	switch {
 	case jr.IsFailed():
 		reqLogger.Info("job failed")
-		result, _ = cc.Do(context.TODO(),
-			DeleteAction(job, DeleteWithDeleteOptions(client.PropagationPolicy(metav1.DeletePropagationBackground))),
-			HandleResult(
-				UpdateStatusCondition(
-					instance,
-					instance.Status.Conditions,
-					marketplacev1alpha1.ReportConditionJobErrored),
-				OnAny(RequeueAfterResponse(1*time.Hour)),
-			),
-		)
+		finishAttempt(instance, jr.Name, marketplacev1alpha1.ReportAttemptFailed, metav1.Now())
+
+		condition := marketplacev1alpha1.ReportConditionJobErrored
+		requeue := reconcile.Result{RequeueAfter: retryBackoff(instance)}
+
+		if attemptsExhausted(instance) {
+			condition = marketplacev1alpha1.ReportConditionJobMaxAttempts
+			requeue = reconcile.Result{}
+		}
+
+		instance.Status.Conditions.SetCondition(condition)
+
+		actions := []ClientAction{UpdateAction(instance, UpdateStatusOnly(true))}
+		if r.runner != nil {
+			r.runner.Remove(request.NamespacedName)
+		} else {
+			actions = append([]ClientAction{
+				DeleteAction(job, DeleteWithDeleteOptions(client.PropagationPolicy(metav1.DeletePropagationBackground))),
+			}, actions...)
+		}
+
+		result, _ = cc.Do(context.TODO(), actions...)
+
+		if result.Is(Error) {
+			return result.Return()
+		}
+
+		return requeue, nil
 	case jr.IsSuccessful():
 		reqLogger.Info("job is complete")
-		result, _ = cc.Do(context.TODO(),
-			UpdateStatusCondition(instance, instance.Status.Conditions, marketplacev1alpha1.ReportConditionJobFinished),
-		)
+		changed := finishAttempt(instance, jr.Name, marketplacev1alpha1.ReportAttemptSucceeded, metav1.Now())
+		changed = instance.Status.Conditions.SetCondition(marketplacev1alpha1.ReportConditionJobFinished) || changed
+
+		if changed {
+			result, _ = cc.Do(context.TODO(),
+				UpdateAction(instance, UpdateStatusOnly(true)),
+			)
+		}
 
+		if r.runner != nil && (result == nil || !result.Is(Error)) {
+			r.runner.Remove(request.NamespacedName)
+		}
 	}
 
 	if result != nil && !result.Is(Continue) {
@@ -265,3 +437,34 @@ func (r *ReconcileMeterReport) Reconcile(request reconcile.Request) (reconcile.R
 	reqLogger.Info("reconcile finished")
 	return reconcile.Result{}, nil
 }
+
+// cancelRunningAttempt deletes the job, or stops the run, of the running
+// attempt and records the attempt as cancelled. It returns true if an
+// attempt was cancelled.
+func (r *ReconcileMeterReport) cancelRunningAttempt(instance *marketplacev1alpha1.MeterReport) (bool, error) {
+	attempt := runningAttempt(instance)
+	if attempt == nil {
+		return false, nil
+	}
+
+	if r.runner != nil {
+		r.runner.Remove(types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name})
+		log.Info("cancelled report run", "run", attempt.JobName)
+		return finishAttempt(instance, attempt.JobName, marketplacev1alpha1.ReportAttemptCancelled, metav1.Now()), nil
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      attempt.JobName,
+			Namespace: instance.Namespace,
+		},
+	}
+
+	err := r.client.Delete(context.TODO(), job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !kerrors.IsNotFound(err) {
+		return false, err
+	}
+
+	log.Info("cancelled report job", "job", attempt.JobName)
+	return finishAttempt(instance, attempt.JobName, marketplacev1alpha1.ReportAttemptCancelled, metav1.Now()), nil
+}
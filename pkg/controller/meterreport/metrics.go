@@ -0,0 +1,90 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterreport
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// The state of the meter reports is exported on the operator metrics
+// endpoint for the metering alerts. The reconciler sets the gauges of a
+// report each time it sees it.
+var (
+	reportLabels = []string{"meter_report", "meter_report_namespace"}
+
+	reportJobErrored = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "meter_report_job_errored",
+		Help: "Whether the job of the meter report errored or reached its maximum attempts.",
+	}, reportLabels)
+	reportUploadFailed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "meter_report_upload_failed",
+		Help: "Whether the last upload of the meter report failed.",
+	}, reportLabels)
+)
+
+func registerReportMetrics() error {
+	for _, c := range []prometheus.Collector{reportJobErrored, reportUploadFailed} {
+		err := metrics.Registry.Register(c)
+		if _, ok := err.(prometheus.AlreadyRegisteredError); err != nil && !ok {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setReportMetrics sets the gauges of the report from its status.
+func setReportMetrics(report *marketplacev1alpha1.MeterReport) {
+	jobErrored, uploadFailed := reportFailures(report)
+
+	reportJobErrored.WithLabelValues(report.Name, report.Namespace).Set(boolValue(jobErrored))
+	reportUploadFailed.WithLabelValues(report.Name, report.Namespace).Set(boolValue(uploadFailed))
+}
+
+// deleteReportMetrics removes the gauges of a deleted report.
+func deleteReportMetrics(key types.NamespacedName) {
+	reportJobErrored.DeleteLabelValues(key.Name, key.Namespace)
+	reportUploadFailed.DeleteLabelValues(key.Name, key.Namespace)
+}
+
+// reportFailures returns if the job of the report errored and if its last
+// upload failed.
+func reportFailures(report *marketplacev1alpha1.MeterReport) (jobErrored bool, uploadFailed bool) {
+	if report.Status.Conditions == nil {
+		return false, false
+	}
+
+	if cond := report.Status.Conditions.GetCondition(marketplacev1alpha1.ReportConditionTypeJobRunning); cond != nil {
+		jobErrored = cond.Reason == marketplacev1alpha1.ReportConditionReasonJobErrored ||
+			cond.Reason == marketplacev1alpha1.ReportConditionReasonJobMaxAttempts
+	}
+
+	if cond := report.Status.Conditions.GetCondition(marketplacev1alpha1.ReportConditionTypeUploaded); cond != nil {
+		uploadFailed = cond.Status == corev1.ConditionFalse
+	}
+
+	return jobErrored, uploadFailed
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
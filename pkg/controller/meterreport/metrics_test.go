@@ -0,0 +1,82 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterreport
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("reportMetrics", func() {
+	const namespace = "openshift-redhat-marketplace"
+
+	newReport := func(name string, conditions ...status.Condition) *marketplacev1alpha1.MeterReport {
+		conds := status.NewConditions(conditions...)
+		return &marketplacev1alpha1.MeterReport{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Status: marketplacev1alpha1.MeterReportStatus{
+				Conditions: &conds,
+			},
+		}
+	}
+
+	It("should export the failures of the reports", func() {
+		reportJobErrored.Reset()
+		reportUploadFailed.Reset()
+
+		registry := prometheus.NewPedanticRegistry()
+		registry.MustRegister(reportJobErrored, reportUploadFailed)
+
+		for _, report := range []*marketplacev1alpha1.MeterReport{
+			newReport("meter-report-2020-10-01",
+				marketplacev1alpha1.ReportConditionJobFinished,
+				marketplacev1alpha1.ReportConditionUploadFinished),
+			newReport("meter-report-2020-10-02",
+				marketplacev1alpha1.ReportConditionJobErrored,
+				marketplacev1alpha1.ReportConditionUploadFailed),
+			newReport("meter-report-2020-10-03",
+				marketplacev1alpha1.ReportConditionJobMaxAttempts),
+			newReport("meter-report-2020-10-04",
+				marketplacev1alpha1.ReportConditionJobErrored),
+		} {
+			setReportMetrics(report)
+		}
+
+		setReportMetrics(newReport("meter-report-2020-10-04",
+			marketplacev1alpha1.ReportConditionJobFinished))
+		deleteReportMetrics(types.NamespacedName{Name: "meter-report-2020-10-03", Namespace: namespace})
+
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP meter_report_job_errored Whether the job of the meter report errored or reached its maximum attempts.
+# TYPE meter_report_job_errored gauge
+meter_report_job_errored{meter_report="meter-report-2020-10-01",meter_report_namespace="openshift-redhat-marketplace"} 0
+meter_report_job_errored{meter_report="meter-report-2020-10-02",meter_report_namespace="openshift-redhat-marketplace"} 1
+meter_report_job_errored{meter_report="meter-report-2020-10-04",meter_report_namespace="openshift-redhat-marketplace"} 0
+# HELP meter_report_upload_failed Whether the last upload of the meter report failed.
+# TYPE meter_report_upload_failed gauge
+meter_report_upload_failed{meter_report="meter-report-2020-10-01",meter_report_namespace="openshift-redhat-marketplace"} 0
+meter_report_upload_failed{meter_report="meter-report-2020-10-02",meter_report_namespace="openshift-redhat-marketplace"} 1
+meter_report_upload_failed{meter_report="meter-report-2020-10-04",meter_report_namespace="openshift-redhat-marketplace"} 0
+`))).To(Succeed())
+	})
+})
@@ -0,0 +1,42 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterreport
+
+import (
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// ReportRunner runs reports in the operator process instead of as jobs.
+type ReportRunner interface {
+	// Run starts the run of the report if it isn't known yet and returns
+	// its state as a job reference, so the controller tracks attempts the
+	// same way for runs and jobs. A known run with another name is
+	// cancelled first.
+	Run(report types.NamespacedName, runName string) *common.JobReference
+
+	// Remove cancels the run of the report if it's still going and
+	// forgets it.
+	Remove(report types.NamespacedName)
+
+	// Events sends an event for the report when one of its runs finishes.
+	Events() <-chan event.GenericEvent
+}
+
+// ReportRunnerFactory creates the runner for the manager. Reports run as
+// jobs if the factory or the runner it returns is nil.
+type ReportRunnerFactory func(mgr manager.Manager) (ReportRunner, error)
@@ -0,0 +1,171 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meterreport
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	openshiftconfigv1 "github.com/openshift/api/config/v1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/config"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type fakeRunner struct {
+	runs    map[types.NamespacedName]*common.JobReference
+	started int
+}
+
+func (f *fakeRunner) Run(report types.NamespacedName, runName string) *common.JobReference {
+	if run, ok := f.runs[report]; ok && run.Name == runName {
+		return run
+	}
+
+	f.started++
+	f.runs[report] = &common.JobReference{Namespace: report.Namespace, Name: runName, Active: 1}
+	return f.runs[report]
+}
+
+func (f *fakeRunner) Remove(report types.NamespacedName) {
+	delete(f.runs, report)
+}
+
+func (f *fakeRunner) Events() <-chan event.GenericEvent {
+	return nil
+}
+
+var _ = Describe("ReportRunner", func() {
+	var (
+		sut     *ReconcileMeterReport
+		runner  *fakeRunner
+		request reconcile.Request
+	)
+
+	getReport := func() *marketplacev1alpha1.MeterReport {
+		report := &marketplacev1alpha1.MeterReport{}
+		Expect(sut.client.Get(context.TODO(), request.NamespacedName, report)).To(Succeed())
+		return report
+	}
+
+	reconcileReport := func() reconcile.Result {
+		result, err := sut.Reconcile(request)
+		Expect(err).To(Succeed())
+		return result
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(marketplacev1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+		Expect(openshiftconfigv1.AddToScheme(scheme)).To(Succeed())
+
+		report := &marketplacev1alpha1.MeterReport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "meter-report-2020-09-16",
+				Namespace: "openshift-redhat-marketplace",
+			},
+			Spec: marketplacev1alpha1.MeterReportSpec{
+				StartTime: metav1.NewTime(time.Now().Add(-48 * time.Hour)),
+				EndTime:   metav1.NewTime(time.Now().Add(-24 * time.Hour)),
+				PrometheusService: &common.ServiceReference{
+					Name:      "rhm-prometheus-meterbase",
+					Namespace: "openshift-redhat-marketplace",
+				},
+			},
+		}
+
+		runner = &fakeRunner{runs: map[types.NamespacedName]*common.JobReference{}}
+		sut = &ReconcileMeterReport{
+			client:     fake.NewFakeClientWithScheme(scheme, report, openshiftInfrastructure()),
+			scheme:     scheme,
+			ccprovider: &reconcileutils.DefaultCommandRunnerProvider{},
+			cfg:        config.OperatorConfig{},
+			runner:     runner,
+		}
+		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: report.Name, Namespace: report.Namespace}}
+	})
+
+	It("should run the report without a job", func() {
+		reconcileReport()
+		Expect(runner.started).To(Equal(1))
+
+		jobs := &batchv1.JobList{}
+		Expect(sut.client.List(context.TODO(), jobs)).To(Succeed())
+		Expect(jobs.Items).To(BeEmpty())
+
+		current := getReport()
+		Expect(runningAttempt(current)).ToNot(BeNil())
+		Expect(current.Status.AssociatedJob.Name).To(Equal(current.Name))
+		Expect(current.Status.Conditions.GetCondition(marketplacev1alpha1.ReportConditionTypeJobRunning).Reason).
+			To(Equal(marketplacev1alpha1.ReportConditionReasonJobSubmitted))
+
+		By("finishing the run")
+		run := runner.runs[request.NamespacedName]
+		run.Active = 0
+		run.Succeeded = 1
+
+		reconcileReport()
+		Expect(runner.runs).To(BeEmpty())
+
+		current = getReport()
+		Expect(lastAttempt(current).Result).To(Equal(marketplacev1alpha1.ReportAttemptSucceeded))
+		Expect(current.Status.Conditions.GetCondition(marketplacev1alpha1.ReportConditionTypeJobRunning).Reason).
+			To(Equal(marketplacev1alpha1.ReportConditionReasonJobFinished))
+
+		By("not running it again")
+		reconcileReport()
+		Expect(runner.started).To(Equal(1))
+	})
+
+	It("should retry failed runs and cancel on suspend", func() {
+		reconcileReport()
+
+		run := runner.runs[request.NamespacedName]
+		run.Active = 0
+		run.Failed = 1
+
+		result := reconcileReport()
+		Expect(result.RequeueAfter).To(Equal(time.Hour))
+		Expect(runner.runs).To(BeEmpty())
+		Expect(getReport().Status.FailedAttempts).To(Equal(int32(1)))
+
+		current := getReport()
+		current.Spec.RetryBackoff = &metav1.Duration{Duration: time.Nanosecond}
+		Expect(sut.client.Update(context.TODO(), current)).To(Succeed())
+
+		reconcileReport()
+		Expect(runner.started).To(Equal(2))
+
+		current = getReport()
+		current.Spec.Suspend = true
+		Expect(sut.client.Update(context.TODO(), current)).To(Succeed())
+
+		reconcileReport()
+		Expect(runner.runs).To(BeEmpty())
+		Expect(lastAttempt(getReport()).Result).To(Equal(marketplacev1alpha1.ReportAttemptCancelled))
+	})
+})
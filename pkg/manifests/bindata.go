@@ -14,14 +14,17 @@
 
 // Code generated for package manifests by go-bindata DO NOT EDIT. (@generated)
 // sources:
-// ../../assets/metric-state/deployment.yaml
+// ../../assets/kube-state-metrics/deployment.yaml
+// ../../assets/kube-state-metrics/service-monitor.yaml
+// ../../assets/kube-state-metrics/service.yaml
 // ../../assets/metric-state/service-monitor.yaml
 // ../../assets/metric-state/service.yaml
 // ../../assets/prometheus/additional-scrape-configs.yaml
 // ../../assets/prometheus/htpasswd-secret.yaml
 // ../../assets/prometheus/kube-rbac-proxy-secret.yaml
-// ../../assets/prometheus/kube-state-service-monitor.yaml
+// ../../assets/prometheus/kubelet-service-monitor.yaml
 // ../../assets/prometheus/kubelet-serving-ca-bundle.yaml
+// ../../assets/prometheus/operator-service-monitor.yaml
 // ../../assets/prometheus/prometheus-additional.yaml
 // ../../assets/prometheus/prometheus-datasources-secret.yaml
 // ../../assets/prometheus/prometheus-rules.yaml
@@ -29,6 +32,7 @@
 // ../../assets/prometheus/proxy-secret.yaml
 // ../../assets/prometheus/service.yaml
 // ../../assets/prometheus/serving-certs-ca-bundle.yaml
+// ../../assets/metric-state/statefulset.yaml
 // ../../assets/prometheus-operator/deployment.yaml
 // ../../assets/prometheus-operator/operator-certs-ca-bundle.yaml
 // ../../assets/prometheus-operator/service.yaml
@@ -113,22 +117,62 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _assetsMetricStateDeploymentYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xec\x57\x4b\x6f\x1b\x37\x10\xbe\xeb\x57\xf0\x96\x4b\xa9\x57\x9a\x36\x21\xe0\x83\x2b\xab\x75\x00\xdb\x11\x2a\xa3\x3d\x0a\x14\x35\xd2\x12\xe2\xab\x33\xb3\xae\x17\x45\xff\x7b\x41\xaf\x6c\xaf\x56\x92\x65\x35\x6d\x80\x00\xe1\x49\x22\xbf\x99\xf9\x76\x1e\xdf\x2e\x75\xb2\xbf\x01\x92\x8d\x41\x09\x9d\x12\xf5\xee\x06\x9d\xb5\x0d\x0b\x25\x2e\x20\xb9\x58\x79\x08\xdc\xf1\xc0\x7a\xa1\x59\xab\x8e\x10\x41\x7b\x50\x02\x0b\x2f\x3d\x30\x5a\x23\x89\x35\x43\x47\x08\xa7\xe7\xe0\x28\x43\x44\xf6\xd4\x5d\x97\x73\xc0\x00\x0c\xd4\xb5\xb1\x67\xa2\x4f\x31\x40\x60\x25\x4c\x0c\x8c\xd1\x39\xc0\x03\xd8\x03\x21\x28\x81\xc9\xee\x11\x92\xb3\x46\x93\x12\x83\x8e\x10\x04\x0e\x0c\x47\xac\x03\x7b\xcd\xa6\xb8\x6a\x30\x39\x8d\xcb\x09\x6c\x84\x60\xf0\xc9\x69\x86\x4d\xe4\x46\x8e\xf2\x72\x5b\x24\x4e\xa5\x71\x12\x11\x21\x1e\x53\x93\x57\xf6\xa5\x6d\x00\x6c\x04\x97\x9b\xb2\xed\x18\xd6\xcb\x7a\xbd\x3a\x72\x3a\x29\x9d\x9b\x44\x67\x4d\xa5\xc4\xc7\xe5\x4d\xe4\x09\x02\xe5\xde\x78\xc6\x21\x50\x2c\xd1\x40\x23\x6e\xbd\xfd\x47\x09\xc4\xad\x5d\x21\x4c\x2a\x95\x18\xf4\xfb\xbe\xb5\xef\xc1\x47\xac\x94\x18\xbc\xeb\x5f\xdb\xc6\x19\x81\x29\xd1\x72\x35\x8a\x81\xe1\x9e\x95\xf8\xeb\xef\xc6\x29\x03\x7a\x1b\x34\xdb\x18\xae\x81\x28\x33\xde\xb0\xfd\x59\x3b\x37\xd7\x66\x7d\x1b\xaf\xe2\x8a\x3e\x85\x31\x62\xc4\x86\x65\x8a\xd8\x26\x27\x9f\x93\x38\x89\xc8\x4a\xbc\xef\xbf\xef\xb7\x68\xd6\x09\xfd\x13\xe6\x47\x2d\x07\x7b\x2d\xeb\x64\x53\xa3\x44\x9b\x2a\x20\x2c\x0a\xcd\xd2\x6b\x5c\x03\x27\xa7\x0d\x48\x5d\x72\x61\x0a\x30\x6b\x95\xfb\x8d\x9a\x49\xaf\x9d\x3d\x01\x3e\xbb\x1c\x87\xaa\x31\xdc\x2e\xc6\xbf\x4d\xb7\x14\x1a\x57\x3b\xd9\x96\xd2\xc5\x15\x47\xe2\x05\x20\xee\x9c\x3d\x14\x1e\xa4\xb3\xc4\x10\xa4\x5e\x2c\x10\x88\xce\xd4\x87\xfe\x87\xe1\x0e\x96\x1d\x49\x63\x53\x01\x28\xa9\xb4\x0c\x74\x76\x7b\x35\x9d\x8d\x47\x17\x97\xe3\xd9\xaf\xd3\xf3\xd9\xef\x1f\x6f\x2f\x67\xe7\xe3\xe9\x6c\x30\x7c\x3f\xfb\x65\x74\x3d\x9b\x5e\x9e\x0f\xdf\xfd\xf0\xdd\x33\x6a\x3c\xba\x38\x82\xdb\xf1\x33\xfa\x69\xf4\x2a\x3f\x7b\x71\x2f\x78\xdb\x79\xba\x32\x11\x23\x68\x7f\x56\x30\x27\xd5\xeb\x0d\x86\x3f\x76\xfb\xdd\x7e\x77\xa0\x72\x83\xf6\xf6\x67\x03\x90\xe5\xd2\x3a\x38\xeb\x01\x9b\x1e\x3b\xea\x25\xb4\x77\x9a\x21\xff\xee\x1a\xe4\xbd\x66\x1b\x8c\x5c\x43\xf5\x82\xf5\x1a\xaa\x83\x24\xa5\xd1\x0d\x4b\x13\xc3\xd2\xae\xbc\x4e\xd4\x23\xc0\x3b\x1b\x56\x35\x33\xa3\xe5\xbc\x0c\x0b\x07\xf5\xb6\x01\x69\x74\x8b\xd4\xd3\x5c\xac\x2c\x31\x56\xdd\x7a\x40\xb2\x22\xc6\x04\x81\x0a\xbb\xe4\xef\x7b\x91\x40\x66\xb9\x94\x38\xd7\x46\x26\x8c\xf7\xd5\xee\xb0\xbc\x56\xc9\xea\xa1\x6a\xb9\x93\x83\x13\x45\x63\xa7\x41\x1f\x1d\xe7\xea\xd1\x97\x9a\xd4\xff\x4b\x36\xef\xa2\x2b\x3d\x5c\xc7\x32\xec\x26\xc2\xe7\xdd\x89\xe6\x42\x89\x76\xdb\xec\x4d\x48\xfb\x9d\x96\x3b\xb0\x05\x44\xd0\x8b\x4f\xc1\x55\x4a\x2c\xb5\x23\x38\x12\xf0\x68\xb7\xed\xa5\xd1\x84\xd2\x41\xec\x21\x26\xff\xad\xb4\xbd\xfd\x26\x6d\xcf\xd2\x36\xf8\x1a\xa5\x8d\xbe\x26\x6d\x1b\x9e\xae\x6d\x6f\x0f\x6b\x9b\x6c\x7f\xdc\x7c\xd3\xb8\x2f\xa6\x71\x87\x85\xeb\xf3\x45\x2e\xc4\x05\x4c\xb7\xee\x5b\x79\xcd\x81\x75\xeb\xaa\x12\x49\x09\x67\x43\x79\xff\x04\xca\xa6\x12\xa3\x83\x16\xd2\x6b\x62\x40\x25\xde\xbc\xd9\x40\x13\xda\xf8\x50\x4b\xa7\x89\x6e\x6a\xca\x15\x31\x78\x69\x5c\x99\xb1\xd2\xa0\x65\x6b\xb4\xeb\x1c\x2b\xfe\x66\xea\xce\x8d\xc9\xa9\xab\x7d\xed\xf9\xba\x8e\x09\x50\xf3\x53\xe1\x39\xba\xfc\xdf\xc6\xb0\x75\x81\x82\xe5\x12\x0c\x2b\x71\x13\xa7\xa6\x80\x45\xb9\x95\xb2\x35\x54\xea\xc8\x23\x36\xd0\x8f\x01\x95\x18\xdf\x5b\xe2\xc7\x36\xa8\xbb\x6d\xcf\xad\xed\x48\xeb\x10\x18\x04\xde\xee\xcf\x7a\xef\xe6\xb8\xf9\xc3\x64\x2f\xed\xea\x5a\xa7\x6d\x0f\xaf\xed\x96\x97\x71\xff\x04\x00\x00\xff\xff\x21\xe1\x3c\x78\x5f\x10\x00\x00")
+var _assetsKubeStateMetricsDeploymentYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9d\x54\x4d\x6f\xdb\x30\x0c\xbd\xe7\x57\xe8\x0f\x38\x1f\x87\x02\x85\x81\x1e\x86\x75\x1b\x06\xb4\x5d\x80\x0e\xbb\x33\x32\x1b\x0b\x91\x44\x8d\xa2\x8b\x64\xc3\xfe\xfb\xa8\x25\x69\x9c\xc4\xed\x96\xc9\x27\xeb\x3d\x91\x4f\x8f\xa4\x20\xb9\x6f\xc8\xd9\x51\xac\x0d\xa4\x94\x27\xcf\xb3\xd1\xca\xc5\xa6\x36\xb7\x98\x3c\x6d\x02\x46\x19\x05\x14\x68\x40\xa0\x1e\x19\x13\x21\x60\x6d\xb8\x0d\xd5\xaa\x5b\x60\x95\x05\x04\x2b\x25\xb0\xb3\x59\x61\x0f\x0b\xf4\xb9\x10\x4d\x89\x37\x2e\x24\x8e\x28\x98\xc7\x8e\x26\x96\x42\xa2\xa8\x21\x6b\x83\xeb\x44\x2c\xc8\xaf\x30\xdf\x4c\x93\x13\xda\x92\x82\x55\xa2\xb3\x90\x6b\x33\xd3\xbf\x8c\x1e\xad\x10\x6f\x93\x07\x10\xdb\xde\xf5\xd4\x5c\xa2\xe7\x62\x45\xc6\x08\x86\xe4\x75\x6b\x97\xbd\xe7\x58\x59\xfe\x48\xc8\x65\x52\xfe\x43\x8c\x9a\xb1\xb3\xa8\x2c\x4b\x51\xc0\x45\x2d\xf3\x41\x40\xb5\x2b\xe4\x2b\xc7\xb7\xcb\x05\x58\xfe\x13\x67\xde\x79\x3f\x27\xad\xc5\xa6\x36\x9f\x9f\x1e\x48\xe6\x8c\xb9\x74\xce\x81\x07\xbc\xec\xa5\xdf\x4a\xa8\xaa\x96\xb2\xdc\x4c\xc7\x7f\xbe\x33\xb0\xb8\x70\x73\x3d\xbd\x3e\x47\x44\x4b\x5d\xa4\x6c\xde\x0e\x70\xa0\xed\x43\xcd\x7a\x9c\xb2\x77\x26\xe9\xc5\xab\xb9\xa2\xb5\x39\xcb\xbe\x1f\x80\x56\x24\x0d\xb8\x31\x1c\x62\x36\x18\xe2\x45\x5d\x0f\x65\x84\x46\x8f\xe6\x3c\x67\x5a\xe0\xb1\xb8\x92\xf2\x13\x4a\x7d\x12\x2c\x81\xb4\xb5\x99\xb4\x08\x5e\xda\x1f\xa7\xe0\xf0\x2d\x5c\x74\xe2\xc0\xdf\xa2\x87\xcd\x23\xaa\xe0\x46\x67\xe8\xea\x88\x22\x2e\x20\x75\x32\x88\x6a\x71\xa9\x63\x8b\x27\xee\x31\x7e\xef\x30\x9f\x7a\xaa\xfd\x97\x3a\x9d\xd0\x69\x38\xd9\x0e\x18\x88\xb5\x61\x66\x57\xd3\x7b\xd7\xc3\x32\xda\x8e\x9d\x6c\xde\xab\x8f\xb8\x56\xf9\x3f\x7f\xf5\x50\x9d\x8b\xe0\x22\x88\xbe\x58\xf7\xea\x53\x69\xbe\x5d\xe3\x7d\x04\xef\x17\x60\x57\x5f\xe9\x8e\x96\xf9\x4b\xfc\xc0\x4c\xfb\x19\x8a\xd4\xe0\xe3\xd1\xfb\x50\xd6\xf1\x4c\x91\xde\xd2\xbb\xd8\xad\x47\x7f\xd3\x91\x91\x9f\x9d\xc5\x77\xd6\x52\x17\xe5\x61\x3b\x8b\xd8\xb4\x20\x55\x00\x5e\xa1\xe8\x4b\x60\xb1\xa2\x84\x0c\x9a\x70\xf4\x1b\xe2\xb2\x0e\xa2\x65\x05\x00\x00")
 
-func assetsMetricStateDeploymentYamlBytes() ([]byte, error) {
+func assetsKubeStateMetricsDeploymentYamlBytes() ([]byte, error) {
 	return bindataRead(
-		_assetsMetricStateDeploymentYaml,
-		"assets/metric-state/deployment.yaml",
+		_assetsKubeStateMetricsDeploymentYaml,
+		"assets/kube-state-metrics/deployment.yaml",
 	)
 }
 
-func assetsMetricStateDeploymentYaml() (*asset, error) {
-	bytes, err := assetsMetricStateDeploymentYamlBytes()
+func assetsKubeStateMetricsDeploymentYaml() (*asset, error) {
+	bytes, err := assetsKubeStateMetricsDeploymentYamlBytes()
 	if err != nil {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "assets/metric-state/deployment.yaml", size: 4191, mode: os.FileMode(420), modTime: time.Unix(1601666853, 0)}
+	info := bindataFileInfo{name: "assets/kube-state-metrics/deployment.yaml", size: 1381, mode: os.FileMode(420), modTime: time.Unix(1792400670, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _assetsKubeStateMetricsServiceMonitorYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x95\x51\x31\x6e\xc3\x30\x0c\xdc\xfd\x0a\x7e\xc0\x0e\xda\x51\x6f\x48\xa7\x16\xdd\x69\xf9\x50\xab\xb1\x44\x81\xa2\x83\xf4\xf7\x95\xec\x0c\x59\x5c\x34\x93\x84\x23\x79\x77\xe4\x71\x0e\x9f\xd0\x12\x24\x39\x8a\x92\x82\x89\x86\xf4\x35\x78\x51\x48\xa9\x4f\x3c\x5d\x5f\xba\x4b\x48\x93\xa3\x77\xe8\x35\x78\xbc\xed\x5d\x5d\x84\xf1\xc4\xc6\xae\x23\x5a\x78\xc4\x52\xda\x8f\x88\x73\x1e\x2e\xeb\x08\x4d\x30\x94\x21\xc8\xa9\xb2\x64\x49\x48\xe6\x08\xb7\x2c\x6a\xd0\x83\xce\xc4\x11\x8e\x74\x8e\x7d\xc3\xfb\x62\x6c\xe8\xab\x90\x06\x5f\xea\xc8\x9f\xe5\x92\xe1\x9b\x03\xa4\x29\x4b\x48\xb6\xd9\xe9\x69\x96\x24\x7a\xde\xfd\x91\xe9\x8a\x4d\xba\xd6\xeb\x36\xbc\x38\x7a\x8d\x1b\xd0\x6c\x39\x9a\xcd\xf2\x83\x1e\x51\xf1\x33\x9a\x66\x2b\xdc\x01\xe5\x8c\x8f\x10\x21\xab\xdd\xa7\xfb\x23\x3a\xc3\x82\xc6\xf6\xf3\x7f\xae\x6f\x19\x37\xb3\xee\xe0\x3a\xb5\xa5\x54\x56\x5f\x13\xd8\xcf\x1d\xd9\xfc\x7c\x7e\xb8\xff\x33\x09\x3c\x9d\xc1\x2f\xa3\xc0\x24\xd5\x2f\x02\x00\x00")
+
+func assetsKubeStateMetricsServiceMonitorYamlBytes() ([]byte, error) {
+	return bindataRead(
+		_assetsKubeStateMetricsServiceMonitorYaml,
+		"assets/kube-state-metrics/service-monitor.yaml",
+	)
+}
+
+func assetsKubeStateMetricsServiceMonitorYaml() (*asset, error) {
+	bytes, err := assetsKubeStateMetricsServiceMonitorYamlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "assets/kube-state-metrics/service-monitor.yaml", size: 559, mode: os.FileMode(420), modTime: time.Unix(1792400670, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _assetsKubeStateMetricsServiceYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb5\x90\xbd\x0e\xc2\x30\x0c\x84\xf7\x3e\x85\x5f\xa0\xd0\x6e\x28\x2b\x13\x1b\x12\x12\xbb\x1b\x4e\x10\xd1\x26\x91\xe3\x56\xf0\xf6\x24\xa8\x48\x88\xbf\x0d\x4f\xd6\xf9\xfc\x9d\x65\x8e\x6e\x0f\x49\x2e\x78\x43\x53\x5b\x9d\x9d\x3f\x18\xda\x41\x26\x67\x51\x0d\x50\x3e\xb0\xb2\xa9\x88\x7a\xee\xd0\xa7\xd2\x11\x71\x8c\x8b\xf3\xd8\x41\x3c\x14\x69\xe1\xc2\xd2\x86\x21\x06\x0f\xaf\x86\x70\x89\x41\x14\xf2\xc5\xe9\x79\x80\x21\x39\x0d\x75\xd1\xeb\xa4\xac\xa8\x73\x90\x38\x9b\xf2\xca\xcf\x71\x8a\xb0\xe5\x82\x12\x30\x9f\x52\xcf\x1b\x27\xd5\xf8\x84\x29\x55\x5c\x86\x56\xcd\xaa\x99\x05\x65\x39\x42\xb7\x77\xf9\xcd\xff\x00\x29\x7a\x14\xfd\xfa\x4a\x69\x3f\x50\x9e\xcd\x29\xf7\x56\x83\xfc\xfd\x45\x7a\x8d\x79\xbc\xee\xc7\x94\x11\x9b\x6d\x75\x03\x4b\xc5\x25\xb9\xc3\x01\x00\x00")
+
+func assetsKubeStateMetricsServiceYamlBytes() ([]byte, error) {
+	return bindataRead(
+		_assetsKubeStateMetricsServiceYaml,
+		"assets/kube-state-metrics/service.yaml",
+	)
+}
+
+func assetsKubeStateMetricsServiceYaml() (*asset, error) {
+	bytes, err := assetsKubeStateMetricsServiceYamlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "assets/kube-state-metrics/service.yaml", size: 451, mode: os.FileMode(420), modTime: time.Unix(1792400670, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -233,22 +277,22 @@ func assetsPrometheusKubeRbacProxySecretYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsPrometheusKubeStateServiceMonitorYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xd4\x93\x41\xab\xd5\x30\x10\x85\xf7\xfd\x15\xe1\xed\xd3\xa2\x2b\xe9\x56\x10\x84\xa7\x1b\x1f\xee\xa7\xd3\xf3\x6e\x63\x93\x4c\x98\x4c\x2f\xf8\xef\x25\xe9\xbd\x22\x22\x17\x04\x37\xee\xc2\xe1\xcc\xe4\x9b\x93\x0c\x95\xf0\x15\x5a\x83\xe4\xd9\x25\xc9\xc1\x44\x43\xbe\x8c\x2c\x0a\xa9\x23\x4b\x9a\xae\x6f\x86\x3d\xe4\x75\x76\x5f\xa0\xd7\xc0\xf8\x74\xba\x86\x04\xa3\x95\x8c\xe6\xc1\xb9\x48\x0b\x62\x6d\x27\xe7\x12\xe9\x0e\x2b\x91\x18\xa3\x62\xdd\xc8\x7a\x9b\x15\x25\xca\x77\xac\xb3\x7b\x32\x3d\xf0\xf4\xc8\x9b\x60\xd0\xbf\xb2\x8e\x27\xe2\xc7\x6c\xd0\x4c\xf1\x37\x54\xe7\x32\x25\xcc\x6e\x3f\x16\xf8\x6a\x64\xf0\x09\xa6\x81\xeb\x50\x0b\xb8\x71\x23\xaf\x45\x42\xb6\x3e\x84\x77\x0b\x48\xa1\x2f\xb2\x23\x7f\x08\x11\xb3\x9b\xae\xa4\x93\x1e\x79\xaa\x60\x85\xd5\xa9\xf5\xd2\x0c\x43\x1d\x83\x4c\xf5\xbc\x90\x98\xe5\xc8\x36\x59\x2b\xec\xd8\x9b\x64\xd1\xe7\x33\x1e\xd7\xa6\xe9\x6a\x68\x9c\x57\x8a\xb3\x7b\x9b\xba\x50\x44\x6d\x76\x9b\x59\xa9\x3e\x51\x38\x6b\x2b\x6f\x68\xd8\x5d\xbe\x29\x4a\x05\x2f\x21\x41\x0e\xfb\x59\x6c\xb1\xbe\x97\xfc\x1a\x2e\xe7\x03\x38\xc7\x74\x83\x86\xf1\x54\x54\x12\x6c\xc3\x51\x27\xee\xae\x44\xa5\x9e\xc0\xf9\xe2\x19\x6a\xd5\x33\xf9\xe5\xc8\x6b\xc4\x7d\x10\xcf\x34\xb2\xda\xad\x5f\x13\xa1\x9f\x7b\x86\xe7\xd9\xb7\x40\xbd\xa2\xbf\xc7\xea\xc9\xbc\x1e\xd9\x42\xc2\xbf\x4d\xef\x61\x4e\x15\xf1\xf5\x7f\xce\xe9\x9b\x2c\xfd\x63\xcc\x6e\x7f\x57\x3d\x95\x32\xb4\x0e\x11\x6c\xa2\xf7\x55\x32\xde\x9e\x7f\xd9\x2d\x77\xb7\xfe\xf1\x2f\xff\x08\x00\x00\xff\xff\x14\x46\xa1\xb2\xcc\x03\x00\x00")
+var _assetsPrometheusKubeletServiceMonitorYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xc5\x52\x31\x6e\xc3\x30\x0c\xdc\xf3\x0a\x7d\xc0\x31\xda\xa9\xd0\x5a\xa0\x53\xda\x25\x41\x76\x46\x66\x62\xd6\xb6\x28\x90\xb4\x81\xfc\xbe\x92\x9c\xa6\x05\xda\xb9\x9d\x24\x9d\x48\xde\x9d\x74\x90\xe8\x88\xa2\xc4\xd1\xbb\x89\x23\x19\x0b\xc5\xcb\x36\xb0\x20\x6b\x5e\xa6\x76\x79\xd8\x0c\x14\x3b\xef\xf6\x28\x0b\x05\x7c\x5d\xab\x36\x13\x1a\x74\x60\xe0\x37\xce\x8d\x70\xc2\x51\xcb\xce\xb9\xe1\x49\x1b\x48\xc9\xbb\x61\xce\x20\x5a\x06\x23\x4c\xf8\x75\xd6\x84\xa1\x94\x62\xec\x12\x53\xb4\xda\xd7\xb8\x13\x82\xa0\x1c\x78\xc0\xf8\x42\x63\xae\x6f\x17\x90\x56\xe6\xd8\x2a\x06\x41\xd3\xb6\x0c\x90\x88\x86\xba\x25\xce\x68\x95\x03\x21\xf0\x1c\xad\xb5\xd2\x58\x05\xf4\x1c\x59\x76\xab\x22\x67\x32\x63\x45\x33\x51\x6e\x80\xd1\xbb\xc7\xa9\x02\x89\xc5\xbc\xeb\xcd\x92\x36\xd9\x8b\x50\xd0\x8a\x6b\xe8\xb1\xc8\xad\x37\x37\x44\x20\xe1\x81\x26\xe4\xd9\xee\xfd\x36\xea\x33\xc7\x33\x5d\x56\xdb\x85\x21\x0b\x9d\x05\xf7\x03\xa5\xfc\xa6\x74\xbe\xde\xe9\xff\xc1\x1d\x58\x9f\x49\x6e\xc6\xda\x00\xdd\x42\x9a\x7f\xed\xaf\x9d\xbf\xf3\xa9\x8a\xf5\x9f\xb9\xb8\xc5\x41\x13\x04\xdc\xe7\x3c\x84\x9c\xa5\x75\xce\x04\x16\xfa\xb7\x72\xb7\x9e\x9b\x9a\x98\x46\xaf\x6a\x58\x88\xf5\x67\xf5\xee\x5b\xee\x7e\x49\xde\x07\x9b\xbf\x40\x51\xdd\x02\x00\x00")
 
-func assetsPrometheusKubeStateServiceMonitorYamlBytes() ([]byte, error) {
+func assetsPrometheusKubeletServiceMonitorYamlBytes() ([]byte, error) {
 	return bindataRead(
-		_assetsPrometheusKubeStateServiceMonitorYaml,
-		"assets/prometheus/kube-state-service-monitor.yaml",
+		_assetsPrometheusKubeletServiceMonitorYaml,
+		"assets/prometheus/kubelet-service-monitor.yaml",
 	)
 }
 
-func assetsPrometheusKubeStateServiceMonitorYaml() (*asset, error) {
-	bytes, err := assetsPrometheusKubeStateServiceMonitorYamlBytes()
+func assetsPrometheusKubeletServiceMonitorYaml() (*asset, error) {
+	bytes, err := assetsPrometheusKubeletServiceMonitorYamlBytes()
 	if err != nil {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "assets/prometheus/kube-state-service-monitor.yaml", size: 972, mode: os.FileMode(420), modTime: time.Unix(1599587957, 0)}
+	info := bindataFileInfo{name: "assets/prometheus/kubelet-service-monitor.yaml", size: 733, mode: os.FileMode(420), modTime: time.Unix(1792400670, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -273,6 +317,26 @@ func assetsPrometheusKubeletServingCaBundleYaml() (*asset, error) {
 	return a, nil
 }
 
+var _assetsPrometheusOperatorServiceMonitorYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x4d\x8f\x31\x6e\xc3\x30\x0c\x45\x77\x9d\x82\x5b\x26\xa7\x48\x47\x9d\xa1\x9d\x5a\x74\x67\xe5\x8f\x58\x88\x24\x0a\x14\x93\xf3\x97\x72\x11\x23\x93\x08\xf1\xf1\xf3\x91\x7b\xfe\x81\x8e\x2c\x2d\x52\x95\x96\x4d\x34\xb7\xeb\x39\x89\x42\x86\x3f\xf5\xed\x71\x09\xb7\xdc\xd6\x48\x5f\xd0\x47\x4e\xf8\xfc\xa7\x42\x85\xf1\xca\xc6\x31\x10\x15\xfe\x45\x19\xb3\x22\xaa\xac\x37\x58\x2f\x9c\x70\x56\xac\x1b\xdb\x1e\xe3\x38\x66\x74\xa4\x93\xe9\x1d\x27\x67\x1b\x57\x44\xd2\xad\x2e\xd2\xa1\xec\xa1\x8b\x53\x9a\xd3\x08\xa3\x23\xcd\x38\xb4\xb5\x4b\x6e\xb6\x67\x2f\xe4\x95\x4b\x70\x89\xf4\x5e\xf7\x65\x5d\xd4\x22\x6d\x66\xfd\x18\x9d\xdf\x23\x29\x77\x7c\xe7\x0a\xb9\x7b\xff\x32\xe1\x81\x82\xe4\x3b\x9e\x96\x96\xb6\x8f\x17\xed\x43\x67\x57\x5e\x5e\xae\x38\xec\xc2\x1f\xf2\xd8\x9f\xe0\x2d\x01\x00\x00")
+
+func assetsPrometheusOperatorServiceMonitorYamlBytes() ([]byte, error) {
+	return bindataRead(
+		_assetsPrometheusOperatorServiceMonitorYaml,
+		"assets/prometheus/operator-service-monitor.yaml",
+	)
+}
+
+func assetsPrometheusOperatorServiceMonitorYaml() (*asset, error) {
+	bytes, err := assetsPrometheusOperatorServiceMonitorYamlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "assets/prometheus/operator-service-monitor.yaml", size: 301, mode: os.FileMode(420), modTime: time.Unix(1792401757, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _assetsPrometheusPrometheusAdditionalYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xd4\x56\x4d\x6f\xdb\x46\x10\xbd\xeb\x57\x0c\x12\x23\xb6\x61\x8b\x8c\xdb\x1c\x02\xb6\x68\x2e\xbd\x14\x68\xd1\x02\xcd\x2d\x75\x37\xa3\xdd\xa1\xb8\x31\xb9\xcb\xce\x0c\xe5\x18\x6d\xff\x7b\xb1\x4b\xca\xa2\x6b\x19\x96\x83\xb6\x48\x4f\x82\x76\x77\xde\xbc\x37\x1f\x4f\x7a\xfe\x1c\x96\x4b\xf8\x01\x6f\x20\x10\x39\xd0\x08\x2b\x02\xe7\xeb\x1a\xea\xc8\x60\xd9\x96\xb1\xa7\x20\x8d\xaf\x75\xb1\x84\x0f\x71\x65\x02\x76\x54\xc1\xf1\xd5\xb0\xa2\xa5\x28\x2a\x2d\x3b\x52\xf6\x56\x8e\x17\x00\xe9\xc0\x5b\x63\x63\xa8\xfd\x5a\xaa\x05\x00\xc0\x12\x14\x79\x4d\x2a\x15\xbc\xdb\x13\x56\x8c\x47\x37\xa2\xd4\x15\xb2\xb1\x85\x6d\x07\x51\xe2\xa2\x8d\x16\xdb\xea\xf5\xcb\xd7\x2f\x8f\x2f\x17\x99\xe7\x7d\x06\x1c\x48\x49\x96\x21\x3a\x92\xa5\x45\xb7\xf1\x12\x39\x11\x79\x0e\xdf\x52\x8d\x43\xab\x49\x92\x58\xc6\xde\x87\x35\xc4\x0d\x31\x34\xaa\xbd\x14\xf0\x5d\x0d\x4c\xbf\x0d\x9e\xc9\x9d\xc3\x87\x41\x14\x9c\x17\x5c\xb5\x04\xda\x78\x81\xa4\xbe\xc1\xb0\x26\xd0\x98\xf1\xde\xa7\xb8\xf7\x45\x12\x69\x1b\x4a\x0c\x32\x50\xbe\x7b\x9b\x22\xde\x7e\xff\x33\xbc\x80\x15\x21\x13\x83\xc6\x2b\x0a\x50\xfb\x96\x60\x2c\x06\x78\x81\x41\xc6\x12\xdb\x18\x02\xd9\x4c\x4d\x1b\x02\xb4\x3a\x60\x3b\xb2\xa4\x8c\x47\xc1\xf5\xd1\x07\x95\xb1\x0b\x63\x41\xc0\xc6\xae\x8f\x81\x82\x4a\x31\x66\xf4\x02\x42\x3d\x32\x6a\x62\x99\xf8\xdb\xa4\xf0\x06\x70\xd0\x26\x03\x8d\xb9\x07\x46\xf5\x31\xc0\x8a\x2c\x0e\x42\xb3\x87\x2f\x76\xb5\x41\x26\xd0\xeb\xb8\x43\xb4\x31\x58\xe2\x20\xe0\x43\xc6\xfa\x89\x63\x47\xda\xd0\x90\xd3\xd3\xdf\xd2\xcd\x64\xe2\xa0\xb1\x4b\x73\x00\xbe\x9e\x45\x01\x0f\x19\x4c\xbc\x1b\x55\x26\xed\xdb\x66\xc3\x8f\xda\x10\x5f\x7b\xa1\x73\xe8\x22\xdf\x56\x2d\xf6\x89\xb9\x40\x83\x1b\x9a\xa6\xb3\xe7\xb8\xf1\x8e\x1c\x5c\x7b\x6d\x7c\x48\x30\x19\xee\xeb\xdd\x40\x18\x71\xd3\x0c\x7e\x93\x1a\xa6\xad\x4c\x5f\xc7\x89\xb4\x68\x52\x67\x2a\x28\x37\xc8\x25\x0f\xa1\x14\xb2\x4c\x2a\xe5\x0e\xa2\xf0\xb1\x14\xe2\x8d\xb7\x84\xd6\xc6\x21\x68\x69\xb1\xb0\xac\x0b\x98\x7a\x6c\x72\x8f\x3f\x09\x29\x47\x2e\x00\xf6\x31\xce\x5b\xb3\x04\x8e\x09\x36\x0d\xf6\x02\x80\xa9\xc5\x15\xb5\x77\x5f\xa0\x4d\xa5\xa9\x20\x5f\x75\xd8\x67\x69\x4c\x6b\xfa\x58\x81\x31\x1d\x29\x9a\x19\x7e\x42\x32\x23\xca\x49\x71\x76\xba\xd8\xed\xe5\x78\x9a\x62\xd0\x39\x26\x11\x63\x26\xa8\xbe\x45\x4b\x1d\x05\xad\x66\x4c\x0b\x37\x2e\x56\xda\xd5\xea\xd5\xab\x2f\x33\x92\xc4\x81\xed\x84\x9f\xf6\xfc\x81\xfc\x69\x73\x2f\xe7\x3c\x27\x2a\x70\x8f\xca\x64\x0e\xa6\x47\x6d\xf6\xf1\x29\xb1\xf7\xe5\xe6\xa2\xcc\x9b\x5f\x1e\xfd\x7e\xf1\x67\xd9\x73\xfc\x78\x53\x4e\x81\xe5\xd6\x0c\xee\x78\xc6\x33\x6e\x3a\xb3\xa6\x40\xec\xad\x19\xf7\xcd\xf4\xd1\x3d\x3b\xa4\x15\x7d\x74\x0f\x75\xe2\x71\xf5\x7d\x74\x53\xf1\x3b\xe4\x2b\xd2\xac\xc4\x30\xb9\x06\xd5\xd8\xd8\xa5\x00\x62\x72\x63\x71\xb6\x9d\xbd\x22\xba\xd3\x55\xe5\x81\xfe\xc1\x84\xa9\x08\xa9\xbe\x77\x93\x4e\x65\x3e\xbc\x2b\xf3\x46\xee\xa3\x76\x3b\x55\xe7\x7b\xc6\xf2\x60\x9e\x91\xf5\x61\x9e\x5b\x12\xef\x7e\xad\x2e\xcf\x4e\x4f\xde\x54\xd5\x2f\xee\xec\xf4\xcd\x57\x27\xe9\xe3\xfe\xf0\x1c\x5d\x54\x47\x5f\xec\x15\x38\xdf\x80\xa7\x6e\xd8\xa3\x52\x1e\x18\xf6\xe9\xc5\x3c\xe8\xe8\xe2\xd0\xad\xc2\x8e\xa4\x47\x4b\x87\xf6\x70\x5f\xec\x13\x46\x6a\xb7\xc0\x4f\x4a\xb5\x0d\xcd\x3e\xbd\x2f\x57\xba\x34\xe6\x32\xdf\xdf\x96\xf8\xf8\xc4\xc6\xa0\xe8\x43\xf2\x5a\x94\x2b\x31\xf9\x8f\xc3\x1f\xbb\xd3\x8e\xba\xc8\x37\xa6\x46\xdf\x0e\x4c\x62\x34\x2a\xb6\xa7\xe3\xef\xff\xdd\x2d\x7a\xcc\x05\x26\x83\xfe\xf7\x9d\x60\x4a\xf4\xdf\xba\xc1\xa1\x49\x3f\x07\x47\x78\x12\xd7\xcf\xdf\x15\x0e\x92\xf3\x3f\x77\x86\xad\xc6\x4f\x74\x87\x79\xf8\xe2\xaf\x00\x00\x00\xff\xff\x6d\x42\x9b\xe2\x91\x0c\x00\x00")
 
 func assetsPrometheusPrometheusAdditionalYamlBytes() ([]byte, error) {
@@ -313,7 +377,7 @@ func assetsPrometheusPrometheusDatasourcesSecretYaml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsPrometheusPrometheusRulesYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x74\x8e\x41\x4e\xc3\x30\x10\x45\xf7\x3e\xc5\x5f\xb6\x8b\xa4\x62\xeb\x53\x20\x84\xd8\x56\x4e\xf2\x95\xba\xd8\x1e\x33\x63\xa3\x72\x7b\xd4\x10\x10\x9b\xee\x46\xa3\xf7\x9e\x7e\xa8\xf1\x8d\x6a\x51\x8a\x47\x96\x12\x9b\x68\x2c\xeb\x38\x8b\x52\x6c\x9c\x25\x9f\x3e\x9f\xdc\x7b\x2c\x8b\xc7\xb3\x4a\x66\xbb\xb0\xdb\x4b\x4f\x74\x99\x2d\x2c\xa1\x05\xef\x80\x59\x19\x5a\x94\xf2\x1a\x33\xad\x85\x5c\x3d\x4a\x4f\xc9\x01\x29\x4c\x4c\x76\x67\x80\xfa\x17\xf0\xe0\x2d\xe4\x9a\xb8\xfd\x55\x12\x3d\x42\xa2\xb6\x41\x7b\xa2\x39\xa0\x84\x4c\xff\xcf\x18\x76\x61\x07\xac\x72\xbe\x47\x57\x95\x5e\xb7\xfc\xb0\x2b\xe3\x69\x27\xc7\xdf\x14\xb0\x5d\x3f\x1b\x06\x28\x67\xd1\xc5\xe3\x2a\x93\xbf\xb4\x56\xcf\xb1\x54\x95\x55\x69\x76\x56\x7e\x74\x5a\x33\x6f\x3d\x6f\x38\xc0\x5b\x55\x0f\xeb\xf9\xf0\x08\x3e\x62\xfa\xc2\xe1\x2a\xd3\xd1\x7d\x07\x00\x00\xff\xff\x78\x3d\x62\x0e\x4e\x01\x00\x00")
+var _assetsPrometheusPrometheusRulesYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xc5\x55\x5d\x4f\xdb\x4a\x10\x7d\xe7\x57\x8c\xa2\x5b\x01\x2d\xe6\xe3\xa1\x0f\x8d\x94\x4a\x29\x98\x5e\xa4\x92\x44\x76\xe8\x7d\xb8\xba\x5a\x6d\xec\x09\xd9\x8b\xed\xb5\x76\xd7\xa1\x69\x70\x7f\x7b\x67\xd7\x76\x3e\xa8\x29\x20\x5a\x95\x07\x1c\xd9\x33\x67\x8e\xcf\x39\x3b\xe6\xb9\xf8\x8c\x4a\x0b\x99\x75\x21\x95\x99\x30\x52\x89\xec\xfa\x30\x92\x0a\xa5\xa6\x4b\x7a\x34\x3f\xd9\xb9\x11\x59\xdc\x85\x91\x92\x29\x9a\x19\x16\x3a\x28\x12\xdc\xa1\xdf\x3c\xe6\x86\x77\x77\x00\x12\x3e\xc1\x44\xdb\x5f\x00\x29\x57\x37\x68\xf2\x84\x47\x78\xa8\x30\x9e\x71\xe3\x60\xa8\x1c\x2d\x74\x17\x76\x8d\x2a\x70\x97\x6a\x33\x9e\x62\x17\xd4\x2c\xf5\x9a\x87\x1e\x4f\x50\x19\xbd\xa3\x73\x8c\x2c\xda\xb5\x92\x45\xee\x70\xbd\xa6\xda\x21\x7a\x1b\x43\x56\xcd\x6e\xba\x22\x6a\x35\x11\x0f\x1c\x5a\x17\x2e\xeb\x02\xba\x2a\x11\x85\x86\x1b\x3c\x93\xb7\x99\x2b\x02\xe0\x59\x26\xe9\x16\x29\x50\xf7\xd9\x3f\x5d\xa4\x34\x62\xb1\xee\x85\xd4\x35\x7b\xda\x76\x83\xd0\x10\xaf\x11\x00\x62\xd4\x91\x12\xb9\x71\x32\xae\x75\x82\x19\xd7\x40\xe8\x40\x4f\x79\x8e\x71\xf3\xae\x1b\x40\x19\x2c\x97\x83\xfe\xa5\x1f\x8e\xfa\xa7\x7e\x59\xc2\x54\x2a\xba\x73\xe9\x8f\x83\x8b\x53\x16\x8e\xfb\x63\x9f\x9d\x0d\xff\x19\xb0\xf3\x61\x50\x96\x07\x70\x2b\xd5\x4d\x22\x79\xac\x81\x2b\x74\xd0\x13\xac\xd9\x21\x49\x73\x58\x33\xc2\x2f\xb9\xea\xc2\x9d\xb7\x22\x58\xe4\xcb\xff\xe5\xa4\xd7\xb9\x4f\xc0\xd3\xa8\xe6\x22\xc2\xce\x81\x95\x57\xe7\xa4\x67\xaf\xb3\xc5\xa8\x53\x42\xaf\x07\xc7\x2b\x24\xe2\xc7\x27\x1a\x33\xb3\xf7\x12\xcc\xfd\x1a\x8f\x5e\x97\x02\xf1\xe0\x0b\xef\xd6\x65\x9b\xf9\x72\xf6\xe0\x9c\x5c\x31\xe4\x0f\xc9\x6e\x44\xc4\x93\x76\xc7\x43\xfa\x8f\xba\xef\xf8\x3e\xcf\x6e\xed\x3a\x9d\xca\xa9\xd0\xba\x89\xd7\x0f\x66\xbb\x06\xba\x35\x15\x74\x76\x2c\x28\xdc\x0a\x33\x23\x0b\xe1\xaf\x8a\xf4\x61\x63\x19\x33\x8b\x1c\x81\x2c\x5e\x7b\x38\xe3\x73\x6b\x62\xe5\x1e\x61\x50\x1c\xa6\xb2\x1e\x7d\x00\x14\x21\xa1\xa0\xd0\xfc\x1a\x21\xe2\xd9\xae\xf5\x9a\xf2\x9f\x4b\x65\x7e\xe2\x74\x24\x8b\x8c\x2a\x17\xb0\xb7\x35\x78\x1f\xf6\x9a\x29\xac\x32\x8b\x39\x7e\xcc\x8e\x5c\x6e\x95\xf6\x3a\x23\x19\x93\x45\x50\x64\x74\x96\x34\xc8\x6c\x6f\xbf\x82\x5d\x43\xe4\x32\x76\x9d\xfb\x9b\xb9\xf8\x25\xa3\xed\x32\xd2\x86\xfc\xfa\x2c\x93\x22\xc5\xd3\x84\x8b\xf4\x31\x32\xab\x9e\xb9\xeb\x89\x6c\xcf\x6f\xa1\xf7\xad\x13\x56\xd9\xbe\xab\xaf\x97\xd5\xca\x7c\x84\x60\x7d\x20\xb6\x28\x35\xc9\x0f\xfd\xe0\xc2\x0f\x59\xff\x43\xe8\x0f\xc6\x4f\x4d\xfd\x2d\x57\x59\x13\xc9\x1f\x42\x1f\xb8\x88\xf8\x4a\xd1\x0e\x8f\x9f\x9e\xfa\x3a\x5a\x40\x67\x1a\x70\xab\xf9\x5e\xe4\xc7\x33\x74\x45\x72\x5a\x05\xb7\x69\xdc\xc8\xbc\xbb\xcf\xaa\xfb\x6c\xb5\x05\x28\xfc\x47\x0f\x14\xd9\x73\x61\x97\x65\x3d\xb8\x5e\x83\x81\x3f\x1a\x06\x63\xe6\x07\xc1\x30\xf0\xcf\x2a\x71\xb6\x83\xbf\x35\x88\x48\xb1\x06\x80\x76\xd6\xc9\x3d\xa5\xdb\xd0\x5e\x28\xf5\x55\x6e\xb3\x71\xce\x45\xb2\x5e\x10\xcf\x90\xba\x70\xed\xf6\x6b\x32\xdd\x82\x68\x11\xbc\x2e\xfd\x1d\x9a\xdb\xd9\x2b\xc9\xaf\x46\x9f\x86\x7d\x12\xa7\x7f\xf1\xe9\x62\xf0\xf1\x51\xc9\x2b\x5a\xac\x86\x68\x11\xbd\x0d\xef\xc5\x5b\x9d\x4e\x1c\x2d\xc5\x01\x72\x95\x2c\xce\x8b\x24\x79\xde\x6a\xdf\xf8\x3e\xeb\x0a\xc9\x3a\x90\x39\x34\x98\xae\xe1\x5a\x5c\xa8\xf6\xcb\xa6\xea\xad\xbb\xc7\x2a\x4b\x4e\xd1\x0c\x48\x5b\xa6\xd2\x34\x8b\x30\xe7\x49\x81\x70\x07\xb3\x22\xe5\x99\xf8\x6a\xad\x7a\x45\xeb\xfe\x27\xbb\xfd\xa6\xa0\x99\x68\x58\x35\x8a\xd9\xaf\xad\x66\xb6\x83\x4d\x16\x06\xf5\xf2\xc1\xef\xed\x41\x2b\x4d\x5a\x67\xf9\x8a\x95\xb7\x5c\x8e\x82\x21\x7d\x87\xff\xf6\xaf\xc2\xb2\xf4\xe2\x89\xf7\xf0\xc3\x7f\x8f\xbd\x77\xff\xbd\xe9\x94\x2b\x66\x47\xed\xdc\x22\x4e\x6c\xc8\xd1\x3f\xce\xef\x35\x9c\x1c\x1f\xc3\x7b\xd2\x3d\x1c\x0f\x83\xfe\x47\x9f\x5d\x85\xb4\x03\x46\x7e\x70\x4a\x6b\xb7\x2c\x37\x53\x7b\xf2\x36\x7d\xf2\x56\xf8\x0e\xb9\x5b\x50\x32\x3f\x0b\x00\x00")
 
 func assetsPrometheusPrometheusRulesYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -328,12 +392,12 @@ func assetsPrometheusPrometheusRulesYaml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "assets/prometheus/prometheus-rules.yaml", size: 334, mode: os.FileMode(420), modTime: time.Unix(1599587957, 0)}
+	info := bindataFileInfo{name: "assets/prometheus/prometheus-rules.yaml", size: 2879, mode: os.FileMode(420), modTime: time.Unix(1792401757, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
 
-var _assetsPrometheusPrometheusYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xd4\x58\x4b\x6f\xe3\x36\x10\xbe\xfb\x57\x10\xbe\x18\x28\x96\x96\x9d\x76\xbb\xbb\x02\x7c\x48\x1d\x77\x13\x34\x0f\x63\x1d\xf4\x71\xa9\xc1\x50\x63\x99\x30\x45\xaa\xc3\x91\x37\x46\xb0\xff\xbd\xa0\x5e\x96\x6c\x25\x4e\x50\x14\x68\xf7\xb4\xe1\xcc\x7c\x9a\xe7\x47\x8e\x45\xaa\x7e\x05\x74\xca\x9a\x90\x25\xd6\x28\xb2\xa8\x4c\x3c\x94\x16\xc1\xba\xa1\xb4\x49\xb0\x1d\xf7\x36\xca\x44\x21\x9b\xa3\x4d\x80\xd6\x90\xb9\x5e\x02\x24\x22\x41\x22\xec\x31\x66\x44\x02\x21\x4b\x6b\x21\x4f\x80\x00\x1f\x84\x83\x1e\x63\x5a\x3c\x80\x76\x5e\x8d\x35\x54\x42\x86\x10\xad\x05\xf1\x44\xe0\x06\x28\xd5\x42\x42\xcf\xa5\x20\xbd\xa2\x58\xad\x94\x51\xb4\x2b\x8d\x6c\x74\x6e\x48\x9d\xb7\x0e\x3d\x16\xac\x00\x11\xa2\x8b\xcc\xfb\xbb\x90\x6b\x88\x32\xad\x4c\x7c\x15\x1b\x5b\x1f\xcf\x1e\x41\x66\xe4\x63\x2b\xcd\x18\xe3\x39\x62\x89\x76\x0f\x98\xec\x45\xfe\x5f\xee\xef\x02\x34\x48\xb2\xd8\x16\x31\x96\x08\x92\xeb\xd9\x63\x8a\xe0\x7c\xc2\xdc\xa1\xdc\xa3\x6f\x60\xd7\x4c\xc6\x91\x06\x63\x36\x05\x14\x1e\x9d\x5d\x99\x0e\xf1\x56\xe8\x0c\x3a\xa0\x4b\xf8\x8f\x6d\x48\x9f\x7c\x97\x0a\x79\x6c\xc1\xbb\x72\xdc\x54\x20\x9b\x5a\x6d\xe3\xdd\x2f\xde\xe3\x4d\xf6\x00\x68\x80\xc0\x0d\x95\x0d\xd6\xd6\x91\x47\x6e\xe8\x7f\x05\x15\xaf\x29\x64\xe3\xd1\xa8\xc7\x98\xb4\x86\x84\x32\x80\xe5\x67\x39\x53\x89\x88\xa1\xab\xae\x5c\x64\xb4\x96\x6b\x90\x9b\x50\x0b\x02\x47\xbd\xbd\xe7\x21\xab\x85\xe5\x29\x82\xb3\x19\xb6\xc2\x41\xf8\x2b\x03\x47\xad\x00\x65\x9a\x79\x57\x92\xc6\x51\x02\x89\xc5\x5d\xc8\xce\x46\x37\xaa\x3c\x26\xc0\x44\x19\xe1\x1b\xe0\x06\x9c\x13\x31\xcc\xad\x56\x72\x17\xb2\x9f\x85\xd6\x0f\x42\x6e\xee\xed\xb5\x8d\xdd\x9d\x99\x21\x5a\x2c\x23\x11\x18\xbb\x66\xbf\xf0\x14\xed\x56\x45\x80\x13\x9b\x82\x71\x6b\xb5\xa2\xa6\x74\x4d\x94\x3a\x2e\xa2\xc8\x77\xc5\x24\xfc\x34\xfa\x34\x3e\x14\xd7\xd2\xa6\x00\x12\xa1\x34\x8f\x6c\x22\x94\x99\x7c\xd7\x94\x64\xa9\x23\x04\x91\x4c\xbc\x6d\x18\x04\xda\x4a\xa1\x7d\x49\x3c\xf8\xa8\x0d\x9e\x0a\xe7\xbe\x46\x7c\xa5\x34\x4c\x02\x20\x19\xa4\x68\x1f\x77\x41\x25\x08\x7c\x7e\x9b\x16\x75\x08\xdc\x01\x6e\x95\x2f\x8f\x94\x36\x33\x34\xe9\xa8\x5c\x47\x1b\x73\x36\x68\x62\x08\x9c\x3c\xf5\xab\x9a\xf5\x43\xd6\xdf\xf7\x63\xff\x1d\xeb\x6f\x01\x1f\xfc\x69\x0c\xd4\xff\x36\x78\x06\x24\x02\x0d\xb1\x20\xe0\x19\x6a\x37\x79\xea\x07\xfd\x90\xbd\x1a\xb4\x85\xca\x49\x3b\x2e\x01\xa9\x48\x05\x69\x17\xa4\xa8\xb6\x82\xc0\xff\x7f\x28\x91\x0e\x95\x37\xb0\xeb\xd6\xdd\xc0\xae\xa9\x2b\xb5\x02\xe3\x73\x26\x11\xa8\xcc\xf6\x56\x60\x80\x99\x09\x8a\x43\x17\xb4\x47\xa8\x4c\x6f\x99\xdd\x80\xec\x06\x4c\x0b\xd1\xda\x8d\x82\x36\xe2\xbe\x7e\x15\xa6\x2b\x98\x66\x59\xfc\xdd\x5d\x48\x29\x4a\xcb\x8d\xca\xc3\xf0\x09\x18\xa6\x90\x3c\xaf\xfd\x26\xcf\xa5\x38\x4c\x9c\xdb\xa8\x34\x9f\x6a\x8e\x10\xc3\xe3\xe4\xcf\x20\x01\x42\x25\xab\x2e\x01\xb3\x6d\xce\x4f\x31\xe8\x97\xf7\xf7\xf3\xe5\xfc\xcb\xdd\xef\x7f\x34\x66\x36\xe7\xba\x90\x0d\x06\x9d\xea\x8b\x37\xe8\xdf\xde\x9d\x54\xae\x19\x2a\x56\x8e\x70\x37\x2c\x1a\xde\x47\x5c\x67\xe7\x87\xc0\x3a\xe0\x36\x8f\x2d\x2f\x44\x9b\xb2\x72\x84\x79\xa6\x75\x45\x23\x57\xab\x5b\x4b\x73\x04\x07\xa6\x4d\x6b\x8d\xdb\x30\xc7\xa9\x6e\x2d\x8b\xd4\xe2\x96\x9a\x47\xe7\x16\x29\x64\x2d\xf2\xa8\xb0\x72\x82\xf9\xaf\xd0\x23\x63\x5b\xab\xb3\x04\x6e\x7c\x6f\xb4\x42\x49\xfc\xc9\x5c\xd0\x3a\x64\x87\x13\x75\x14\x52\xd9\xf5\xb8\x4e\x78\xd7\xbb\xc1\x8f\xe6\x0b\xc8\xad\x11\x79\x33\x76\xb3\x1e\xcf\xa3\x57\x04\xfa\x66\xf8\x96\x61\xc7\x65\xc2\xb5\x8d\xc9\x3a\x8a\x00\xb1\x75\xee\x40\x66\x08\x5c\x2b\x47\x60\x5a\xf7\xc9\x59\x4b\x2f\xe7\x38\x95\xae\x01\xb9\xcb\x14\x81\x9b\xdc\x5f\x2f\x96\xb3\xe9\xc5\xe5\x6c\xf9\x65\x71\xbe\xfc\xed\xea\xfe\x72\x79\x3e\x5b\x2c\xc7\x67\x1f\x97\x9f\xa7\x37\xcb\xc5\xe5\xf9\xd9\xfb\x1f\xdf\xed\xb5\x66\xd3\x8b\x13\x7a\x47\x38\xd3\x9f\xa6\xaf\xc2\xe9\xd4\x7b\x01\xad\x15\x59\x3e\x76\x39\x51\x72\x91\x45\x0a\x8c\x04\x37\x79\x2e\xd1\xc3\x3d\xa5\x1d\xdf\x5c\x43\xb7\x95\x2d\xe8\xc3\x2b\x75\x7c\xf6\x61\x38\x1a\x8e\x86\xe3\xfc\x4a\x0d\x8e\x13\x0c\xd8\x24\xe5\x13\x37\x49\x6e\x52\xca\xfd\x95\xf2\x82\xe5\xc1\xbd\x52\x3b\xc6\xa5\x68\x58\x49\x6b\x56\x2a\x4e\x44\xea\x0a\x36\x36\x71\xee\x91\xf3\x5a\x0f\x99\x89\x34\x54\x2c\xcd\x5b\xf4\xfc\x6a\x8a\xf3\x8c\xcf\xf1\x41\xc8\x7f\x46\x73\x07\x30\x7c\xfc\x7a\x9e\x3b\x3b\x9a\x2c\x8f\xf3\x6f\xd0\x5c\x3e\x58\x8a\x76\x53\x6b\x08\x1e\x29\x64\x4f\xdf\xfe\x57\x04\xe8\x63\x17\xd1\x9d\xd1\xbb\x90\xad\x84\x76\xf0\xc2\x37\x4f\x37\xce\x91\x2b\xb5\x09\x3f\x6d\x71\xec\x08\x42\xaa\x95\x14\x2e\x64\x67\xbd\xa3\xba\x1d\xd6\x2c\xaf\xd7\x87\xba\x5e\x55\xad\xc6\x9f\x7d\xa9\xca\x86\x3e\x2f\x9e\x1d\xb7\x45\x4b\x9c\x78\x93\xa6\xa8\x6c\x5e\x5a\x2d\x9c\x2b\x4c\xdc\xce\x11\x24\x5c\xea\xcc\x11\x20\x97\xa8\x48\x49\xa1\xfd\x2a\x9a\x33\xeb\xb5\x7f\x4c\x87\x8c\x30\x2b\xdc\x27\x30\xf9\x82\xc8\xbe\x1f\x79\xce\x7e\xf5\x04\x35\x96\xd9\x7a\x78\x4e\x0d\x8e\xb1\x11\xb4\x77\xcb\xf6\xcb\xcb\xba\x90\x69\x65\xb2\xc7\xde\x73\x5d\x5b\x26\xe9\xa6\x58\xd4\xdb\x58\xf9\x76\x7a\xdd\xd8\xb7\xfd\xd1\x9e\x12\xcb\x48\xfc\x46\x9f\xf7\x99\x32\x71\xc8\x06\x3e\x0f\x83\x23\xe0\xdb\xea\xc1\xdd\xf1\x85\x8e\xfd\x97\xb3\xa7\x62\xef\x1d\xd4\x49\xf2\xe1\x54\x35\xd8\xff\xac\x30\x78\xd7\xd8\x7e\x2f\x2c\xb8\x5b\x4b\xb3\x47\xe5\x88\xf9\xe0\x44\x14\x29\x5f\x0b\xa1\x17\x12\x45\x0a\xd3\xbc\x33\xcb\xaf\x94\x1c\xb1\x4e\x1a\x53\xb2\x37\xe0\x2e\xb7\xe0\x45\x33\x17\xb3\x93\x7b\x94\x2b\x47\xb0\x1a\xee\x44\xa2\x8b\xb4\xfa\x57\x43\xb5\xb5\x1e\x10\x58\x79\x7a\x72\x2c\x5f\x50\x79\x15\x4e\xe3\x91\x50\x78\x7c\x23\xd2\xda\xa7\x97\xc6\xb0\xf0\x58\x03\xed\x87\xb5\x21\x77\x64\xd1\xb7\x6f\x6f\x4f\x53\x53\x2d\x54\x72\x0f\x49\xea\x9b\xb4\xaa\x57\xf5\x4b\x4b\xf9\x57\x61\xd5\x98\xa1\x81\x23\x61\x22\x81\xd1\xa0\x41\xbf\x47\x8c\xdc\xcd\xc9\x7b\x2f\xd8\xfb\xcf\xaa\xf7\x77\x00\x00\x00\xff\xff\x07\x91\xf3\xd2\x5f\x12\x00\x00")
+var _assetsPrometheusPrometheusYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xd5\x58\x5b\x6f\x22\x37\x14\x7e\xe7\x57\x58\x79\x41\xaa\xd6\x0c\xd0\xcb\xee\x8e\xc4\x43\x9a\xa5\x9b\xa8\xb9\xa0\x05\xf5\xf2\x52\xe4\x78\x0e\x60\xe1\x19\x4f\x6d\x0f\x0b\x5a\xed\x7f\xef\xf1\xdc\x0d\x93\x90\xa8\xad\xd4\x26\x52\x06\x7c\xce\xf9\xe6\x5c\x3f\xdb\x61\xa9\xf8\x05\xb4\x11\x2a\x09\x49\xac\x12\x61\x95\x16\xc9\x7a\xc0\x95\x06\x65\xf0\x11\x07\xbb\x51\x6f\x2b\x92\x28\x24\x33\xad\x62\xb0\x1b\xc8\x4c\x0f\x9f\x2c\x62\x96\x85\x3d\x42\x12\x16\x43\x48\xd2\x5a\x48\xf1\x09\xfa\x91\x19\x40\xa1\x64\x8f\x20\x8d\x53\x23\x2d\x95\x90\x68\x88\x36\xcc\xd2\x98\xe9\x2d\xd8\x54\x32\x0e\x3d\x93\x02\x77\x8a\x6c\xb5\x12\xe8\xc7\xa1\x34\x52\xd1\x65\x62\xc5\xa5\xb7\xe8\xb0\x60\x05\x1a\x51\x3e\x64\xce\xdf\x39\xdf\x40\x94\x49\xfc\x74\xb3\x4e\x54\xbd\x3c\xdd\x03\xcf\xac\x8b\xad\x34\x23\x84\xe6\x88\x25\xda\x02\x74\xdc\x88\xdc\x4f\xee\xef\x1c\x24\x70\x4c\x84\x2f\x22\x24\x66\x96\x6f\xa6\x7b\x7c\xb7\x71\x09\x33\xc7\x72\x87\xbe\x85\x43\x3b\x19\x27\x1a\x84\xa8\x14\x34\x73\xe8\xe4\x26\xe9\x10\xef\x98\xcc\xa0\x03\xba\x84\x7f\xe7\x43\xba\xe4\x9b\x14\xd3\x77\x62\x41\xbb\x72\xdc\x56\xb0\x2a\x55\x52\xad\x0f\x3f\x3b\x8f\xb7\xd9\x23\xe8\x04\x0b\x67\x06\x42\x05\x1b\x65\xac\x43\x6e\xe9\x7f\x06\xb1\xde\xd8\x90\x8c\x86\x43\x5c\xe5\x2a\xb1\x4c\x24\xd8\x38\xc5\x6b\x29\x11\x31\x5b\x43\x57\x5d\x29\xcb\xec\x06\xcb\xc3\xb7\xa1\x64\x88\x6f\x7b\x8d\xe7\x21\xa9\x85\xe5\x2a\xa6\x56\x65\xda\x0b\x47\xc3\x9f\x98\x10\xeb\x05\xc8\xd3\xcc\xb9\x12\xb7\x96\x62\x88\x95\xc6\x48\xc6\xc3\x3b\x51\x2e\x63\x1b\xc6\x22\x61\xae\x01\xee\xb0\x64\xe8\xe0\x4c\x49\xc1\x51\xe9\x27\x26\xe5\x23\xe3\xdb\x85\xba\x55\x6b\xf3\x90\x4c\xb5\x56\xba\x8c\x84\xe9\xb5\x69\xf7\x0b\xc5\x6a\xee\x44\x04\x7a\x82\x95\x4b\xcc\x46\xac\x6c\x5b\xba\xb1\x36\x35\x94\x45\x91\xeb\x8a\x49\xf8\x7e\xf8\x7e\x74\x2c\xae\xa5\x6d\x01\xc4\x4c\x48\x1a\x29\x7c\x24\x93\x6f\xda\x92\x2c\x35\x56\x03\x8b\x27\xce\x36\x0c\x02\xa9\x38\x93\xae\x24\x0e\x7c\xe8\x83\xa7\xcc\x98\xcf\x11\x5d\x09\x09\x93\x00\x2c\x0f\xd0\xd9\xfd\x21\xa8\x04\x81\xcb\x6f\xdb\xa2\x0e\x81\x1a\xd0\x3b\xe1\xca\xc3\xb9\xca\x12\x3b\xe9\xa8\x5c\x47\x1b\x53\xd2\x6f\x63\x30\x3d\xf9\x72\x51\xd5\xec\x22\x24\x17\x4d\x3f\x5e\xbc\x21\x17\x3b\xe4\x01\xb7\xba\x06\x7b\xf1\xb5\xff\x04\x48\x84\xd3\xb6\xc6\xce\xa0\x99\x96\x06\xe1\x02\xb4\x78\x31\xa8\x87\x4a\xad\x34\x94\x83\xb6\x45\x2a\xf0\x1b\xa6\x43\xec\x10\xdb\x7d\x1e\x70\x6d\x8f\x95\x71\x5a\xbb\x75\x51\xd0\xd6\xe5\x52\x40\xe2\x72\xc6\x35\xd8\x32\xdb\x3b\xa6\x03\x9d\x25\x41\xb1\x68\x02\x7f\x84\xca\xf4\x96\xd9\x0d\xac\xda\x42\xe2\x21\x2a\xb5\x15\xe0\x23\x36\xf5\xab\x30\x4d\xc1\x34\xcb\xe2\x7b\x77\x21\x39\x2b\x2d\xb7\x22\x0f\xc3\x25\x60\x90\x42\xfc\xb4\xf6\xab\x3c\xe7\xec\x38\x71\x66\x2b\xd2\x7c\xaa\xa9\x86\x35\xec\x27\x7f\x04\xd8\x25\x5a\xf0\xaa\x4b\x20\xd9\xb5\xe7\xa7\x18\xf4\xeb\xc5\x62\xb6\x9c\x7d\x7a\xf8\xed\xf7\xde\x11\xd7\x85\xa4\xdf\xef\x54\x9f\xbf\x42\xff\xfe\xe1\xac\x72\xcd\x50\x6b\x81\xf3\x75\x18\x14\x0d\xef\x22\xae\xb3\xf3\x5d\xa0\x0c\x50\x95\xc7\x96\x17\xc2\xa7\xac\x1c\x61\x96\x49\x59\xd1\xc8\xcd\xea\x5e\xd9\x19\xb6\x2a\x36\x87\x47\x6b\xad\xdd\x30\xc7\xa9\x76\x2d\xa5\xad\xc7\x2d\x35\x8f\xce\x50\x12\x12\x8f\x3c\x2a\xac\x9c\x60\xfe\x2b\xf4\x88\x69\x55\x32\x8b\xe1\xce\xf5\x86\x17\x4a\xec\x56\x66\xcc\x6e\x42\x72\x3c\x51\x27\x21\x95\x5d\xaf\x37\x31\xed\x3a\x37\xb8\xd1\x7c\x06\xd9\x1b\x91\x57\x63\xb7\xeb\xf1\x34\x7a\x45\xa0\xaf\x86\xf7\x0c\x3b\x36\x13\x8a\x5b\xae\x45\x2e\xc7\xfd\x44\x7b\xeb\x08\x9c\x69\xa0\x12\x9b\x13\x12\x6f\x3f\x19\x7b\x7a\x39\xc7\x89\x74\x03\x9a\x9a\x4c\x60\x77\x4e\x16\xb7\xf3\xe5\xf4\xea\xc3\xf5\x74\xf9\x69\x7e\xb9\xfc\xf5\x66\x71\xbd\xbc\x9c\xce\x97\xa3\xf1\xbb\xe5\xc7\xab\xbb\xe5\xfc\xfa\x72\xfc\xfd\x0f\x6f\x1a\x2d\xfc\x7b\x46\xef\x04\xe7\xea\xc7\xab\x17\xe1\x74\xea\x3d\x83\xe6\x45\x96\x8f\x5d\x4e\x94\xf8\x31\x42\xc2\xc5\x3e\x9f\x3c\x95\xe8\x41\x43\x69\xa7\x3b\xd7\xc0\xec\xb8\x07\x7d\xbc\xa5\x8e\xc6\x6f\x07\x43\xfc\x1d\xe5\x5b\x6a\x70\x9a\x60\xe4\xd0\x16\x29\x9f\xd9\x49\x72\x93\x52\xee\xb6\x94\x67\x2c\x8f\xf6\x95\xda\x31\xe4\xe5\x96\x15\xd2\xc2\x4a\xac\x63\x96\x9a\x82\x8d\x93\x75\xee\x91\x71\x5a\x8f\x59\x12\x49\xa8\x58\x9a\x7a\xf4\xfc\x62\x8a\x73\x8c\x4f\x31\x8f\xfc\xef\xd1\xdc\x11\x0c\x1d\xbd\x9c\xe7\xc6\x27\x93\xe5\x70\xfe\x0d\x9a\xcb\x07\x0b\x0f\xfb\x57\xe8\x03\xec\xf1\xed\x5f\xbe\xfe\xaf\x08\xd0\xc5\xce\xa2\x87\x44\xa2\x5f\x2b\x26\x0d\x3c\xf3\xce\xf3\x8d\x73\xe2\x4a\x6d\x42\xcf\x5b\x9c\x3a\xa2\x21\xc5\x8c\x31\xbc\xd1\x8d\x7b\x27\x75\x3b\xae\x59\x5e\xaf\xb7\x75\xbd\xaa\x5a\x8d\x3e\xba\x52\x95\x0d\x7d\x59\x1c\x3b\xee\x8b\x96\x38\x73\x26\xc5\xf4\xaa\xbc\xb4\x12\x39\xb7\x30\x31\x07\xe4\x4f\x1c\x27\x99\xe1\x53\x53\x8e\x62\xf4\x4f\xba\xab\x68\xce\xac\xb7\xee\x30\x1d\x12\xab\xb3\xc2\x7d\x5c\xca\x2f\x88\xe4\xdb\xa1\xe3\xec\x17\x4f\x50\xeb\x32\x5b\x0f\xcf\xb9\xc1\x49\x54\x04\xfe\xdd\xd2\x3f\x79\x29\x4c\x23\xde\x61\xb3\x7d\xef\xa9\xae\x2d\x93\x74\x57\x5c\xd4\x7d\xac\xfc\x76\x7a\xdb\xba\x6f\xbb\xa5\x86\x12\xcb\x48\xdc\x8d\x3e\xef\x33\xac\x34\x1e\x8e\x5c\x1e\xfa\x27\xc0\xf7\xd5\x81\xbb\xe3\x0d\x1d\xf7\x5f\x4a\xbe\x14\xf7\xde\x7e\x9d\x24\x17\x4e\x55\x83\xe6\xdf\x0a\xfd\x37\xad\xdb\xef\x07\x05\x06\xd3\x33\xdd\x63\xa6\x89\x0b\x4e\x67\x12\xfe\xc1\x90\x70\x0b\x15\xae\xb4\x4c\xce\xb9\x66\x29\x5c\xe5\x8d\x5e\x02\x95\x94\x83\xf3\xd7\x0c\x5d\x63\x40\x4d\x6e\x41\x8b\xd9\x28\x46\x31\x0f\x30\x57\x8e\x60\x35\x38\xb0\x58\x16\x55\x72\x87\x90\xea\x12\x7c\xc4\x87\xe5\xea\xd9\x29\x7f\x46\xe5\x45\x38\xad\x33\x47\xe1\xf1\x1d\x12\x40\xe5\xd3\x73\x53\x5d\x78\x2c\xc1\x36\xb3\xdf\x92\x1b\xac\x83\x9b\x86\x5e\xc3\x7a\x38\x68\x22\x5e\x40\x9c\xba\x9e\xaf\x4a\x52\xfd\xe3\xa6\xfc\x56\x58\xb5\x46\xb2\x6f\x2c\x4b\x22\xa6\xa3\x7e\x8b\xcd\x4f\x08\xbe\x9b\xe2\x1b\x2f\xc8\xf7\x48\x13\x7f\x01\xf7\xa0\x58\x21\xae\x12\x00\x00")
 
 func assetsPrometheusPrometheusYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -348,7 +412,7 @@ func assetsPrometheusPrometheusYaml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "assets/prometheus/prometheus.yaml", size: 4703, mode: os.FileMode(420), modTime: time.Unix(1603328530, 0)}
+	info := bindataFileInfo{name: "assets/prometheus/prometheus.yaml", size: 4782, mode: os.FileMode(420), modTime: time.Unix(1792401757, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -413,6 +477,26 @@ func assetsPrometheusServingCertsCaBundleYaml() (*asset, error) {
 	return a, nil
 }
 
+var _assetsMetricStateStatefulsetYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xed\x58\xdf\x8f\xe2\x36\x10\x7e\xdf\xbf\xc2\x0f\x95\xae\x95\x6a\x02\x5c\xaf\xdd\x8b\xc4\x03\x65\xb9\xee\x49\x0b\x8b\x8e\x55\xfb\x88\x8c\x33\x01\x0b\xc7\x4e\x6d\x87\x5b\x54\xf5\x7f\xef\x98\x04\x36\x24\x84\x1f\x6d\xf7\xa4\x93\x2e\x2b\xad\x20\xf3\xcd\x78\x3c\xf3\xcd\x97\x18\x96\x8a\xdf\xc1\x58\xa1\x55\x48\x58\x9a\xda\x60\xdd\xb9\x59\x09\x15\x85\x64\xea\x98\x83\x38\x93\x53\x70\x37\x09\x38\x16\x31\xc7\xc2\x1b\x42\x14\x4b\x20\x24\x66\x99\x50\xbc\x6b\x04\xa7\xd6\x03\xd1\x20\xd9\x1c\xa4\xf5\x10\xe2\x43\xb5\x56\xd9\x1c\x8c\x02\x07\xb6\x25\x74\xc0\x75\x92\x6a\x05\xca\x85\x84\x6b\xe5\x8c\x96\x12\x4c\x03\xb6\x61\x09\x9b\x02\xf7\xe1\x0d\xa4\x52\x70\x66\x43\xd2\xc1\x6f\x16\xcc\x5a\x70\x18\x1f\xf5\xa1\x85\x15\x71\xa9\x8e\x46\x4c\xb1\x05\x24\x98\xc4\x44\x63\x84\x4d\x48\x26\xcc\x30\x4c\x44\x6e\xe3\x48\xe0\x4e\x9b\x7c\x03\x09\x73\x7c\xf9\x50\xda\xd1\x75\x7b\xba\x62\x57\x84\x38\x48\x52\x89\x1f\x8b\x95\x4b\xb5\xf6\x97\x3c\x48\xe2\xda\x34\xae\x4a\x04\x8b\x50\x94\xd8\x5f\x3e\x16\x13\x0a\xd9\xf1\xb2\x38\x2d\xda\x5f\x73\xcc\x2f\x91\x60\x7d\x4f\x5b\x27\x99\x94\xbb\xea\x7f\x8c\xc7\xda\x4d\x0c\x58\xcc\xbd\x84\x63\x66\x51\x5a\x32\x5f\x96\x52\xec\x5f\xef\xbb\xef\x27\x8f\x77\xb3\x71\x7f\x34\xfc\xe1\x98\x9d\xfa\xe4\x6c\xca\x38\x94\x90\xd3\x49\x7f\x70\x04\x6e\x15\x4b\xed\x52\x3b\x8a\xdb\x8c\xc5\x22\x61\x69\xaf\x4e\x9e\x02\xd3\xec\x7c\xd1\x82\xa0\xd6\xd5\xed\xe4\x55\xdc\x79\x1c\x18\x09\x59\x33\x99\xc1\x07\xa3\x93\xb0\x62\x20\x24\x16\x20\xa3\x4f\x10\xd7\x2d\x85\x6d\xc2\xdc\x32\xdc\x73\xa8\xe5\xd7\x39\xb9\xf4\x36\xd9\xd7\x5d\x7f\x5b\x9f\x12\x1e\xfb\xad\x33\xc3\xa1\xd2\x63\x03\x7f\x66\x60\x9d\xad\x86\xe6\x69\x86\x83\xde\x6e\x27\x95\xfb\x09\x24\xda\x20\x89\x3a\xef\xda\x23\x51\xb2\x59\xe0\x99\x11\x6e\x33\x40\xfa\xc2\x33\xce\xc4\x5f\x7f\x97\xac\x0e\x4c\x22\x14\x73\x28\x78\x23\xb0\xd6\x13\xb2\x20\xe3\x07\x14\x82\x39\xe3\xab\x27\xfd\xa0\x17\xf6\x51\x0d\x8d\xd1\xa6\xe4\x99\x6a\xe3\x6a\xb4\xdc\xcf\xc8\x04\xad\x21\xb9\x6d\xdf\xb6\x2b\x69\xe6\xe5\xfe\x0c\xf3\xb3\x9e\x9d\xa3\x9e\x39\x21\x6d\xc9\xb6\xd6\x32\x4b\x60\xa4\x33\x55\xcf\x27\xf1\x77\xf3\x1e\x04\x6b\x66\x02\x29\xe6\x41\xc3\x34\xbe\x2c\x51\xe3\xbd\x6f\x5d\x69\xe4\x8b\xa9\x36\x10\x2d\x99\xa3\x09\x33\x2b\x70\x28\x58\x1c\x28\xcb\xdc\x92\x2f\x81\xaf\x42\xaf\x5f\xb6\x3c\x28\x79\xe8\x3d\xe0\x3f\xf7\xbf\xa9\xfd\xdd\xc3\xee\xff\xdb\xfe\xd2\x06\xd5\x91\x7a\xe1\xb4\x75\x11\x18\x53\x57\x01\xcf\x34\xa0\x52\x58\x07\x8a\xb2\x28\xc2\x9d\xd9\x5e\xf8\xbe\xfd\xbe\x5b\xc3\x3a\x69\x29\x17\xe9\x12\x0c\xb5\x99\xc0\x5a\xf5\x9e\x1e\xa6\xb3\xe1\xe0\xee\x7e\x38\xfb\x34\xed\xcf\xfe\xf8\xf8\x74\x3f\xeb\x0f\xa7\xb3\x4e\xf7\x76\xf6\xdb\x60\x34\x9b\xde\xf7\xbb\xef\x7e\xfe\xf1\x05\x85\xff\xcf\xe0\x6a\x71\x06\xbf\x0e\x2e\x8a\x73\x14\x77\x22\x5a\x6d\x77\x59\x6a\x9d\x01\x96\xf4\x96\xce\xa5\x61\x10\x74\xba\xbf\xb4\xda\xf8\xd7\x09\xfd\x44\x04\xc7\xab\x01\xc6\xd1\x58\x48\xe8\x05\xe0\x78\x80\xb7\x82\xd4\x88\x35\xf2\xc8\x7f\x6e\x71\xe3\x8e\xba\x15\x18\xba\x82\xcd\x09\x6f\xb4\x36\x26\x49\x39\x2b\x79\xee\xe5\xdf\x06\xdb\x77\x05\xb5\xc8\x33\x43\xd0\x3c\x53\x91\x84\xa0\x78\x85\xc0\x3b\x95\xa4\xf6\x73\xb1\x40\x06\x98\x4d\x2b\x1f\x10\xff\x84\xd5\x29\x28\xbb\x14\xb1\xfb\x29\xd0\x16\x73\xc5\xc7\x2f\x35\x48\x3e\xcc\x5e\x3f\x6f\xea\xc3\x72\xe9\x93\x31\x1f\xaa\x4a\x38\xda\xb9\x52\xa5\x6a\x04\xdd\x05\xf6\xdd\xb3\x5f\x6a\x52\x5f\x4b\xa7\x2f\x95\xc7\x0a\x6d\x2e\x53\x46\x74\xa8\x00\x91\x51\xd1\xa3\x92\x98\x58\xcc\xa4\x85\x33\x0b\x9e\x65\xdb\xd1\x34\xca\x50\xdb\x88\x6d\xca\xe4\xff\x95\xb6\xb7\xdf\xa4\xed\x45\xda\x3a\x5f\xa3\xb4\xd9\xaf\x49\xdb\xba\xd7\x6b\xdb\xdb\x66\x6d\xa3\xf5\xb7\xa9\x6f\x1a\xf7\x85\x34\xce\xbe\xa2\xc8\x29\x1d\xc1\xf4\xe0\xfc\xee\xaf\x39\x9e\x42\x2a\x47\x5f\x6d\x43\x22\x85\xca\x9e\xf7\x20\xef\x4a\xf1\xc8\x0c\x15\x64\xc2\x50\xfa\x4c\x48\xde\xbc\x29\xa0\x58\x43\xbd\xed\xa5\x64\xd6\xe6\xbf\x36\xd8\x0d\x62\x70\xe8\x64\xe6\xb1\x94\xa3\x59\x70\x26\x6f\xce\x35\xbf\x98\xba\x3e\xe7\xbe\x74\xc5\x2f\x17\xf5\xb7\x6b\x9c\x34\xc3\xdc\xbe\xf1\x0e\x93\x34\x5b\xb6\x1c\x1c\xc8\x21\x8e\x71\xdf\x21\x19\xeb\x29\xbe\x69\x47\xd9\x41\xc9\x50\x2c\xc2\x33\x5b\x2c\xa1\x77\x0b\x86\x64\xf8\x8c\x63\xbf\xa3\x41\xce\xb6\x23\xbf\x02\x9c\x3e\x38\xe0\xc1\x37\x49\xdd\xe6\x4e\x98\x03\xde\xd3\xcb\x78\x87\xd5\x33\xe0\x0e\xc9\x9d\xdf\x1b\x9f\x77\xdf\xca\x02\x92\x70\xc4\xd2\xc3\x08\x97\x52\xed\x34\xee\x1f\x80\x6f\x5f\x83\x35\x13\x00\x00")
+
+func assetsMetricStateStatefulsetYamlBytes() ([]byte, error) {
+	return bindataRead(
+		_assetsMetricStateStatefulsetYaml,
+		"assets/metric-state/statefulset.yaml",
+	)
+}
+
+func assetsMetricStateStatefulsetYaml() (*asset, error) {
+	bytes, err := assetsMetricStateStatefulsetYamlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "assets/metric-state/statefulset.yaml", size: 4917, mode: os.FileMode(420), modTime: time.Unix(1792405832, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _assetsPrometheusOperatorDeploymentYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xe4\x56\xc1\x6e\x22\x39\x10\xbd\xe7\x2b\x7c\x9b\xcb\x1a\x92\x4c\x66\x14\x59\xe2\xc0\x12\x26\x89\x14\x08\x0a\xd1\xee\x11\x19\x77\x01\x16\x6e\xdb\x5b\x55\x8d\x82\x50\xfe\x7d\x65\x9a\x24\xd0\x34\x49\xd8\xec\x6d\x7c\xea\xb6\x5f\x95\xab\x9e\x5f\x95\xad\xa3\xfd\x0b\x90\x6c\xf0\x4a\xe8\x18\xa9\xb9\x38\x3b\x99\x5b\x9f\x29\x71\x05\xd1\x85\x65\x0e\x9e\x4f\x72\x60\x9d\x69\xd6\xea\x44\x08\xa7\xc7\xe0\x28\x7d\x89\x64\xd0\x98\x17\x63\x40\x0f\x0c\xd4\xb0\xa1\x69\x42\x1e\x83\x07\xcf\x4a\x98\xe0\x19\x83\x73\x80\x07\xb0\x5e\xe7\xa0\x44\xc4\x90\x03\xcf\xa0\x20\x19\x22\xa0\xe6\x70\x08\xbf\x78\x89\x73\x71\xda\xf8\x7e\xd9\x38\x3b\x11\xe2\xb0\x0b\x8a\x60\x52\x90\x08\xd1\x59\xa3\x49\x89\x84\x27\x70\x60\x38\x60\x19\x7e\xae\xd9\xcc\xee\xb6\xf2\x39\x2e\xa3\x63\x73\x62\xc8\xa3\xd3\x0c\x9b\xcd\xb7\x38\x4d\xc3\xed\xc4\x71\x6c\x24\xc7\xf3\xfb\x69\x8e\x85\x78\xe1\x32\x8d\xb4\xb3\xb6\x1e\x70\x2b\x54\x29\x6c\xae\xa7\xa0\x04\x42\x36\xd3\x2c\x73\x8d\x73\xe0\xe8\xb4\x01\xa9\x0b\x9e\x99\x19\x98\xb9\x4a\xa9\x13\xbf\x1a\x89\xd2\x68\x50\x38\x37\x08\xce\x9a\xa5\x12\xb7\x93\x7e\xe0\x01\x02\x25\xcd\xbd\xe1\xca\x3c\x5e\x1d\x6d\xad\x20\x50\x28\xd0\xc0\x56\x2c\xe5\xf4\x3f\x05\x10\x57\x66\x85\x30\xb1\x50\xe2\xec\x34\xaf\x4c\xe7\x90\x07\x5c\x2a\x71\x7e\xda\xb3\x5b\x4b\x0c\x98\x5b\xaf\xd9\x06\xdf\x03\xa2\x14\xeb\x26\xce\x5f\xda\xb9\xb1\x36\xf3\xc7\x70\x17\xa6\x74\xef\xbb\x88\x5b\xa4\x4a\xa1\x71\x5a\xd9\x5b\x0a\x29\x13\xd1\x0e\x58\x12\xe0\xc2\x1a\x68\xa5\x7f\x49\x4b\x62\xc8\x9b\x9b\xb5\x3d\x1b\x17\xa6\x1c\x88\x33\x40\x6c\x31\x16\xb0\x07\x30\xc1\x4f\xec\x54\x22\xb8\xa0\x33\x40\xb9\xe6\xb4\xb5\x5a\x75\xee\xfb\xbf\x6e\xaf\x7b\xed\xc1\xe8\xa1\x7b\x77\xdf\xbe\xea\x3e\x8c\x6e\x7b\xed\xeb\xee\xf3\xf3\x9e\x8b\x2d\x81\x54\xbc\xb5\x56\xab\xc1\xc3\x7d\x6f\x74\x84\xb3\x74\x56\x14\xb5\x01\x6a\xad\x56\xfd\x76\xaf\x3b\x1c\xb4\x3b\xdd\xe1\xfb\xdb\x5a\x4f\xac\xbd\x81\x03\xd6\x35\xc6\xda\x01\x72\xae\xbd\x9e\xa6\x9c\x8f\x36\x2f\x2d\xa5\xc1\x8c\x5a\x13\xed\x08\xaa\xaa\x4c\x52\x9e\x5a\x62\x5c\x36\x4a\x4d\xa7\xda\x08\x11\x3c\xcd\xec\x84\x2f\x9a\x81\x40\xd6\x14\xd6\x57\x35\xfe\x5e\xad\x0a\x11\x03\x56\x35\x2d\xdf\xea\x71\x10\x90\x95\xb8\x3c\xbd\x3c\xad\xc8\xbb\x74\x3d\x63\x8e\x5f\xac\x9c\x1f\x87\x0a\xe7\xe7\x6e\xe1\x10\x98\x02\x2d\x2f\x3b\xc1\x33\x3c\xb1\x12\xab\xe7\xff\xa1\xac\x84\x58\x04\x57\xe4\xd0\x0b\x85\xdf\xa7\x21\x4f\xb3\x03\xcd\x33\x25\x9a\xc0\xa6\xc9\x8e\x9a\x11\xed\x42\x33\xd4\xd2\x51\xc3\xb4\x64\x47\x15\x2c\x82\xce\xee\xbd\x5b\x2a\xb1\xab\x92\x43\x25\xbe\x55\xae\x7b\x6b\x6b\x56\x40\x3a\x4b\x0c\x5e\xea\x2c\x43\x20\x6a\xa9\xcb\x8b\x8b\xef\x7b\x58\x76\x24\x8d\x8d\x33\x40\x49\x85\x65\xa0\xd6\xe3\xdd\x70\xd4\xed\x5c\xdd\x74\x47\x0f\xc3\xf6\xe8\xef\xdb\xc7\x9b\x51\xbb\x3b\x1c\x9d\x9d\x5f\x8e\xae\x3b\xbd\xd1\xf0\xa6\x7d\xfe\xe3\xe7\x1f\x6f\xa8\x6e\xe7\xea\x03\xdc\x9e\x9f\xce\x9f\x9d\x4f\xf9\xa9\xc5\xbd\xe3\x6d\x2f\xbb\x22\x12\x23\xe8\xbc\x95\x34\x49\xaa\xd9\xac\x39\x8c\xc6\x4e\x01\x37\x68\x61\x54\x92\x76\xb3\x9e\x2a\x40\x96\x13\xeb\xa0\x55\x3d\xfb\xf4\xdd\x30\xb8\xdf\x58\x93\xd9\x06\x23\xe7\xb0\x7c\xc7\x7a\x0e\xcb\xff\xd2\x1d\xd6\xdd\x1d\xc7\xda\xa4\x3e\xf1\xb4\xfc\x6a\x67\xa8\xb8\x3b\xb6\x2b\x54\x45\xb6\xdd\x15\xe8\xab\x17\xea\xa1\xb6\x70\xf1\xbb\xb5\x85\xfa\x3d\xcb\x4b\x35\xd7\x91\x9a\xaf\x3e\xd7\x8a\x35\x5a\x8e\x0b\x9f\xb9\xfa\x50\x76\xb0\x74\x10\x5c\x1f\x8b\x0f\x19\x0c\x77\xde\xba\x69\x8c\x81\x75\xe5\xbd\x17\x48\x09\x67\x7d\xf1\xf4\x0a\x4a\xa6\x12\x83\x83\x0a\x32\xd7\xc4\x80\x4a\x7c\xfb\xb6\x81\x46\xb4\x61\x7d\x9e\x4e\x13\xf5\xd7\x31\x97\xaf\x19\x69\x5c\x91\xb0\xd2\xa0\x65\x6b\xb4\x3b\xf9\x48\x00\x9b\x27\x51\xdb\x98\xc4\x5d\xe9\xab\xe6\x2d\x79\xf8\x7a\xe4\xe0\xd2\xbf\x0d\x7e\xe7\x59\x0a\x93\x09\x18\x56\xa2\x1f\x86\x66\x06\x59\xb1\xc3\xde\x1c\x96\xea\x83\x6c\xb7\xd0\xaf\x57\xbc\xe8\x3e\x59\xe2\x17\x49\x94\xe2\xdb\xd9\xf4\xb3\x4a\x22\x30\x08\xbc\xab\xd8\x72\xae\xff\x29\x0f\xeb\x2a\x9f\xd8\x69\x4f\xc7\x5d\x27\x9f\xd6\xcf\x07\xc0\x7f\x03\x00\x00\xff\xff\x58\xba\x22\x53\x1b\x0e\x00\x00")
 
 func assetsPrometheusOperatorDeploymentYamlBytes() ([]byte, error) {
@@ -625,14 +709,18 @@ func AssetNames() []string {
 
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
-	"assets/metric-state/deployment.yaml":                      assetsMetricStateDeploymentYaml,
+	"assets/metric-state/statefulset.yaml":                     assetsMetricStateStatefulsetYaml,
+	"assets/kube-state-metrics/deployment.yaml":                assetsKubeStateMetricsDeploymentYaml,
+	"assets/kube-state-metrics/service-monitor.yaml":           assetsKubeStateMetricsServiceMonitorYaml,
+	"assets/kube-state-metrics/service.yaml":                   assetsKubeStateMetricsServiceYaml,
 	"assets/metric-state/service-monitor.yaml":                 assetsMetricStateServiceMonitorYaml,
 	"assets/metric-state/service.yaml":                         assetsMetricStateServiceYaml,
 	"assets/prometheus/additional-scrape-configs.yaml":         assetsPrometheusAdditionalScrapeConfigsYaml,
 	"assets/prometheus/htpasswd-secret.yaml":                   assetsPrometheusHtpasswdSecretYaml,
 	"assets/prometheus/kube-rbac-proxy-secret.yaml":            assetsPrometheusKubeRbacProxySecretYaml,
-	"assets/prometheus/kube-state-service-monitor.yaml":        assetsPrometheusKubeStateServiceMonitorYaml,
+	"assets/prometheus/kubelet-service-monitor.yaml":           assetsPrometheusKubeletServiceMonitorYaml,
 	"assets/prometheus/kubelet-serving-ca-bundle.yaml":         assetsPrometheusKubeletServingCaBundleYaml,
+	"assets/prometheus/operator-service-monitor.yaml":          assetsPrometheusOperatorServiceMonitorYaml,
 	"assets/prometheus/prometheus-additional.yaml":             assetsPrometheusPrometheusAdditionalYaml,
 	"assets/prometheus/prometheus-datasources-secret.yaml":     assetsPrometheusPrometheusDatasourcesSecretYaml,
 	"assets/prometheus/prometheus-rules.yaml":                  assetsPrometheusPrometheusRulesYaml,
@@ -654,11 +742,13 @@ var _bindata = map[string]func() (*asset, error){
 // directory embedded in the file by go-bindata.
 // For example if you run go-bindata on data/... and data contains the
 // following hierarchy:
-//     data/
-//       foo.txt
-//       img/
-//         a.png
-//         b.png
+//
+//	data/
+//	  foo.txt
+//	  img/
+//	    a.png
+//	    b.png
+//
 // then AssetDir("data") would return []string{"foo.txt", "img"}
 // AssetDir("data/img") would return []string{"a.png", "b.png"}
 // AssetDir("foo.txt") and AssetDir("notexist") would return an error
@@ -692,8 +782,13 @@ type bintree struct {
 
 var _bintree = &bintree{nil, map[string]*bintree{
 	"assets": &bintree{nil, map[string]*bintree{
+		"kube-state-metrics": &bintree{nil, map[string]*bintree{
+			"deployment.yaml":      &bintree{assetsKubeStateMetricsDeploymentYaml, map[string]*bintree{}},
+			"service-monitor.yaml": &bintree{assetsKubeStateMetricsServiceMonitorYaml, map[string]*bintree{}},
+			"service.yaml":         &bintree{assetsKubeStateMetricsServiceYaml, map[string]*bintree{}},
+		}},
 		"metric-state": &bintree{nil, map[string]*bintree{
-			"deployment.yaml":      &bintree{assetsMetricStateDeploymentYaml, map[string]*bintree{}},
+			"statefulset.yaml":     &bintree{assetsMetricStateStatefulsetYaml, map[string]*bintree{}},
 			"service-monitor.yaml": &bintree{assetsMetricStateServiceMonitorYaml, map[string]*bintree{}},
 			"service.yaml":         &bintree{assetsMetricStateServiceYaml, map[string]*bintree{}},
 		}},
@@ -701,8 +796,9 @@ var _bintree = &bintree{nil, map[string]*bintree{
 			"additional-scrape-configs.yaml":     &bintree{assetsPrometheusAdditionalScrapeConfigsYaml, map[string]*bintree{}},
 			"htpasswd-secret.yaml":               &bintree{assetsPrometheusHtpasswdSecretYaml, map[string]*bintree{}},
 			"kube-rbac-proxy-secret.yaml":        &bintree{assetsPrometheusKubeRbacProxySecretYaml, map[string]*bintree{}},
-			"kube-state-service-monitor.yaml":    &bintree{assetsPrometheusKubeStateServiceMonitorYaml, map[string]*bintree{}},
+			"kubelet-service-monitor.yaml":       &bintree{assetsPrometheusKubeletServiceMonitorYaml, map[string]*bintree{}},
 			"kubelet-serving-ca-bundle.yaml":     &bintree{assetsPrometheusKubeletServingCaBundleYaml, map[string]*bintree{}},
+			"operator-service-monitor.yaml":      &bintree{assetsPrometheusOperatorServiceMonitorYaml, map[string]*bintree{}},
 			"prometheus-additional.yaml":         &bintree{assetsPrometheusPrometheusAdditionalYaml, map[string]*bintree{}},
 			"prometheus-datasources-secret.yaml": &bintree{assetsPrometheusPrometheusDatasourcesSecretYaml, map[string]*bintree{}},
 			"prometheus-rules.yaml":              &bintree{assetsPrometheusPrometheusRulesYaml, map[string]*bintree{}},
@@ -16,20 +16,29 @@ package manifests
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 
 	configv1 "github.com/openshift/api/config/v1"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/config"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
 	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// PlatformKubernetes is the platform of clusters without the OpenShift
+// config API.
+const PlatformKubernetes configv1.PlatformType = "Kubernetes"
+
 type Config struct {
 	RelatedImages            config.RelatedImages      `json:"relatedImages"`
 	PrometheusOperatorConfig *PrometheusOperatorConfig `json:"prometheusOperator"`
 	PrometheusConfig         *PrometheusConfig         `json:"prometheusConfig"`
 	Platform                 configv1.PlatformType     `json:"-"`
+	ClusterSecrets           config.ClusterSecrets     `json:"-"`
 }
 
 type PrometheusOperatorConfig struct {
@@ -58,6 +67,36 @@ func (c *Config) LoadPlatform(load func() (*configv1.Infrastructure, error)) err
 	return nil
 }
 
+// IsOpenShift is true unless the platform was loaded from a cluster
+// without the OpenShift config API.
+func (c *Config) IsOpenShift() bool {
+	return c.Platform != PlatformKubernetes
+}
+
+// InfrastructureLoader returns a LoadPlatform loader that gets the cluster
+// infrastructure. Clusters without the OpenShift config API get an
+// infrastructure on PlatformKubernetes.
+func InfrastructureLoader(
+	ctx context.Context,
+	c client.Reader,
+) func() (*configv1.Infrastructure, error) {
+	return func() (*configv1.Infrastructure, error) {
+		infra := &configv1.Infrastructure{}
+		err := c.Get(ctx, types.NamespacedName{Name: "cluster"}, infra)
+
+		if meta.IsNoMatchError(err) {
+			infra.Status.Platform = PlatformKubernetes
+			return infra, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return infra, nil
+	}
+}
+
 func NewConfigFromString(content string) (*Config, error) {
 	if content == "" {
 		return NewDefaultConfig(), nil
@@ -81,6 +120,7 @@ func NewDefaultConfig() *Config {
 	cfg, _ := config.ProvideConfig()
 	c := &Config{}
 	c.RelatedImages = cfg.RelatedImages
+	c.ClusterSecrets = cfg.ClusterSecrets
 	c.applyDefaults()
 	return c
 }
@@ -88,6 +128,7 @@ func NewDefaultConfig() *Config {
 func NewOperatorConfig(cfg config.OperatorConfig) *Config {
 	c := &Config{}
 	c.RelatedImages = cfg.RelatedImages
+	c.ClusterSecrets = cfg.ClusterSecrets
 	c.applyDefaults()
 	return c
 }
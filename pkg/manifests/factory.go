@@ -23,10 +23,15 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/gotidy/ptr"
+	"github.com/prometheus/common/model"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
 	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils"
 	appsv1 "k8s.io/api/apps/v1"
@@ -52,12 +57,27 @@ const (
 	PrometheusDatasourcesSecret      = "assets/prometheus/prometheus-datasources-secret.yaml"
 	PrometheusServingCertsCABundle   = "assets/prometheus/serving-certs-ca-bundle.yaml"
 	PrometheusKubeletServingCABundle = "assets/prometheus/kubelet-serving-ca-bundle.yaml"
+	PrometheusRules                  = "assets/prometheus/prometheus-rules.yaml"
+	OperatorServiceMonitor           = "assets/prometheus/operator-service-monitor.yaml"
 
 	ReporterJob = "assets/reporter/job.yaml"
 
-	MetricStateDeployment     = "assets/metric-state/deployment.yaml"
+	// prometheusSecretsDir is where the prometheus operator mounts the
+	// secrets of a prometheus
+	prometheusSecretsDir = "/etc/prometheus/secrets"
+
+	// reporterAuthDir is where the reporter job mounts the token and CA
+	// of the prometheus service of its report
+	reporterAuthDir = "/etc/prometheus-auth"
+
+	MetricStateStatefulSet    = "assets/metric-state/statefulset.yaml"
 	MetricStateServiceMonitor = "assets/metric-state/service-monitor.yaml"
 	MetricStateService        = "assets/metric-state/service.yaml"
+
+	KubeStateMetricsDeployment     = "assets/kube-state-metrics/deployment.yaml"
+	KubeStateMetricsService        = "assets/kube-state-metrics/service.yaml"
+	KubeStateMetricsServiceMonitor = "assets/kube-state-metrics/service-monitor.yaml"
+	KubeletServiceMonitor          = "assets/prometheus/kubelet-service-monitor.yaml"
 )
 
 var log = logf.Log.WithName("manifests_factory")
@@ -90,6 +110,8 @@ func (f *Factory) ReplaceImages(container *corev1.Container) {
 		container.Image = f.config.RelatedImages.PrometheusOperator
 	case container.Name == "prometheus-proxy":
 		container.Image = f.config.RelatedImages.OAuthProxy
+	case container.Name == "kube-state-metrics":
+		container.Image = f.config.RelatedImages.KubeStateMetrics
 	}
 }
 
@@ -106,6 +128,19 @@ func (f *Factory) NewDeployment(manifest io.Reader) (*appsv1.Deployment, error)
 	return d, nil
 }
 
+func (f *Factory) NewStatefulSet(manifest io.Reader) (*appsv1.StatefulSet, error) {
+	d, err := NewStatefulSet(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.GetNamespace() == "" {
+		d.SetNamespace(f.namespace)
+	}
+
+	return d, nil
+}
+
 func (f *Factory) NewService(manifest io.Reader) (*corev1.Service, error) {
 	d, err := NewService(manifest)
 	if err != nil {
@@ -323,6 +358,10 @@ func (f *Factory) NewPrometheusDeployment(
 		}
 	}
 
+	if cr.Spec.LongTermStorage != nil {
+		f.addLongTermStorage(p, cr.Spec.LongTermStorage)
+	}
+
 	for i := range p.Spec.Containers {
 		f.ReplaceImages(&p.Spec.Containers[i])
 	}
@@ -330,6 +369,44 @@ func (f *Factory) NewPrometheusDeployment(
 	return p, err
 }
 
+// addLongTermStorage adds the remote write targets and the thanos sidecar
+// of the meterbase to the prometheus.
+func (f *Factory) addLongTermStorage(
+	p *monitoringv1.Prometheus,
+	spec *marketplacev1alpha1.LongTermStorageSpec,
+) {
+	for _, target := range spec.RemoteWrite {
+		remoteWrite := monitoringv1.RemoteWriteSpec{
+			Name:          target.Name,
+			URL:           target.URL,
+			RemoteTimeout: target.RemoteTimeout,
+			BasicAuth:     target.BasicAuth,
+			QueueConfig:   target.QueueConfig,
+		}
+
+		// secrets listed on the prometheus are mounted in its pods
+		if secret := target.BearerTokenSecret; secret != nil {
+			if !utils.Contains(p.Spec.Secrets, secret.Name) {
+				p.Spec.Secrets = append(p.Spec.Secrets, secret.Name)
+			}
+
+			remoteWrite.BearerTokenFile = path.Join(prometheusSecretsDir, secret.Name, secret.Key)
+		}
+
+		p.Spec.RemoteWrite = append(p.Spec.RemoteWrite, remoteWrite)
+	}
+
+	if spec.Thanos != nil {
+		objectStorageConfig := spec.Thanos.ObjectStorageConfig
+
+		p.Spec.Thanos = &monitoringv1.ThanosSpec{
+			Image:               ptr.String(f.config.RelatedImages.Thanos),
+			Resources:           spec.Thanos.Resources,
+			ObjectStorageConfig: &objectStorageConfig,
+		}
+	}
+}
+
 func (f *Factory) NewPrometheusOperatorService() (*corev1.Service, error) {
 	service, err := f.NewService(MustAssetReader(PrometheusOperatorService))
 
@@ -413,7 +490,7 @@ func (f *Factory) ReporterJob(report *marketplacev1alpha1.MeterReport) (*batchv1
 	container := j.Spec.Template.Spec.Containers[0]
 	container.Image = f.config.RelatedImages.Reporter
 
-	j.Name = report.GetName()
+	j.Name = report.GetJobName()
 	container.Args = append(container.Args,
 		"--name",
 		report.Name,
@@ -421,6 +498,17 @@ func (f *Factory) ReporterJob(report *marketplacev1alpha1.MeterReport) (*batchv1
 		report.Namespace,
 	)
 
+	addReporterServiceAuth(&j.Spec.Template.Spec, &container, report.Spec.PrometheusService)
+
+	if !f.config.IsOpenShift() {
+		container.Args = append(container.Args,
+			"--clusterid-secret",
+			f.config.ClusterSecrets.ClusterIDSecret,
+			"--upload-secret",
+			f.config.ClusterSecrets.UploadSecret,
+		)
+	}
+
 	if len(report.Spec.ExtraArgs) > 0 {
 		container.Args = append(container.Args, report.Spec.ExtraArgs...)
 	}
@@ -430,8 +518,71 @@ func (f *Factory) ReporterJob(report *marketplacev1alpha1.MeterReport) (*batchv1
 	return j, nil
 }
 
-func (f *Factory) MetricStateDeployment() (*appsv1.Deployment, error) {
-	d, err := f.NewDeployment(MustAssetReader(MetricStateDeployment))
+// addReporterServiceAuth mounts the bearer token secret and CA of the
+// prometheus service in the reporter and points its flags at them.
+func addReporterServiceAuth(
+	spec *corev1.PodSpec,
+	container *corev1.Container,
+	ref *common.ServiceReference,
+) {
+	if ref == nil {
+		return
+	}
+
+	mount := func(name string, source corev1.VolumeSource) string {
+		dir := path.Join(reporterAuthDir, name)
+		spec.Volumes = append(spec.Volumes, corev1.Volume{Name: name, VolumeSource: source})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: dir,
+			ReadOnly:  true,
+		})
+		return dir
+	}
+
+	if secret := ref.BearerTokenSecret; secret.Name != "" {
+		dir := mount("prometheus-token", corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: secret.Name},
+		})
+		container.Args = setArg(container.Args, "--tokenfile", path.Join(dir, secret.Key))
+	}
+
+	if ref.TLSConfig == nil {
+		return
+	}
+
+	switch ca := ref.TLSConfig.CA; {
+	case ca.Secret != nil:
+		dir := mount("prometheus-ca", corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: ca.Secret.Name},
+		})
+		container.Args = setArg(container.Args, "--cafile", path.Join(dir, ca.Secret.Key))
+	case ca.ConfigMap != nil:
+		dir := mount("prometheus-ca", corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: ca.ConfigMap.Name},
+			},
+		})
+		container.Args = setArg(container.Args, "--cafile", path.Join(dir, ca.ConfigMap.Key))
+	}
+}
+
+// setArg sets the value following flag in args, or appends both.
+func setArg(args []string, flag, value string) []string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == flag {
+			args[i+1] = value
+			return args
+		}
+	}
+
+	return append(args, flag, value)
+}
+
+// MetricStateStatefulSet returns the metric-state replicas. Each
+// replica reads its shard from its ordinal.
+func (f *Factory) MetricStateStatefulSet() (*appsv1.StatefulSet, error) {
+	d, err := f.NewStatefulSet(MustAssetReader(MetricStateStatefulSet))
 	if err != nil {
 		return nil, err
 	}
@@ -469,6 +620,122 @@ func (f *Factory) MetricStateService() (*v1.Service, error) {
 	return s, nil
 }
 
+func (f *Factory) KubeStateMetricsDeployment() (*appsv1.Deployment, error) {
+	d, err := f.NewDeployment(MustAssetReader(KubeStateMetricsDeployment))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range d.Spec.Template.Spec.Containers {
+		f.ReplaceImages(&d.Spec.Template.Spec.Containers[i])
+	}
+
+	d.Namespace = f.namespace
+
+	return d, nil
+}
+
+func (f *Factory) KubeStateMetricsService() (*v1.Service, error) {
+	s, err := f.NewService(MustAssetReader(KubeStateMetricsService))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespace
+
+	return s, nil
+}
+
+// KubeStateMetricsServiceMonitor is the service monitor of the
+// kube-state-metrics installed on clusters that aren't OpenShift.
+func (f *Factory) KubeStateMetricsServiceMonitor() (*monitoringv1.ServiceMonitor, error) {
+	sm, err := f.NewServiceMonitor(MustAssetReader(KubeStateMetricsServiceMonitor))
+	if err != nil {
+		return nil, err
+	}
+
+	sm.Namespace = f.namespace
+
+	return sm, nil
+}
+
+// KubeletServiceMonitor scrapes the kubelet service the prometheus
+// operator maintains in kube-system on clusters that aren't OpenShift.
+func (f *Factory) KubeletServiceMonitor() (*monitoringv1.ServiceMonitor, error) {
+	sm, err := f.NewServiceMonitor(MustAssetReader(KubeletServiceMonitor))
+	if err != nil {
+		return nil, err
+	}
+
+	sm.Namespace = f.namespace
+
+	return sm, nil
+}
+
+// OperatorServiceMonitor scrapes the metrics service of the operator,
+// which has the state of the meter reports.
+func (f *Factory) OperatorServiceMonitor() (*monitoringv1.ServiceMonitor, error) {
+	sm, err := f.NewServiceMonitor(MustAssetReader(OperatorServiceMonitor))
+	if err != nil {
+		return nil, err
+	}
+
+	sm.Namespace = f.namespace
+
+	return sm, nil
+}
+
+// AlertThresholds fill in the metering alerts.
+type AlertThresholds struct {
+	MetricStateDownFor time.Duration
+	SeriesAbsentFor    time.Duration
+	ReportErroredFor   time.Duration
+	UploadFailingFor   time.Duration
+	StorageUsedPercent int32
+}
+
+// MeteringPrometheusRule returns the metering alerts of the meterbase with
+// the thresholds filled in. An external Prometheus doesn't get the storage
+// alert, its volume isn't ours.
+func (f *Factory) MeteringPrometheusRule(
+	cr *marketplacev1alpha1.MeterBase,
+	thresholds AlertThresholds,
+) (*monitoringv1.PrometheusRule, error) {
+	replacer := strings.NewReplacer(
+		"{{NAMESPACE}}", f.namespace,
+		"{{PROMETHEUS}}", cr.Name,
+		"{{METRIC_STATE_DOWN_FOR}}", model.Duration(thresholds.MetricStateDownFor).String(),
+		"{{SERIES_ABSENT_FOR}}", model.Duration(thresholds.SeriesAbsentFor).String(),
+		"{{REPORT_ERRORED_FOR}}", model.Duration(thresholds.ReportErroredFor).String(),
+		"{{UPLOAD_FAILING_FOR}}", model.Duration(thresholds.UploadFailingFor).String(),
+		"{{STORAGE_USED_PERCENT}}", strconv.Itoa(int(thresholds.StorageUsedPercent)),
+	)
+
+	rule, err := NewPrometheusRule(strings.NewReader(replacer.Replace(string(MustAsset(PrometheusRules)))))
+	if err != nil {
+		return nil, err
+	}
+
+	rule.Namespace = f.namespace
+
+	if cr.Spec.ExternalPrometheus != nil {
+		for i := range rule.Spec.Groups {
+			group := &rule.Spec.Groups[i]
+			rules := []monitoringv1.Rule{}
+
+			for _, r := range group.Rules {
+				if r.Alert != "MeteringStorageNearlyFull" {
+					rules = append(rules, r)
+				}
+			}
+
+			group.Rules = rules
+		}
+	}
+
+	return rule, nil
+}
+
 func (f *Factory) NewServiceMonitor(manifest io.Reader) (*monitoringv1.ServiceMonitor, error) {
 	sm, err := NewServiceMonitor(manifest)
 	if err != nil {
@@ -492,6 +759,16 @@ func NewDeployment(manifest io.Reader) (*appsv1.Deployment, error) {
 	return &d, nil
 }
 
+func NewStatefulSet(manifest io.Reader) (*appsv1.StatefulSet, error) {
+	d := appsv1.StatefulSet{}
+	err := yaml.NewYAMLOrJSONDecoder(manifest, 100).Decode(&d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
 func NewConfigMap(manifest io.Reader) (*v1.ConfigMap, error) {
 	cm := v1.ConfigMap{}
 	err := yaml.NewYAMLOrJSONDecoder(manifest, 100).Decode(&cm)
@@ -551,6 +828,16 @@ func GeneratePassword(n int) (string, error) {
 	return base64.StdEncoding.EncodeToString(b), err
 }
 
+func NewPrometheusRule(manifest io.Reader) (*monitoringv1.PrometheusRule, error) {
+	r := monitoringv1.PrometheusRule{}
+	err := yaml.NewYAMLOrJSONDecoder(manifest, 100).Decode(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
 func NewServiceMonitor(manifest io.Reader) (*monitoringv1.ServiceMonitor, error) {
 	sm := monitoringv1.ServiceMonitor{}
 	err := yaml.NewYAMLOrJSONDecoder(manifest, 100).Decode(&sm)
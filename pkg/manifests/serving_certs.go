@@ -0,0 +1,252 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"emperror.dev/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ServingCertSecretAnnotation names the secret OpenShift's service-ca
+	// writes a service's serving certificate to. Off OpenShift the operator
+	// writes the secret itself.
+	ServingCertSecretAnnotation = "service.beta.openshift.io/serving-cert-secret-name"
+
+	// ServingCAKey is the CA bundle key of the serving certs configmaps.
+	ServingCAKey = "service-ca.crt"
+
+	ServingCASecretName = "rhm-serving-certs-ca"
+
+	servingCAValidity   = 10 * 365 * 24 * time.Hour
+	servingCertValidity = 2 * 365 * 24 * time.Hour
+
+	// ServingCertRenewBefore is how long before expiry certificates are
+	// generated again.
+	ServingCertRenewBefore = 30 * 24 * time.Hour
+)
+
+// ServingCA signs the serving certificates of our services on clusters
+// without the OpenShift service-ca.
+type ServingCA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// NewServingCA generates a self-signed CA valid from now.
+func NewServingCA(now time.Time) (*ServingCA, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating ca key")
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("rhm-serving-ca@%d", now.Unix())},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(servingCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating ca certificate")
+	}
+
+	return newServingCA(der, key)
+}
+
+// LoadServingCA reads a CA from a secret made by ServingCA.Secret.
+func LoadServingCA(secret *corev1.Secret) (*ServingCA, error) {
+	cert, err := parseCertificate(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(secret.Data[corev1.TLSPrivateKeyKey])
+	if block == nil {
+		return nil, errors.New("ca key is not pem encoded")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing ca key")
+	}
+
+	return newServingCA(cert.Raw, key)
+}
+
+func newServingCA(der []byte, key *rsa.PrivateKey) (*ServingCA, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing ca certificate")
+	}
+
+	return &ServingCA{
+		cert:    cert,
+		key:     key,
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	}, nil
+}
+
+// NeedsRenewal is true once the CA is within ServingCertRenewBefore of
+// expiring.
+func (ca *ServingCA) NeedsRenewal(now time.Time) bool {
+	return !now.Add(ServingCertRenewBefore).Before(ca.cert.NotAfter)
+}
+
+// Secret returns the CA as a tls secret.
+func (ca *ServingCA) Secret(namespace string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ServingCASecretName,
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       ca.CertPEM,
+			corev1.TLSPrivateKeyKey: ca.KeyPEM,
+		},
+	}
+}
+
+// ServingCertSecret returns the serving certificate secret of a service
+// annotated with ServingCertSecretAnnotation, signed by the CA.
+func (ca *ServingCA) ServingCertSecret(service *corev1.Service, now time.Time) (*corev1.Secret, error) {
+	name, ok := service.Annotations[ServingCertSecretAnnotation]
+	if !ok {
+		return nil, errors.Errorf("service %s has no serving cert secret annotation", service.Name)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating serving key")
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	dnsNames := ServingCertDNSNames(service)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(servingCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating serving certificate")
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: service.Namespace,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+	}, nil
+}
+
+// ServingCertValid is true if the secret holds a certificate for the
+// service signed by the CA that isn't due for renewal.
+func (ca *ServingCA) ServingCertValid(secret *corev1.Secret, service *corev1.Service, now time.Time) bool {
+	if len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+		return false
+	}
+
+	cert, err := parseCertificate(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return false
+	}
+
+	if !bytes.Equal(cert.RawIssuer, ca.cert.RawSubject) || cert.CheckSignatureFrom(ca.cert) != nil {
+		return false
+	}
+
+	if !now.Add(ServingCertRenewBefore).Before(cert.NotAfter) {
+		return false
+	}
+
+	for _, name := range ServingCertDNSNames(service) {
+		if cert.VerifyHostname(name) != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ServingCertDNSNames are the names the service is reached by in cluster.
+func ServingCertDNSNames(service *corev1.Service) []string {
+	return []string{
+		fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", service.Name, service.Namespace),
+	}
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("certificate is not pem encoded")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing certificate")
+	}
+
+	return cert, nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating serial number")
+	}
+
+	return serial, nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// StoreSummary is the size of the maps kept by a store.
+type StoreSummary struct {
+	MeterDefinitions int `json:"meterDefinitions"`
+	MatchedObjects   int `json:"matchedObjects"`
+	ObjectsSeen      int `json:"objectsSeen"`
+}
+
+// LookupSummary describes the filters of a meter definition lookup.
+type LookupSummary struct {
+	UID        types.UID            `json:"uid"`
+	MeterDef   types.NamespacedName `json:"meterDefinition"`
+	Lookup     string               `json:"lookup"`
+	Hash       string               `json:"hash"`
+	Namespaces []string             `json:"namespaces"`
+	Filters    map[string][]string  `json:"filters"`
+}
+
+// FilterTrace is the result of a filter applied to an object.
+type FilterTrace struct {
+	Filter string `json:"filter"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WorkloadTrace is the result of the filters of a workload applied
+// to an object.
+type WorkloadTrace struct {
+	Workload string        `json:"workload"`
+	Matched  bool          `json:"matched"`
+	Filters  []FilterTrace `json:"filters"`
+}
+
+// MeterDefinitionTrace is the result of the workloads of a meter
+// definition applied to an object.
+type MeterDefinitionTrace struct {
+	UID       types.UID            `json:"uid"`
+	MeterDef  types.NamespacedName `json:"meterDefinition"`
+	Workloads []WorkloadTrace      `json:"workloads"`
+}
+
+// FilterStrings returns the filters of each workload as strings.
+func (s *MeterDefinitionLookupFilter) FilterStrings() map[string][]string {
+	out := make(map[string][]string, len(s.filters))
+
+	for key, workloadFilters := range s.filters {
+		strs := make([]string, 0, len(workloadFilters))
+		for _, filter := range workloadFilters {
+			strs = append(strs, printFilter(filter))
+		}
+		out[key] = strs
+	}
+
+	return out
+}
+
+// Trace applies all the filters of every workload to the object and
+// returns the result of each filter. Unlike FindMatchingWorkloads it
+// does not stop at the first filter that fails.
+func (s *MeterDefinitionLookupFilter) Trace(obj interface{}) []WorkloadTrace {
+	keys := make([]string, 0, len(s.filters))
+	for key := range s.filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	traces := make([]WorkloadTrace, 0, len(keys))
+
+	for _, key := range keys {
+		trace := WorkloadTrace{
+			Workload: key,
+			Matched:  true,
+		}
+
+		for _, filter := range s.filters[key] {
+			ans, err := filter.Filter(obj)
+			filterTrace := FilterTrace{
+				Filter: printFilter(filter),
+				Passed: ans && err == nil,
+			}
+
+			if err != nil {
+				filterTrace.Error = err.Error()
+			}
+
+			trace.Matched = trace.Matched && filterTrace.Passed
+			trace.Filters = append(trace.Filters, filterTrace)
+		}
+
+		traces = append(traces, trace)
+	}
+
+	return traces
+}
+
+// Summary returns the size of the store.
+func (s *MeterDefinitionStore) Summary() StoreSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return StoreSummary{
+		MeterDefinitions: len(s.meterDefinitionFilters),
+		MatchedObjects:   len(s.objectResourceSet),
+		ObjectsSeen:      len(s.objectsSeen),
+	}
+}
+
+// Lookups returns the lookups of the meter definitions in the store.
+func (s *MeterDefinitionStore) Lookups() []LookupSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	summaries := make([]LookupSummary, 0, len(s.meterDefinitionFilters))
+
+	for uid, lookup := range s.meterDefinitionFilters {
+		summaries = append(summaries, LookupSummary{
+			UID:        types.UID(uid),
+			MeterDef:   lookup.MeterDefName,
+			Lookup:     lookup.String(),
+			Hash:       lookup.Hash(),
+			Namespaces: lookup.Namespaces(),
+			Filters:    lookup.FilterStrings(),
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].MeterDef.String() < summaries[j].MeterDef.String()
+	})
+
+	return summaries
+}
+
+// TraceObject applies every meter definition lookup to the object seen
+// with the uid. Returns false if the object has not been seen. Filters
+// may query the api server, so they are applied without holding the
+// mutex.
+func (s *MeterDefinitionStore) TraceObject(uid types.UID) ([]MeterDefinitionTrace, bool) {
+	s.mutex.Lock()
+	obj, ok := s.objectsSeen[ObjectUID(uid)]
+
+	lookups := make(map[MeterDefUID]*MeterDefinitionLookupFilter, len(s.meterDefinitionFilters))
+	for meterDefUID, lookup := range s.meterDefinitionFilters {
+		lookups[meterDefUID] = lookup
+	}
+	s.mutex.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	traces := make([]MeterDefinitionTrace, 0, len(lookups))
+
+	for meterDefUID, lookup := range lookups {
+		traces = append(traces, MeterDefinitionTrace{
+			UID:       types.UID(meterDefUID),
+			MeterDef:  lookup.MeterDefName,
+			Workloads: lookup.Trace(obj),
+		})
+	}
+
+	sort.Slice(traces, func(i, j int) bool {
+		return traces[i].MeterDef.String() < traces[j].MeterDef.String()
+	})
+
+	return traces, true
+}
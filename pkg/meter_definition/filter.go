@@ -124,42 +124,19 @@ func (f *WorkloadFilterForOwner) Filter(obj interface{}) (bool, error) {
 		return false, errors.New("type was not a metav1.Object")
 	}
 
-	owner := metav1.GetControllerOf(meta)
-
-	if owner == nil {
-		return false, nil
-	}
-
-	if owner.APIVersion == f.workload.OwnerCRD.APIVersion && owner.Kind == f.workload.OwnerCRD.Kind {
-		return true, nil
-	}
-
-	namespace := meta.GetNamespace()
-	var err error
-
-	for i := 0; i < 5; i++ {
-		owner, err = f.findOwner.FindOwner(owner.Name, namespace, owner)
-
-		if err != nil {
-			return false, err
-		}
-
-		if owner == nil {
-			return false, nil
-		}
-
-		if owner.APIVersion == f.workload.OwnerCRD.APIVersion && owner.Kind == f.workload.OwnerCRD.Kind {
-			return true, nil
-		}
-	}
-
-	return false, nil
+	return f.findOwner.FindOwnerMatching(meta, func(owner *metav1.OwnerReference) bool {
+		return owner.APIVersion == f.workload.OwnerCRD.APIVersion && owner.Kind == f.workload.OwnerCRD.Kind
+	})
 }
 
 type WorkloadLabelFilter struct {
 	labelSelector labels.Selector
 }
 
+func (f *WorkloadLabelFilter) String() string {
+	return fmt.Sprintf("WorkloadLabelFilter{labelSelector: %s}", f.labelSelector)
+}
+
 func (f *WorkloadLabelFilter) Filter(obj interface{}) (bool, error) {
 	meta, ok := obj.(metav1.Object)
 
@@ -174,6 +151,10 @@ type WorkloadAnnotationFilter struct {
 	annotationSelector labels.Selector
 }
 
+func (f *WorkloadAnnotationFilter) String() string {
+	return fmt.Sprintf("WorkloadAnnotationFilter{annotationSelector: %s}", f.annotationSelector)
+}
+
 func (f *WorkloadAnnotationFilter) Filter(obj interface{}) (bool, error) {
 	meta, ok := obj.(metav1.Object)
 
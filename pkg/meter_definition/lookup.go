@@ -16,14 +16,17 @@ package meter_definition
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	"emperror.dev/errors"
 	"github.com/cespare/xxhash"
 	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/go-logr/logr"
+	olmv1 "github.com/operator-framework/api/pkg/operators/v1"
 	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
 	rhmclient "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/client"
@@ -38,6 +41,8 @@ import (
 
 type MeterDefinitionLookupFilter struct {
 	MeterDefName types.NamespacedName
+	meterdef     *v1alpha1.MeterDefinition
+	namespaces   []string
 	workloads    map[string]v1alpha1.Workload
 	filters      map[string][]FilterRuntimeObject
 	cc           ClientCommandRunner
@@ -58,6 +63,7 @@ func NewMeterDefinitionLookupFilter(
 
 	s := &MeterDefinitionLookupFilter{
 		MeterDefName: types.NamespacedName{Name: meterdef.Name, Namespace: meterdef.Namespace},
+		meterdef:     meterdef.DeepCopy(),
 		findOwner:    findOwner,
 		cc:           cc,
 		log:          log.WithValues("meterdefName", meterdef.Name, "meterdefNamespace", meterdef.Namespace),
@@ -75,23 +81,76 @@ func NewMeterDefinitionLookupFilter(
 	}
 
 	workloads := map[string]v1alpha1.Workload{}
-	for _, wkld := range meterdef.Spec.Workloads {
+	for _, wkld := range s.meterdef.Spec.Workloads {
 		workloads[wkld.Name] = wkld
 	}
 
+	sort.Strings(ns)
+
+	s.namespaces = ns
 	s.workloads = workloads
 	s.filters = filters
 
 	return s, nil
 }
 
+// Namespaces returns the namespaces the workloads are matched in. An
+// empty string means all namespaces.
+func (s *MeterDefinitionLookupFilter) Namespaces() []string {
+	return s.namespaces
+}
+
+// Rebuild creates a new lookup for the same meter definition, resolving
+// the namespaces again.
+func (s *MeterDefinitionLookupFilter) Rebuild() (*MeterDefinitionLookupFilter, error) {
+	return NewMeterDefinitionLookupFilter(s.cc, s.meterdef, s.findOwner)
+}
+
+// DependsOnNamespaces returns true if the namespaces are resolved with
+// a label selector on namespaces.
+func (s *MeterDefinitionLookupFilter) DependsOnNamespaces() bool {
+	return s.meterdef.Spec.WorkloadVertexType == v1alpha1.WorkloadVertexNamespace &&
+		s.meterdef.Spec.VertexLabelSelector != nil
+}
+
+// DependsOnOperatorGroup returns true if the namespaces are resolved
+// from the operator group in the namespace.
+func (s *MeterDefinitionLookupFilter) DependsOnOperatorGroup(namespace string) bool {
+	return s.meterdef.Spec.WorkloadVertexType == v1alpha1.WorkloadVertexOperatorGroup &&
+		s.meterdef.Spec.InstalledBy != nil &&
+		s.meterdef.Spec.InstalledBy.Namespace == namespace
+}
+
+// Hash identifies the filters of the lookup. Lookups with the same
+// hash match the same objects.
 func (s *MeterDefinitionLookupFilter) Hash() string {
 	h := xxhash.New()
 
 	h.Write([]byte(fmt.Sprintf("%v", s.MeterDefName)))
-	for k, v := range s.workloads {
-		h.Write([]byte(fmt.Sprintf("%v", k)))
-		h.Write([]byte(fmt.Sprintf("%v", v)))
+
+	// the vertex resolves the namespaces again on a rebuild
+	vertexSelector, _ := json.Marshal(s.meterdef.Spec.VertexLabelSelector)
+	installedBy, _ := json.Marshal(s.meterdef.Spec.InstalledBy)
+	h.Write([]byte(s.meterdef.Spec.WorkloadVertexType))
+	h.Write(vertexSelector)
+	h.Write(installedBy)
+
+	keys := make([]string, 0, len(s.workloads))
+	for k := range s.workloads {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		// json is used so pointers are hashed by value
+		workload, _ := json.Marshal(s.workloads[k])
+		h.Write([]byte(k))
+		h.Write(workload)
+	}
+
+	for _, ns := range s.namespaces {
+		h.Write([]byte(ns))
+		h.Write([]byte{0})
 	}
 
 	return fmt.Sprintf("%x", h.Sum(nil))
@@ -181,6 +240,21 @@ func (s *MeterDefinitionLookupFilter) findNamespaces(
 			return
 		}
 
+		// prefer the operator group status, the csv annotation is
+		// updated after the operator group changes
+		if ogName, ok := csv.GetAnnotations()["olm.operatorGroup"]; ok {
+			og := &olmv1.OperatorGroup{}
+			result, _ := cc.Do(context.TODO(),
+				GetAction(types.NamespacedName{Name: ogName, Namespace: csv.GetNamespace()}, og),
+			)
+
+			if result.Is(Continue) && len(og.Status.Namespaces) > 0 {
+				reqLogger.Info("using operatorGroup namespaces", "operatorGroup", ogName)
+				namespaces = og.Status.Namespaces
+				return
+			}
+		}
+
 		olmNamespacesStr, ok := csv.GetAnnotations()["olm.targetNamespaces"]
 
 		if !ok {
@@ -203,7 +277,8 @@ func (s *MeterDefinitionLookupFilter) findNamespaces(
 
 		if instance.Spec.VertexLabelSelector == nil {
 			reqLogger.Info("namespace vertex is for all namespaces")
-			break
+			namespaces = []string{corev1.NamespaceAll}
+			return
 		}
 
 		namespaceList := &corev1.NamespaceList{}
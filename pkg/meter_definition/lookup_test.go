@@ -0,0 +1,266 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	olmv1 "github.com/operator-framework/api/pkg/operators/v1"
+	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("MeterDefinitionLookupFilter", func() {
+	var (
+		scheme    *runtime.Scheme
+		k8sClient client.Client
+		cc        reconcileutils.ClientCommandRunner
+		meterdef  *v1alpha1.MeterDefinition
+	)
+
+	labels := map[string]string{"app": "foo"}
+
+	newNamespace := func(name, team string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"team": team},
+			},
+		}
+	}
+
+	newPod := func(namespace string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod",
+				Namespace: namespace,
+				Labels:    labels,
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		scheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(olmv1.AddToScheme(scheme)).To(Succeed())
+		Expect(olmv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		k8sClient = fake.NewFakeClientWithScheme(scheme,
+			newNamespace("team-a-1", "a"),
+			newNamespace("team-b-1", "b"),
+		)
+		cc = reconcileutils.NewClientCommand(k8sClient, scheme, logf.Log)
+
+		meterdef = &v1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: "bar",
+			},
+			Spec: v1alpha1.MeterDefinitionSpec{
+				Group:              "apps.partner.metering.com",
+				Kind:               "App",
+				WorkloadVertexType: v1alpha1.WorkloadVertexNamespace,
+				Workloads: []v1alpha1.Workload{
+					{
+						Name:         "pods",
+						WorkloadType: v1alpha1.WorkloadTypePod,
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "foo"},
+						},
+					},
+					{
+						Name:         "services",
+						WorkloadType: v1alpha1.WorkloadTypeService,
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "foo"},
+						},
+					},
+				},
+			},
+		}
+	})
+
+	It("should have the same hash for the same meterdef", func() {
+		lookup1, err := NewMeterDefinitionLookupFilter(nil, meterdef, nil)
+		Expect(err).To(Succeed())
+		lookup2, err := NewMeterDefinitionLookupFilter(nil, meterdef.DeepCopy(), nil)
+		Expect(err).To(Succeed())
+
+		Expect(lookup1.Namespaces()).To(Equal([]string{""}))
+		Expect(lookup1.Hash()).To(Equal(lookup2.Hash()))
+	})
+
+	It("should change the hash when a workload changes", func() {
+		lookup1, err := NewMeterDefinitionLookupFilter(nil, meterdef, nil)
+		Expect(err).To(Succeed())
+
+		meterdef.Spec.Workloads[0].LabelSelector.MatchLabels["app"] = "bar"
+		lookup2, err := NewMeterDefinitionLookupFilter(nil, meterdef, nil)
+		Expect(err).To(Succeed())
+
+		Expect(lookup1.Hash()).ToNot(Equal(lookup2.Hash()))
+	})
+
+	It("should change the hash when the vertex changes", func() {
+		meterdef.Spec.VertexLabelSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"team": "a"},
+		}
+		lookup1, err := NewMeterDefinitionLookupFilter(cc, meterdef, nil)
+		Expect(err).To(Succeed())
+
+		meterdef.Spec.VertexLabelSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: metav1.LabelSelectorOpIn, Values: []string{"a"}},
+			},
+		}
+		lookup2, err := NewMeterDefinitionLookupFilter(cc, meterdef, nil)
+		Expect(err).To(Succeed())
+
+		Expect(lookup2.Namespaces()).To(Equal(lookup1.Namespaces()))
+		Expect(lookup1.Hash()).ToNot(Equal(lookup2.Hash()))
+	})
+
+	It("should match objects to the workload of their type", func() {
+		lookup, err := NewMeterDefinitionLookupFilter(cc, meterdef, nil)
+		Expect(err).To(Succeed())
+
+		workload, ok, err := lookup.FindMatchingWorkloads(newPod("bar", labels))
+		Expect(err).To(Succeed())
+		Expect(ok).To(BeTrue())
+		Expect(workload.Name).To(Equal("pods"))
+
+		workload, ok, err = lookup.FindMatchingWorkloads(&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "bar", Labels: labels},
+		})
+		Expect(err).To(Succeed())
+		Expect(ok).To(BeTrue())
+		Expect(workload.Name).To(Equal("services"))
+
+		_, ok, err = lookup.FindMatchingWorkloads(newPod("bar", map[string]string{"app": "bar"}))
+		Expect(err).To(Succeed())
+		Expect(ok).To(BeFalse())
+
+		_, ok, err = lookup.FindMatchingWorkloads(&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "bar", Labels: labels},
+		})
+		Expect(err).To(Succeed())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should reject workloads without a selector", func() {
+		meterdef.Spec.Workloads[0].LabelSelector = nil
+
+		_, err := NewMeterDefinitionLookupFilter(cc, meterdef, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should resolve the namespaces with the vertex label selector", func() {
+		meterdef.Spec.VertexLabelSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"team": "a"},
+		}
+
+		lookup, err := NewMeterDefinitionLookupFilter(cc, meterdef, nil)
+		Expect(err).To(Succeed())
+		Expect(lookup.Namespaces()).To(Equal([]string{"team-a-1"}))
+		Expect(lookup.DependsOnNamespaces()).To(BeTrue())
+		Expect(lookup.DependsOnOperatorGroup(meterdef.Namespace)).To(BeFalse())
+
+		_, ok, err := lookup.FindMatchingWorkloads(newPod("team-a-1", labels))
+		Expect(err).To(Succeed())
+		Expect(ok).To(BeTrue())
+
+		_, ok, err = lookup.FindMatchingWorkloads(newPod("team-b-1", labels))
+		Expect(err).To(Succeed())
+		Expect(ok).To(BeFalse())
+
+		By("rebuilding after a namespace is added")
+		Expect(k8sClient.Create(context.TODO(), newNamespace("team-a-2", "a"))).To(Succeed())
+
+		rebuilt, err := lookup.Rebuild()
+		Expect(err).To(Succeed())
+		Expect(rebuilt.Namespaces()).To(Equal([]string{"team-a-1", "team-a-2"}))
+		Expect(rebuilt.Hash()).ToNot(Equal(lookup.Hash()))
+		Expect(lookup.Namespaces()).To(Equal([]string{"team-a-1"}))
+
+		_, ok, err = rebuilt.FindMatchingWorkloads(newPod("team-a-2", labels))
+		Expect(err).To(Succeed())
+		Expect(ok).To(BeTrue())
+	})
+
+	Context("with an operator group vertex", func() {
+		var csv *olmv1alpha1.ClusterServiceVersion
+
+		BeforeEach(func() {
+			csv = &olmv1alpha1.ClusterServiceVersion{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "app-operator.v1",
+					Namespace: "operators",
+					Annotations: map[string]string{
+						"olm.operatorGroup":    "app-og",
+						"olm.targetNamespaces": "team-a-1",
+					},
+				},
+			}
+
+			meterdef.Spec.WorkloadVertexType = v1alpha1.WorkloadVertexOperatorGroup
+			meterdef.Spec.InstalledBy = &common.NamespacedNameReference{
+				Name:      csv.Name,
+				Namespace: csv.Namespace,
+			}
+		})
+
+		It("should fall back to the meter definition namespace", func() {
+			lookup, err := NewMeterDefinitionLookupFilter(cc, meterdef, nil)
+			Expect(err).To(Succeed())
+			Expect(lookup.Namespaces()).To(Equal([]string{meterdef.Namespace}))
+		})
+
+		It("should use the target namespaces of the csv", func() {
+			Expect(k8sClient.Create(context.TODO(), csv)).To(Succeed())
+
+			lookup, err := NewMeterDefinitionLookupFilter(cc, meterdef, nil)
+			Expect(err).To(Succeed())
+			Expect(lookup.Namespaces()).To(Equal([]string{"team-a-1"}))
+			Expect(lookup.DependsOnOperatorGroup("operators")).To(BeTrue())
+			Expect(lookup.DependsOnOperatorGroup("other")).To(BeFalse())
+			Expect(lookup.DependsOnNamespaces()).To(BeFalse())
+		})
+
+		It("should prefer the namespaces of the operator group status", func() {
+			Expect(k8sClient.Create(context.TODO(), csv)).To(Succeed())
+			og := &olmv1.OperatorGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-og", Namespace: "operators"},
+			}
+			Expect(k8sClient.Create(context.TODO(), og)).To(Succeed())
+			og.Status.Namespaces = []string{"team-b-1", "team-a-1"}
+			Expect(k8sClient.Status().Update(context.TODO(), og)).To(Succeed())
+
+			lookup, err := NewMeterDefinitionLookupFilter(cc, meterdef, nil)
+			Expect(err).To(Succeed())
+			Expect(lookup.Namespaces()).To(Equal([]string{"team-a-1", "team-b-1"}))
+		})
+	})
+})
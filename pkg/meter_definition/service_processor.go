@@ -68,6 +68,28 @@ func (u *ServiceProcessor) Process(ctx context.Context, inObj *ObjectResourceMes
 		return nil
 	}
 
+	if inObj.Action == BatchMessageAction {
+		batch, ok := inObj.Object.(*ObjectResourceBatch)
+
+		if !ok {
+			return nil
+		}
+
+		for _, value := range batch.Added {
+			err := u.Process(ctx, &ObjectResourceMessage{
+				Action:              AddMessageAction,
+				Object:              value.Object,
+				ObjectResourceValue: value,
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
 	if reflect.TypeOf(inObj.Object) != serviceType {
 		return nil
 	}
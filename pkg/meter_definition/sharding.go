@@ -0,0 +1,74 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"hash/fnv"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Sharding splits the objects between the metric-state replicas.
+// Objects are assigned with a consistent hash of their uid so only
+// about 1/TotalShards of them move when a replica is added.
+type Sharding struct {
+	// Shard is the zero indexed shard of this replica.
+	Shard int32
+
+	// TotalShards is the number of replicas.
+	TotalShards int
+}
+
+// NoSharding owns every object.
+var NoSharding = Sharding{Shard: 0, TotalShards: 1}
+
+// Enabled is true when there is more than one shard.
+func (s Sharding) Enabled() bool {
+	return s.TotalShards > 1
+}
+
+// Owns is true when the object with the uid belongs to this shard.
+func (s Sharding) Owns(uid types.UID) bool {
+	if !s.Enabled() {
+		return true
+	}
+
+	return ShardOf(uid, s.TotalShards) == s.Shard
+}
+
+// ShardOf returns the shard of the uid.
+func ShardOf(uid types.UID, totalShards int) int32 {
+	if totalShards <= 1 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(uid))
+
+	return jumpHash(h.Sum64(), totalShards)
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach.
+func jumpHash(key uint64, buckets int) int32 {
+	var b, j int64 = -1, 0
+
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int32(b)
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Sharding", func() {
+	uids := []types.UID{}
+	for i := 0; i < 3000; i++ {
+		uids = append(uids, types.UID(fmt.Sprintf("7f9c3e2a-%04d-4c4e-9a8b-1f2e3d4c5b6a", i)))
+	}
+
+	It("should own everything without sharding", func() {
+		for _, uid := range uids[:10] {
+			Expect(NoSharding.Owns(uid)).To(BeTrue())
+			Expect(Sharding{}.Owns(uid)).To(BeTrue())
+		}
+	})
+
+	It("should give each object to one shard", func() {
+		perShard := map[int32]int{}
+
+		for _, uid := range uids {
+			owners := 0
+			for shard := int32(0); shard < 3; shard++ {
+				if (Sharding{Shard: shard, TotalShards: 3}).Owns(uid) {
+					owners = owners + 1
+					perShard[shard] = perShard[shard] + 1
+				}
+			}
+			Expect(owners).To(Equal(1))
+		}
+
+		for shard := int32(0); shard < 3; shard++ {
+			Expect(perShard[shard]).To(BeNumerically("~", 1000, 150))
+		}
+	})
+
+	It("should only move objects to the new shard when scaling up", func() {
+		moved := 0
+
+		for _, uid := range uids {
+			before, after := ShardOf(uid, 3), ShardOf(uid, 4)
+
+			if before != after {
+				Expect(after).To(Equal(int32(3)))
+				moved = moved + 1
+			}
+		}
+
+		Expect(moved).To(BeNumerically("~", 750, 150))
+	})
+})
@@ -0,0 +1,548 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	snapshotVersion = 1
+
+	snapshotLabel           = "marketplace.redhat.com/metric-state-snapshot"
+	snapshotShardAnnotation = "marketplace.redhat.com/snapshot-shard"
+	snapshotCountAnnotation = "marketplace.redhat.com/snapshot-shards"
+	snapshotIDAnnotation    = "marketplace.redhat.com/snapshot-id"
+	snapshotKey             = "snapshot.json.gz"
+
+	// defaultShardSize keeps each ConfigMap shard under the 1MiB limit.
+	defaultShardSize = 900 * 1024
+)
+
+// Snapshotter persists the matched objects of a store so it can be
+// warm started after a restart. Load returns nil when there is no
+// snapshot.
+type Snapshotter interface {
+	Load(store string) (*StoreSnapshot, error)
+	Save(store string, snapshot *StoreSnapshot) error
+}
+
+// StoreSnapshot is the persisted objectResourceSet of a store.
+type StoreSnapshot struct {
+	Version int              `json:"version"`
+	Created metav1.Time      `json:"created"`
+	Objects []SnapshotObject `json:"objects"`
+	Values  []SnapshotValue  `json:"values"`
+}
+
+// SnapshotObject is an object matched by at least one meter definition.
+type SnapshotObject struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Object     json.RawMessage `json:"object"`
+}
+
+// SnapshotValue is an entry of the objectResourceSet.
+type SnapshotValue struct {
+	ObjectUID        ObjectUID                  `json:"objectUID"`
+	MeterDefUID      MeterDefUID                `json:"meterDefUID"`
+	MeterDef         types.NamespacedName       `json:"meterDef"`
+	MeterDefHash     string                     `json:"meterDefHash"`
+	Generation       int64                      `json:"generation"`
+	WorkloadResource *v1alpha1.WorkloadResource `json:"workloadResource"`
+}
+
+func encodeSnapshot(snapshot *StoreSnapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+
+	if err := json.NewEncoder(zw).Encode(snapshot); err != nil {
+		return nil, errors.Wrap(err, "failed to encode snapshot")
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to compress snapshot")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decodeSnapshot(data []byte) (*StoreSnapshot, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress snapshot")
+	}
+	defer zr.Close()
+
+	snapshot := &StoreSnapshot{}
+	if err := json.NewDecoder(zr).Decode(snapshot); err != nil {
+		return nil, errors.Wrap(err, "failed to decode snapshot")
+	}
+
+	if snapshot.Version != snapshotVersion {
+		return nil, errors.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+
+	return snapshot, nil
+}
+
+// FileSnapshotter saves snapshots to a directory, usually a volume
+// that outlives the pod.
+type FileSnapshotter struct {
+	Dir string
+}
+
+var _ Snapshotter = &FileSnapshotter{}
+
+func (f *FileSnapshotter) path(store string) string {
+	return filepath.Join(f.Dir, store+".json.gz")
+}
+
+func (f *FileSnapshotter) Load(store string) (*StoreSnapshot, error) {
+	data, err := ioutil.ReadFile(f.path(store))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot")
+	}
+
+	return decodeSnapshot(data)
+}
+
+func (f *FileSnapshotter) Save(store string, snapshot *StoreSnapshot) error {
+	data, err := encodeSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(f.Dir, store+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create snapshot file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write snapshot")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write snapshot")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), f.path(store)), "failed to replace snapshot")
+}
+
+// ConfigMapSnapshotter saves snapshots to ConfigMaps, split into shards
+// to stay under the ConfigMap size limit. Shards of the same snapshot
+// share an id so a partially written snapshot is never loaded.
+type ConfigMapSnapshotter struct {
+	KubeClient clientset.Interface
+	Namespace  string
+	Name       string
+
+	shardSize int
+}
+
+var _ Snapshotter = &ConfigMapSnapshotter{}
+
+func (c *ConfigMapSnapshotter) selector(store string) string {
+	return fmt.Sprintf("%s=%s", snapshotLabel, c.snapshotName(store))
+}
+
+func (c *ConfigMapSnapshotter) snapshotName(store string) string {
+	return fmt.Sprintf("%s-%s", c.Name, strings.ToLower(store))
+}
+
+func (c *ConfigMapSnapshotter) shardName(store string, shard int) string {
+	return fmt.Sprintf("%s-%d", c.snapshotName(store), shard)
+}
+
+func (c *ConfigMapSnapshotter) list(store string) ([]corev1.ConfigMap, error) {
+	list, err := c.KubeClient.CoreV1().ConfigMaps(c.Namespace).List(
+		context.TODO(), metav1.ListOptions{LabelSelector: c.selector(store)})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list snapshot shards")
+	}
+
+	return list.Items, nil
+}
+
+func (c *ConfigMapSnapshotter) Load(store string) (*StoreSnapshot, error) {
+	shards, err := c.list(store)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(shards) == 0 {
+		return nil, nil
+	}
+
+	id := shards[0].Annotations[snapshotIDAnnotation]
+	count, _ := strconv.Atoi(shards[0].Annotations[snapshotCountAnnotation])
+	parts := make([][]byte, count)
+
+	for _, shard := range shards {
+		i, err := strconv.Atoi(shard.Annotations[snapshotShardAnnotation])
+
+		if err != nil || i < 0 || i >= count || shard.Annotations[snapshotIDAnnotation] != id {
+			return nil, errors.Errorf("snapshot %s is incomplete", c.snapshotName(store))
+		}
+
+		parts[i] = shard.BinaryData[snapshotKey]
+	}
+
+	for _, part := range parts {
+		if part == nil {
+			return nil, errors.Errorf("snapshot %s is incomplete", c.snapshotName(store))
+		}
+	}
+
+	return decodeSnapshot(bytes.Join(parts, nil))
+}
+
+func (c *ConfigMapSnapshotter) Save(store string, snapshot *StoreSnapshot) error {
+	data, err := encodeSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	shardSize := c.shardSize
+	if shardSize <= 0 {
+		shardSize = defaultShardSize
+	}
+
+	parts := [][]byte{}
+	for len(data) > shardSize {
+		parts = append(parts, data[:shardSize])
+		data = data[shardSize:]
+	}
+	parts = append(parts, data)
+
+	id := strconv.FormatInt(snapshot.Created.UnixNano(), 10)
+	configMaps := c.KubeClient.CoreV1().ConfigMaps(c.Namespace)
+
+	for i, part := range parts {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      c.shardName(store, i),
+				Namespace: c.Namespace,
+				Labels: map[string]string{
+					snapshotLabel: c.snapshotName(store),
+				},
+				Annotations: map[string]string{
+					snapshotIDAnnotation:    id,
+					snapshotShardAnnotation: strconv.Itoa(i),
+					snapshotCountAnnotation: strconv.Itoa(len(parts)),
+				},
+			},
+			BinaryData: map[string][]byte{
+				snapshotKey: part,
+			},
+		}
+
+		existing, err := configMaps.Get(context.TODO(), cm.Name, metav1.GetOptions{})
+
+		switch {
+		case kerrors.IsNotFound(err):
+			_, err = configMaps.Create(context.TODO(), cm, metav1.CreateOptions{})
+		case err == nil:
+			cm.ResourceVersion = existing.ResourceVersion
+			_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to save snapshot shard %s", cm.Name)
+		}
+	}
+
+	shards, err := c.list(store)
+	if err != nil {
+		return err
+	}
+
+	for _, shard := range shards {
+		if i, err := strconv.Atoi(shard.Annotations[snapshotShardAnnotation]); err == nil && i < len(parts) {
+			continue
+		}
+
+		err := configMaps.Delete(context.TODO(), shard.Name, metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete snapshot shard %s", shard.Name)
+		}
+	}
+
+	return nil
+}
+
+// snapshot copies the matched objects of the store. Managed fields are
+// dropped to keep the snapshot small.
+func (s *MeterDefinitionStore) snapshot() (*StoreSnapshot, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot := &StoreSnapshot{
+		Version: snapshotVersion,
+		Created: metav1.Now(),
+		Objects: []SnapshotObject{},
+		Values:  []SnapshotValue{},
+	}
+
+	objects := map[ObjectUID]bool{}
+
+	for key, value := range s.objectResourceSet {
+		if !value.Matched {
+			continue
+		}
+
+		snapshot.Values = append(snapshot.Values, SnapshotValue{
+			ObjectUID:        key.ObjectUID,
+			MeterDefUID:      key.MeterDefUID,
+			MeterDef:         value.MeterDef,
+			MeterDefHash:     value.MeterDefHash,
+			Generation:       value.Generation,
+			WorkloadResource: value.WorkloadResource,
+		})
+
+		if objects[key.ObjectUID] {
+			continue
+		}
+
+		obj, ok := value.Object.(runtime.Object)
+		if !ok {
+			continue
+		}
+
+		gvks, _, err := s.scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find kind of object")
+		}
+
+		obj = obj.DeepCopyObject()
+		if o, err := meta.Accessor(obj); err == nil {
+			o.SetManagedFields(nil)
+		}
+
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode object")
+		}
+
+		apiVersion, kind := gvks[0].ToAPIVersionAndKind()
+		snapshot.Objects = append(snapshot.Objects, SnapshotObject{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Object:     data,
+		})
+		objects[key.ObjectUID] = true
+	}
+
+	return snapshot, nil
+}
+
+// restore warm starts the store from a snapshot. Restored objects are
+// kept until the reflectors have listed, see reconcileRestored. The
+// store is left untouched when the snapshot can't be decoded.
+func (s *MeterDefinitionStore) restore(snapshot *StoreSnapshot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	objects := map[ObjectUID]interface{}{}
+
+	for _, item := range snapshot.Objects {
+		obj, err := s.scheme.New(schema.FromAPIVersionAndKind(item.APIVersion, item.Kind))
+		if err != nil {
+			return errors.Wrap(err, "failed to create object")
+		}
+
+		if err := json.Unmarshal(item.Object, obj); err != nil {
+			return errors.Wrap(err, "failed to decode object")
+		}
+
+		o, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+
+		objects[ObjectUID(o.GetUID())] = obj
+	}
+
+	for _, value := range snapshot.Values {
+		obj, ok := objects[value.ObjectUID]
+		if !ok {
+			continue
+		}
+
+		s.objectsSeen[value.ObjectUID] = obj
+		s.restored[value.ObjectUID] = struct{}{}
+		s.objectResourceSet[ObjectResourceKey{
+			ObjectUID:   value.ObjectUID,
+			MeterDefUID: value.MeterDefUID,
+		}] = &ObjectResourceValue{
+			MeterDef:         value.MeterDef,
+			MeterDefHash:     value.MeterDefHash,
+			Generation:       value.Generation,
+			Matched:          true,
+			Object:           obj,
+			WorkloadResource: value.WorkloadResource,
+		}
+	}
+
+	s.log.Info("restored snapshot", "store", s.name,
+		"created", snapshot.Created, "objects", len(s.restored))
+	return nil
+}
+
+// reconcileRestored removes the restored objects that were not listed
+// again and the matches of meter definitions that no longer exist. It
+// is called once every reflector of the store has listed.
+func (s *MeterDefinitionStore) reconcileRestored() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	removed := 0
+	batches := map[MeterDefUID]*ObjectResourceBatch{}
+
+	for key, value := range s.objectResourceSet {
+		_, stale := s.restored[key.ObjectUID]
+		_, exists := s.meterDefinitionFilters[key.MeterDefUID]
+
+		if !stale && exists {
+			continue
+		}
+
+		delete(s.objectResourceSet, key)
+		removed = removed + 1
+
+		if stale {
+			s.broadcast(&ObjectResourceMessage{
+				Action:              DeleteMessageAction,
+				Object:              value.Object,
+				ObjectResourceValue: value,
+			})
+			continue
+		}
+
+		// the object is still around so only the deleted meter
+		// definition is removed from it
+		batch, ok := batches[key.MeterDefUID]
+		if !ok {
+			batch = &ObjectResourceBatch{MeterDef: value.MeterDef}
+			batches[key.MeterDefUID] = batch
+		}
+		batch.Removed = append(batch.Removed, value)
+	}
+
+	for _, batch := range batches {
+		s.broadcast(&ObjectResourceMessage{
+			Action: BatchMessageAction,
+			Object: batch,
+		})
+	}
+
+	for uid := range s.restored {
+		delete(s.objectsSeen, uid)
+	}
+
+	s.restored = map[ObjectUID]struct{}{}
+	s.log.Info("reconciled snapshot", "store", s.name, "removed", removed)
+}
+
+// reflectorSynced is called once per reflector after its first list.
+func (s *MeterDefinitionStore) reflectorSynced() {
+	s.mutex.Lock()
+	s.unsynced = s.unsynced - 1
+	synced := s.unsynced == 0
+	s.mutex.Unlock()
+
+	if synced {
+		s.reconcileRestored()
+	}
+}
+
+// saveSnapshots saves a snapshot every interval when the store changed
+// and once more when the context is done.
+func (s *MeterDefinitionStore) saveSnapshots() {
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+
+	save := func() {
+		if atomic.SwapInt32(&s.dirty, 0) == 0 {
+			return
+		}
+
+		snapshot, err := s.snapshot()
+		if err == nil {
+			err = s.snapshotter.Save(s.name, snapshot)
+		}
+
+		if err != nil {
+			atomic.StoreInt32(&s.dirty, 1)
+			s.log.Error(err, "failed to save snapshot", "store", s.name)
+			return
+		}
+
+		s.log.V(2).Info("saved snapshot", "store", s.name, "objects", len(snapshot.Objects))
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			save()
+		case <-s.ctx.Done():
+			save()
+			return
+		}
+	}
+}
+
+// reflectorStore tells the store when its reflector has listed.
+type reflectorStore struct {
+	*MeterDefinitionStore
+	once sync.Once
+}
+
+func (r *reflectorStore) Replace(list []interface{}, resourceVersion string) error {
+	err := r.MeterDefinitionStore.Replace(list, resourceVersion)
+	r.once.Do(r.MeterDefinitionStore.reflectorSynced)
+	return err
+}
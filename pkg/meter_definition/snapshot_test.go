@@ -0,0 +1,235 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Snapshot", func() {
+	var (
+		scheme   *runtime.Scheme
+		builder  *MeterDefinitionStoreBuilder
+		meterdef *v1alpha1.MeterDefinition
+		lookup   *MeterDefinitionLookupFilter
+		pods     []*corev1.Pod
+	)
+
+	newPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "bar",
+				UID:       types.UID(name + "-uid"),
+				Labels:    map[string]string{"app": "foo"},
+				ManagedFields: []metav1.ManagedFieldsEntry{
+					{Manager: "kubelet"},
+				},
+			},
+		}
+	}
+
+	newStore := func() *MeterDefinitionStore {
+		store := builder.NewInstance()
+		store.name = PodStore
+		return store
+	}
+
+	BeforeEach(func() {
+		scheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+
+		builder = NewMeterDefinitionStoreBuilder(
+			context.TODO(), logf.Log, nil, nil, nil, nil, nil, nil, scheme)
+
+		meterdef = &v1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: "bar",
+				UID:       "meterdef-uid",
+			},
+			Spec: v1alpha1.MeterDefinitionSpec{
+				Group:              "apps.partner.metering.com",
+				Kind:               "App",
+				WorkloadVertexType: v1alpha1.WorkloadVertexNamespace,
+				Workloads: []v1alpha1.Workload{
+					{
+						Name:         "pods",
+						WorkloadType: v1alpha1.WorkloadTypePod,
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "foo"},
+						},
+					},
+				},
+			},
+		}
+
+		var err error
+		lookup, err = NewMeterDefinitionLookupFilter(nil, meterdef, nil)
+		Expect(err).To(Succeed())
+
+		pods = []*corev1.Pod{newPod("pod-a"), newPod("pod-b")}
+	})
+
+	// snapshotOf builds a snapshot of a store that matched the pods
+	snapshotOf := func() *StoreSnapshot {
+		store := newStore()
+		store.addMeterDefinition(meterdef, lookup)
+
+		for _, pod := range pods {
+			resource, err := v1alpha1.NewWorkloadResource(meterdef.Spec.Workloads[0], pod, scheme)
+			Expect(err).To(Succeed())
+			value, err := NewObjectResourceValue(lookup, resource, pod, true)
+			Expect(err).To(Succeed())
+
+			store.objectsSeen[ObjectUID(pod.UID)] = pod
+			store.objectResourceSet[NewObjectResourceKey(pod, MeterDefUID(meterdef.UID))] = value
+		}
+
+		snapshot, err := store.snapshot()
+		Expect(err).To(Succeed())
+		return snapshot
+	}
+
+	It("should save and load snapshots from a directory", func() {
+		dir, err := ioutil.TempDir("", "snapshot")
+		Expect(err).To(Succeed())
+		defer os.RemoveAll(dir)
+
+		snapshotter := &FileSnapshotter{Dir: dir}
+
+		loaded, err := snapshotter.Load(PodStore)
+		Expect(err).To(Succeed())
+		Expect(loaded).To(BeNil())
+
+		snapshot := snapshotOf()
+		Expect(snapshotter.Save(PodStore, snapshot)).To(Succeed())
+
+		loaded, err = snapshotter.Load(PodStore)
+		Expect(err).To(Succeed())
+		Expect(loaded.Values).To(ConsistOf(snapshot.Values))
+		Expect(loaded.Objects).To(HaveLen(2))
+	})
+
+	It("should shard snapshots over configmaps", func() {
+		client := fake.NewSimpleClientset()
+		snapshotter := &ConfigMapSnapshotter{
+			KubeClient: client,
+			Namespace:  "openshift-redhat-marketplace",
+			Name:       "rhm-metric-state-snapshot",
+			shardSize:  256,
+		}
+
+		snapshot := snapshotOf()
+		Expect(snapshotter.Save(PodStore, snapshot)).To(Succeed())
+
+		shards, err := snapshotter.list(PodStore)
+		Expect(err).To(Succeed())
+		Expect(len(shards)).To(BeNumerically(">", 1))
+
+		loaded, err := snapshotter.Load(PodStore)
+		Expect(err).To(Succeed())
+		Expect(loaded.Values).To(ConsistOf(snapshot.Values))
+
+		By("removing the shards no longer needed")
+		snapshotter.shardSize = defaultShardSize
+		Expect(snapshotter.Save(PodStore, snapshot)).To(Succeed())
+
+		shards, err = snapshotter.list(PodStore)
+		Expect(err).To(Succeed())
+		Expect(shards).To(HaveLen(1))
+		Expect(shards[0].Name).To(Equal("rhm-metric-state-snapshot-podstore-0"))
+	})
+
+	It("should warm start and reconcile against the listed objects", func() {
+		snapshot := snapshotOf()
+		Expect(snapshot.Objects).To(HaveLen(2))
+
+		store := newStore()
+		store.unsynced = 1
+		Expect(store.restore(snapshot)).To(Succeed())
+
+		refs := store.GetMeterDefinitionRefs(pods[0].UID)
+		Expect(refs).To(HaveLen(1))
+		Expect(refs[0].MeterDef).To(Equal(types.NamespacedName{Name: "foo", Namespace: "bar"}))
+		Expect(refs[0].Object.(*corev1.Pod).ManagedFields).To(BeEmpty())
+		Expect(store.isRestored(pods[1])).To(BeTrue())
+
+		ch := make(chan *ObjectResourceMessage, 10)
+		store.RegisterListener("test", ch)
+
+		var replayed *ObjectResourceMessage
+		Eventually(ch).Should(Receive(&replayed))
+		Expect(replayed.Action).To(Equal(BatchMessageAction))
+		Expect(replayed.Object.(*ObjectResourceBatch).Added).To(HaveLen(2))
+
+		By("listing only the first pod")
+		store.addMeterDefinition(meterdef, lookup)
+		Expect(store.addSeenObject(pods[0])).To(Succeed())
+		store.reflectorSynced()
+
+		var deleted *ObjectResourceMessage
+		Eventually(ch).Should(Receive(&deleted))
+		Expect(deleted.Action).To(BeEquivalentTo(DeleteMessageAction))
+		Expect(deleted.ObjectResourceValue.UID).To(Equal(pods[1].UID))
+
+		Expect(store.GetMeterDefinitionRefs(pods[0].UID)).To(HaveLen(1))
+		Expect(store.GetMeterDefinitionRefs(pods[1].UID)).To(BeEmpty())
+		Expect(store.isRestored(pods[0])).To(BeFalse())
+	})
+
+	It("should unmatch restored objects that no longer match", func() {
+		store := newStore()
+		store.unsynced = 1
+		Expect(store.restore(snapshotOf())).To(Succeed())
+		store.addMeterDefinition(meterdef, lookup)
+
+		ch := make(chan *ObjectResourceMessage, 10)
+		store.RegisterListener("test", ch)
+
+		var replayed *ObjectResourceMessage
+		Eventually(ch).Should(Receive(&replayed))
+
+		By("listing a pod relabeled while the store was down")
+		relabeled := newPod("pod-a")
+		relabeled.Labels = map[string]string{"app": "bar"}
+		Expect(store.Replace([]interface{}{relabeled, pods[1]}, "")).To(Succeed())
+
+		var removed *ObjectResourceMessage
+		Eventually(ch).Should(Receive(&removed))
+		Expect(removed.Action).To(Equal(BatchMessageAction))
+		batch := removed.Object.(*ObjectResourceBatch)
+		Expect(batch.Added).To(BeEmpty())
+		Expect(batch.Removed).To(HaveLen(1))
+		Expect(batch.Removed[0].UID).To(Equal(pods[0].UID))
+
+		Expect(store.GetMeterDefinitionRefs(pods[0].UID)).To(BeEmpty())
+		Expect(store.GetMeterDefinitionRefs(pods[1].UID)).To(HaveLen(1))
+	})
+})
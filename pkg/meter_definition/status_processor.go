@@ -16,23 +16,71 @@ package meter_definition
 
 import (
 	"context"
-	"fmt"
-	"sort"
+	"reflect"
 	"sync"
+	"time"
 
+	"emperror.dev/errors"
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
 	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// DefaultStatusWindow is how long status changes of a meter
+	// definition are coalesced before they are applied.
+	DefaultStatusWindow = 5 * time.Second
+
+	// statusMaxAttempts is how many windows a change is retried for
+	// before it is dropped.
+	statusMaxAttempts = 5
 )
 
 // StatusProcessor will update the meter definition
-// status with the objects that matched it.
+// status with the objects that matched it. Changes are
+// coalesced per meter definition over a window and applied
+// in a single update. The status carries counts per workload
+// and a sample of the objects read from the stores.
 type StatusProcessor struct {
-	log           logr.Logger
-	cc            ClientCommandRunner
-	mutex         sync.Mutex
-	locks         map[types.NamespacedName]sync.Mutex
+	log        logr.Logger
+	cc         ClientCommandRunner
+	window     time.Duration
+	sampleSize int
+	sharding   Sharding
+
+	mutex   sync.Mutex
+	pending map[types.NamespacedName]*pendingStatus
+	stores  []*MeterDefinitionStore
+
+	queueDepth prometheus.GaugeFunc
+	latency    prometheus.Histogram
+	updates    *prometheus.CounterVec
+	conflicts  prometheus.Counter
+}
+
+// pendingStatus are the changes of a meter definition status
+// waiting to be applied. The objects are read from the stores
+// when the changes are applied.
+type pendingStatus struct {
+	namespaces []string
+	messages   int
+	attempts   int
+	enqueued   time.Time
+}
+
+// merge puts changes that failed to apply back in front of the
+// changes that arrived since.
+func (p *pendingStatus) merge(newer *pendingStatus) {
+	if newer.namespaces != nil {
+		p.namespaces = newer.namespaces
+	}
+
+	p.messages = p.messages + newer.messages
 }
 
 // NewStatusProcessor is the provider that creates
@@ -41,89 +89,283 @@ func NewStatusProcessor(
 	log logr.Logger,
 	cc ClientCommandRunner,
 ) *StatusProcessor {
-	return &StatusProcessor{
-		log:           log,
-		cc:            cc,
-		locks:         make(map[types.NamespacedName]sync.Mutex),
+	u := &StatusProcessor{
+		log:        log,
+		cc:         cc,
+		window:     DefaultStatusWindow,
+		sampleSize: DefaultStatusSampleSize,
+		pending:    make(map[types.NamespacedName]*pendingStatus),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "meterdef_status_update_latency_seconds",
+			Help:    "Time from the first coalesced change of a meter definition status to its update.",
+			Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+		}),
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "meterdef_status_updates_total",
+			Help: "Number of coalesced meter definition status updates by result.",
+		}, []string{"result"}),
+		conflicts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "meterdef_status_update_conflicts_total",
+			Help: "Number of meter definition status updates retried because of a conflict.",
+		}),
 	}
+
+	u.queueDepth = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "meterdef_status_queue_depth",
+		Help: "Number of messages waiting to be applied to meter definition statuses.",
+	}, func() float64 {
+		u.mutex.Lock()
+		defer u.mutex.Unlock()
+
+		depth := 0
+		for _, p := range u.pending {
+			depth = depth + p.messages
+		}
+		return float64(depth)
+	})
+
+	return u
+}
+
+// SetWindow sets how long changes are coalesced before they are applied.
+func (u *StatusProcessor) SetWindow(window time.Duration) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.window = window
+}
+
+// SetSampleSize sets how many objects are kept on the status.
+func (u *StatusProcessor) SetSampleSize(sampleSize int) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.sampleSize = sampleSize
+}
+
+// SetSharding makes the processor merge its objects with the ones
+// written to the status by the other shards.
+func (u *StatusProcessor) SetSharding(sharding Sharding) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.sharding = sharding
+}
+
+// Describe implements prometheus.Collector.
+func (u *StatusProcessor) Describe(ch chan<- *prometheus.Desc) {
+	u.queueDepth.Describe(ch)
+	u.latency.Describe(ch)
+	u.updates.Describe(ch)
+	u.conflicts.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (u *StatusProcessor) Collect(ch chan<- prometheus.Metric) {
+	u.queueDepth.Collect(ch)
+	u.latency.Collect(ch)
+	u.updates.Collect(ch)
+	u.conflicts.Collect(ch)
 }
 
 // Start will register it's listener and execute the function.
 func (u *StatusProcessor) New(store *MeterDefinitionStore) Processor {
+	u.mutex.Lock()
+	u.stores = append(u.stores, store)
+	u.mutex.Unlock()
+
 	return NewProcessor("statusProcessor", u.log, u.cc, store, u)
 }
 
-// Process will receive a new ObjectResourceMessage and find and update the metere
-// definition associated with the object. To prevent gaps, it bulk retrieves the
-// resources and checks it against the status.
+// Process will receive a new ObjectResourceMessage and queue a status
+// update for the meter definition associated with the object. The first
+// message of a meter definition schedules a flush after the window.
 func (u *StatusProcessor) Process(ctx context.Context, inObj *ObjectResourceMessage) error {
-	log := u.log.WithValues("process", "statusProcessor")
-	mdef := &marketplacev1alpha1.MeterDefinition{}
-
 	if inObj == nil {
 		return nil
 	}
 
-	if inObj.ObjectResourceValue == nil {
-		return nil
+	switch inObj.Action {
+	case NamespacesMessageAction:
+		lookup, ok := inObj.Object.(*MeterDefinitionLookupFilter)
+		if !ok {
+			return nil
+		}
+
+		namespaces := []string{}
+		for _, ns := range lookup.Namespaces() {
+			if ns == corev1.NamespaceAll {
+				ns = "*"
+			}
+			namespaces = append(namespaces, ns)
+		}
+
+		u.enqueue(ctx, lookup.MeterDefName, namespaces)
+	case BatchMessageAction:
+		batch, ok := inObj.Object.(*ObjectResourceBatch)
+		if !ok {
+			return nil
+		}
+
+		u.enqueue(ctx, batch.MeterDef, nil)
+	case AddMessageAction, DeleteMessageAction:
+		if inObj.ObjectResourceValue == nil {
+			return nil
+		}
+
+		u.enqueue(ctx, inObj.ObjectResourceValue.MeterDef, nil)
 	}
 
+	return nil
+}
+
+func (u *StatusProcessor) enqueue(ctx context.Context, name types.NamespacedName, namespaces []string) {
 	u.mutex.Lock()
-	lock, exists := u.locks[inObj.MeterDef]
+	defer u.mutex.Unlock()
+
+	p, ok := u.pending[name]
+	if !ok {
+		p = &pendingStatus{enqueued: time.Now()}
+		u.pending[name] = p
+		time.AfterFunc(u.window, func() { u.flush(ctx, name) })
+	}
 
-	if !exists {
-		u.locks[inObj.MeterDef] = sync.Mutex{}
-		lock, _ = u.locks[inObj.MeterDef]
+	if namespaces != nil {
+		p.namespaces = namespaces
 	}
+
+	p.messages = p.messages + 1
+}
+
+// flush applies the pending changes of the meter definition. Changes
+// that fail are merged with the changes queued since and retried on the
+// next window.
+func (u *StatusProcessor) flush(ctx context.Context, name types.NamespacedName) {
+	log := u.log.WithValues("process", "statusProcessor", "mdef", name)
+
+	u.mutex.Lock()
+	p, ok := u.pending[name]
+	delete(u.pending, name)
 	u.mutex.Unlock()
 
-	lock.Lock()
-	defer lock.Unlock()
+	if !ok {
+		return
+	}
+
+	var updated bool
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		updated, err = u.apply(ctx, name, p)
+
+		if kerrors.IsConflict(err) {
+			u.conflicts.Inc()
+		}
+
+		return err
+	})
+
+	if err == nil {
+		result := "unchanged"
+		if updated {
+			result = "updated"
+		}
+
+		u.updates.WithLabelValues(result).Inc()
+		u.latency.Observe(time.Since(p.enqueued).Seconds())
+		return
+	}
+
+	u.updates.WithLabelValues("error").Inc()
+	p.attempts = p.attempts + 1
 
-	log.Info("incoming obj", "type", fmt.Sprintf("%T", inObj.Object), "mdef", inObj.MeterDef)
+	if p.attempts >= statusMaxAttempts || ctx.Err() != nil {
+		log.Error(err, "dropping status changes", "messages", p.messages, "attempts", p.attempts)
+		return
+	}
+
+	log.Error(err, "failed to update status, retrying", "messages", p.messages, "attempts", p.attempts)
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if newer, ok := u.pending[name]; ok {
+		p.merge(newer)
+	} else {
+		time.AfterFunc(u.window, func() { u.flush(ctx, name) })
+	}
+
+	u.pending[name] = p
+}
+
+// apply updates the meter definition status with the pending changes
+// and returns if an update was needed.
+func (u *StatusProcessor) apply(ctx context.Context, name types.NamespacedName, p *pendingStatus) (bool, error) {
+	log := u.log.WithValues("process", "statusProcessor", "mdef", name)
+	mdef := &marketplacev1alpha1.MeterDefinition{}
+	updated := false
 
 	result, _ := u.cc.Do(ctx,
 		HandleResult(
-			GetAction(inObj.MeterDef, mdef),
+			GetAction(name, mdef),
 			OnContinue(Call(func() (ClientAction, error) {
-				log.Info("found objs", "mdef", inObj.MeterDef)
-
-				resources := []marketplacev1alpha1.WorkloadResource{}
+				u.mutex.Lock()
+				stores := u.stores
+				sampleSize := u.sampleSize
+				sharding := u.sharding
+				u.mutex.Unlock()
 
-				set := map[types.UID]marketplacev1alpha1.WorkloadResource{}
+				all := WorkloadResources(mdef.UID, stores...)
+				resources, counts := SummarizeWorkloadResources(all, sampleSize)
+				total := len(all)
+				var shards []marketplacev1alpha1.WorkloadShardStatus
 
-				for _, obj := range mdef.Status.WorkloadResources {
-					set[obj.UID] = obj
+				if sharding.Enabled() {
+					resources, counts, total, shards = mergeShardWorkloadResources(
+						mdef.Status, all, sharding, sampleSize)
 				}
 
-				switch inObj.Action {
-				case DeleteMessageAction:
-					delete(set, inObj.ObjectResourceValue.UID)
-				case AddMessageAction:
-					set[inObj.ObjectResourceValue.UID] = *inObj.ObjectResourceValue.WorkloadResource
+				namespaces := mdef.Status.ResolvedNamespaces
+				if p.namespaces != nil {
+					namespaces = p.namespaces
 				}
 
-				for _, obj := range set {
-					resources = append(resources, obj)
+				if mdef.Status.TotalWorkloadResources == total &&
+					equalOrEmpty(resources, mdef.Status.WorkloadResources) &&
+					equalOrEmpty(counts, mdef.Status.WorkloadResourceCounts) &&
+					equalOrEmpty(shards, mdef.Status.WorkloadShards) &&
+					reflect.DeepEqual(namespaces, mdef.Status.ResolvedNamespaces) {
+					return nil, nil
 				}
 
-				sort.Sort(marketplacev1alpha1.ByAlphabetical(resources))
 				mdef.Status.WorkloadResources = resources
+				mdef.Status.WorkloadResourceCounts = counts
+				mdef.Status.TotalWorkloadResources = total
+				mdef.Status.WorkloadShards = shards
+				mdef.Status.ResolvedNamespaces = namespaces
+				updated = true
 
-				log.Info("updating meter def", "mdef", inObj.MeterDef, "uid", mdef.UID, "len", len(mdef.Status.WorkloadResources))
+				log.Info("updating meter def", "uid", mdef.UID, "messages", p.messages,
+					"total", total, "sample", len(resources))
 				return UpdateAction(mdef, UpdateStatusOnly(true)), nil
 			})),
 		),
 	)
 
 	if result.Is(NotFound) {
-		return nil
+		return false, nil
 	}
 
 	if result.Is(Error) {
-		log.Error(result, "failed run")
-		return result
+		return false, errors.Cause(result.GetError())
 	}
 
-	return nil
+	return updated, nil
+}
+
+// equalOrEmpty compares slices treating nil and empty as equal.
+func equalOrEmpty(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+
+	if va.Len() == 0 && vb.Len() == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
 }
@@ -0,0 +1,197 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("StatusProcessor", func() {
+	var (
+		ctx       context.Context
+		cancel    context.CancelFunc
+		scheme    *runtime.Scheme
+		k8sClient client.Client
+		processor *StatusProcessor
+		store     *MeterDefinitionStore
+		meterdef  *v1alpha1.MeterDefinition
+		name      types.NamespacedName
+	)
+
+	// match adds the object to the store as if it matched the workload
+	match := func(workload string, i int) *ObjectResourceValue {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%d", workload, i),
+				Namespace: "bar",
+				UID:       types.UID(fmt.Sprintf("%s-%d-uid", workload, i)),
+			},
+		}
+
+		resource, err := v1alpha1.NewWorkloadResource(v1alpha1.Workload{Name: workload}, pod, scheme)
+		Expect(err).To(Succeed())
+
+		value := &ObjectResourceValue{
+			MeterDef:         name,
+			Matched:          true,
+			Object:           pod,
+			WorkloadResource: resource,
+		}
+
+		store.mutex.Lock()
+		store.objectResourceSet[NewObjectResourceKey(pod, MeterDefUID(meterdef.UID))] = value
+		store.mutex.Unlock()
+
+		return value
+	}
+
+	getStatus := func() v1alpha1.MeterDefinitionStatus {
+		mdef := &v1alpha1.MeterDefinition{}
+		Expect(k8sClient.Get(ctx, name, mdef)).To(Succeed())
+		return mdef.Status
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+
+		scheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+
+		name = types.NamespacedName{Name: "foo", Namespace: "bar"}
+		meterdef = &v1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name.Name,
+				Namespace: name.Namespace,
+				UID:       "meterdef-uid",
+			},
+		}
+
+		k8sClient = fake.NewFakeClientWithScheme(scheme, meterdef)
+		store = NewMeterDefinitionStoreBuilder(
+			ctx, logf.Log, nil, nil, nil, nil, nil, nil, scheme).NewInstance()
+
+		processor = NewStatusProcessor(logf.Log, reconcileutils.NewClientCommand(k8sClient, scheme, logf.Log))
+		processor.SetWindow(100 * time.Millisecond)
+		processor.New(store)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should coalesce messages into one update", func() {
+		for i := 0; i < 50; i++ {
+			value := match("pods", i)
+			Expect(processor.Process(ctx, &ObjectResourceMessage{
+				Action:              AddMessageAction,
+				Object:              value.Object,
+				ObjectResourceValue: value,
+			})).To(Succeed())
+		}
+
+		Expect(testutil.ToFloat64(processor.queueDepth)).To(Equal(float64(50)))
+
+		Eventually(func() int {
+			return len(getStatus().WorkloadResources)
+		}, 2*time.Second).Should(Equal(50))
+
+		Expect(testutil.ToFloat64(processor.updates.WithLabelValues("updated"))).To(Equal(float64(1)))
+		Expect(testutil.ToFloat64(processor.queueDepth)).To(Equal(float64(0)))
+	})
+
+	It("should keep counts and a sample of each workload", func() {
+		processor.SetSampleSize(10)
+
+		for i := 0; i < 30; i++ {
+			match("pods", i)
+		}
+		for i := 0; i < 20; i++ {
+			match("services", i)
+		}
+
+		Expect(processor.Process(ctx, &ObjectResourceMessage{
+			Action: BatchMessageAction,
+			Object: &ObjectResourceBatch{MeterDef: name},
+		})).To(Succeed())
+
+		Eventually(func() int {
+			return getStatus().TotalWorkloadResources
+		}, 2*time.Second).Should(Equal(50))
+
+		status := getStatus()
+		Expect(status.WorkloadResourceCounts).To(Equal([]v1alpha1.WorkloadResourceCount{
+			{Name: "pods", Count: 30},
+			{Name: "services", Count: 20},
+		}))
+		Expect(status.WorkloadResources).To(HaveLen(10))
+		Expect(status.WorkloadResources[0].ReferencedWorkloadName).To(Equal("pods"))
+		Expect(status.WorkloadResources[9].ReferencedWorkloadName).To(Equal("services"))
+	})
+
+	It("should remove deleted objects", func() {
+		match("pods", 0)
+		value := match("pods", 1)
+
+		store.mutex.Lock()
+		delete(store.objectResourceSet, NewObjectResourceKey(value.Object.(*corev1.Pod), MeterDefUID(meterdef.UID)))
+		store.mutex.Unlock()
+
+		Expect(processor.Process(ctx, &ObjectResourceMessage{
+			Action:              DeleteMessageAction,
+			Object:              value.Object,
+			ObjectResourceValue: value,
+		})).To(Succeed())
+
+		Eventually(func() []v1alpha1.WorkloadResource {
+			return getStatus().WorkloadResources
+		}, 2*time.Second).Should(HaveLen(1))
+		Expect(getStatus().WorkloadResources[0].Name).To(Equal("pods-0"))
+	})
+
+	It("should skip updates that don't change the status", func() {
+		value := match("pods", 0)
+
+		store.mutex.Lock()
+		store.objectResourceSet = map[ObjectResourceKey]*ObjectResourceValue{}
+		store.mutex.Unlock()
+
+		Expect(processor.Process(ctx, &ObjectResourceMessage{
+			Action:              DeleteMessageAction,
+			Object:              value.Object,
+			ObjectResourceValue: value,
+		})).To(Succeed())
+
+		Eventually(func() float64 {
+			return testutil.ToFloat64(processor.updates.WithLabelValues("unchanged"))
+		}, 2*time.Second).Should(Equal(float64(1)))
+	})
+})
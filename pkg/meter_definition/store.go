@@ -17,21 +17,27 @@ package meter_definition
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"emperror.dev/errors"
 	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	monitoringv1client "github.com/coreos/prometheus-operator/pkg/client/versioned/typed/monitoring/v1"
 	"github.com/go-logr/logr"
+	olmv1 "github.com/operator-framework/api/pkg/operators/v1"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
 	rhmclient "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/client"
 	marketplacev1alpha1client "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/generated/clientset/versioned/typed/marketplace/v1alpha1"
 	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
 	"github.com/sasha-s/go-deadlock"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -62,6 +68,7 @@ type MeterDefinitionStore struct {
 
 	// kubeClient to query kube
 	kubeClient        clientset.Interface
+	dynamicClient     dynamic.Interface
 	findOwner         *rhmclient.FindOwnerHelper
 	monitoringClient  *monitoringv1client.MonitoringV1Client
 	marketplaceClient *marketplacev1alpha1client.MarketplaceV1alpha1Client
@@ -72,6 +79,22 @@ type MeterDefinitionStore struct {
 
 	// resyncObjChan will resync the store
 	resyncObjChan chan interface{}
+
+	// name of the store in the snapshot
+	name string
+
+	// snapshotter saves the matched objects so the store can be
+	// warm started; restored are the objects of the snapshot that
+	// have not been listed again yet
+	snapshotter      Snapshotter
+	snapshotInterval time.Duration
+	snapshotsSaved   *sync.WaitGroup
+	restored         map[ObjectUID]struct{}
+	unsynced         int
+	dirty            int32
+
+	// sharding decides which objects this replica matches
+	sharding Sharding
 }
 
 type MeterDefinitionStoreBuilder struct {
@@ -87,9 +110,16 @@ type MeterDefinitionStoreBuilder struct {
 
 	// kubeClient to query kube
 	kubeClient        clientset.Interface
+	dynamicClient     dynamic.Interface
 	findOwner         *rhmclient.FindOwnerHelper
 	monitoringClient  *monitoringv1client.MonitoringV1Client
 	marketplaceClient *marketplacev1alpha1client.MarketplaceV1alpha1Client
+
+	snapshotter      Snapshotter
+	snapshotInterval time.Duration
+	snapshotsSaved   *sync.WaitGroup
+
+	sharding Sharding
 }
 
 func NewMeterDefinitionStoreBuilder(
@@ -97,6 +127,7 @@ func NewMeterDefinitionStoreBuilder(
 	log logr.Logger,
 	cc ClientCommandRunner,
 	kubeClient clientset.Interface,
+	dynamicClient dynamic.Interface,
 	findOwner *rhmclient.FindOwnerHelper,
 	monitoringClient *monitoringv1client.MonitoringV1Client,
 	marketplaceclient *marketplacev1alpha1client.MarketplaceV1alpha1Client,
@@ -107,10 +138,12 @@ func NewMeterDefinitionStoreBuilder(
 		log:               log,
 		cc:                cc,
 		kubeClient:        kubeClient,
+		dynamicClient:     dynamicClient,
 		monitoringClient:  monitoringClient,
 		marketplaceClient: marketplaceclient,
 		findOwner:         findOwner,
 		scheme:            scheme,
+		snapshotsSaved:    &sync.WaitGroup{},
 	}
 }
 
@@ -121,10 +154,16 @@ func (s *MeterDefinitionStoreBuilder) NewInstance() *MeterDefinitionStore {
 		cc:                     s.cc,
 		scheme:                 s.scheme,
 		kubeClient:             s.kubeClient,
+		dynamicClient:          s.dynamicClient,
 		monitoringClient:       s.monitoringClient,
 		marketplaceClient:      s.marketplaceClient,
 		findOwner:              s.findOwner,
 		namespaces:             s.namespaces,
+		snapshotter:            s.snapshotter,
+		snapshotInterval:       s.snapshotInterval,
+		snapshotsSaved:         s.snapshotsSaved,
+		sharding:               s.sharding,
+		restored:               make(map[ObjectUID]struct{}),
 		mutex:                  deadlock.Mutex{},
 		listenerMutex:          deadlock.Mutex{},
 		resyncObjChan:          make(chan interface{}),
@@ -135,11 +174,44 @@ func (s *MeterDefinitionStoreBuilder) NewInstance() *MeterDefinitionStore {
 	}
 }
 
+// RegisterListener adds a listener for the changes of the store. The
+// objects already matched are replayed to the listener as batches.
 func (s *MeterDefinitionStore) RegisterListener(name string, ch chan *ObjectResourceMessage) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.log.Info("registering listener", "name", name)
 	s.listeners = append(s.listeners, ch)
+
+	batches := map[MeterDefUID]*ObjectResourceBatch{}
+	for key, value := range s.objectResourceSet {
+		if !value.Matched {
+			continue
+		}
+
+		batch, ok := batches[key.MeterDefUID]
+		if !ok {
+			batch = &ObjectResourceBatch{MeterDef: value.MeterDef}
+			batches[key.MeterDefUID] = batch
+		}
+		batch.Added = append(batch.Added, value)
+	}
+
+	if len(batches) == 0 {
+		return
+	}
+
+	go func() {
+		for _, batch := range batches {
+			select {
+			case ch <- &ObjectResourceMessage{
+				Action: BatchMessageAction,
+				Object: batch,
+			}:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
 }
 
 func (s *MeterDefinitionStore) addMeterDefinition(meterdef *v1alpha1.MeterDefinition, lookup *MeterDefinitionLookupFilter) {
@@ -170,11 +242,18 @@ func (s *MeterDefinitionStore) removeMeterDefinition(meterdef *v1alpha1.MeterDef
 	})
 }
 
+// broadcast sends the message to the listeners. Listeners stop reading
+// once the context is done, so a message sent after that is dropped
+// rather than blocking with the mutex held.
 func (s *MeterDefinitionStore) broadcast(msg *ObjectResourceMessage) {
+	atomic.StoreInt32(&s.dirty, 1)
+
 	for _, ch := range s.listeners {
 		select {
 		case ch <- msg:
 			s.log.V(3).Info("sent message", "msg", msg)
+		case <-s.ctx.Done():
+			s.log.V(3).Info("dropped message after shutdown", "msg", msg)
 		}
 	}
 }
@@ -192,6 +271,15 @@ func (s *MeterDefinitionStore) GetMeterDefinitionRefs(uid types.UID) []*ObjectRe
 	return vals
 }
 
+// GetObject returns the object seen with the uid.
+func (s *MeterDefinitionStore) GetObject(uid types.UID) (interface{}, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	obj, ok := s.objectsSeen[ObjectUID(uid)]
+	return obj, ok
+}
+
 func (s *MeterDefinitionStore) GetMeterDefObjects(meterDefUID types.UID) []*ObjectResourceValue {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -231,8 +319,17 @@ func (s *MeterDefinitionStore) Add(obj interface{}) error {
 	logger := s.log.WithValues("func", "add", "name/namespace", key)
 	logger.V(2).Info("adding obj")
 
-	if meterdef, ok := obj.(*v1alpha1.MeterDefinition); ok {
-		return s.handleMeterDefinition(meterdef)
+	switch v := obj.(type) {
+	case *v1alpha1.MeterDefinition:
+		return s.handleMeterDefinition(v)
+	case *corev1.Namespace, *olmv1.OperatorGroup:
+		return s.handleVertexChange(obj)
+	}
+
+	// objects of other shards are matched by their replica
+	if o, err := meta.Accessor(obj); err == nil && !s.sharding.Owns(o.GetUID()) {
+		logger.V(4).Info("object belongs to another shard")
+		return nil
 	}
 
 	// save obj to objectsSeen
@@ -256,27 +353,33 @@ func (s *MeterDefinitionStore) Add(obj interface{}) error {
 		return nil
 	}
 
-	matchedResults := []result{}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	for _, result := range results {
 		if !result.ok {
 			logger.V(4).Info("no match", "obj", obj, "meterDefUID", string(result.meterDefUID))
-			continue
-		}
-
-		matchedResults = append(matchedResults, result)
-	}
 
-	if len(matchedResults) == 0 {
-		logger.V(2).Info("no matched results returned")
-		return nil
-	}
+			// the object stopped matching, e.g. its labels changed or it
+			// was restored from a snapshot taken before they did
+			existing, exists := s.objectResourceSet[result.key]
+			if !exists {
+				continue
+			}
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+			delete(s.objectResourceSet, result.key)
 
-	logger.Info("return matched results", len(matchedResults))
+			logger.Info("broadcasting removal", "mdef", existing.MeterDef)
+			s.broadcast(&ObjectResourceMessage{
+				Action: BatchMessageAction,
+				Object: &ObjectResourceBatch{
+					MeterDef: result.lookup.MeterDefName,
+					Removed:  []*ObjectResourceValue{existing},
+				},
+			})
+			continue
+		}
 
-	for _, result := range matchedResults {
 		resource, err := v1alpha1.NewWorkloadResource(*result.workload, obj, s.scheme)
 		if err != nil {
 			logger.Error(err, "failed to init a new workload resource")
@@ -289,8 +392,16 @@ func (s *MeterDefinitionStore) Add(obj interface{}) error {
 			return err
 		}
 
+		existing, exists := s.objectResourceSet[result.key]
 		s.objectResourceSet[result.key] = value
 
+		if exists &&
+			existing.MeterDefHash == value.MeterDefHash &&
+			existing.ReferencedWorkloadName == value.ReferencedWorkloadName {
+			logger.V(4).Info("object already matched", "mdef", value.MeterDef)
+			continue
+		}
+
 		msg := &ObjectResourceMessage{
 			Action:              AddMessageAction,
 			Object:              obj,
@@ -320,8 +431,19 @@ func (s *MeterDefinitionStore) handleMeterDefinition(meterdef *v1alpha1.MeterDef
 		return err
 	}
 
+	meterDefUID := MeterDefUID(meterdef.UID)
+
+	// the new lookup is kept even if it matches the same objects so
+	// rebuilds use the current meter definition
+	existing, ok := s.meterDefinitionFilters[meterDefUID]
+	s.meterDefinitionFilters[meterDefUID] = lookup
+
+	if ok && existing.Hash() == lookup.Hash() {
+		s.log.V(2).Info("meterdef lookup unchanged", "name", meterdef.Name, "namespace", meterdef.Namespace)
+		return nil
+	}
+
 	s.log.Info("found lookup", "lookup", lookup)
-	s.meterDefinitionFilters[MeterDefUID(meterdef.UID)] = lookup
 
 	msg := &ObjectResourceMessage{
 		Action: AddMessageAction,
@@ -331,9 +453,161 @@ func (s *MeterDefinitionStore) handleMeterDefinition(meterdef *v1alpha1.MeterDef
 	s.log.Info("broadcasting meterdef message", "msg", msg)
 	s.broadcast(msg)
 
+	err = s.reevaluate(meterDefUID, lookup)
+	if err != nil {
+		return err
+	}
+
+	s.broadcastNamespaces(lookup)
+
+	return nil
+}
+
+// handleVertexChange rebuilds the lookups that resolve their namespaces
+// from the changed namespace or operator group. Lookups whose namespaces
+// changed are applied to the objects already seen. Rebuilding queries the
+// API server so it is done without holding the mutex; a lookup replaced
+// in the meantime is left as is.
+func (s *MeterDefinitionStore) handleVertexChange(obj interface{}) error {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	lookups := map[MeterDefUID]*MeterDefinitionLookupFilter{}
+	for meterDefUID, lookup := range s.meterDefinitionFilters {
+		switch obj.(type) {
+		case *corev1.Namespace:
+			if !lookup.DependsOnNamespaces() {
+				continue
+			}
+		case *olmv1.OperatorGroup:
+			if !lookup.DependsOnOperatorGroup(o.GetNamespace()) {
+				continue
+			}
+		}
+		lookups[meterDefUID] = lookup
+	}
+	s.mutex.Unlock()
+
+	rebuilt := map[MeterDefUID]*MeterDefinitionLookupFilter{}
+	for meterDefUID, lookup := range lookups {
+		newLookup, err := lookup.Rebuild()
+		if err != nil {
+			s.log.Error(err, "error rebuilding lookup", "meterdef", lookup.MeterDefName)
+			return err
+		}
+
+		if reflect.DeepEqual(newLookup.Namespaces(), lookup.Namespaces()) {
+			continue
+		}
+
+		rebuilt[meterDefUID] = newLookup
+	}
+
+	if len(rebuilt) == 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for meterDefUID, newLookup := range rebuilt {
+		lookup := lookups[meterDefUID]
+		if s.meterDefinitionFilters[meterDefUID] != lookup {
+			continue
+		}
+
+		s.log.Info("namespaces changed", "meterdef", lookup.MeterDefName,
+			"old", lookup.Namespaces(), "new", newLookup.Namespaces())
+
+		s.meterDefinitionFilters[meterDefUID] = newLookup
+
+		err = s.reevaluate(meterDefUID, newLookup)
+		if err != nil {
+			return err
+		}
+
+		s.broadcastNamespaces(newLookup)
+	}
+
+	return nil
+}
+
+// reevaluate applies the lookup to all objects seen and broadcasts the
+// objects that were added or removed as a single batch. Objects that
+// still match the same workload are not sent. The mutex must be held
+// by the caller.
+func (s *MeterDefinitionStore) reevaluate(meterDefUID MeterDefUID, lookup *MeterDefinitionLookupFilter) error {
+	batch := &ObjectResourceBatch{
+		MeterDef: lookup.MeterDefName,
+	}
+
+	for _, obj := range s.objectsSeen {
+		o, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+
+		key := NewObjectResourceKey(o, meterDefUID)
+		workload, ok, err := lookup.FindMatchingWorkloads(obj)
+
+		if err != nil {
+			s.log.Error(err, "error matching")
+			return err
+		}
+
+		existing, exists := s.objectResourceSet[key]
+
+		if !ok {
+			if exists {
+				delete(s.objectResourceSet, key)
+				batch.Removed = append(batch.Removed, existing)
+			}
+			continue
+		}
+
+		resource, err := v1alpha1.NewWorkloadResource(*workload, obj, s.scheme)
+		if err != nil {
+			return err
+		}
+
+		value, err := NewObjectResourceValue(lookup, resource, obj, ok)
+		if err != nil {
+			return err
+		}
+
+		s.objectResourceSet[key] = value
+
+		if exists && existing.ReferencedWorkloadName == value.ReferencedWorkloadName {
+			continue
+		}
+
+		batch.Added = append(batch.Added, value)
+	}
+
+	if len(batch.Added) == 0 && len(batch.Removed) == 0 {
+		return nil
+	}
+
+	s.log.Info("broadcasting batch", "mdef", batch.MeterDef,
+		"added", len(batch.Added), "removed", len(batch.Removed))
+	s.broadcast(&ObjectResourceMessage{
+		Action: BatchMessageAction,
+		Object: batch,
+	})
+
 	return nil
 }
 
+func (s *MeterDefinitionStore) broadcastNamespaces(lookup *MeterDefinitionLookupFilter) {
+	s.broadcast(&ObjectResourceMessage{
+		Action: NamespacesMessageAction,
+		Object: lookup,
+	})
+}
+
 func (s *MeterDefinitionStore) addSeenObject(obj interface{}) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -345,6 +619,7 @@ func (s *MeterDefinitionStore) addSeenObject(obj interface{}) error {
 
 	uid := ObjectUID(o.GetUID())
 	s.objectsSeen[uid] = obj
+	delete(s.restored, uid)
 	return nil
 }
 
@@ -379,12 +654,23 @@ func (s *MeterDefinitionStore) findObjectMatches(obj interface{}, results *[]res
 
 // Update updates the existing entry in the OwnerCache.
 func (s *MeterDefinitionStore) Update(obj interface{}) error {
+	// the owners of the object aren't watched, so the controllers
+	// cached for them are looked up again when the object changes
+	if o, err := meta.Accessor(obj); err == nil && s.findOwner != nil {
+		s.findOwner.InvalidateObject(o)
+	}
+
 	// TODO: For now, just call Add, in the future one could check if the resource version changed?
 	return s.Add(obj)
 }
 
 // Delete deletes an existing entry in the OwnerCache.
 func (s *MeterDefinitionStore) Delete(obj interface{}) error {
+	switch obj.(type) {
+	case *corev1.Namespace, *olmv1.OperatorGroup:
+		return s.handleVertexChange(obj)
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -394,22 +680,41 @@ func (s *MeterDefinitionStore) Delete(obj interface{}) error {
 	}
 
 	delete(s.objectsSeen, ObjectUID(o.GetUID()))
+	delete(s.restored, ObjectUID(o.GetUID()))
+	if s.findOwner != nil {
+		s.findOwner.InvalidateObject(o)
+	}
 
 	if meterdef, ok := obj.(*v1alpha1.MeterDefinition); ok {
 		s.removeMeterDefinition(meterdef)
 		return nil
 	}
 
-	for key, _ := range s.objectResourceSet {
+	removed := []*ObjectResourceValue{}
+
+	for key, value := range s.objectResourceSet {
 		if key.ObjectUID == ObjectUID(o.GetUID()) {
 			delete(s.objectResourceSet, key)
+			removed = append(removed, value)
 		}
 	}
 
-	s.broadcast(&ObjectResourceMessage{
-		Action: DeleteMessageAction,
-		Object: obj,
-	})
+	if len(removed) == 0 {
+		s.broadcast(&ObjectResourceMessage{
+			Action: DeleteMessageAction,
+			Object: obj,
+		})
+		return nil
+	}
+
+	// one message per meter definition so their statuses are updated
+	for _, value := range removed {
+		s.broadcast(&ObjectResourceMessage{
+			Action:              DeleteMessageAction,
+			Object:              obj,
+			ObjectResourceValue: value,
+		})
+	}
 
 	return nil
 }
@@ -437,9 +742,21 @@ func (s *MeterDefinitionStore) GetByKey(key string) (item interface{}, exists bo
 // Replace will delete the contents of the store, using instead the
 // given list.
 func (s *MeterDefinitionStore) Replace(list []interface{}, _ string) error {
+	// namespaces only need to be resolved once for the whole list
+	var namespace interface{}
+
 	for _, o := range list {
-		if err := s.Delete(o); err != nil {
-			return err
+		if _, ok := o.(*corev1.Namespace); ok {
+			namespace = o
+			continue
+		}
+
+		// restored objects keep their matches until the meter
+		// definitions have been listed
+		if !s.isRestored(o) {
+			if err := s.Delete(o); err != nil {
+				return err
+			}
 		}
 
 		if err := s.Add(o); err != nil {
@@ -447,9 +764,26 @@ func (s *MeterDefinitionStore) Replace(list []interface{}, _ string) error {
 		}
 	}
 
+	if namespace != nil {
+		return s.handleVertexChange(namespace)
+	}
+
 	return nil
 }
 
+func (s *MeterDefinitionStore) isRestored(obj interface{}) bool {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.restored[ObjectUID(o.GetUID())]
+	return ok
+}
+
 // Resync implements the Resync method of the store interface.
 func (s *MeterDefinitionStore) Resync() error {
 	s.mutex.Lock()
@@ -494,36 +828,142 @@ func (s *MeterDefinitionStore) Start() {
 			}
 		}
 	}()
+
+	if s.snapshotter != nil {
+		s.snapshotsSaved.Add(1)
+		go func() {
+			defer s.snapshotsSaved.Done()
+			s.saveSnapshots()
+		}()
+	}
 }
 
 func (s *MeterDefinitionStoreBuilder) CreateStores() MeterDefinitionStores {
 	stores := make(MeterDefinitionStores)
+	olmInstalled := s.operatorGroupsInstalled()
+
+	if !olmInstalled {
+		s.log.Info("operatorgroups aren't served, not watching them")
+	}
 
 	for _, storeConfig := range storeConfigs {
-		store := s.NewInstance()
+		createListers := storeConfig.createListers
+		if olmInstalled {
+			createListers = append(createListers, storeConfig.createOLMListers...)
+		}
+
+		store := s.newStore(storeConfig, len(createListers))
 
-		for _, createLister := range storeConfig.createListers {
+		for _, createLister := range createListers {
 			for _, ns := range s.namespaces {
 				lister := createLister(s, ns)
-				reflector := cache.NewReflector(lister.lister, lister.expectedType, store, 5*60*time.Second)
+				reflector := cache.NewReflector(lister.lister, lister.expectedType, &reflectorStore{MeterDefinitionStore: store}, 5*60*time.Second)
 				go reflector.Run(s.ctx.Done())
 			}
 		}
 
-		go store.Start()
+		for _, createLister := range storeConfig.createClusterListers {
+			lister := createLister(s, corev1.NamespaceAll)
+			reflector := cache.NewReflector(lister.lister, lister.expectedType, &reflectorStore{MeterDefinitionStore: store}, 5*60*time.Second)
+			go reflector.Run(s.ctx.Done())
+		}
+
+		store.Start()
 		stores[storeConfig.name] = store
 	}
 
 	return stores
 }
 
+// newStore creates the store of the config, warm started from the last
+// snapshot when there is one.
+func (s *MeterDefinitionStoreBuilder) newStore(config storeConfig, listers int) *MeterDefinitionStore {
+	store := s.NewInstance()
+	store.name = config.name
+	store.unsynced = listers*len(s.namespaces) + len(config.createClusterListers)
+
+	if s.snapshotter == nil {
+		return store
+	}
+
+	snapshot, err := s.snapshotter.Load(store.name)
+
+	if err != nil {
+		s.log.Error(err, "failed to load snapshot, starting cold", "store", store.name)
+		return store
+	}
+
+	if snapshot == nil {
+		return store
+	}
+
+	if err := store.restore(snapshot); err != nil {
+		s.log.Error(err, "failed to restore snapshot, starting cold", "store", store.name)
+	}
+
+	return store
+}
+
+// operatorGroupsInstalled returns true if the OperatorGroup resource of OLM
+// is served. Without OLM its reflectors would never sync.
+func (s *MeterDefinitionStoreBuilder) operatorGroupsInstalled() bool {
+	resources, err := s.kubeClient.Discovery().ServerResourcesForGroupVersion(olmv1.SchemeGroupVersion.String())
+
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			s.log.Error(err, "failed to discover operatorgroups")
+		}
+		return false
+	}
+
+	for _, resource := range resources.APIResources {
+		if resource.Name == operatorGroupResource.Resource {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetContext sets the context the stores run in. The stores save a
+// last snapshot when it is done.
+func (s *MeterDefinitionStoreBuilder) SetContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
+// WaitForSnapshots blocks until the stores saved their last snapshot
+// after their context is done.
+func (s *MeterDefinitionStoreBuilder) WaitForSnapshots() {
+	s.snapshotsSaved.Wait()
+}
+
+// SetSharding limits the stores to the objects of a shard. Meter
+// definitions, namespaces and operator groups are kept by every shard.
+func (s *MeterDefinitionStoreBuilder) SetSharding(sharding Sharding) {
+	s.sharding = sharding
+}
+
 func (s *MeterDefinitionStoreBuilder) SetNamespaces(ns []string) {
 	s.namespaces = ns
 }
 
+// SetSnapshotter enables saving the stores every interval and warm
+// starting them from the last snapshot.
+func (s *MeterDefinitionStoreBuilder) SetSnapshotter(snapshotter Snapshotter, interval time.Duration) {
+	s.snapshotter = snapshotter
+	s.snapshotInterval = interval
+}
+
 type storeConfig struct {
 	name          string
 	createListers []createLister
+
+	// createClusterListers are for cluster scoped resources
+	// and are only created once
+	createClusterListers []createLister
+
+	// createOLMListers are only created if OLM is installed
+	createOLMListers []createLister
 }
 
 type reflectorConfig struct {
@@ -546,18 +986,36 @@ var (
 		createListers: []createLister{
 			pvcLister, meterDefLister,
 		},
+		createClusterListers: []createLister{
+			namespaceLister,
+		},
+		createOLMListers: []createLister{
+			operatorGroupLister,
+		},
 	}
 	podStore = storeConfig{
 		name: PodStore,
 		createListers: []createLister{
 			podLister, meterDefLister,
 		},
+		createClusterListers: []createLister{
+			namespaceLister,
+		},
+		createOLMListers: []createLister{
+			operatorGroupLister,
+		},
 	}
 	serviceStore = storeConfig{
 		name: ServiceStore,
 		createListers: []createLister{
 			serviceLister, serviceMonitorLister, meterDefLister,
 		},
+		createClusterListers: []createLister{
+			namespaceLister,
+		},
+		createOLMListers: []createLister{
+			operatorGroupLister,
+		},
 	}
 )
 
@@ -595,3 +1053,17 @@ func meterDefLister(s *MeterDefinitionStoreBuilder, ns string) reflectorConfig {
 		lister:       CreateMeterDefinitionWatch(s.marketplaceClient, ns),
 	}
 }
+
+func namespaceLister(s *MeterDefinitionStoreBuilder, _ string) reflectorConfig {
+	return reflectorConfig{
+		expectedType: &corev1.Namespace{},
+		lister:       CreateNamespaceListWatch(s.kubeClient),
+	}
+}
+
+func operatorGroupLister(s *MeterDefinitionStoreBuilder, ns string) reflectorConfig {
+	return reflectorConfig{
+		expectedType: &olmv1.OperatorGroup{},
+		lister:       CreateOperatorGroupListWatch(s.dynamicClient, ns),
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	olmv1 "github.com/operator-framework/api/pkg/operators/v1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("MeterDefinitionStoreBuilder", func() {
+	var (
+		kubeClient *fake.Clientset
+		builder    *MeterDefinitionStoreBuilder
+	)
+
+	BeforeEach(func() {
+		kubeClient = fake.NewSimpleClientset()
+		builder = NewMeterDefinitionStoreBuilder(
+			context.TODO(), logf.Log, nil, kubeClient, nil, nil, nil, nil, nil)
+	})
+
+	It("should not watch operator groups without OLM", func() {
+		Expect(builder.operatorGroupsInstalled()).To(BeFalse())
+	})
+
+	It("should watch operator groups when they are served", func() {
+		kubeClient.Fake.Resources = []*metav1.APIResourceList{
+			{
+				GroupVersion: olmv1.SchemeGroupVersion.String(),
+				APIResources: []metav1.APIResource{
+					{Name: "operatorgroups", Namespaced: true, Kind: "OperatorGroup"},
+				},
+			},
+		}
+
+		Expect(builder.operatorGroupsInstalled()).To(BeTrue())
+	})
+})
+
+var _ = Describe("MeterDefinitionStore", func() {
+	It("should keep the current meter definition of an unchanged lookup", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+
+		k8sClient := ctrlfake.NewFakeClientWithScheme(scheme)
+		cc := reconcileutils.NewClientCommand(k8sClient, scheme, logf.Log)
+		store := NewMeterDefinitionStoreBuilder(
+			context.TODO(), logf.Log, cc, fake.NewSimpleClientset(), nil, nil, nil, nil, scheme).NewInstance()
+
+		meterdef := &v1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "mdef"},
+			Spec: v1alpha1.MeterDefinitionSpec{
+				Group: "apps.partner.metering.com",
+				Kind:  "App",
+				Workloads: []v1alpha1.Workload{
+					{
+						Name:         "pods",
+						WorkloadType: v1alpha1.WorkloadTypePod,
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "foo"},
+						},
+					},
+				},
+			},
+		}
+		Expect(store.Add(meterdef)).To(Succeed())
+
+		updated := meterdef.DeepCopy()
+		updated.Labels = map[string]string{"version": "2"}
+		Expect(store.Add(updated)).To(Succeed())
+
+		lookup := store.meterDefinitionFilters[MeterDefUID(meterdef.UID)]
+		Expect(lookup.meterdef.Labels).To(Equal(updated.Labels))
+	})
+})
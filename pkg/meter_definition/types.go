@@ -31,6 +31,14 @@ type ObjectResourceMessageAction string
 const (
 	AddMessageAction    ObjectResourceMessageAction = "Add"
 	DeleteMessageAction                             = "Delete"
+
+	// NamespacesMessageAction is sent with the lookup filter as
+	// the object when the namespaces of a meter definition are resolved.
+	NamespacesMessageAction ObjectResourceMessageAction = "Namespaces"
+
+	// BatchMessageAction is sent with an ObjectResourceBatch as the
+	// object when a meter definition is re-evaluated.
+	BatchMessageAction ObjectResourceMessageAction = "Batch"
 )
 
 type ObjectResourceMessage struct {
@@ -90,3 +98,11 @@ func NewObjectResourceValue(
 		Matched:          matched,
 	}, nil
 }
+
+// ObjectResourceBatch are the objects added to and removed from a meter
+// definition when it is re-evaluated.
+type ObjectResourceBatch struct {
+	MeterDef types.NamespacedName
+	Added    []*ObjectResourceValue
+	Removed  []*ObjectResourceValue
+}
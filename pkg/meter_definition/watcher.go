@@ -18,10 +18,13 @@ import (
 	"context"
 
 	monitoringv1client "github.com/coreos/prometheus-operator/pkg/client/versioned/typed/monitoring/v1"
+	olmv1 "github.com/operator-framework/api/pkg/operators/v1"
 	marketplacev1alpha1client "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/generated/clientset/versioned/typed/marketplace/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
@@ -80,3 +83,58 @@ func CreateMeterDefinitionWatch(c *marketplacev1alpha1client.MarketplaceV1alpha1
 		},
 	}
 }
+
+func CreateNamespaceListWatch(kubeClient clientset.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return kubeClient.CoreV1().Namespaces().List(context.TODO(), opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return kubeClient.CoreV1().Namespaces().Watch(context.TODO(), opts)
+		},
+	}
+}
+
+var operatorGroupResource = olmv1.SchemeGroupVersion.WithResource("operatorgroups")
+
+// CreateOperatorGroupListWatch uses the dynamic client since there is no
+// typed client for operator groups. Objects are converted to
+// OperatorGroups before they are returned.
+func CreateOperatorGroupListWatch(c dynamic.Interface, ns string) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			list, err := c.Resource(operatorGroupResource).Namespace(ns).List(context.TODO(), opts)
+
+			if err != nil {
+				return nil, err
+			}
+
+			ogList := &olmv1.OperatorGroupList{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(list.UnstructuredContent(), ogList)
+			return ogList, err
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			w, err := c.Resource(operatorGroupResource).Namespace(ns).Watch(context.TODO(), opts)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+				u, ok := in.Object.(*unstructured.Unstructured)
+
+				if !ok {
+					return in, true
+				}
+
+				og := &olmv1.OperatorGroup{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), og); err != nil {
+					return in, false
+				}
+
+				in.Object = og
+				return in, true
+			}), nil
+		},
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DefaultStatusSampleSize is the number of workload resources kept
+// on the meter definition status.
+const DefaultStatusSampleSize = 50
+
+// WorkloadResources returns the sorted resources matched by the meter
+// definition in all of the stores.
+func WorkloadResources(meterDefUID types.UID, stores ...*MeterDefinitionStore) []v1alpha1.WorkloadResource {
+	set := map[types.UID]v1alpha1.WorkloadResource{}
+
+	for _, store := range stores {
+		for _, value := range store.GetMeterDefObjects(meterDefUID) {
+			set[value.UID] = *value.WorkloadResource
+		}
+	}
+
+	resources := make([]v1alpha1.WorkloadResource, 0, len(set))
+	for _, resource := range set {
+		resources = append(resources, resource)
+	}
+
+	sort.Sort(v1alpha1.ByAlphabetical(resources))
+	return resources
+}
+
+// SummarizeWorkloadResources counts the sorted resources per workload
+// and samples at most sampleSize of them, shared between the workloads.
+func SummarizeWorkloadResources(
+	resources []v1alpha1.WorkloadResource,
+	sampleSize int,
+) ([]v1alpha1.WorkloadResource, []v1alpha1.WorkloadResourceCount) {
+	counts := []v1alpha1.WorkloadResourceCount{}
+	byWorkload := [][]v1alpha1.WorkloadResource{}
+
+	for i, resource := range resources {
+		if i == 0 || resources[i-1].ReferencedWorkloadName != resource.ReferencedWorkloadName {
+			counts = append(counts, v1alpha1.WorkloadResourceCount{Name: resource.ReferencedWorkloadName})
+			byWorkload = append(byWorkload, []v1alpha1.WorkloadResource{})
+		}
+
+		last := len(counts) - 1
+		counts[last].Count = counts[last].Count + 1
+		byWorkload[last] = append(byWorkload[last], resource)
+	}
+
+	if len(resources) <= sampleSize {
+		return resources, counts
+	}
+
+	// take one resource of each workload at a time so every workload
+	// is in the sample
+	sample := make([]v1alpha1.WorkloadResource, 0, sampleSize)
+	for i := 0; len(sample) < sampleSize; i++ {
+		for _, workload := range byWorkload {
+			if i < len(workload) && len(sample) < sampleSize {
+				sample = append(sample, workload[i])
+			}
+		}
+	}
+
+	sort.Sort(v1alpha1.ByAlphabetical(sample))
+	return sample, counts
+}
+
+// PageWorkloadResources returns at most limit of the sorted resources
+// after the continue token and the token of the next page. The token
+// is empty on the last page.
+func PageWorkloadResources(
+	resources []v1alpha1.WorkloadResource,
+	limit int,
+	continueToken string,
+) ([]v1alpha1.WorkloadResource, string, error) {
+	start := 0
+
+	if continueToken != "" {
+		data, err := base64.RawURLEncoding.DecodeString(continueToken)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "invalid continue token")
+		}
+
+		last := v1alpha1.WorkloadResource{}
+		if err := json.Unmarshal(data, &last); err != nil {
+			return nil, "", errors.Wrap(err, "invalid continue token")
+		}
+
+		start = sort.Search(len(resources), func(i int) bool {
+			return resources[i].Compare(last) > 0
+		})
+	}
+
+	end := len(resources)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	page := resources[start:end]
+
+	if end == len(resources) {
+		return page, "", nil
+	}
+
+	last := page[len(page)-1]
+	last.GroupVersionKind = nil
+
+	data, err := json.Marshal(last)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return page, base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// mergeShardWorkloadResources combines the sorted resources of this
+// shard with the status written by the other shards. Shards that no
+// longer exist are dropped.
+func mergeShardWorkloadResources(
+	status v1alpha1.MeterDefinitionStatus,
+	resources []v1alpha1.WorkloadResource,
+	sharding Sharding,
+	sampleSize int,
+) ([]v1alpha1.WorkloadResource, []v1alpha1.WorkloadResourceCount, int, []v1alpha1.WorkloadShardStatus) {
+	_, ownCounts := SummarizeWorkloadResources(resources, sampleSize)
+
+	shards := []v1alpha1.WorkloadShardStatus{}
+	for _, shard := range status.WorkloadShards {
+		if shard.Shard == sharding.Shard || int(shard.Shard) >= sharding.TotalShards {
+			continue
+		}
+		shards = append(shards, shard)
+	}
+
+	shards = append(shards, v1alpha1.WorkloadShardStatus{
+		Shard:  sharding.Shard,
+		Counts: ownCounts,
+		Total:  len(resources),
+	})
+	sort.Slice(shards, func(i, j int) bool { return shards[i].Shard < shards[j].Shard })
+
+	total := 0
+	countByName := map[string]int{}
+	for _, shard := range shards {
+		total = total + shard.Total
+		for _, count := range shard.Counts {
+			countByName[count.Name] = countByName[count.Name] + count.Count
+		}
+	}
+
+	counts := make([]v1alpha1.WorkloadResourceCount, 0, len(countByName))
+	for name, count := range countByName {
+		counts = append(counts, v1alpha1.WorkloadResourceCount{Name: name, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Name < counts[j].Name })
+
+	// the sample keeps what the other shards sampled
+	merged := []v1alpha1.WorkloadResource{}
+	for _, resource := range status.WorkloadResources {
+		if ShardOf(resource.UID, sharding.TotalShards) != sharding.Shard {
+			merged = append(merged, resource)
+		}
+	}
+	merged = append(merged, resources...)
+	sort.Sort(v1alpha1.ByAlphabetical(merged))
+
+	sample, _ := SummarizeWorkloadResources(merged, sampleSize)
+	return sample, counts, total, shards
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meter_definition
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("WorkloadResources", func() {
+	var resources []v1alpha1.WorkloadResource
+
+	BeforeEach(func() {
+		resources = []v1alpha1.WorkloadResource{}
+
+		for i := 0; i < 7; i++ {
+			resources = append(resources, v1alpha1.WorkloadResource{
+				ReferencedWorkloadName: "pods",
+				NamespacedNameReference: common.NamespacedNameReference{
+					Namespace: "bar",
+					Name:      fmt.Sprintf("pod-%d", i),
+					UID:       types.UID(fmt.Sprintf("pod-%d", i)),
+				},
+			})
+		}
+
+		resources = append(resources, v1alpha1.WorkloadResource{
+			ReferencedWorkloadName: "services",
+			NamespacedNameReference: common.NamespacedNameReference{
+				Namespace: "bar",
+				Name:      "service",
+				UID:       "service",
+			},
+		})
+	})
+
+	It("should sample every workload", func() {
+		sample, counts := SummarizeWorkloadResources(resources, 4)
+
+		Expect(counts).To(Equal([]v1alpha1.WorkloadResourceCount{
+			{Name: "pods", Count: 7},
+			{Name: "services", Count: 1},
+		}))
+		Expect(sample).To(Equal([]v1alpha1.WorkloadResource{
+			resources[0], resources[1], resources[2], resources[7],
+		}))
+	})
+
+	It("should not sample when under the size", func() {
+		sample, _ := SummarizeWorkloadResources(resources, 10)
+		Expect(sample).To(Equal(resources))
+	})
+
+	It("should page through all resources", func() {
+		all := []v1alpha1.WorkloadResource{}
+		token := ""
+		pages := 0
+
+		for {
+			page, next, err := PageWorkloadResources(resources, 3, token)
+			Expect(err).To(Succeed())
+
+			all = append(all, page...)
+			pages = pages + 1
+
+			if next == "" {
+				break
+			}
+			token = next
+		}
+
+		Expect(pages).To(Equal(3))
+		Expect(all).To(Equal(resources))
+	})
+
+	It("should continue after removed resources", func() {
+		page, next, err := PageWorkloadResources(resources, 3, "")
+		Expect(err).To(Succeed())
+		Expect(page).To(HaveLen(3))
+
+		remaining := append([]v1alpha1.WorkloadResource{}, resources[:2]...)
+		remaining = append(remaining, resources[3:]...)
+
+		page, _, err = PageWorkloadResources(remaining, 3, next)
+		Expect(err).To(Succeed())
+		Expect(page[0]).To(Equal(resources[3]))
+	})
+
+	It("should reject invalid tokens", func() {
+		_, _, err := PageWorkloadResources(resources, 3, "not a token")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should merge the status of other shards", func() {
+		sharding := Sharding{Shard: 1, TotalShards: 2}
+
+		own := []v1alpha1.WorkloadResource{}
+		other := []v1alpha1.WorkloadResource{}
+		for _, resource := range resources {
+			if sharding.Owns(resource.UID) {
+				own = append(own, resource)
+			} else {
+				other = append(other, resource)
+			}
+		}
+		Expect(own).ToNot(BeEmpty())
+		Expect(other).ToNot(BeEmpty())
+
+		status := v1alpha1.MeterDefinitionStatus{
+			WorkloadResources: other,
+			WorkloadShards: []v1alpha1.WorkloadShardStatus{
+				{Shard: 0, Total: len(other), Counts: []v1alpha1.WorkloadResourceCount{{Name: "pods", Count: len(other)}}},
+				{Shard: 1, Total: 100},
+				{Shard: 2, Total: 100},
+			},
+		}
+
+		sample, counts, total, shards := mergeShardWorkloadResources(status, own, sharding, 50)
+
+		Expect(total).To(Equal(len(resources)))
+		Expect(shards).To(HaveLen(2))
+		Expect(shards[1].Total).To(Equal(len(own)))
+		Expect(sample).To(Equal(resources))
+
+		sum := 0
+		for _, count := range counts {
+			sum = sum + count.Count
+		}
+		Expect(sum).To(Equal(len(resources)))
+	})
+})
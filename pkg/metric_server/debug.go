@@ -0,0 +1,212 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const debugPath = "/debug/meterdefinitions"
+
+// debugHandler exposes the contents of the meter definition stores.
+//
+//	/debug/meterdefinitions/stores             summary of each store
+//	/debug/meterdefinitions/filters            filters of each meter definition
+//	/debug/meterdefinitions/objects?uid=       objects matched by a meter definition
+//	/debug/meterdefinitions/trace?uid=         filter results for an object
+type debugHandler struct {
+	stores meter_definition.MeterDefinitionStores
+}
+
+func newDebugHandler(
+	kubeClient clientset.Interface,
+	stores meter_definition.MeterDefinitionStores,
+) http.Handler {
+	mux := http.NewServeMux()
+	h := &debugHandler{stores: stores}
+
+	mux.HandleFunc(debugPath+"/stores", h.serveStores)
+	mux.HandleFunc(debugPath+"/filters", h.serveFilters)
+	mux.HandleFunc(debugPath+"/objects", h.serveObjects)
+	mux.HandleFunc(debugPath+"/trace", h.serveTrace)
+
+	return &authHandler{
+		kubeClient: kubeClient,
+		next:       mux,
+	}
+}
+
+func (h *debugHandler) serveStores(w http.ResponseWriter, r *http.Request) {
+	out := map[string]meter_definition.StoreSummary{}
+
+	for name, store := range h.stores {
+		out[name] = store.Summary()
+	}
+
+	writeJSON(w, out)
+}
+
+func (h *debugHandler) serveFilters(w http.ResponseWriter, r *http.Request) {
+	out := map[string][]meter_definition.LookupSummary{}
+
+	for name, store := range h.stores {
+		out[name] = store.Lookups()
+	}
+
+	writeJSON(w, out)
+}
+
+func (h *debugHandler) serveObjects(w http.ResponseWriter, r *http.Request) {
+	uid := r.URL.Query().Get("uid")
+
+	if uid == "" {
+		http.Error(w, "uid of the meter definition is required", http.StatusBadRequest)
+		return
+	}
+
+	out := map[string][]*v1alpha1.WorkloadResource{}
+
+	for name, store := range h.stores {
+		resources := []*v1alpha1.WorkloadResource{}
+		for _, value := range store.GetMeterDefObjects(types.UID(uid)) {
+			resources = append(resources, value.WorkloadResource)
+		}
+		out[name] = resources
+	}
+
+	writeJSON(w, out)
+}
+
+func (h *debugHandler) serveTrace(w http.ResponseWriter, r *http.Request) {
+	uid := r.URL.Query().Get("uid")
+
+	if uid == "" {
+		http.Error(w, "uid of the object is required", http.StatusBadRequest)
+		return
+	}
+
+	out := map[string][]meter_definition.MeterDefinitionTrace{}
+
+	for name, store := range h.stores {
+		if traces, ok := store.TraceObject(types.UID(uid)); ok {
+			out[name] = traces
+		}
+	}
+
+	if len(out) == 0 {
+		http.Error(w, "object has not been seen", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, out)
+}
+
+func writeJSON(w http.ResponseWriter, out interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(out); err != nil {
+		log.Error(err, "failed to write debug response")
+	}
+}
+
+// authHandler authenticates the bearer token of the request with a
+// TokenReview and checks the user can get the path with a
+// SubjectAccessReview.
+type authHandler struct {
+	kubeClient clientset.Interface
+	next       http.Handler
+}
+
+func (a *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+
+	if !strings.HasPrefix(auth, "Bearer ") {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	token := strings.TrimPrefix(auth, "Bearer ")
+
+	review, err := a.kubeClient.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+
+	if err != nil {
+		log.Error(err, "failed to review token")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if !review.Status.Authenticated {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	allowed, err := a.isAllowed(ctx, review.Status.User, r.URL.Path)
+
+	if err != nil {
+		log.Error(err, "failed to review access")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if !allowed {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	a.next.ServeHTTP(w, r)
+}
+
+func (a *authHandler) isAllowed(ctx context.Context, user authenticationv1.UserInfo, path string) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	sar, err := a.kubeClient.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: path,
+				Verb: "get",
+			},
+		},
+	}, metav1.CreateOptions{})
+
+	if err != nil {
+		return false, err
+	}
+
+	return sar.Status.Allowed, nil
+}
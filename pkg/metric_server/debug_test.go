@@ -0,0 +1,212 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("authHandler", func() {
+	var (
+		kubeClient *fake.Clientset
+		handler    http.Handler
+		served     int
+		paths      []string
+	)
+
+	BeforeEach(func() {
+		served = 0
+		paths = nil
+		kubeClient = fake.NewSimpleClientset()
+
+		kubeClient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+
+			switch review.Spec.Token {
+			case "admin", "user":
+				review.Status.Authenticated = true
+				review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+			case "error":
+				return true, nil, errors.New("token review failed")
+			}
+
+			return true, review, nil
+		})
+
+		kubeClient.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+			paths = append(paths, sar.Spec.NonResourceAttributes.Path)
+			sar.Status.Allowed = sar.Spec.User == "admin"
+			return true, sar, nil
+		})
+
+		handler = &authHandler{
+			kubeClient: kubeClient,
+			next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = served + 1
+			}),
+		}
+	})
+
+	serve := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, debugPath+"/stores", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	It("should require a bearer token", func() {
+		Expect(serve("")).To(Equal(http.StatusUnauthorized))
+		Expect(serve("unknown")).To(Equal(http.StatusUnauthorized))
+		Expect(served).To(Equal(0))
+	})
+
+	It("should fail when the token can't be reviewed", func() {
+		Expect(serve("error")).To(Equal(http.StatusInternalServerError))
+		Expect(served).To(Equal(0))
+	})
+
+	It("should check access to the path", func() {
+		Expect(serve("user")).To(Equal(http.StatusForbidden))
+		Expect(served).To(Equal(0))
+
+		Expect(serve("admin")).To(Equal(http.StatusOK))
+		Expect(served).To(Equal(1))
+
+		Expect(paths).To(Equal([]string{debugPath + "/stores", debugPath + "/stores"}))
+	})
+})
+
+var _ = Describe("debugHandler", func() {
+	var (
+		store    *meter_definition.MeterDefinitionStore
+		handler  *debugHandler
+		meterdef *v1alpha1.MeterDefinition
+		pod      *corev1.Pod
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha1.SchemeBuilder.AddToScheme(scheme)).To(Succeed())
+
+		store = meter_definition.NewMeterDefinitionStoreBuilder(
+			nil, logf.Log, nil, nil, nil, nil, nil, nil, scheme).NewInstance()
+		handler = &debugHandler{stores: meter_definition.MeterDefinitionStores{"pods": store}}
+
+		meterdef = &v1alpha1.MeterDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: "bar",
+				UID:       "meterdef-uid",
+			},
+			Spec: v1alpha1.MeterDefinitionSpec{
+				Group:              "apps.partner.metering.com",
+				Kind:               "App",
+				WorkloadVertexType: v1alpha1.WorkloadVertexNamespace,
+				Workloads: []v1alpha1.Workload{
+					{
+						Name:         "pods",
+						WorkloadType: v1alpha1.WorkloadTypePod,
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "foo"},
+						},
+					},
+				},
+			},
+		}
+
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod",
+				Namespace: "bar",
+				UID:       "pod-uid",
+				Labels:    map[string]string{"app": "foo"},
+			},
+		}
+
+		Expect(store.Add(meterdef)).To(Succeed())
+		Expect(store.Add(pod)).To(Succeed())
+	})
+
+	get := func(serve http.HandlerFunc, url string, out interface{}) int {
+		rec := httptest.NewRecorder()
+		serve(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+		if rec.Code == http.StatusOK {
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(json.Unmarshal(rec.Body.Bytes(), out)).To(Succeed())
+		}
+
+		return rec.Code
+	}
+
+	It("should summarize the stores", func() {
+		out := map[string]meter_definition.StoreSummary{}
+		Expect(get(handler.serveStores, debugPath+"/stores", &out)).To(Equal(http.StatusOK))
+		Expect(out).To(Equal(map[string]meter_definition.StoreSummary{
+			"pods": {MeterDefinitions: 1, MatchedObjects: 1, ObjectsSeen: 1},
+		}))
+	})
+
+	It("should list the filters of the meter definitions", func() {
+		out := map[string][]meter_definition.LookupSummary{}
+		Expect(get(handler.serveFilters, debugPath+"/filters", &out)).To(Equal(http.StatusOK))
+		Expect(out["pods"]).To(HaveLen(1))
+		Expect(out["pods"][0].UID).To(Equal(meterdef.UID))
+		Expect(out["pods"][0].Filters).To(HaveKey("pods"))
+	})
+
+	It("should list the objects of a meter definition", func() {
+		Expect(get(handler.serveObjects, debugPath+"/objects", nil)).To(Equal(http.StatusBadRequest))
+
+		out := map[string][]*v1alpha1.WorkloadResource{}
+		Expect(get(handler.serveObjects, debugPath+"/objects?uid=meterdef-uid", &out)).To(Equal(http.StatusOK))
+		Expect(out["pods"]).To(HaveLen(1))
+		Expect(out["pods"][0].UID).To(Equal(pod.UID))
+	})
+
+	It("should trace the filters applied to an object", func() {
+		Expect(get(handler.serveTrace, debugPath+"/trace", nil)).To(Equal(http.StatusBadRequest))
+		Expect(get(handler.serveTrace, debugPath+"/trace?uid=unknown", nil)).To(Equal(http.StatusNotFound))
+
+		out := map[string][]meter_definition.MeterDefinitionTrace{}
+		Expect(get(handler.serveTrace, debugPath+"/trace?uid=pod-uid", &out)).To(Equal(http.StatusOK))
+		Expect(out["pods"]).To(HaveLen(1))
+		Expect(out["pods"][0].Workloads).To(HaveLen(1))
+		Expect(out["pods"][0].Workloads[0].Matched).To(BeTrue())
+	})
+})
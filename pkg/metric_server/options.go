@@ -18,7 +18,12 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"regexp"
+	"time"
 
+	"github.com/redhat-marketplace/redhat-marketplace-operator/internal/metrics"
+	rhmclient "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/client"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
 	"k8s.io/klog"
 
 	"github.com/spf13/pflag"
@@ -43,6 +48,32 @@ type Options struct {
 
 	EnableGZIPEncoding bool
 
+	OwnerMaxDepth int
+	OwnerCacheTTL time.Duration
+
+	EnableDebugEndpoint bool
+
+	SnapshotDir       string
+	SnapshotConfigMap string
+	SnapshotNamespace string
+	SnapshotInterval  time.Duration
+
+	StatusUpdateWindow time.Duration
+	StatusSampleSize   int
+
+	MeterDefinitionSeriesLimit int
+	GlobalSeriesLimit          int
+
+	RemoteWriteURL             string
+	RemoteWriteInterval        time.Duration
+	RemoteWriteTimeout         time.Duration
+	RemoteWriteMatch           string
+	RemoteWriteLabels          map[string]string
+	RemoteWriteWALDir          string
+	RemoteWriteMaxSegments     int
+	RemoteWriteBearerTokenFile string
+	RemoteWriteCAFile          string
+
 	flags *pflag.FlagSet
 }
 
@@ -92,15 +123,55 @@ func (o *Options) AddFlags() {
 	o.flags.Int32Var(&o.Shard, "shard", int32(0), "The instances shard nominal (zero indexed) within the total number of shards. (default 0)")
 	o.flags.IntVar(&o.TotalShards, "total-shards", 1, "The total number of shards. Sharding is disabled when total shards is set to 1.")
 
-	autoshardingNotice := "When set, it is expected that --pod and --pod-namespace are both set. Most likely this should be passed via the downward API. This is used for auto-detecting sharding. If set, this has preference over statically configured sharding. This is experimental, it may be removed without notice."
+	autoshardingNotice := "When set, it is expected that --pod and --pod-namespace are both set. Most likely this should be passed via the downward API. If the pod is part of a StatefulSet, its ordinal is used as the shard and the StatefulSet replicas as the total shards, overriding --shard and --total-shards."
 
-	o.flags.StringVar(&o.Pod, "pod", "", "Name of the pod that contains the kube-state-metrics container. "+autoshardingNotice)
+	o.flags.StringVar(&o.Pod, "pod", "", "Name of the pod that contains the metric-state container. "+autoshardingNotice)
 	o.flags.StringVar(&o.Namespace, "pod-namespace", "", "Name of the namespace of the pod specified by --pod. "+autoshardingNotice)
 	o.flags.BoolVarP(&o.Version, "version", "", false, "kube-state-metrics build version information")
 	o.flags.BoolVar(&o.EnableGZIPEncoding, "enable-gzip-encoding", false, "Gzip responses when requested by clients via 'Accept-Encoding: gzip' header.")
+	o.flags.IntVar(&o.OwnerMaxDepth, "owner-max-depth", rhmclient.DefaultOwnerMaxDepth, "The number of owners matched against the ownerCRD of a workload, starting with the controller of the workload object.")
+	o.flags.DurationVar(&o.OwnerCacheTTL, "owner-cache-ttl", rhmclient.DefaultOwnerCacheTTL, "How long the owner of an object is cached for. Set to 0 to disable the cache.")
+	o.flags.StringVar(&o.SnapshotDir, "snapshot-dir", "", "Directory to save the meter definition store snapshots to. Used to warm start after a restart.")
+	o.flags.StringVar(&o.SnapshotConfigMap, "snapshot-configmap", "", "Name prefix of the ConfigMaps to save the meter definition store snapshots to when --snapshot-dir is not set.")
+	o.flags.StringVar(&o.SnapshotNamespace, "snapshot-namespace", "", "Namespace of the snapshot ConfigMaps.")
+	o.flags.DurationVar(&o.SnapshotInterval, "snapshot-interval", time.Minute, "How often the meter definition store snapshots are saved.")
+	o.flags.DurationVar(&o.StatusUpdateWindow, "status-update-window", meter_definition.DefaultStatusWindow, "How long changes to a meter definition status are coalesced before they are applied.")
+	o.flags.IntVar(&o.StatusSampleSize, "status-sample-size", meter_definition.DefaultStatusSampleSize, "How many matched objects are listed on a meter definition status. All of them are paged from "+workloadsPath+".")
+	o.flags.IntVar(&o.MeterDefinitionSeriesLimit, "meterdef-series-limit", metrics.DefaultMeterDefinitionSeriesLimit, "The number of series a meter definition can generate before new series are dropped. Overridden by the "+metrics.SeriesLimitAnnotation+" annotation. Set to 0 to disable.")
+	o.flags.IntVar(&o.GlobalSeriesLimit, "global-series-limit", metrics.DefaultGlobalSeriesLimit, "The number of meter definition series metric-state generates before new series are dropped. Set to 0 to disable.")
+	o.flags.StringVar(&o.RemoteWriteURL, "remote-write-url", "", "Prometheus remote write endpoint to push the meter definition series to. Push mode is disabled when empty.")
+	o.flags.DurationVar(&o.RemoteWriteInterval, "remote-write-interval", DefaultRemoteWriteInterval, "How often the series are pushed to the remote write endpoint.")
+	o.flags.DurationVar(&o.RemoteWriteTimeout, "remote-write-timeout", 30*time.Second, "Timeout of a remote write request.")
+	o.flags.StringVar(&o.RemoteWriteMatch, "remote-write-match", DefaultRemoteWriteMatch, "Regular expression of the metric names that are pushed to the remote write endpoint.")
+	o.flags.StringToStringVar(&o.RemoteWriteLabels, "remote-write-label", map[string]string{}, "Labels added to every pushed series, for example cluster=<id>.")
+	o.flags.StringVar(&o.RemoteWriteWALDir, "remote-write-wal-dir", DefaultRemoteWriteWALDir, "Directory of the write ahead log that keeps the requests until they are sent.")
+	o.flags.IntVar(&o.RemoteWriteMaxSegments, "remote-write-max-segments", DefaultRemoteWriteSegments, "The number of unsent requests kept in the write ahead log, the oldest are dropped first.")
+	o.flags.StringVar(&o.RemoteWriteBearerTokenFile, "remote-write-bearer-token-file", "", "File with the bearer token for the remote write endpoint.")
+	o.flags.StringVar(&o.RemoteWriteCAFile, "remote-write-ca-file", "", "CA certificate to verify the remote write endpoint.")
+	o.flags.BoolVar(&o.EnableDebugEndpoint, "enable-debug-endpoint", false, "Serve the authenticated meter definition store debug endpoint under "+debugPath+".")
 }
 
 func (o *Options) Mount(addFlags func(newSet *pflag.FlagSet)) {
 	o.AddFlags()
 	addFlags(o.flags)
 }
+
+// RemoteWriteConfig returns the push mode configuration.
+func (o *Options) RemoteWriteConfig() (RemoteWriteConfig, error) {
+	match, err := regexp.Compile(o.RemoteWriteMatch)
+	if err != nil {
+		return RemoteWriteConfig{}, err
+	}
+
+	return RemoteWriteConfig{
+		URL:             o.RemoteWriteURL,
+		Interval:        o.RemoteWriteInterval,
+		Timeout:         o.RemoteWriteTimeout,
+		Match:           match,
+		ExternalLabels:  o.RemoteWriteLabels,
+		WALDir:          o.RemoteWriteWALDir,
+		MaxSegments:     o.RemoteWriteMaxSegments,
+		BearerTokenFile: o.RemoteWriteBearerTokenFile,
+		CAFile:          o.RemoteWriteCAFile,
+	}, nil
+}
@@ -0,0 +1,524 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const (
+	remoteWriteVersion = "0.1.0"
+	walSegmentSuffix   = ".snappy"
+
+	DefaultRemoteWriteInterval = time.Minute
+	DefaultRemoteWriteMatch    = "^meterdef_.*_info$"
+	DefaultRemoteWriteSegments = 1000
+	// DefaultRemoteWriteWALDir is on the volume of the metric-state pod
+	DefaultRemoteWriteWALDir = "/var/lib/metric-state/wal"
+
+	remoteWriteMinBackoff = time.Second
+	remoteWriteMaxBackoff = 5 * time.Minute
+)
+
+// RemoteWriteConfig configures pushing the series of metric-state to a
+// Prometheus remote write endpoint.
+type RemoteWriteConfig struct {
+	URL      string
+	Interval time.Duration
+	Timeout  time.Duration
+	// Match selects the families that are pushed by name
+	Match *regexp.Regexp
+	// ExternalLabels are added to every series
+	ExternalLabels map[string]string
+	// WALDir holds the requests that were not sent yet
+	WALDir string
+	// MaxSegments is the number of requests kept in the WAL, the oldest
+	// are dropped first
+	MaxSegments     int
+	BearerTokenFile string
+	CAFile          string
+}
+
+// remoteWriter periodically gathers the series of the stores and appends
+// them to the WAL as encoded remote write requests. Requests are sent in
+// order and only removed from the WAL once the endpoint accepted them, or
+// rejected them with an unrecoverable error.
+type remoteWriter struct {
+	config RemoteWriteConfig
+	client *http.Client
+	gather func(io.Writer)
+	wal    *writeAheadLog
+	now    func() time.Time
+
+	samples  prometheus.Counter
+	failures *prometheus.CounterVec
+	dropped  prometheus.Counter
+}
+
+func newRemoteWriter(config RemoteWriteConfig, gather func(io.Writer)) (*remoteWriter, error) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultRemoteWriteInterval
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	if config.Match == nil {
+		config.Match = regexp.MustCompile(DefaultRemoteWriteMatch)
+	}
+
+	if config.MaxSegments <= 0 {
+		config.MaxSegments = DefaultRemoteWriteSegments
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.CAFile != "" {
+		ca, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read remote write ca")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("no certificates in %s", config.CAFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	wal, err := openWriteAheadLog(config.WALDir, config.MaxSegments)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &remoteWriter{
+		config: config,
+		client: &http.Client{Transport: transport, Timeout: config.Timeout},
+		gather: gather,
+		wal:    wal,
+		now:    time.Now,
+		samples: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "metric_state_remote_write_samples_total",
+			Help: "Number of samples sent to the remote write endpoint",
+		}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metric_state_remote_write_failures_total",
+			Help: "Number of failed remote write requests",
+		}, []string{"recoverable"}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "metric_state_remote_write_dropped_requests_total",
+			Help: "Number of remote write requests dropped from the WAL",
+		}),
+	}
+
+	wal.onDrop = func() { w.dropped.Inc() }
+	return w, nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (w *remoteWriter) Describe(ch chan<- *prometheus.Desc) {
+	w.samples.Describe(ch)
+	w.failures.Describe(ch)
+	w.dropped.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (w *remoteWriter) Collect(ch chan<- prometheus.Metric) {
+	w.samples.Collect(ch)
+	w.failures.Collect(ch)
+	w.dropped.Collect(ch)
+}
+
+// Start pushes the series every interval until the context is done.
+// Failed requests are retried with a backoff, the ticks in between only
+// append to the WAL.
+func (w *remoteWriter) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	backoff := remoteWriteMinBackoff
+	var retry <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.append(); err != nil {
+				log.Error(err, "failed to append remote write request")
+			}
+
+			// wait for the backoff before sending again
+			if retry != nil {
+				continue
+			}
+		case <-retry:
+		}
+
+		if err := w.flush(ctx); err != nil {
+			log.Error(err, "failed to send remote write request, retrying", "backoff", backoff)
+			retry = time.After(backoff)
+			backoff = backoff * 2
+			if backoff > remoteWriteMaxBackoff {
+				backoff = remoteWriteMaxBackoff
+			}
+			continue
+		}
+
+		backoff = remoteWriteMinBackoff
+		retry = nil
+	}
+}
+
+// append gathers the series and appends them to the WAL.
+func (w *remoteWriter) append() error {
+	req, err := w.request()
+	if err != nil {
+		return err
+	}
+
+	if len(req.Timeseries) == 0 {
+		return nil
+	}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal remote write request")
+	}
+
+	return w.wal.Append(snappy.Encode(nil, data))
+}
+
+// request converts the gathered series that match into a write request.
+func (w *remoteWriter) request() (*prompb.WriteRequest, error) {
+	buf := &bytes.Buffer{}
+	w.gather(buf)
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse metrics")
+	}
+
+	timestamp := w.now().UnixNano() / int64(time.Millisecond)
+	req := &prompb.WriteRequest{}
+
+	for name, family := range families {
+		if !w.config.Match.MatchString(name) {
+			continue
+		}
+
+		for _, m := range family.Metric {
+			value, ok := sampleValue(family.GetType(), m)
+			if !ok {
+				continue
+			}
+
+			req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+				Labels:  w.labels(name, m),
+				Samples: []prompb.Sample{{Value: value, Timestamp: timestamp}},
+			})
+		}
+	}
+
+	sort.Slice(req.Timeseries, func(i, j int) bool {
+		return labelsString(req.Timeseries[i].Labels) < labelsString(req.Timeseries[j].Labels)
+	})
+
+	return req, nil
+}
+
+// labels returns the sorted labels of the series, the labels of the
+// series win over the external labels.
+func (w *remoteWriter) labels(name string, m *dto.Metric) []prompb.Label {
+	set := make(map[string]string, len(m.Label)+len(w.config.ExternalLabels)+1)
+
+	for k, v := range w.config.ExternalLabels {
+		set[k] = v
+	}
+
+	for _, l := range m.Label {
+		set[l.GetName()] = l.GetValue()
+	}
+
+	set["__name__"] = name
+
+	labels := make([]prompb.Label, 0, len(set))
+	for k, v := range set {
+		labels = append(labels, prompb.Label{Name: k, Value: v})
+	}
+
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels
+}
+
+func labelsString(labels []prompb.Label) string {
+	b := strings.Builder{}
+	for _, l := range labels {
+		b.WriteString(l.Name)
+		b.WriteByte('=')
+		b.WriteString(l.Value)
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+func sampleValue(t dto.MetricType, m *dto.Metric) (float64, bool) {
+	switch t {
+	case dto.MetricType_GAUGE:
+		return m.GetGauge().GetValue(), true
+	case dto.MetricType_COUNTER:
+		return m.GetCounter().GetValue(), true
+	case dto.MetricType_UNTYPED:
+		return m.GetUntyped().GetValue(), true
+	}
+
+	return 0, false
+}
+
+// flush sends the requests in the WAL, oldest first.
+func (w *remoteWriter) flush(ctx context.Context) error {
+	for {
+		segment, data, err := w.wal.Oldest()
+		if err != nil {
+			return err
+		}
+
+		if segment == "" {
+			return nil
+		}
+
+		err = w.send(ctx, data)
+
+		if err != nil {
+			var recoverable recoverableError
+			if errors.As(err, &recoverable) {
+				w.failures.WithLabelValues("true").Inc()
+				return err
+			}
+
+			w.failures.WithLabelValues("false").Inc()
+			log.Error(err, "dropping remote write request", "segment", segment)
+		}
+
+		if err := w.wal.Remove(segment); err != nil {
+			return err
+		}
+	}
+}
+
+type recoverableError struct {
+	error
+}
+
+func (w *remoteWriter) send(ctx context.Context, data []byte) error {
+	httpReq, err := http.NewRequest(http.MethodPost, w.config.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Add("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("User-Agent", "metric-state")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", remoteWriteVersion)
+
+	if w.config.BearerTokenFile != "" {
+		token, err := ioutil.ReadFile(w.config.BearerTokenFile)
+		if err != nil {
+			return recoverableError{errors.Wrap(err, "failed to read bearer token")}
+		}
+
+		httpReq.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		w.samples.Add(float64(countSamples(data)))
+		return nil
+	}
+
+	line := ""
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 512))
+	if scanner.Scan() {
+		line = scanner.Text()
+	}
+
+	err = errors.Errorf("server returned HTTP status %s: %s", resp.Status, line)
+
+	// like prometheus, only retry server errors and throttling
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+
+	return err
+}
+
+// writeAheadLog keeps encoded requests as numbered files in a directory
+// so they survive restarts until they are sent.
+type writeAheadLog struct {
+	dir         string
+	maxSegments int
+	next        uint64
+	onDrop      func()
+}
+
+func openWriteAheadLog(dir string, maxSegments int) (*writeAheadLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create wal dir")
+	}
+
+	wal := &writeAheadLog{dir: dir, maxSegments: maxSegments, onDrop: func() {}}
+
+	segments, err := wal.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segments) > 0 {
+		last, err := strconv.ParseUint(strings.TrimSuffix(segments[len(segments)-1], walSegmentSuffix), 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid wal segment")
+		}
+		wal.next = last + 1
+	}
+
+	return wal, nil
+}
+
+// segments returns the segment file names, oldest first.
+func (l *writeAheadLog) segments() ([]string, error) {
+	files, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read wal dir")
+	}
+
+	segments := []string{}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), walSegmentSuffix) {
+			continue
+		}
+		segments = append(segments, f.Name())
+	}
+
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// Append writes the data as the newest segment and drops the oldest
+// segments over the limit.
+func (l *writeAheadLog) Append(data []byte) error {
+	name := fmt.Sprintf("%020d%s", l.next, walSegmentSuffix)
+	tmp := filepath.Join(l.dir, name+".tmp")
+
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write wal segment")
+	}
+
+	if err := os.Rename(tmp, filepath.Join(l.dir, name)); err != nil {
+		return errors.Wrap(err, "failed to write wal segment")
+	}
+
+	l.next = l.next + 1
+
+	segments, err := l.segments()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(segments)-l.maxSegments; i++ {
+		log.Info("wal is full, dropping oldest remote write request", "segment", segments[i])
+		if err := l.Remove(segments[i]); err != nil {
+			return err
+		}
+		l.onDrop()
+	}
+
+	return nil
+}
+
+// Oldest returns the oldest segment and its data, or an empty name if
+// the WAL is empty.
+func (l *writeAheadLog) Oldest() (string, []byte, error) {
+	segments, err := l.segments()
+	if err != nil || len(segments) == 0 {
+		return "", nil, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(l.dir, segments[0]))
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to read wal segment")
+	}
+
+	return segments[0], data, nil
+}
+
+// Remove deletes the segment.
+func (l *writeAheadLog) Remove(segment string) error {
+	err := os.Remove(filepath.Join(l.dir, segment))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove wal segment")
+	}
+	return nil
+}
+
+// countSamples is the number of samples in an encoded request.
+func countSamples(data []byte) int {
+	decoded, err := snappy.Decode(nil, data)
+	if err != nil {
+		return 0
+	}
+
+	req := &prompb.WriteRequest{}
+	if err := proto.Unmarshal(decoded, req); err != nil {
+		return 0
+	}
+
+	samples := 0
+	for _, ts := range req.Timeseries {
+		samples = samples + len(ts.Samples)
+	}
+	return samples
+}
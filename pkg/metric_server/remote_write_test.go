@@ -0,0 +1,233 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_server
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// remoteWriteReceiver is a local remote write endpoint that records the
+// requests it accepted.
+type remoteWriteReceiver struct {
+	sync.Mutex
+	status   int
+	requests []*prompb.WriteRequest
+	headers  []http.Header
+}
+
+func (r *remoteWriteReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.headers = append(r.headers, req.Header.Clone())
+
+	if r.status != http.StatusOK {
+		http.Error(w, "failed", r.status)
+		return
+	}
+
+	compressed, err := ioutil.ReadAll(req.Body)
+	Expect(err).To(Succeed())
+
+	data, err := snappy.Decode(nil, compressed)
+	Expect(err).To(Succeed())
+
+	writeReq := &prompb.WriteRequest{}
+	Expect(proto.Unmarshal(data, writeReq)).To(Succeed())
+
+	r.requests = append(r.requests, writeReq)
+}
+
+func (r *remoteWriteReceiver) setStatus(status int) {
+	r.Lock()
+	defer r.Unlock()
+	r.status = status
+}
+
+func (r *remoteWriteReceiver) received() []*prompb.WriteRequest {
+	r.Lock()
+	defer r.Unlock()
+	return append([]*prompb.WriteRequest{}, r.requests...)
+}
+
+const remoteWriteMetrics = `# HELP meterdef_pod_info Metering info for pod
+# TYPE meterdef_pod_info gauge
+meterdef_pod_info{namespace="ns",pod="pod-1",meter_def_name="mdef-a",meter_def_namespace="ns"} 1
+# HELP meterdef_pod_container_resource_requests_cpu_cores The number of requested cpu cores
+# TYPE meterdef_pod_container_resource_requests_cpu_cores gauge
+meterdef_pod_container_resource_requests_cpu_cores{namespace="ns",pod="pod-1",container="app"} 0.25
+`
+
+var _ = Describe("remoteWriter", func() {
+	var (
+		receiver *remoteWriteReceiver
+		server   *httptest.Server
+		walDir   string
+		writer   *remoteWriter
+		now      time.Time
+		ctx      context.Context
+	)
+
+	BeforeEach(func() {
+		var err error
+		receiver = &remoteWriteReceiver{status: http.StatusOK}
+		server = httptest.NewServer(receiver)
+		ctx = context.TODO()
+		now = time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+		walDir, err = ioutil.TempDir("", "remote-write")
+		Expect(err).To(Succeed())
+
+		writer, err = newRemoteWriter(RemoteWriteConfig{
+			URL:            server.URL,
+			ExternalLabels: map[string]string{"cluster": "c1"},
+			WALDir:         walDir,
+			MaxSegments:    2,
+		}, func(w io.Writer) {
+			io.WriteString(w, remoteWriteMetrics)
+		})
+		Expect(err).To(Succeed())
+		writer.now = func() time.Time { return now }
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(walDir)
+	})
+
+	It("should push the matching series", func() {
+		Expect(writer.append()).To(Succeed())
+		Expect(writer.flush(ctx)).To(Succeed())
+
+		requests := receiver.received()
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Timeseries).To(Equal([]prompb.TimeSeries{
+			{
+				Labels: []prompb.Label{
+					{Name: "__name__", Value: "meterdef_pod_info"},
+					{Name: "cluster", Value: "c1"},
+					{Name: "meter_def_name", Value: "mdef-a"},
+					{Name: "meter_def_namespace", Value: "ns"},
+					{Name: "namespace", Value: "ns"},
+					{Name: "pod", Value: "pod-1"},
+				},
+				Samples: []prompb.Sample{{Value: 1, Timestamp: now.UnixNano() / int64(time.Millisecond)}},
+			},
+		}))
+
+		Expect(receiver.headers[0].Get("Content-Encoding")).To(Equal("snappy"))
+		Expect(receiver.headers[0].Get("Content-Type")).To(Equal("application/x-protobuf"))
+		Expect(receiver.headers[0].Get("X-Prometheus-Remote-Write-Version")).To(Equal(remoteWriteVersion))
+		Expect(testutil.ToFloat64(writer.samples)).To(Equal(1.0))
+
+		segment, _, err := writer.wal.Oldest()
+		Expect(err).To(Succeed())
+		Expect(segment).To(BeEmpty())
+	})
+
+	It("should keep requests in the wal until they are accepted", func() {
+		writer.config.Match = regexp.MustCompile("^meterdef_")
+		receiver.setStatus(http.StatusServiceUnavailable)
+
+		Expect(writer.append()).To(Succeed())
+		Expect(writer.flush(ctx)).ToNot(Succeed())
+		Expect(receiver.received()).To(BeEmpty())
+
+		By("reopening the wal after a restart")
+		reopened, err := newRemoteWriter(writer.config, writer.gather)
+		Expect(err).To(Succeed())
+
+		receiver.setStatus(http.StatusOK)
+		Expect(reopened.flush(ctx)).To(Succeed())
+
+		requests := receiver.received()
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Timeseries).To(HaveLen(2))
+	})
+
+	It("should only append on the ticks during the backoff", func() {
+		receiver.setStatus(http.StatusServiceUnavailable)
+		writer.config.Interval = 10 * time.Millisecond
+
+		startCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			writer.Start(startCtx)
+			close(done)
+		}()
+
+		Eventually(func() float64 {
+			return testutil.ToFloat64(writer.failures.WithLabelValues("true"))
+		}).Should(Equal(1.0))
+		Consistently(func() float64 {
+			return testutil.ToFloat64(writer.failures.WithLabelValues("true"))
+		}, 200*time.Millisecond).Should(Equal(1.0))
+
+		cancel()
+		<-done
+
+		segments, err := writer.wal.segments()
+		Expect(err).To(Succeed())
+		Expect(len(segments)).To(BeNumerically(">", 1))
+	})
+
+	It("should drop requests the endpoint rejects", func() {
+		receiver.setStatus(http.StatusBadRequest)
+
+		Expect(writer.append()).To(Succeed())
+		Expect(writer.flush(ctx)).To(Succeed())
+		Expect(testutil.ToFloat64(writer.failures.WithLabelValues("false"))).To(Equal(1.0))
+
+		segment, _, err := writer.wal.Oldest()
+		Expect(err).To(Succeed())
+		Expect(segment).To(BeEmpty())
+	})
+
+	It("should drop the oldest requests when the wal is full", func() {
+		receiver.setStatus(http.StatusServiceUnavailable)
+
+		for i := 0; i < 3; i++ {
+			now = now.Add(time.Minute)
+			Expect(writer.append()).To(Succeed())
+		}
+
+		segments, err := writer.wal.segments()
+		Expect(err).To(Succeed())
+		Expect(segments).To(HaveLen(2))
+		Expect(testutil.ToFloat64(writer.dropped)).To(Equal(1.0))
+
+		receiver.setStatus(http.StatusOK)
+		Expect(writer.flush(ctx)).To(Succeed())
+
+		requests := receiver.received()
+		Expect(requests).To(HaveLen(2))
+		Expect(requests[0].Timeseries[0].Samples[0].Timestamp).To(BeNumerically("<", requests[1].Timeseries[0].Samples[0].Timestamp))
+	})
+})
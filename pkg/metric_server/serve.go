@@ -17,15 +17,20 @@ package metric_server
 import (
 	"compress/gzip"
 	"context"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/internal/metrics"
 	rhmclient "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/client"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/managers"
@@ -48,6 +53,8 @@ import (
 const (
 	metricsPath = "/metrics"
 	healthzPath = "/healthz"
+
+	serverShutdownTimeout = 10 * time.Second
 )
 
 var log = logf.Log.WithName("meteric_generator")
@@ -57,12 +64,14 @@ type Service struct {
 	k8sclient        client.Client
 	k8sRestClient    clientset.Interface
 	opts             *options.Options
+	rhmOpts          *Options
 	cache            cache.Cache
 	metricsRegistry  *prometheus.Registry
 	cc               reconcileutils.ClientCommandRunner
 	meterDefStore    *meter_definition.MeterDefinitionStoreBuilder
 	statusProcessor  *meter_definition.StatusProcessor
 	serviceProcessor *meter_definition.ServiceProcessor
+	findOwner        *rhmclient.FindOwnerHelper
 	isCacheStarted   managers.CacheIsStarted
 
 	mutex deadlock.Mutex `wire:"-"`
@@ -78,7 +87,39 @@ func (s *Service) Serve(done <-chan struct{}) error {
 
 	proc.StartReaper()
 
+	s.findOwner.SetMaxDepth(s.rhmOpts.OwnerMaxDepth)
+	s.findOwner.SetCacheTTL(s.rhmOpts.OwnerCacheTTL)
+	s.statusProcessor.SetWindow(s.rhmOpts.StatusUpdateWindow)
+	s.statusProcessor.SetSampleSize(s.rhmOpts.StatusSampleSize)
+
 	s.meterDefStore.SetNamespaces(options.DefaultNamespaces)
+	s.meterDefStore.SetContext(ctx)
+
+	sharding, sts, err := resolveSharding(ctx, s.k8sRestClient, s.rhmOpts)
+	if err != nil {
+		log.Error(err, "failed to detect sharding")
+		return err
+	}
+
+	log.Info("sharding", "shard", sharding.Shard, "totalShards", sharding.TotalShards)
+
+	errs := make(chan error, 2)
+
+	if sts != nil {
+		go func() {
+			if err := watchStatefulSetReplicas(ctx, s.k8sRestClient, sts, sharding.TotalShards, statefulSetPollInterval); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	storeBuilder.WithSharding(sharding.Shard, sharding.TotalShards)
+	s.meterDefStore.SetSharding(sharding)
+	s.statusProcessor.SetSharding(sharding)
+
+	if snapshotter := s.snapshotter(sharding); snapshotter != nil {
+		s.meterDefStore.SetSnapshotter(snapshotter, s.rhmOpts.SnapshotInterval)
+	}
 	stores := s.meterDefStore.CreateStores()
 
 	storeBuilder.WithContext(ctx)
@@ -86,14 +127,19 @@ func (s *Service) Serve(done <-chan struct{}) error {
 	storeBuilder.WithClientCommand(s.cc)
 	storeBuilder.WithMeterDefinitionStores(stores)
 
+	limiter := metrics.NewSeriesLimiter(s.rhmOpts.MeterDefinitionSeriesLimit, s.rhmOpts.GlobalSeriesLimit)
+	limiter.SetSharding(sharding)
+	storeBuilder.WithSeriesLimiter(limiter)
+	go limiter.Start(ctx, s.cc, s.rhmOpts.StatusUpdateWindow)
+
 	for expectedType, store := range stores {
 		log.Info("stores", "type", expectedType, "store", store)
 		p := s.statusProcessor.New(store)
 		go func() {
-			err := p.Start(ctx)
-			log.Error(err, "failed to register status processor")
-
-			panic(err)
+			if err := p.Start(ctx); err != nil {
+				log.Error(err, "failed to register status processor")
+				panic(err)
+			}
 		}()
 	}
 
@@ -102,18 +148,116 @@ func (s *Service) Serve(done <-chan struct{}) error {
 	p := s.serviceProcessor.New(store)
 
 	go func() {
-		err := p.Start(ctx)
-		log.Error(err, "failed to register service processor")
-		panic(err)
+		if err := p.Start(ctx); err != nil {
+			log.Error(err, "failed to register service processor")
+			panic(err)
+		}
 	}()
 
+	metricStores := storeBuilder.Build()
+	for _, store := range metricStores {
+		store.Start(ctx)
+	}
+
+	log.Info("built stores")
+
 	s.metricsRegistry.MustRegister(
 		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
 		prometheus.NewGoCollector(),
+		s.findOwner,
+		s.statusProcessor,
+		limiter,
 	)
+
+	if s.rhmOpts.RemoteWriteURL != "" {
+		config, err := s.rhmOpts.RemoteWriteConfig()
+		if err != nil {
+			log.Error(err, "invalid remote write config")
+			return err
+		}
+
+		writer, err := newRemoteWriter(config, func(w io.Writer) {
+			for _, store := range metricStores {
+				store.WriteAll(w)
+			}
+		})
+		if err != nil {
+			log.Error(err, "failed to create remote writer")
+			return err
+		}
+
+		log.Info("pushing series to remote write endpoint", "url", config.URL, "interval", config.Interval)
+		s.metricsRegistry.MustRegister(writer)
+		go writer.Start(ctx)
+	}
 	go telemetryServer(s.metricsRegistry, s.opts.TelemetryHost, s.opts.TelemetryPort)
 
-	serveMetrics(ctx, storeBuilder, s.opts, s.opts.Host, opts.Port, s.opts.EnableGZIPEncoding)
+	var debug http.Handler
+	if s.rhmOpts.EnableDebugEndpoint {
+		debug = newDebugHandler(s.k8sRestClient, stores)
+	}
+
+	workloads := newWorkloadsHandler(s.k8sRestClient, stores)
+
+	server := newMetricsServer(metricStores, s.opts.Host, opts.Port, s.opts.EnableGZIPEncoding, debug, workloads)
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error(err, "failing to listen and serve")
+			errs <- err
+		}
+	}()
+
+	select {
+	case err = <-errs:
+	case <-done:
+	}
+
+	// stopping the stores saves their last snapshot
+	log.Info("shutting down", "reason", err)
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer shutdownCancel()
+
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+		log.Error(shutdownErr, "failed to shut down metrics server")
+	}
+
+	s.meterDefStore.WaitForSnapshots()
+	return err
+}
+
+// snapshotter returns where the meter definition stores are saved, a
+// directory is preferred over ConfigMaps. Each shard has its own
+// snapshot.
+func (s *Service) snapshotter(sharding meter_definition.Sharding) meter_definition.Snapshotter {
+	suffix := ""
+	if sharding.Enabled() {
+		suffix = fmt.Sprintf("-%d", sharding.Shard)
+	}
+
+	switch {
+	case s.rhmOpts.SnapshotDir != "":
+		dir := s.rhmOpts.SnapshotDir
+		if sharding.Enabled() {
+			dir = filepath.Join(dir, "shard"+suffix)
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Error(err, "failed to create snapshot dir", "dir", dir)
+			return nil
+		}
+
+		return &meter_definition.FileSnapshotter{Dir: dir}
+	case s.rhmOpts.SnapshotConfigMap != "" && s.rhmOpts.SnapshotNamespace != "":
+		return &meter_definition.ConfigMapSnapshotter{
+			KubeClient: s.k8sRestClient,
+			Namespace:  s.rhmOpts.SnapshotNamespace,
+			Name:       s.rhmOpts.SnapshotConfigMap + suffix,
+		}
+	}
+
 	return nil
 }
 
@@ -202,24 +346,25 @@ func telemetryServer(registry prometheus.Gatherer, host string, port int) {
 	}
 }
 
-func serveMetrics(ctx context.Context, storeBuilder *metrics.Builder, opts *options.Options, host string, port int, enableGZIPEncoding bool) {
+func newMetricsServer(stores []*metrics.MetricsStore, host string, port int, enableGZIPEncoding bool, debug, workloads http.Handler) *http.Server {
 	// Address to listen on for web interface and telemetry
 	listenAddress := net.JoinHostPort(host, strconv.Itoa(port))
 
 	log.Info("Starting metrics server", "listenAddress", listenAddress)
 
 	mux := http.NewServeMux()
-	stores := storeBuilder.Build()
-
-	for _, store := range stores {
-		store.Start(ctx)
-	}
-
-	log.Info("built stores")
 
 	m := &metricHandler{stores, enableGZIPEncoding}
 	mux.Handle(metricsPath, m)
 
+	mux.Handle(workloadsPath, workloads)
+
+	debugLink := ""
+	if debug != nil {
+		mux.Handle(debugPath+"/", debug)
+		debugLink = `<li><a href='` + debugPath + `/stores'>debug</a></li>`
+	}
+
 	// Add healthzPath
 	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -234,15 +379,13 @@ func serveMetrics(ctx context.Context, storeBuilder *metrics.Builder, opts *opti
 			 <ul>
              <li><a href='` + metricsPath + `'>metrics</a></li>
              <li><a href='` + healthzPath + `'>healthz</a></li>
+             ` + debugLink + `
 			 </ul>
              </body>
              </html>`))
 	})
-	err := http.ListenAndServe(listenAddress, mux)
-	if err != nil {
-		log.Error(err, "failing to listen and serve")
-		panic(err)
-	}
+
+	return &http.Server{Addr: listenAddress, Handler: mux}
 }
 
 type metricHandler struct {
@@ -254,9 +397,14 @@ func (m *metricHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	resHeader := w.Header()
 	var writer io.Writer = w
 
-	// Set the exposition format version of Prometheus.
+	// Serve OpenMetrics if the scraper asks for it, the Prometheus text
+	// format otherwise.
 	// https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format
-	resHeader.Set("Content-Type", `text/plain; version=`+"0.0.4")
+	format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+	if format != expfmt.FmtOpenMetrics {
+		format = expfmt.FmtText
+	}
+	resHeader.Set("Content-Type", string(format))
 
 	if m.enableGZIPEncoding {
 		// Gzip response if requested. Taken from
@@ -272,8 +420,12 @@ func (m *metricHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	for _, c := range m.stores {
-		c.WriteAll(w)
+	if format == expfmt.FmtOpenMetrics {
+		metrics.WriteOpenMetrics(writer, m.stores)
+	} else {
+		for _, c := range m.stores {
+			c.WriteAll(writer)
+		}
 	}
 
 	// In case we gzipped the response, we have to close the writer.
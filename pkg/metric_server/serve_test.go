@@ -0,0 +1,141 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/expfmt"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/internal/metrics"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kbsm "k8s.io/kube-state-metrics/pkg/metric"
+)
+
+type allMeterDefinitions []*v1alpha1.MeterDefinition
+
+func (f allMeterDefinitions) GetMeterDefinitions(obj interface{}) ([]*v1alpha1.MeterDefinition, error) {
+	return f, nil
+}
+
+var _ = Describe("metricHandler", func() {
+	const (
+		textBody        = "# HELP meterdef_pod_info Metering info for pod\n# TYPE meterdef_pod_info gauge\nmeterdef_pod_info 1\n"
+		openMetricsBody = textBody + "# EOF\n"
+	)
+
+	var (
+		handler *metricHandler
+	)
+
+	BeforeEach(func() {
+		families := []metrics.FamilyGenerator{
+			{
+				FamilyGenerator: kbsm.FamilyGenerator{
+					Name: "meterdef_pod_info",
+					Type: kbsm.Gauge,
+					Help: "Metering info for pod",
+				},
+				GenerateMeterFunc: func(obj interface{}, mdefs []*v1alpha1.MeterDefinition) *kbsm.Family {
+					return &kbsm.Family{Metrics: []*kbsm.Metric{{Value: 1}}}
+				},
+			},
+		}
+
+		store := metrics.NewMetricsStore(
+			metrics.ExtractMetricFamilyHeaders(families),
+			metrics.ComposeMetricGenFuncs(families),
+			nil,
+			allMeterDefinitions{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "mdef", Namespace: "ns"},
+					Spec:       v1alpha1.MeterDefinitionSpec{Group: "app.com", Kind: "App"},
+				},
+			},
+			reflect.TypeOf(&corev1.Pod{}),
+		)
+		Expect(store.Add(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns", UID: "pod-uid"},
+		})).To(Succeed())
+
+		handler = &metricHandler{
+			stores:             []*metrics.MetricsStore{store},
+			enableGZIPEncoding: true,
+		}
+	})
+
+	serve := func(accept, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if encoding != "" {
+			req.Header.Set("Accept-Encoding", encoding)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	It("should serve the text format without an accept header", func() {
+		rec := serve("", "")
+		Expect(rec.Header().Get("Content-Type")).To(Equal(string(expfmt.FmtText)))
+		Expect(rec.Body.String()).To(Equal(textBody))
+	})
+
+	It("should serve the text format when it's asked for", func() {
+		rec := serve("text/plain;version=0.0.4", "")
+		Expect(rec.Header().Get("Content-Type")).To(Equal(string(expfmt.FmtText)))
+		Expect(rec.Body.String()).To(Equal(textBody))
+	})
+
+	It("should serve the text format instead of protobuf", func() {
+		rec := serve("application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited", "")
+		Expect(rec.Header().Get("Content-Type")).To(Equal(string(expfmt.FmtText)))
+		Expect(rec.Body.String()).To(Equal(textBody))
+	})
+
+	It("should serve OpenMetrics when it's asked for", func() {
+		rec := serve("application/openmetrics-text;version=0.0.1", "")
+		Expect(rec.Header().Get("Content-Type")).To(Equal(string(expfmt.FmtOpenMetrics)))
+		Expect(rec.Body.String()).To(Equal(openMetricsBody))
+	})
+
+	It("should serve OpenMetrics when it's preferred by the scraper", func() {
+		rec := serve("application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1", "")
+		Expect(rec.Header().Get("Content-Type")).To(Equal(string(expfmt.FmtOpenMetrics)))
+		Expect(rec.Body.String()).To(Equal(openMetricsBody))
+	})
+
+	It("should gzip the response when it's accepted", func() {
+		rec := serve("application/openmetrics-text;version=0.0.1", "gzip")
+		Expect(rec.Header().Get("Content-Encoding")).To(Equal("gzip"))
+
+		reader, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		Expect(err).To(Succeed())
+		body, err := ioutil.ReadAll(reader)
+		Expect(err).To(Succeed())
+		Expect(string(body)).To(Equal(openMetricsBody))
+	})
+})
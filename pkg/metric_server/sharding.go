@@ -0,0 +1,118 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_server
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const statefulSetPollInterval = 30 * time.Second
+
+// ErrResharded is returned by the server when the StatefulSet was scaled.
+// The replica exits so it restarts with the new number of shards.
+const ErrResharded = errors.Sentinel("statefulset scaled, restarting to reshard")
+
+// resolveSharding returns the shard of this replica. When the pod is
+// known and belongs to a StatefulSet, the shard is the pod ordinal and
+// the total is the StatefulSet replicas. Otherwise --shard and
+// --total-shards are used.
+func resolveSharding(
+	ctx context.Context,
+	kubeClient clientset.Interface,
+	opts *Options,
+) (meter_definition.Sharding, *appsv1.StatefulSet, error) {
+	static := meter_definition.Sharding{
+		Shard:       opts.Shard,
+		TotalShards: opts.TotalShards,
+	}
+
+	if opts.Pod == "" || opts.Namespace == "" {
+		return static, nil, nil
+	}
+
+	pod, err := kubeClient.CoreV1().Pods(opts.Namespace).Get(ctx, opts.Pod, metav1.GetOptions{})
+	if err != nil {
+		return static, nil, errors.Wrap(err, "failed to get pod")
+	}
+
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "StatefulSet" {
+		log.Info("pod is not part of a statefulset, using static sharding", "shard", static.Shard, "totalShards", static.TotalShards)
+		return static, nil, nil
+	}
+
+	sts, err := kubeClient.AppsV1().StatefulSets(opts.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	if err != nil {
+		return static, nil, errors.Wrap(err, "failed to get statefulset")
+	}
+
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(pod.Name, sts.Name+"-"))
+	if err != nil {
+		return static, nil, errors.Wrapf(err, "failed to find ordinal of pod %s", pod.Name)
+	}
+
+	replicas := 1
+	if sts.Spec.Replicas != nil {
+		replicas = int(*sts.Spec.Replicas)
+	}
+
+	return meter_definition.Sharding{
+		Shard:       int32(ordinal),
+		TotalShards: replicas,
+	}, sts, nil
+}
+
+// watchStatefulSetReplicas returns ErrResharded when the StatefulSet is
+// scaled so the replica restarts with the new number of shards. The
+// stores are warm started from their snapshots so the restart is cheap.
+// Returns nil when the context is done.
+func watchStatefulSetReplicas(
+	ctx context.Context,
+	kubeClient clientset.Interface,
+	sts *appsv1.StatefulSet,
+	totalShards int,
+	interval time.Duration,
+) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			current, err := kubeClient.AppsV1().StatefulSets(sts.Namespace).Get(ctx, sts.Name, metav1.GetOptions{})
+			if err != nil {
+				log.Error(err, "failed to get statefulset")
+				continue
+			}
+
+			if current.Spec.Replicas != nil && int(*current.Spec.Replicas) != totalShards {
+				log.Info("statefulset scaled, restarting to reshard",
+					"totalShards", totalShards, "replicas", *current.Spec.Replicas)
+				return ErrResharded
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_server
+
+import (
+	"context"
+	"time"
+
+	"github.com/gotidy/ptr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Sharding", func() {
+	const namespace = "openshift-redhat-marketplace"
+
+	var (
+		kubeClient *fake.Clientset
+		sts        *appsv1.StatefulSet
+	)
+
+	BeforeEach(func() {
+		sts = &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rhm-metric-state",
+				Namespace: namespace,
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: ptr.Int32(3),
+			},
+		}
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rhm-metric-state-2",
+				Namespace: namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: "apps/v1",
+						Kind:       "StatefulSet",
+						Name:       sts.Name,
+						Controller: ptr.Bool(true),
+					},
+				},
+			},
+		}
+
+		kubeClient = fake.NewSimpleClientset(sts, pod)
+	})
+
+	It("should use the ordinal of the statefulset pod as the shard", func() {
+		sharding, found, err := resolveSharding(context.TODO(), kubeClient, &Options{
+			Pod:       "rhm-metric-state-2",
+			Namespace: namespace,
+		})
+		Expect(err).To(Succeed())
+		Expect(found.Name).To(Equal(sts.Name))
+		Expect(sharding.Shard).To(Equal(int32(2)))
+		Expect(sharding.TotalShards).To(Equal(3))
+	})
+
+	It("should return an error when the statefulset is scaled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- watchStatefulSetReplicas(ctx, kubeClient, sts, 3, 10*time.Millisecond)
+		}()
+
+		Consistently(errs, 50*time.Millisecond).ShouldNot(Receive())
+
+		scaled := sts.DeepCopy()
+		scaled.Spec.Replicas = ptr.Int32(4)
+		_, err := kubeClient.AppsV1().StatefulSets(namespace).Update(context.TODO(), scaled, metav1.UpdateOptions{})
+		Expect(err).To(Succeed())
+
+		Eventually(errs).Should(Receive(Equal(ErrResharded)))
+	})
+
+	It("should stop watching when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Expect(watchStatefulSetReplicas(ctx, kubeClient, sts, 3, time.Hour)).To(Succeed())
+	})
+})
@@ -75,7 +75,7 @@ func NewServer(opts *Options) (*Service, error) {
 	if err != nil {
 		return nil, err
 	}
-	meterDefinitionStoreBuilder := meter_definition.NewMeterDefinitionStoreBuilder(context, logger, clientCommandRunner, clientset, findOwnerHelper, monitoringV1Client, marketplaceV1alpha1Client, scheme)
+	meterDefinitionStoreBuilder := meter_definition.NewMeterDefinitionStoreBuilder(context, logger, clientCommandRunner, clientset, dynamicInterface, findOwnerHelper, monitoringV1Client, marketplaceV1alpha1Client, scheme)
 	statusProcessor := meter_definition.NewStatusProcessor(logger, clientCommandRunner)
 	serviceProcessor := meter_definition.NewServiceProcessor(logger, clientCommandRunner)
 	cacheIsIndexed, err := addIndex(context, cache)
@@ -87,12 +87,14 @@ func NewServer(opts *Options) (*Service, error) {
 		k8sclient:        clientClient,
 		k8sRestClient:    clientset,
 		opts:             options,
+		rhmOpts:          opts,
 		cache:            cache,
 		metricsRegistry:  registry,
 		cc:               clientCommandRunner,
 		meterDefStore:    meterDefinitionStoreBuilder,
 		statusProcessor:  statusProcessor,
 		serviceProcessor: serviceProcessor,
+		findOwner:        findOwnerHelper,
 		isCacheStarted:   cacheIsStarted,
 	}
 	return service, nil
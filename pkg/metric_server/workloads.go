@@ -0,0 +1,104 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/meter_definition"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	workloadsPath = "/meterdefinitions/workloads"
+
+	defaultWorkloadsLimit = 500
+	maxWorkloadsLimit     = 5000
+)
+
+// WorkloadResourceList is a page of the resources matched by a meter
+// definition. Continue is set when there are more pages.
+type WorkloadResourceList struct {
+	Items    []v1alpha1.WorkloadResource `json:"items"`
+	Total    int                         `json:"total"`
+	Continue string                      `json:"continue,omitempty"`
+}
+
+// workloadsHandler pages the resources matched by a meter definition
+// since the meter definition status only holds a sample.
+//
+//	/meterdefinitions/workloads?uid=<meterdef uid>&limit=500&continue=
+type workloadsHandler struct {
+	stores []*meter_definition.MeterDefinitionStore
+}
+
+func newWorkloadsHandler(
+	kubeClient clientset.Interface,
+	stores meter_definition.MeterDefinitionStores,
+) http.Handler {
+	h := &workloadsHandler{}
+
+	for _, store := range stores {
+		h.stores = append(h.stores, store)
+	}
+
+	return &authHandler{
+		kubeClient: kubeClient,
+		next:       h,
+	}
+}
+
+func (h *workloadsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	uid := query.Get("uid")
+
+	if uid == "" {
+		http.Error(w, "uid of the meter definition is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultWorkloadsLimit
+
+	if query.Get("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if limit > maxWorkloadsLimit {
+		limit = maxWorkloadsLimit
+	}
+
+	resources := meter_definition.WorkloadResources(types.UID(uid), h.stores...)
+	page, next, err := meter_definition.PageWorkloadResources(resources, limit, query.Get("continue"))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, &WorkloadResourceList{
+		Items:    page,
+		Total:    len(resources),
+		Continue: next,
+	})
+}
@@ -12,17 +12,20 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package reporter
+package prometheus
 
 import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 
 	"emperror.dev/errors"
 	"github.com/prometheus/client_golang/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type PrometheusSecureClientConfig struct {
@@ -33,6 +36,9 @@ type PrometheusSecureClientConfig struct {
 	UserAuth *UserAuth
 
 	ServerCertFile string
+
+	// ServerCertData is PEM encoded CA data trusted along with ServerCertFile.
+	ServerCertData []byte
 }
 
 type UserAuth struct {
@@ -40,12 +46,21 @@ type UserAuth struct {
 }
 
 func NewSecureClient(config *PrometheusSecureClientConfig) (api.Client, error) {
-	tlsConfig, err := generateCACertPool(config.ServerCertFile)
+	files := []string{}
+	if config.ServerCertFile != "" {
+		files = append(files, config.ServerCertFile)
+	}
+
+	tlsConfig, err := GenerateCACertPool(files...)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get tlsConfig")
 	}
 
+	if len(config.ServerCertData) > 0 {
+		tlsConfig.RootCAs.AppendCertsFromPEM(config.ServerCertData)
+	}
+
 	var transport http.RoundTripper
 
 	transport = &http.Transport{
@@ -68,7 +83,60 @@ func NewSecureClient(config *PrometheusSecureClientConfig) (api.Client, error) {
 	return client, err
 }
 
-func generateCACertPool(files ...string) (*tls.Config, error) {
+// NewSecureClientForService creates a client for the prometheus behind the
+// service using the port that matches targetPort. The token file is read
+// on every call so rotated service account tokens are picked up.
+func NewSecureClientForService(
+	service *corev1.Service,
+	targetPort intstr.IntOrString,
+	caFile, tokenFile string,
+) (api.Client, error) {
+	return NewSecureClientForServiceAuth(service, targetPort, nil, caFile, tokenFile)
+}
+
+// NewSecureClientForServiceAuth is NewSecureClientForService with the token
+// and CA of auth. The token file is only read if auth has no token.
+func NewSecureClientForServiceAuth(
+	service *corev1.Service,
+	targetPort intstr.IntOrString,
+	auth *ServiceAuth,
+	caFile, tokenFile string,
+) (api.Client, error) {
+	var port int32
+
+	switch {
+	case targetPort.Type == intstr.Int:
+		port = targetPort.IntVal
+	default:
+		for _, p := range service.Spec.Ports {
+			if p.Name == targetPort.StrVal {
+				port = p.Port
+			}
+		}
+	}
+
+	if auth == nil {
+		auth = &ServiceAuth{}
+	}
+
+	token := auth.Token
+	if token == "" && tokenFile != "" {
+		content, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = string(content)
+	}
+
+	return NewSecureClient(&PrometheusSecureClientConfig{
+		Address:        fmt.Sprintf("https://%s.%s.svc:%v", service.Name, service.Namespace, port),
+		ServerCertFile: caFile,
+		ServerCertData: auth.CAData,
+		Token:          token,
+	})
+}
+
+func GenerateCACertPool(files ...string) (*tls.Config, error) {
 	caCertPool, err := x509.SystemCertPool()
 
 	if err != nil {
@@ -0,0 +1,131 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// BuiltInPodMeters maps the meter labels that can be used on pod workloads
+// without a query to the metric-state families that back them.
+var BuiltInPodMeters = map[string]string{
+	"cpu_request_cores":    "meterdef_pod_container_resource_requests_cpu_cores",
+	"cpu_limit_cores":      "meterdef_pod_container_resource_limits_cpu_cores",
+	"memory_request_bytes": "meterdef_pod_container_resource_requests_memory_bytes",
+	"memory_limit_bytes":   "meterdef_pod_container_resource_limits_memory_bytes",
+}
+
+// BuiltInPersistentVolumeClaimMeters maps the meter labels that can be used
+// on pvc workloads without a query to the metric-state families that back them.
+var BuiltInPersistentVolumeClaimMeters = map[string]string{
+	"storage_request_bytes":  "meterdef_persistentvolumeclaim_resource_requests_storage_bytes",
+	"storage_capacity_bytes": "meterdef_persistentvolumeclaim_capacity_bytes",
+	"volume_capacity_bytes":  "meterdef_persistentvolume_capacity_bytes",
+}
+
+type PromQuery struct {
+	Type          v1alpha1.WorkloadType
+	MeterDef      types.NamespacedName
+	Metric        string
+	Query         string
+	Start, End    time.Time
+	Step          time.Duration
+	Time          string
+	AggregateFunc string
+	AggregateBy   []string
+}
+
+func (q *PromQuery) makeLeftSide() string {
+	switch q.Type {
+	case v1alpha1.WorkloadTypePVC:
+		return fmt.Sprintf(`avg(meterdef_persistentvolumeclaim_info{meter_def_name="%v",meter_def_namespace="%v",phase="Bound"}) without (instance, container, endpoint, job, service)`, q.MeterDef.Name, q.MeterDef.Namespace)
+	case v1alpha1.WorkloadTypePod:
+		return fmt.Sprintf(`avg(meterdef_pod_info{meter_def_name="%v",meter_def_namespace="%v"}) without (pod_uid, instance, container, endpoint, job, service)`, q.MeterDef.Name, q.MeterDef.Namespace)
+	case v1alpha1.WorkloadTypeService:
+		// Service and service monitor are handled the same
+		fallthrough
+	case v1alpha1.WorkloadTypeServiceMonitor:
+		return fmt.Sprintf(`avg(meterdef_service_info{meter_def_name="%v",meter_def_namespace="%v"}) without (pod_uid, instance, container, endpoint, job, pod)`, q.MeterDef.Name, q.MeterDef.Namespace)
+	default:
+		return "NOTSUPPORTED"
+	}
+}
+
+func (q *PromQuery) makeJoin() string {
+	switch q.Type {
+	case v1alpha1.WorkloadTypePVC:
+		return "* on(persistentvolumeclaim,namespace) group_right"
+	case v1alpha1.WorkloadTypePod:
+		return "* on(pod,namespace) group_right"
+	case v1alpha1.WorkloadTypeService:
+		fallthrough
+	case v1alpha1.WorkloadTypeServiceMonitor:
+		return "* on(service,namespace) group_right"
+	default:
+		return "NOTSUPPORTED"
+	}
+}
+
+func (q *PromQuery) makeAggregateBy() string {
+	switch q.Type {
+	case v1alpha1.WorkloadTypePVC:
+		return fmt.Sprintf(`%v by (persistentvolumeclaim,namespace)`, q.AggregateFunc)
+	case v1alpha1.WorkloadTypePod:
+		return fmt.Sprintf(`%v by (pod,namespace)`, q.AggregateFunc)
+	case v1alpha1.WorkloadTypeService:
+		fallthrough
+	case v1alpha1.WorkloadTypeServiceMonitor:
+		return fmt.Sprintf(`%v by (service,namespace)`, q.AggregateFunc)
+	default:
+		return "NOTSUPPORTED"
+	}
+}
+
+func (q *PromQuery) String() string {
+	aggregate := q.makeAggregateBy()
+	leftSide := q.makeLeftSide()
+	join := q.makeJoin()
+
+	var query string
+	if q.Query != "" {
+		query = q.Query
+	} else if family, ok := q.builtInMeter(); ok {
+		query = fmt.Sprintf(`%s{meter_def_name="%v",meter_def_namespace="%v"}`, family, q.MeterDef.Name, q.MeterDef.Namespace)
+	} else {
+		query = fmt.Sprintf("%s{}", q.Metric)
+	}
+
+	return fmt.Sprintf(
+		`%v (%v %v %v)`, aggregate, leftSide, join, query,
+	)
+}
+
+func (q *PromQuery) builtInMeter() (string, bool) {
+	var family string
+	var ok bool
+
+	switch q.Type {
+	case v1alpha1.WorkloadTypePod:
+		family, ok = BuiltInPodMeters[q.Metric]
+	case v1alpha1.WorkloadTypePVC:
+		family, ok = BuiltInPersistentVolumeClaimMeters[q.Metric]
+	}
+
+	return family, ok
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ServiceAuth is the bearer token and CA read from the secrets of a
+// service reference.
+type ServiceAuth struct {
+	Token  string
+	CAData []byte
+}
+
+// LoadServiceAuth reads the bearer token secret and the tls CA of ref from
+// the namespace. Fields that aren't set on ref are left empty.
+func LoadServiceAuth(
+	ctx context.Context,
+	c client.Reader,
+	namespace string,
+	ref *common.ServiceReference,
+) (*ServiceAuth, error) {
+	auth := &ServiceAuth{}
+
+	if ref == nil {
+		return auth, nil
+	}
+
+	if ref.BearerTokenSecret.Name != "" {
+		token, err := secretKey(ctx, c, namespace, &ref.BearerTokenSecret)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read bearer token")
+		}
+		auth.Token = string(token)
+	}
+
+	if ref.TLSConfig == nil {
+		return auth, nil
+	}
+
+	switch ca := ref.TLSConfig.CA; {
+	case ca.Secret != nil:
+		data, err := secretKey(ctx, c, namespace, ca.Secret)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read ca")
+		}
+		auth.CAData = data
+	case ca.ConfigMap != nil:
+		cm := &corev1.ConfigMap{}
+		key := types.NamespacedName{Name: ca.ConfigMap.Name, Namespace: namespace}
+		if err := c.Get(ctx, key, cm); err != nil {
+			return nil, errors.Wrap(err, "failed to read ca")
+		}
+		data, ok := cm.Data[ca.ConfigMap.Key]
+		if !ok {
+			return nil, errors.Errorf("key %s not found in configmap %s", ca.ConfigMap.Key, key)
+		}
+		auth.CAData = []byte(data)
+	}
+
+	return auth, nil
+}
+
+func secretKey(
+	ctx context.Context,
+	c client.Reader,
+	namespace string,
+	selector *corev1.SecretKeySelector,
+) ([]byte, error) {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: selector.Name, Namespace: namespace}
+
+	if err := c.Get(ctx, key, secret); err != nil {
+		return nil, err
+	}
+
+	data, ok := secret.Data[selector.Key]
+	if !ok {
+		return nil, errors.Errorf("key %s not found in secret %s", selector.Key, key)
+	}
+
+	return data, nil
+}
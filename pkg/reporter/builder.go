@@ -52,6 +52,13 @@ type ReportMetadata struct {
 	Source         uuid.UUID                            `json:"source"`
 	SourceMetadata ReportSourceMetadata                 `json:"source_metadata"`
 	ReportSlices   map[ReportSliceKey]ReportSlicesValue `json:"report_slices"`
+	Correction     *ReportCorrection                    `json:"correction,omitempty"`
+}
+
+// ReportCorrection marks a report that replaces the metrics of a period
+// that may already have been reported.
+type ReportCorrection struct {
+	BackfillID string `json:"backfill_id"`
 }
 
 type ReportSourceMetadata struct {
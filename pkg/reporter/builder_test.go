@@ -69,6 +69,24 @@ var _ = Describe("Builder", func() {
 		u := ReportMetadata{}
 		Expect(json.Unmarshal(data, &u)).To(Succeed())
 		Expect(u.SourceMetadata.RhmEnvironment).To(Equal(ReportSandboxEnv))
+		Expect(string(data)).ToNot(ContainSubstring("correction"))
+	})
+
+	It("should mark corrections in the metadata", func() {
+		metadata := NewReportMetadata(uuid.New(), ReportSourceMetadata{
+			RhmClusterID: "testCluster",
+			RhmAccountID: "testAccount",
+		})
+		metadata.Correction = &ReportCorrection{BackfillID: "fix-1"}
+
+		data, err := json.Marshal(metadata)
+		Expect(err).To(Succeed())
+
+		u := map[string]interface{}{}
+		Expect(json.Unmarshal(data, &u)).To(Succeed())
+		Expect(u).To(HaveKeyWithValue("correction", map[string]interface{}{
+			"backfill_id": "fix-1",
+		}))
 	})
 
 	It("should add metrics to a base", func() {
@@ -36,6 +36,12 @@ type Config struct {
 	Local           bool
 	Upload          bool
 	UploaderTarget
+
+	// ClusterIDSecret and UploadSecret name secrets in the report namespace
+	// with the cluster id and upload token. They replace the OpenShift
+	// cluster version and pull secret on other clusters.
+	ClusterIDSecret string
+	UploadSecret    string
 }
 
 const (
@@ -18,11 +18,13 @@ import (
 	"time"
 
 	"github.com/prometheus/common/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
 )
 
 var _ = Describe("Query", func() {
@@ -32,11 +34,11 @@ var _ = Describe("Query", func() {
 		start, _ = time.Parse(time.RFC3339, "2020-04-19T13:00:00Z")
 		end, _   = time.Parse(time.RFC3339, "2020-04-19T16:00:00Z")
 
-		rpcDurationSecondsQuery *PromQuery
+		rpcDurationSecondsQuery *prom.PromQuery
 	)
 
 	BeforeEach(func() {
-		rpcDurationSecondsQuery = &PromQuery{
+		rpcDurationSecondsQuery = &prom.PromQuery{
 			Metric: "rpc_durations_seconds_count",
 			Query:  `foo{bar="true"}`,
 			Start:  start,
@@ -64,7 +66,7 @@ var _ = Describe("Query", func() {
 	})
 
 	It("should build a query", func() {
-		q1 := &PromQuery{
+		q1 := &prom.PromQuery{
 			Metric: "foo",
 			Query:  "kube_persistentvolumeclaim_resource_requests_storage_bytes",
 			MeterDef: types.NamespacedName{
@@ -81,7 +83,7 @@ var _ = Describe("Query", func() {
 
 	PIt("should build a query", func() {
 		By("building a query with no args")
-		q1 := &PromQuery{
+		q1 := &prom.PromQuery{
 			Metric: "foo",
 		}
 
@@ -96,3 +98,75 @@ var _ = Describe("Query", func() {
 		}
 	})
 })
+
+var _ = Describe("meterDefWindows", func() {
+	var (
+		start, _   = time.Parse(time.RFC3339, "2020-04-19T00:00:00Z")
+		end, _     = time.Parse(time.RFC3339, "2020-04-20T00:00:00Z")
+		changed, _ = time.Parse(time.RFC3339, "2020-04-19T10:30:00Z")
+
+		meterdef *v1alpha1.MeterDefinition
+	)
+
+	BeforeEach(func() {
+		meterdef = &v1alpha1.MeterDefinition{
+			Spec: v1alpha1.MeterDefinitionSpec{
+				Group: "apps.partner.metering.com",
+				Kind:  "App",
+				Workloads: []v1alpha1.Workload{
+					{Name: "v2"},
+				},
+			},
+		}
+	})
+
+	It("should use the spec without revisions", func() {
+		windows := meterDefWindows(meterdef, start, end)
+
+		Expect(windows).To(HaveLen(1))
+		Expect(windows[0].Start).To(Equal(start))
+		Expect(windows[0].End).To(Equal(end))
+		Expect(windows[0].Version).To(BeEmpty())
+	})
+
+	It("should split the report by revision", func() {
+		meterdef.Status.Revisions = []v1alpha1.MeterDefinitionRevision{
+			{
+				Revision:      1,
+				Version:       "1",
+				EffectiveFrom: metav1.NewTime(start.AddDate(0, 0, -1)),
+				EffectiveTo:   &metav1.Time{Time: changed},
+				Workloads:     []v1alpha1.Workload{{Name: "v1"}},
+			},
+			{
+				Revision:      2,
+				Version:       "2",
+				EffectiveFrom: metav1.NewTime(changed),
+				Workloads:     []v1alpha1.Workload{{Name: "v2"}},
+			},
+		}
+
+		windows := meterDefWindows(meterdef, start, end)
+
+		Expect(windows).To(HaveLen(2))
+		Expect(windows[0].Version).To(Equal("1"))
+		Expect(windows[0].Start).To(Equal(start))
+		Expect(windows[0].End).To(Equal(start.Add(10 * time.Hour)))
+		Expect(windows[0].Spec.Workloads[0].Name).To(Equal("v1"))
+		Expect(windows[1].Version).To(Equal("2"))
+		Expect(windows[1].Start).To(Equal(start.Add(11 * time.Hour)))
+		Expect(windows[1].End).To(Equal(end))
+		Expect(windows[1].Spec.Workloads[0].Name).To(Equal("v2"))
+	})
+
+	It("should skip revisions outside of the report", func() {
+		meterdef.Status.Revisions = []v1alpha1.MeterDefinitionRevision{
+			{
+				Revision:      1,
+				EffectiveFrom: metav1.NewTime(end.Add(time.Hour)),
+			},
+		}
+
+		Expect(meterDefWindows(meterdef, start, end)).To(BeEmpty())
+	})
+})
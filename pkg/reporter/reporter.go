@@ -32,6 +32,7 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
 	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/version"
 	corev1 "k8s.io/api/core/v1"
@@ -143,8 +144,8 @@ func (r *MarketplaceReporter) CollectMetrics(ctxIn context.Context) (map[MetricK
 		errorsChan)
 
 	// send & close data pipe
-	for _, meterDef := range r.meterDefinitions {
-		meterDefsChan <- &meterDef
+	for i := range r.meterDefinitions {
+		meterDefsChan <- &r.meterDefinitions[i]
 	}
 	close(meterDefsChan)
 
@@ -182,6 +183,94 @@ type meterDefPromModel struct {
 	MetricName string
 	Type       v1alpha1.WorkloadType
 	Workload   v1alpha1.Workload
+	Version    string
+}
+
+// meterDefWindow is the revision of a meter definition to use for
+// a range of the report.
+type meterDefWindow struct {
+	*marketplacev1alpha1.MeterDefinition
+	Version    string
+	Start, End time.Time
+}
+
+// meterDefWindows returns the revisions of the meter definition that were
+// effective between start and end. Each hour is reported with the revision
+// effective at the start of the hour. Meter definitions without revisions
+// use the spec for the whole range.
+func meterDefWindows(
+	mdef *marketplacev1alpha1.MeterDefinition,
+	start, end time.Time,
+) []meterDefWindow {
+	revisions := mdef.Status.Revisions
+
+	if len(revisions) == 0 {
+		version := ""
+		if mdef.Spec.Version != nil {
+			version = *mdef.Spec.Version
+		}
+
+		revisions = []v1alpha1.MeterDefinitionRevision{
+			{
+				Version:   version,
+				Group:     mdef.Spec.Group,
+				Kind:      mdef.Spec.Kind,
+				Workloads: mdef.Spec.Workloads,
+			},
+		}
+
+		if mdef.Spec.EffectiveFrom != nil {
+			revisions[0].EffectiveFrom = *mdef.Spec.EffectiveFrom
+		}
+
+		revisions[0].EffectiveTo = mdef.Spec.EffectiveTo
+	}
+
+	windows := []meterDefWindow{}
+
+	for _, rev := range revisions {
+		windowStart := ceilHour(rev.EffectiveFrom.Time)
+		if windowStart.Before(start) {
+			windowStart = start
+		}
+
+		windowEnd := end
+		if rev.EffectiveTo != nil {
+			if revEnd := ceilHour(rev.EffectiveTo.Time); revEnd.Before(end) {
+				// range queries include the end, so stop an hour
+				// before the next revision starts
+				windowEnd = revEnd.Add(-time.Hour)
+			}
+		}
+
+		if windowEnd.Before(windowStart) {
+			continue
+		}
+
+		revMeterDef := mdef.DeepCopy()
+		revMeterDef.Spec.Group = rev.Group
+		revMeterDef.Spec.Kind = rev.Kind
+		revMeterDef.Spec.Workloads = rev.Workloads
+
+		windows = append(windows, meterDefWindow{
+			MeterDefinition: revMeterDef,
+			Version:         rev.Version,
+			Start:           windowStart,
+			End:             windowEnd,
+		})
+	}
+
+	return windows
+}
+
+func ceilHour(t time.Time) time.Time {
+	truncated := t.Truncate(time.Hour)
+
+	if truncated.Equal(t) {
+		return t
+	}
+
+	return truncated.Add(time.Hour)
 }
 
 func (r *MarketplaceReporter) query(
@@ -192,7 +281,9 @@ func (r *MarketplaceReporter) query(
 	done chan bool,
 	errorsch chan<- error,
 ) {
-	queryProcess := func(mdef *marketplacev1alpha1.MeterDefinition) {
+	queryProcess := func(window meterDefWindow) {
+		mdef := window.MeterDefinition
+
 		for _, workload := range mdef.Spec.Workloads {
 			for _, metric := range workload.MetricLabels {
 				logger.Info("query", "metric", metric)
@@ -200,7 +291,7 @@ func (r *MarketplaceReporter) query(
 				// Guage = delta
 				// Counter = increase
 				// Histogram and summary are unsupported
-				query := &PromQuery{
+				query := &prom.PromQuery{
 					Metric: metric.Label,
 					Type:   workload.WorkloadType,
 					MeterDef: types.NamespacedName{
@@ -209,8 +300,8 @@ func (r *MarketplaceReporter) query(
 					},
 					Query:         metric.Query,
 					Time:          "60m",
-					Start:         startTime,
-					End:           endTime,
+					Start:         window.Start,
+					End:           window.End,
 					Step:          time.Hour,
 					AggregateFunc: metric.Aggregation,
 				}
@@ -240,14 +331,16 @@ func (r *MarketplaceReporter) query(
 					return
 				}
 
-				outPromModels <- meterDefPromModel{mdef, val, metric.Label, query.Type, workload}
+				outPromModels <- meterDefPromModel{mdef, val, metric.Label, query.Type, workload, window.Version}
 			}
 		}
 	}
 
 	wgWait(ctx, "queryProcess", *r.MaxRoutines, done, func() {
 		for mdef := range inMeterDefs {
-			queryProcess(mdef)
+			for _, window := range meterDefWindows(mdef, startTime, endTime) {
+				queryProcess(window)
+			}
 		}
 	})
 }
@@ -319,6 +412,7 @@ func (r *MarketplaceReporter) process(
 							IntervalEnd:       pair.Timestamp.Add(time.Hour).Time().Format(time.RFC3339),
 							MeterDomain:       mdef.Spec.Group,
 							MeterKind:         mdef.Spec.Kind,
+							MeterVersion:      pmodel.Version,
 							Namespace:         namespace,
 							ResourceName:      objName,
 							Workload:          pmodel.Workload.Name,
@@ -391,6 +485,12 @@ func (r *MarketplaceReporter) WriteReport(
 		Version:        version.Version,
 	})
 
+	if correction := r.report.Spec.Correction; correction != nil {
+		metadata.Correction = &ReportCorrection{
+			BackfillID: correction.BackfillID,
+		}
+	}
+
 	var partitionSize = *r.MetricsPerFile
 
 	metricsArr := make([]*MetricBase, 0, len(metrics))
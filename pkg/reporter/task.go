@@ -17,22 +17,22 @@ package reporter
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"path/filepath"
 
 	"emperror.dev/errors"
 	"github.com/google/uuid"
 	"github.com/gotidy/ptr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
 	"github.com/prometheus/client_golang/api"
 	"github.com/prometheus/common/log"
 	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/managers"
+	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils"
 	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -93,9 +93,15 @@ func (r *Task) Run() error {
 		err = r.Uploader.UploadFile(fileName)
 
 		if err != nil {
+			failed := marketplacev1alpha1.ReportConditionUploadFailed
+			failed.Message = err.Error()
+			r.setUploadCondition(failed)
+
 			return errors.Wrap(err, "error uploading file")
 		}
 
+		r.setUploadCondition(marketplacev1alpha1.ReportConditionUploadFinished)
+
 		logger.Info("uploaded metrics", "metrics", len(metrics))
 	}
 
@@ -133,6 +139,37 @@ func (r *Task) Run() error {
 	return nil
 }
 
+// setUploadCondition records the result of the upload on the report, the
+// operator exports it for the upload failing alert.
+func (r *Task) setUploadCondition(condition status.Condition) {
+	report := &marketplacev1alpha1.MeterReport{}
+	err := utils.Retry(func() error {
+		result, _ := r.CC.Do(
+			r.Ctx,
+			HandleResult(
+				GetAction(types.NamespacedName(r.ReportName), report),
+				OnContinue(Call(func() (ClientAction, error) {
+					if report.Status.Conditions == nil {
+						report.Status.Conditions = &status.Conditions{}
+					}
+
+					return UpdateStatusCondition(report, report.Status.Conditions, condition), nil
+				})),
+			),
+		)
+
+		if result.Is(Error) {
+			return result
+		}
+
+		return nil
+	}, 3)
+
+	if err != nil {
+		logger.Error(err, "failed to update upload condition")
+	}
+}
+
 func provideApiClient(
 	report *marketplacev1alpha1.MeterReport,
 	promService *corev1.Service,
@@ -151,42 +188,12 @@ func provideApiClient(
 		return client, nil
 	}
 
-	var port int32
-	name := promService.Name
-	namespace := promService.Namespace
-	targetPort := report.Spec.PrometheusService.TargetPort
-
-	switch {
-	case targetPort.Type == intstr.Int:
-		port = targetPort.IntVal
-	default:
-		for _, p := range promService.Spec.Ports {
-			if p.Name == targetPort.StrVal {
-				port = p.Port
-			}
-		}
-	}
-
-	var auth = ""
-	if config.TokenFile != "" {
-		content, err := ioutil.ReadFile(config.TokenFile)
-		if err != nil {
-			return nil, err
-		}
-		auth = fmt.Sprintf(string(content))
-	}
-
-	conf, err := NewSecureClient(&PrometheusSecureClientConfig{
-		Address:        fmt.Sprintf("https://%s.%s.svc:%v", name, namespace, port),
-		ServerCertFile: config.CaFile,
-		Token:          auth,
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return conf, nil
+	return prom.NewSecureClientForService(
+		promService,
+		report.Spec.PrometheusService.TargetPort,
+		config.CaFile,
+		config.TokenFile,
+	)
 }
 
 func getClientOptions() managers.ClientOptions {
@@ -268,8 +275,12 @@ func getMeterDefinitions(
 		HandleResult(
 			ListAction(defs, client.InNamespace("")),
 			OnContinue(Call(func() (ClientAction, error) {
-				for _, item := range defs.Items {
-					item.Status = marketplacev1alpha1.MeterDefinitionStatus{}
+				for i := range defs.Items {
+					// revisions are kept so the report uses the meter
+					// definition that was effective for each hour
+					defs.Items[i].Status = marketplacev1alpha1.MeterDefinitionStatus{
+						Revisions: defs.Items[i].Status.Revisions,
+					}
 				}
 
 				report.Spec.MeterDefinitions = defs.Items
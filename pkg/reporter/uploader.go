@@ -32,6 +32,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/gotidy/ptr"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/managers"
+	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
 	. "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
 	"github.com/redhat-marketplace/redhat-marketplace-operator/version"
 	"golang.org/x/net/http2"
@@ -86,7 +87,7 @@ var _ Uploader = &RedHatInsightsUploader{}
 func NewRedHatInsightsUploader(
 	config *RedHatInsightsUploaderConfig,
 ) (Uploader, error) {
-	tlsConfig, err := generateCACertPool(config.AdditionalCertFiles...)
+	tlsConfig, err := prom.GenerateCACertPool(config.AdditionalCertFiles...)
 
 	if err != nil {
 		return nil, err
@@ -229,11 +230,19 @@ func ProvideUploader(
 	cc ClientCommandRunner,
 	log logr.Logger,
 	isCacheStarted managers.CacheIsStarted,
-	uploaderTarget UploaderTarget,
+	reportName ReportName,
+	cfg *Config,
 ) (Uploader, error) {
-	switch uploaderTarget {
+	switch cfg.UploaderTarget {
 	case UploaderTargetRedHatInsights:
-		config, err := provideProductionInsightsConfig(ctx, cc, log)
+		var config *RedHatInsightsUploaderConfig
+		var err error
+
+		if cfg.ClusterIDSecret != "" {
+			config, err = provideSecretInsightsConfig(ctx, cc, reportName.Namespace, cfg)
+		} else {
+			config, err = provideProductionInsightsConfig(ctx, cc, log)
+		}
 
 		if err != nil {
 			return nil, err
@@ -244,7 +253,51 @@ func ProvideUploader(
 		return &NoOpUploader{}, nil
 	}
 
-	return nil, errors.Errorf("uploader target not available %s", string(uploaderTarget))
+	return nil, errors.Errorf("uploader target not available %s", string(cfg.UploaderTarget))
+}
+
+// provideSecretInsightsConfig reads the cluster id and upload token from
+// the secrets of the config, for clusters that aren't OpenShift.
+func provideSecretInsightsConfig(
+	ctx context.Context,
+	cc ClientCommandRunner,
+	namespace string,
+	cfg *Config,
+) (*RedHatInsightsUploaderConfig, error) {
+	clusterIDSecret := &corev1.Secret{}
+	uploadSecret := &corev1.Secret{}
+	result, _ := cc.Do(ctx,
+		GetAction(types.NamespacedName{
+			Name:      cfg.ClusterIDSecret,
+			Namespace: namespace,
+		}, clusterIDSecret),
+		GetAction(types.NamespacedName{
+			Name:      cfg.UploadSecret,
+			Namespace: namespace,
+		}, uploadSecret))
+
+	if !result.Is(Continue) {
+		return nil, result
+	}
+
+	clusterID, ok := clusterIDSecret.Data["clusterID"]
+
+	if !ok {
+		return nil, errors.Errorf("clusterID is not found in secret %s", cfg.ClusterIDSecret)
+	}
+
+	token, ok := uploadSecret.Data["token"]
+
+	if !ok {
+		return nil, errors.Errorf("token is not found in secret %s", cfg.UploadSecret)
+	}
+
+	return &RedHatInsightsUploaderConfig{
+		URL:             "https://cloud.redhat.com",
+		ClusterID:       string(clusterID),
+		OperatorVersion: version.Version,
+		Token:           string(token),
+	}, nil
 }
 
 func provideProductionInsightsConfig(
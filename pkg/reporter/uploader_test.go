@@ -0,0 +1,83 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/managers"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/version"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Uploader", func() {
+	var (
+		namespace = "redhat-marketplace"
+		name      = ReportName(types.NamespacedName{Name: "meter-report-2020-09-16", Namespace: namespace})
+		cfg       *Config
+		cc        reconcileutils.ClientCommandRunner
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+
+		client := fake.NewFakeClientWithScheme(scheme,
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "rhm-cluster-id", Namespace: namespace},
+				Data:       map[string][]byte{"clusterID": []byte("cluster-1")},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "rhm-upload-credentials", Namespace: namespace},
+				Data:       map[string][]byte{"token": []byte("token-1")},
+			},
+		)
+		cc = reconcileutils.NewClientCommand(client, scheme, logf.Log)
+
+		cfg = &Config{
+			UploaderTarget:  UploaderTargetRedHatInsights,
+			ClusterIDSecret: "rhm-cluster-id",
+			UploadSecret:    "rhm-upload-credentials",
+		}
+	})
+
+	It("should read the insights config from the cluster secrets", func() {
+		uploader, err := ProvideUploader(context.TODO(), cc, logf.Log, managers.CacheIsStarted{}, name, cfg)
+		Expect(err).To(Succeed())
+
+		insights, ok := uploader.(*RedHatInsightsUploader)
+		Expect(ok).To(BeTrue())
+		Expect(insights.URL).To(Equal("https://cloud.redhat.com"))
+		Expect(insights.ClusterID).To(Equal("cluster-1"))
+		Expect(insights.Token).To(Equal("token-1"))
+		Expect(insights.OperatorVersion).To(Equal(version.Version))
+	})
+
+	It("should fail without the upload token", func() {
+		cfg.UploadSecret = "rhm-cluster-id"
+
+		_, err := ProvideUploader(context.TODO(), cc, logf.Log, managers.CacheIsStarted{}, name, cfg)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -47,8 +47,7 @@ func NewTask(ctx context.Context, reportName ReportName, config2 *Config) (*Task
 	clientCommandRunner := reconcileutils.NewClientCommand(client, scheme, logrLogger)
 	cacheIsIndexed := managers.CacheIsIndexed{}
 	cacheIsStarted := managers.StartCache(ctx, cache, logrLogger, cacheIsIndexed)
-	uploaderTarget := config2.UploaderTarget
-	uploader, err := ProvideUploader(ctx, clientCommandRunner, logrLogger, cacheIsStarted, uploaderTarget)
+	uploader, err := ProvideUploader(ctx, clientCommandRunner, logrLogger, cacheIsStarted, reportName, config2)
 	if err != nil {
 		return nil, err
 	}
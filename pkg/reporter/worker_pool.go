@@ -0,0 +1,338 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/gotidy/ptr"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	marketplacev1alpha1 "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/v1alpha1"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/config"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/controller/meterreport"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/managers"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/manifests"
+	prom "github.com/redhat-marketplace/redhat-marketplace-operator/pkg/prometheus"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/utils/reconcileutils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// WorkerPool runs reports in the operator process instead of as jobs. It
+// shares the client and cache of the manager and runs at most
+// cfg.Workers reports at a time.
+type WorkerPool struct {
+	client client.Client
+	cache  cache.Cache
+	scheme *runtime.Scheme
+	cfg    config.ReportWorkers
+
+	// secrets are used for uploads on clusters that aren't OpenShift
+	secrets config.ClusterSecrets
+
+	// runTask runs the report, replaced in tests
+	runTask func(ctx context.Context, name ReportName) error
+
+	slots  chan struct{}
+	events chan event.GenericEvent
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	runs map[types.NamespacedName]*reportRun
+}
+
+type reportRun struct {
+	name   string
+	cancel context.CancelFunc
+	ref    common.JobReference
+}
+
+var _ meterreport.ReportRunner = &WorkerPool{}
+var _ manager.Runnable = &WorkerPool{}
+
+func NewWorkerPool(
+	client client.Client,
+	cache cache.Cache,
+	scheme *runtime.Scheme,
+	cfg config.ReportWorkers,
+	secrets config.ClusterSecrets,
+) *WorkerPool {
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pool := &WorkerPool{
+		client:  client,
+		cache:   cache,
+		scheme:  scheme,
+		cfg:     cfg,
+		secrets: secrets,
+		slots:   make(chan struct{}, workers),
+		events:  make(chan event.GenericEvent, 100),
+		ctx:     ctx,
+		cancel:  cancel,
+		runs:    map[types.NamespacedName]*reportRun{},
+	}
+	pool.runTask = pool.runReport
+
+	return pool
+}
+
+// ProvideReportRunnerFactory returns the factory of the worker pool if
+// reports run in the operator, or nil so they run as jobs.
+func ProvideReportRunnerFactory(cfg config.OperatorConfig) meterreport.ReportRunnerFactory {
+	if !cfg.Features.InProcessReports {
+		return nil
+	}
+
+	return func(mgr manager.Manager) (meterreport.ReportRunner, error) {
+		pool := NewWorkerPool(mgr.GetClient(), mgr.GetCache(), mgr.GetScheme(), cfg.ReportWorkers, cfg.ClusterSecrets)
+
+		if err := mgr.Add(pool); err != nil {
+			return nil, err
+		}
+
+		return pool, nil
+	}
+}
+
+// Start waits for the stop channel, then cancels the runs and waits for
+// them to return.
+func (p *WorkerPool) Start(stop <-chan struct{}) error {
+	<-stop
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
+
+func (p *WorkerPool) Run(report types.NamespacedName, runName string) *common.JobReference {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if run, ok := p.runs[report]; ok {
+		if run.name == runName {
+			ref := run.ref
+			return &ref
+		}
+
+		run.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(p.ctx)
+
+	run := &reportRun{
+		name:   runName,
+		cancel: cancel,
+		ref: common.JobReference{
+			Namespace: report.Namespace,
+			Name:      runName,
+			Active:    1,
+		},
+	}
+	p.runs[report] = run
+
+	p.wg.Add(1)
+	go p.work(ctx, report, run)
+
+	ref := run.ref
+	return &ref
+}
+
+func (p *WorkerPool) Remove(report types.NamespacedName) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if run, ok := p.runs[report]; ok {
+		run.cancel()
+		delete(p.runs, report)
+	}
+}
+
+func (p *WorkerPool) Events() <-chan event.GenericEvent {
+	return p.events
+}
+
+func (p *WorkerPool) work(ctx context.Context, report types.NamespacedName, run *reportRun) {
+	defer p.wg.Done()
+	defer run.cancel()
+
+	select {
+	case p.slots <- struct{}{}:
+		defer func() { <-p.slots }()
+	case <-ctx.Done():
+		p.finish(report, run, ctx.Err())
+		return
+	}
+
+	// the timeout starts once the run has a worker
+	if p.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+		defer cancel()
+	}
+
+	p.mu.Lock()
+	now := metav1.Now()
+	run.ref.StartTime = &now
+	p.mu.Unlock()
+
+	logger.Info("running report", "report", report, "run", run.name)
+	p.finish(report, run, p.safeRunTask(ctx, ReportName(report)))
+}
+
+// safeRunTask keeps a panicking report from stopping the operator.
+func (p *WorkerPool) safeRunTask(ctx context.Context, name ReportName) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("report panicked: %v", r)
+		}
+	}()
+
+	return p.runTask(ctx, name)
+}
+
+func (p *WorkerPool) finish(report types.NamespacedName, run *reportRun, err error) {
+	p.mu.Lock()
+	now := metav1.Now()
+	run.ref.Active = 0
+	run.ref.CompletionTime = &now
+
+	if err != nil {
+		logger.Error(err, "report run failed", "report", report, "run", run.name)
+		run.ref.Failed = 1
+	} else {
+		logger.Info("report run finished", "report", report, "run", run.name)
+		run.ref.Succeeded = 1
+	}
+
+	current := p.runs[report] == run
+	p.mu.Unlock()
+
+	if !current {
+		return
+	}
+
+	obj := &marketplacev1alpha1.MeterReport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      report.Name,
+			Namespace: report.Namespace,
+		},
+	}
+
+	// the controller requeues running reports, so a full channel only
+	// delays the status update
+	select {
+	case p.events <- event.GenericEvent{Meta: obj, Object: obj}:
+	default:
+	}
+}
+
+func (p *WorkerPool) runReport(ctx context.Context, name ReportName) error {
+	dir, err := ioutil.TempDir("", "report-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create output dir")
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &Config{
+		OutputDirectory: dir,
+		Retry:           ptr.Int(3),
+		CaFile:          p.cfg.CaFile,
+		TokenFile:       p.cfg.TokenFile,
+		Upload:          true,
+	}
+	cfg.SetDefaults()
+
+	if err := p.loadServiceAuth(ctx, name, dir, cfg); err != nil {
+		return err
+	}
+
+	platform := &manifests.Config{}
+	if err := platform.LoadPlatform(manifests.InfrastructureLoader(ctx, p.client)); err != nil {
+		return err
+	}
+
+	if !platform.IsOpenShift() {
+		cfg.ClusterIDSecret = p.secrets.ClusterIDSecret
+		cfg.UploadSecret = p.secrets.UploadSecret
+	}
+
+	cc := reconcileutils.NewClientCommand(p.client, p.scheme, logger)
+
+	uploader, err := ProvideUploader(ctx, cc, logger, managers.CacheIsStarted{}, name, cfg)
+	if err != nil {
+		return err
+	}
+
+	task := &Task{
+		ReportName: name,
+		CC:         cc,
+		Cache:      p.cache,
+		K8SClient:  p.client,
+		Ctx:        ctx,
+		Config:     cfg,
+		K8SScheme:  p.scheme,
+		Uploader:   uploader,
+	}
+
+	return task.Run()
+}
+
+// loadServiceAuth writes the bearer token and CA of the prometheus service
+// of the report to dir and points cfg at them. The pool defaults are kept
+// for the ones the service doesn't set.
+func (p *WorkerPool) loadServiceAuth(ctx context.Context, name ReportName, dir string, cfg *Config) error {
+	report := &marketplacev1alpha1.MeterReport{}
+	if err := p.client.Get(ctx, types.NamespacedName(name), report); err != nil {
+		return errors.Wrap(err, "failed to get report")
+	}
+
+	auth, err := prom.LoadServiceAuth(ctx, p.client, report.Namespace, report.Spec.PrometheusService)
+	if err != nil {
+		return err
+	}
+
+	if auth.Token != "" {
+		cfg.TokenFile = filepath.Join(dir, "token")
+		if err := ioutil.WriteFile(cfg.TokenFile, []byte(auth.Token), 0600); err != nil {
+			return errors.Wrap(err, "failed to write token")
+		}
+	}
+
+	if len(auth.CAData) != 0 {
+		cfg.CaFile = filepath.Join(dir, "ca.crt")
+		if err := ioutil.WriteFile(cfg.CaFile, auth.CAData, 0600); err != nil {
+			return errors.Wrap(err, "failed to write ca")
+		}
+	}
+
+	return nil
+}
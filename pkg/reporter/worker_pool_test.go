@@ -0,0 +1,150 @@
+// Copyright 2020 IBM Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/apis/marketplace/common"
+	"github.com/redhat-marketplace/redhat-marketplace-operator/pkg/config"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("WorkerPool", func() {
+	var (
+		pool    *WorkerPool
+		stop    chan struct{}
+		release chan error
+		report  = types.NamespacedName{Name: "meter-report-2020-09-16", Namespace: "openshift-redhat-marketplace"}
+		other   = types.NamespacedName{Name: "meter-report-2020-09-17", Namespace: "openshift-redhat-marketplace"}
+	)
+
+	getRun := func(name types.NamespacedName, runName string) func() *common.JobReference {
+		return func() *common.JobReference {
+			return pool.Run(name, runName)
+		}
+	}
+
+	BeforeEach(func() {
+		release = make(chan error)
+		pool = NewWorkerPool(nil, nil, nil, config.ReportWorkers{Workers: 1, Timeout: time.Minute}, config.ClusterSecrets{})
+		pool.runTask = func(ctx context.Context, name ReportName) error {
+			select {
+			case err := <-release:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		stop = make(chan struct{})
+		go pool.Start(stop)
+	})
+
+	AfterEach(func() {
+		close(stop)
+	})
+
+	It("should run reports with the available workers", func() {
+		Expect(pool.Run(report, report.Name).IsActive()).To(BeTrue())
+		Eventually(getRun(report, report.Name)).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.StartTime != nil
+		}, BeTrue()))
+
+		By("queueing the second report")
+		Consistently(getRun(other, other.Name), 100*time.Millisecond).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.StartTime == nil
+		}, BeTrue()))
+
+		release <- nil
+		Eventually(getRun(report, report.Name)).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.IsDone() && jr.IsSuccessful()
+		}, BeTrue()))
+		Eventually(pool.Events()).Should(Receive())
+
+		release <- errors.New("query failed")
+		Eventually(getRun(other, other.Name)).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.IsDone() && jr.IsFailed()
+		}, BeTrue()))
+	})
+
+	It("should start the timeout once a run has a worker", func() {
+		pool.cfg.Timeout = 200 * time.Millisecond
+		pool.runTask = func(ctx context.Context, name ReportName) error {
+			// the first report holds the worker past the timeout
+			if types.NamespacedName(name) == report {
+				return <-release
+			}
+
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		pool.Run(report, report.Name)
+		Eventually(getRun(report, report.Name)).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.StartTime != nil
+		}, BeTrue()))
+
+		By("queueing the second report past its timeout")
+		pool.Run(other, other.Name)
+		time.Sleep(300 * time.Millisecond)
+		Expect(getRun(other, other.Name)().IsActive()).To(BeTrue())
+
+		release <- nil
+		Eventually(getRun(other, other.Name)).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.StartTime != nil && !jr.IsDone()
+		}, BeTrue()))
+
+		By("timing out the running report")
+		Eventually(getRun(other, other.Name)).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.IsDone() && jr.IsFailed()
+		}, BeTrue()))
+	})
+
+	It("should cancel removed runs", func() {
+		pool.Run(report, report.Name)
+		Eventually(getRun(report, report.Name)).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.StartTime != nil
+		}, BeTrue()))
+
+		pool.Remove(report)
+
+		By("starting a new run")
+		Eventually(getRun(report, report.Name+"-1")).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.StartTime != nil
+		}, BeTrue()))
+
+		release <- nil
+		Eventually(getRun(report, report.Name+"-1")).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.IsSuccessful()
+		}, BeTrue()))
+	})
+
+	It("should recover from panicking reports", func() {
+		pool.runTask = func(ctx context.Context, name ReportName) error {
+			panic("no files")
+		}
+
+		pool.Run(report, report.Name)
+		Eventually(getRun(report, report.Name)).Should(WithTransform(func(jr *common.JobReference) bool {
+			return jr.IsFailed()
+		}, BeTrue()))
+	})
+})
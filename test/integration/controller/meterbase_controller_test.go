@@ -59,14 +59,14 @@ var _ = Describe("MeterbaseController", func() {
 
 				By("creating metric-state")
 
-				deployment = &appsv1.Deployment{}
+				statefulSet := &appsv1.StatefulSet{}
 				service = &corev1.Service{}
 				serviceMonitor := &monitoringv1.ServiceMonitor{}
 
 				Eventually(func() bool {
 					result, _ := testHarness.Do(
 						context.TODO(),
-						GetAction(types.NamespacedName{Name: "rhm-metric-state", Namespace: Namespace}, deployment),
+						GetAction(types.NamespacedName{Name: "rhm-metric-state", Namespace: Namespace}, statefulSet),
 						GetAction(types.NamespacedName{Name: "rhm-metric-state-service", Namespace: Namespace}, service),
 						GetAction(types.NamespacedName{Name: "rhm-metric-state", Namespace: Namespace}, serviceMonitor),
 					)